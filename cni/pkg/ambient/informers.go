@@ -66,8 +66,8 @@ func (s *Server) ReconcileNamespaces() sets.Set[string] {
 	return processed
 }
 
-// EnqueueNamespace takes a Namespace and enqueues all Pod objects that make need an update
-// TODO it is sort of pointless/confusing/implicit to populate Old and New with the same reference here
+// EnqueueNamespace takes a Namespace and enqueues all Pod objects that make need an update.
+// Old and New are populated with the same reference, which Reconcile handles as a resync of the pod.
 func (s *Server) EnqueueNamespace(o controllers.Object) {
 	s.enqueueNamespace(o)
 }
@@ -121,10 +121,21 @@ func (s *Server) Reconcile(input any) error {
 			s.DelPodFromMesh(newPod, event)
 		}
 
-		if !wasEnabled && nowEnabled {
+		// Updates of the redirection status are our own, and should not trigger another attempt.
+		statusUpdated := oldPod.Annotations[constants.AmbientRedirectionStatus] != newPod.Annotations[constants.AmbientRedirectionStatus]
+		if !wasEnabled && nowEnabled && !statusUpdated {
 			log.Debugf("Pod %s now matches, adding to mesh", newPod.Name)
 			s.AddPodToMesh(pod)
 		}
+
+		// Namespaces are reconciled on startup and when the active ztunnel changes. Redirect the pods already in the
+		// mesh again, which leaves the redirection in place if it still is, so that their status records the
+		// current agent and ztunnel.
+		resync := event.Old == event.New
+		if wasEnabled && nowEnabled && resync {
+			log.Debugf("Pod %s resynced, redirecting it again", newPod.Name)
+			s.AddPodToMesh(pod)
+		}
 	case controllers.EventDelete:
 		s.DelPodFromMesh(pod, event)
 	}
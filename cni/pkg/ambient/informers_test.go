@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/cni/pkg/ambient/status"
+	ebpf "istio.io/istio/cni/pkg/ebpf/server"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestResyncRestampsEnrolledPods(t *testing.T) {
+	previous := status.Enrollment{
+		Redirected:     true,
+		Mode:           EbpfMode.String(),
+		Ztunnel:        "ztunnel-old",
+		LastUpdateTime: time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+	client := kube.NewFakeClient(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "default",
+			Labels: map[string]string{constants.DataplaneMode: constants.DataplaneModeAmbient},
+		}},
+		// The pod has no IP yet, so redirecting it again does not need the eBPF programs.
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "default",
+				Annotations: map[string]string{
+					constants.AmbientRedirection:       constants.AmbientRedirectionEnabled,
+					constants.AmbientRedirectionStatus: previous.String(),
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: NodeName},
+		},
+	)
+	s := &Server{
+		ctx:          context.Background(),
+		kubeClient:   client,
+		redirectMode: EbpfMode,
+		ebpfServer:   &ebpf.RedirectServer{},
+		status:       status.NewStore(),
+		ztunnelPod:   &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ztunnel-new", Namespace: "istio-system"}},
+	}
+	s.setupHandlers()
+	stop := test.NewStop(t)
+	client.RunAndWait(stop)
+	go s.queue.Run(stop)
+
+	// The namespaces are reconciled when the agent starts, and again when the active ztunnel changes.
+	s.ReconcileNamespaces()
+	assert.EventuallyEqual(t, func() string {
+		pod, err := client.Kube().CoreV1().Pods("default").Get(context.Background(), "app", metav1.GetOptions{})
+		if err != nil {
+			return err.Error()
+		}
+		st, err := status.Parse(pod.Annotations[constants.AmbientRedirectionStatus])
+		if err != nil {
+			return err.Error()
+		}
+		if !st.Redirected || !st.LastUpdateTime.After(previous.LastUpdateTime) {
+			return "not restamped"
+		}
+		return st.Ztunnel
+	}, "ztunnel-new")
+	assert.Equal(t, len(s.status.List(false)), 1)
+}
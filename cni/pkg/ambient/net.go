@@ -16,17 +16,21 @@ package ambient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
 	"os"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	klabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 
+	"istio.io/istio/cni/pkg/ambient/status"
+	"istio.io/istio/pkg/config"
 	pconstants "istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -50,17 +54,21 @@ func RouteExists(rte []string) bool {
 	return output == "1"
 }
 
-func AddPodToMesh(client kubernetes.Interface, pod *corev1.Pod, ip string) {
-	addPodToMeshWithIptables(pod, ip)
+var errNoPodIP = errors.New("pod IP not yet allocated")
+
+func AddPodToMesh(client kubernetes.Interface, pod *corev1.Pod, ip string) error {
+	return addPodToMeshWithIptables(pod, ip)
 }
 
-func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
+// addPodToMeshWithIptables adds the pod to the ipset and routes its traffic to ztunnel. Failing to disable the
+// reverse path filters of the pod's device is only logged, as it is not needed on every platform.
+func addPodToMeshWithIptables(pod *corev1.Pod, ip string) error {
 	if ip == "" {
 		ip = pod.Status.PodIP
 	}
 	if ip == "" {
 		log.Debugf("skip adding pod %s/%s, IP not yet allocated", pod.Name, pod.Namespace)
-		return
+		return errNoPodIP
 	}
 
 	if !IsPodInIpset(pod) {
@@ -68,6 +76,7 @@ func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
 		err := Ipset.AddIP(net.ParseIP(ip).To4(), string(pod.UID))
 		if err != nil {
 			log.Errorf("Failed to add pod %s to ipset list: %v", pod.Name, err)
+			return fmt.Errorf("failed to add pod to ipset: %v", err)
 		}
 	} else {
 		log.Infof("Pod '%s/%s' (%s) is in ipset", pod.Name, pod.Namespace, string(pod.UID))
@@ -76,6 +85,7 @@ func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
 	rte, err := buildRouteForPod(ip)
 	if err != nil {
 		log.Errorf("Failed to build route for pod %s: %v", pod.Name, err)
+		return fmt.Errorf("failed to build route: %v", err)
 	}
 
 	if !RouteExists(rte) {
@@ -88,6 +98,7 @@ func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
 		err = execute("ip", append([]string{"route", "add"}, rte...)...)
 		if err != nil {
 			log.Warnf("Failed to add route (%s) for pod %s: %v", rte, pod.Name, err)
+			return fmt.Errorf("failed to add route %s: %v", strings.Join(rte, " "), err)
 		}
 	} else {
 		log.Infof("Route already exists for %s/%s: %+v", pod.Name, pod.Namespace, rte)
@@ -96,13 +107,14 @@ func addPodToMeshWithIptables(pod *corev1.Pod, ip string) {
 	dev, err := getDeviceWithDestinationOf(ip)
 	if err != nil {
 		log.Warnf("Failed to get device for destination %s", ip)
-		return
+		return nil
 	}
 
 	err = disableRPFiltersForLink(dev)
 	if err != nil {
 		log.Warnf("failed to disable procfs rp_filter for device %s: %v", dev, err)
 	}
+	return nil
 }
 
 func delPodFromMeshWithIptables(pod *corev1.Pod) {
@@ -218,19 +230,40 @@ func GetHostIP(kubeClient kubernetes.Interface) (string, error) {
 }
 
 func (s *Server) AddPodToMesh(pod *corev1.Pod) {
+	var err error
 	switch s.redirectMode {
 	case IptablesMode:
-		AddPodToMesh(s.kubeClient.Kube(), pod, "")
+		err = AddPodToMesh(s.kubeClient.Kube(), pod, "")
 	case EbpfMode:
-		if err := s.updatePodEbpfOnNode(pod); err != nil {
+		if err = s.updatePodEbpfOnNode(pod); err != nil {
 			log.Errorf("failed to update POD ebpf: %v", err)
 		}
 	}
-	if err := AnnotateEnrolledPod(s.kubeClient.Kube(), pod); err != nil {
+	st := s.enrollmentStatus(err)
+	s.status.Set(config.NamespacedName(pod), st)
+	if err := AnnotateEnrollment(s.kubeClient.Kube(), pod, st); err != nil {
 		log.Errorf("failed to annotate pod enrollment: %v", err)
 	}
 }
 
+// enrollmentStatus returns the status of a pod whose redirection was just configured, or failed with err.
+func (s *Server) enrollmentStatus(err error) status.Enrollment {
+	st := status.Enrollment{
+		Redirected:     err == nil,
+		Mode:           s.redirectMode.String(),
+		LastUpdateTime: time.Now(),
+	}
+	s.mu.RLock()
+	if s.ztunnelPod != nil {
+		st.Ztunnel = s.ztunnelPod.Name
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		st.Error = err.Error()
+	}
+	return st
+}
+
 func (s *Server) DelPodFromMesh(pod *corev1.Pod, event controllers.Event) {
 	log.Debugf("Pod %s/%s is now stopped or opt out... cleaning up.", pod.Namespace, pod.Name)
 	switch s.redirectMode {
@@ -245,6 +278,7 @@ func (s *Server) DelPodFromMesh(pod *corev1.Pod, event controllers.Event) {
 			log.Errorf("failed to del POD ebpf: %v", err)
 		}
 	}
+	s.status.Delete(config.NamespacedName(pod))
 	// event.New will be nil if the pod is deleted
 	if event.New != nil {
 		if err := AnnotateUnenrollPod(s.kubeClient.Kube(), pod); err != nil {
@@ -285,6 +319,7 @@ func (s *Server) cleanupPodsEbpfOnNode() error {
 			if err := s.delPodEbpfOnNode(pod.Status.PodIP, true); err != nil {
 				log.Errorf("failed to cleanup pod ebpf: %v", err)
 			}
+			s.status.Delete(config.NamespacedName(pod))
 			if err := AnnotateUnenrollPod(s.kubeClient.Kube(), pod); err != nil {
 				log.Errorf("failed to annotate pod unenrollment: %v", err)
 			}
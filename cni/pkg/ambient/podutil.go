@@ -16,6 +16,7 @@ package ambient
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,6 +26,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/cni/pkg/ambient/status"
 	"istio.io/istio/pkg/config/constants"
 )
 
@@ -35,8 +37,9 @@ var annotationPatch = []byte(fmt.Sprintf(
 ))
 
 var annotationRemovePatch = []byte(fmt.Sprintf(
-	`{"metadata":{"annotations":{"%s":null}}}`,
+	`{"metadata":{"annotations":{"%s":null,"%s":null}}}`,
 	constants.AmbientRedirection,
+	constants.AmbientRedirectionStatus,
 ))
 
 // PodRedirectionEnabled determines if a pod should or should not be configured
@@ -81,8 +84,34 @@ func AnnotateEnrolledPod(client kubernetes.Interface, pod *corev1.Pod) error {
 	return err
 }
 
+// AnnotateEnrollment records the redirection status of the pod. The pod is also marked as enrolled if the
+// redirection succeeded.
+func AnnotateEnrollment(client kubernetes.Interface, pod *corev1.Pod, st status.Enrollment) error {
+	annotations := map[string]any{
+		constants.AmbientRedirectionStatus: st.String(),
+	}
+	if st.Redirected {
+		annotations[constants.AmbientRedirection] = constants.AmbientRedirectionEnabled
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().
+		Pods(pod.Namespace).
+		Patch(
+			context.Background(),
+			pod.Name,
+			types.MergePatchType,
+			patch,
+			metav1.PatchOptions{},
+		)
+	return err
+}
+
 func AnnotateUnenrollPod(client kubernetes.Interface, pod *corev1.Pod) error {
-	if pod.Annotations[constants.AmbientRedirection] != constants.AmbientRedirectionEnabled {
+	_, hasStatus := pod.Annotations[constants.AmbientRedirectionStatus]
+	if pod.Annotations[constants.AmbientRedirection] != constants.AmbientRedirectionEnabled && !hasStatus {
 		return nil
 	}
 	// TODO: do not overwrite if already none
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"sync"
 
@@ -27,6 +28,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"istio.io/istio/cni/pkg/ambient/constants"
+	"istio.io/istio/cni/pkg/ambient/status"
 	ebpf "istio.io/istio/cni/pkg/ebpf/server"
 	"istio.io/istio/pkg/file"
 	"istio.io/istio/pkg/kube"
@@ -49,6 +51,9 @@ type Server struct {
 	iptablesCommand lazy.Lazy[string]
 	redirectMode    RedirectMode
 	ebpfServer      *ebpf.RedirectServer
+
+	// status records whether the redirection of each pod handled since startup succeeded.
+	status *status.Store
 }
 
 type AmbientConfigFile struct {
@@ -65,6 +70,7 @@ func NewServer(ctx context.Context, args AmbientArgs) (*Server, error) {
 	s := &Server{
 		ctx:        ctx,
 		kubeClient: client,
+		status:     status.NewStore(),
 	}
 
 	s.iptablesCommand = lazy.New(func() (string, error) {
@@ -97,6 +103,11 @@ func NewServer(ctx context.Context, args AmbientArgs) (*Server, error) {
 	return s, nil
 }
 
+// StatusHandler serves the redirection status of the pods on the node.
+func (s *Server) StatusHandler() http.Handler {
+	return s.status
+}
+
 func (s *Server) isZTunnelRunning() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status records whether the CNI node agent managed to redirect the traffic of the ambient pods on its
+// node to ztunnel.
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Enrollment is the outcome of the last attempt to redirect the traffic of a pod to ztunnel. It is written to the
+// pod in the ambient.istio.io/redirection-status annotation.
+type Enrollment struct {
+	// Redirected is true if the redirection was configured.
+	Redirected bool `json:"redirected"`
+	// Mode is the redirection mode of the node agent, iptables or ebpf.
+	Mode string `json:"mode"`
+	// Ztunnel is the name of the ztunnel pod that was active on the node, if any.
+	Ztunnel string `json:"ztunnel,omitempty"`
+	// Error is the reason the redirection failed.
+	Error string `json:"error,omitempty"`
+	// LastUpdateTime is the time of the attempt.
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+}
+
+// Parse decodes the value of the status annotation.
+func Parse(v string) (*Enrollment, error) {
+	e := &Enrollment{}
+	if err := json.Unmarshal([]byte(v), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// String encodes the status as the value of the status annotation.
+func (e Enrollment) String() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// PodEnrollment is the status of a pod, as served by the node agent.
+type PodEnrollment struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Enrollment
+}
+
+// Store keeps the status of the pods handled by the node agent since it started, and serves them as JSON.
+type Store struct {
+	mu   sync.RWMutex
+	pods map[types.NamespacedName]Enrollment
+}
+
+func NewStore() *Store {
+	return &Store{pods: map[types.NamespacedName]Enrollment{}}
+}
+
+// Set records the status of a pod.
+func (s *Store) Set(pod types.NamespacedName, e Enrollment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pods[pod] = e
+}
+
+// Delete forgets a pod, once it is deleted or no longer meant to be redirected.
+func (s *Store) Delete(pod types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pods, pod)
+}
+
+// List returns the status of every pod, sorted by namespace and name. If failedOnly is set, only the pods whose
+// redirection failed are returned.
+func (s *Store) List(failedOnly bool) []PodEnrollment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]PodEnrollment, 0, len(s.pods))
+	for pod, e := range s.pods {
+		if failedOnly && e.Redirected {
+			continue
+		}
+		res = append(res, PodEnrollment{Namespace: pod.Namespace, Name: pod.Name, Enrollment: e})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// ServeHTTP writes the status of the pods. The `failed` query parameter restricts the list to the pods whose
+// redirection failed.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := json.MarshalIndent(s.List(r.URL.Query().Has("failed")), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
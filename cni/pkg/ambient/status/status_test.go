@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParse(t *testing.T) {
+	e := Enrollment{
+		Mode:           "iptables",
+		Ztunnel:        "ztunnel-abcde",
+		Error:          "failed to add pod to ipset",
+		LastUpdateTime: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	assert.Equal(t, e.String(),
+		`{"redirected":false,"mode":"iptables","ztunnel":"ztunnel-abcde","error":"failed to add pod to ipset","lastUpdateTime":"2023-05-01T10:00:00Z"}`)
+	got, err := Parse(e.String())
+	assert.NoError(t, err)
+	assert.Equal(t, *got, e)
+
+	if _, err := Parse("enabled"); err == nil {
+		t.Fatal("expected an error for an invalid status")
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore()
+	s.Set(types.NamespacedName{Namespace: "b", Name: "pod"}, Enrollment{Redirected: true, Mode: "ebpf"})
+	s.Set(types.NamespacedName{Namespace: "a", Name: "pod-2"}, Enrollment{Mode: "ebpf", Error: "no IP"})
+	s.Set(types.NamespacedName{Namespace: "a", Name: "pod-1"}, Enrollment{Redirected: true, Mode: "ebpf"})
+	s.Delete(types.NamespacedName{Namespace: "b", Name: "pod"})
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	get := func(query string) []PodEnrollment {
+		t.Helper()
+		res, err := http.Get(server.URL + query)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		var pods []PodEnrollment
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&pods))
+		return pods
+	}
+	assert.Equal(t, get(""), []PodEnrollment{
+		{Namespace: "a", Name: "pod-1", Enrollment: Enrollment{Redirected: true, Mode: "ebpf"}},
+		{Namespace: "a", Name: "pod-2", Enrollment: Enrollment{Mode: "ebpf", Error: "no IP"}},
+	})
+	assert.Equal(t, get("?failed"), []PodEnrollment{
+		{Namespace: "a", Name: "pod-2", Enrollment: Enrollment{Mode: "ebpf", Error: "no IP"}},
+	})
+}
@@ -18,8 +18,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
@@ -80,6 +82,7 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
+		handlers := map[string]http.Handler{}
 		if cfg.InstallConfig.AmbientEnabled {
 			// Start ambient controller
 			redirectMode := ambient.IptablesMode
@@ -97,9 +100,10 @@ var rootCmd = &cobra.Command{
 			}
 			server.Start()
 			defer server.Stop()
+			handlers[constants.AmbientStatusEndpoint] = server.StatusHandler()
 		}
 
-		isReady := install.StartServer()
+		isReady := install.StartServer(handlers)
 
 		installer := install.NewInstaller(&cfg.InstallConfig, isReady)
 
@@ -186,6 +190,11 @@ func init() {
 		"A set of label selectors in label=value format that will be added to the pod list filters")
 	registerStringParameter(constants.RepairFieldSelectors, "",
 		"A set of field selectors in label=value format that will be added to the pod list filters")
+	registerDurationParameter(constants.RepairDriftInterval, 0,
+		"How often to compare the redirection rules of the pods on the node with the expected ones (disabled if zero)")
+	registerBooleanParameter(constants.RepairDrift, false, "Controller will program the redirection rules again when they drifted")
+	registerStringParameter(constants.RepairDriftInterceptType, "iptables",
+		"The type of redirection rules programmed by the CNI plugin (iptables or nftables)")
 }
 
 func registerStringParameter(name, value, usage string) {
@@ -198,6 +207,11 @@ func registerIntegerParameter(name string, value int, usage string) {
 	registerEnvironment(name, value, usage)
 }
 
+func registerDurationParameter(name string, value time.Duration, usage string) {
+	rootCmd.Flags().Duration(name, value, usage)
+	registerEnvironment(name, value, usage)
+}
+
 func registerBooleanParameter(name string, value bool, usage string) {
 	rootCmd.Flags().Bool(name, value, usage)
 	registerEnvironment(name, value, usage)
@@ -268,6 +282,9 @@ func constructConfig() (*config.Config, error) {
 		InitExitCode:       viper.GetInt(constants.RepairInitExitCode),
 		LabelSelectors:     viper.GetString(constants.RepairLabelSelectors),
 		FieldSelectors:     viper.GetString(constants.RepairFieldSelectors),
+		DriftCheckInterval: viper.GetDuration(constants.RepairDriftInterval),
+		RepairDrift:        viper.GetBool(constants.RepairDrift),
+		DriftInterceptType: viper.GetString(constants.RepairDriftInterceptType),
 	}
 
 	return &config.Config{InstallConfig: installCfg, RepairConfig: repairCfg}, nil
@@ -17,6 +17,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -109,6 +110,14 @@ type RepairConfig struct {
 	// Label and field selectors to select pods managed by race repair.
 	LabelSelectors string
 	FieldSelectors string
+
+	// How often to compare the redirection rules of the pods on the node with the expected ones.
+	// Drift detection is disabled if zero.
+	DriftCheckInterval time.Duration
+	// Whether to program the redirection rules again when they drifted
+	RepairDrift bool
+	// The type of rules programmed by the CNI plugin (iptables or nftables)
+	DriftInterceptType string
 }
 
 func (c InstallConfig) String() string {
@@ -153,5 +162,8 @@ func (c RepairConfig) String() string {
 	b.WriteString("InitExitCode: " + fmt.Sprint(c.InitExitCode) + "\n")
 	b.WriteString("LabelSelectors: " + c.LabelSelectors + "\n")
 	b.WriteString("FieldSelectors: " + c.FieldSelectors + "\n")
+	b.WriteString("DriftCheckInterval: " + c.DriftCheckInterval.String() + "\n")
+	b.WriteString("RepairDrift: " + fmt.Sprint(c.RepairDrift) + "\n")
+	b.WriteString("DriftInterceptType: " + c.DriftInterceptType + "\n")
 	return b.String()
 }
@@ -46,6 +46,9 @@ const (
 	RepairInitExitCode       = "repair-init-container-exit-code"
 	RepairLabelSelectors     = "repair-label-selectors"
 	RepairFieldSelectors     = "repair-field-selectors"
+	RepairDriftInterval      = "repair-drift-check-interval"
+	RepairDrift              = "repair-drift"
+	RepairDriftInterceptType = "repair-drift-intercept-type"
 )
 
 // Internal constants
@@ -59,6 +62,9 @@ const (
 	LivenessEndpoint  = "/healthz"
 	ReadinessEndpoint = "/readyz"
 	ReadinessPort     = "8000"
+
+	// AmbientStatusEndpoint serves the ambient redirection status of the pods on the node, on the readiness port.
+	AmbientStatusEndpoint = "/ambient/status"
 )
 
 // Exposed for testing constants
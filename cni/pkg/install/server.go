@@ -21,10 +21,14 @@ import (
 	"istio.io/istio/cni/pkg/constants"
 )
 
-// StartServer initializes and starts a web server that exposes liveness and readiness endpoints at port 8000.
-func StartServer() *atomic.Value {
+// StartServer initializes and starts a web server that exposes liveness and readiness endpoints at port 8000,
+// along with the given handlers, keyed by path.
+func StartServer(handlers map[string]http.Handler) *atomic.Value {
 	router := http.NewServeMux()
 	isReady := initRouter(router)
+	for path, handler := range handlers {
+		router.Handle(path, handler)
+	}
 
 	go func() {
 		_ = http.ListenAndServe(":"+constants.ReadinessPort, router)
@@ -74,7 +74,10 @@ func checkAmbient(
 			_ = ambient.SetProc("/proc/sys/net/ipv4/conf/"+podIfname+"/rp_filter", "0")
 
 			for _, ip := range podIPs {
-				ambient.AddPodToMesh(client, pod, ip.IP.String())
+				// The node agent adds the pod again on its next update, and records the outcome.
+				if err := ambient.AddPodToMesh(client, pod, ip.IP.String()); err != nil {
+					log.Errorf("failed to add pod %s/%s to mesh: %v", podNamespace, podName, err)
+				}
 			}
 			return true, nil
 		}
@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/cmd"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// RulesVerifier compares the rules an InterceptRuleMgr programmed in a pod network namespace with the ones it
+// would program now, and programs them again if they drifted.
+type RulesVerifier interface {
+	Verify(netns string, redirect *Redirect) (builder.RulesDiff, error)
+	Repair(netns string, redirect *Redirect) error
+}
+
+// GetRulesVerifier returns the RulesVerifier for a type of InterceptRuleMgr, or nil if it cannot be verified.
+func GetRulesVerifier(interceptType string) RulesVerifier {
+	if interceptType == "" {
+		interceptType = defInterceptRuleMgrType
+	}
+	ctor := GetInterceptRuleMgrCtor(interceptType)
+	if ctor == nil {
+		return nil
+	}
+	v, _ := ctor().(RulesVerifier)
+	return v
+}
+
+// PodRulesVerifier returns the RulesVerifier for the rules of a redirection: the one of the InterceptRuleMgr the pod
+// requested if it can be verified, def otherwise.
+func PodRulesVerifier(redirect *Redirect, def RulesVerifier) RulesVerifier {
+	if redirect.interceptionBackend == "" {
+		return def
+	}
+	if v := GetRulesVerifier(redirect.interceptionBackend); v != nil {
+		return v
+	}
+	return def
+}
+
+// PodRedirect returns the redirection the plugin sets up for a pod, or nil if the pod is excluded.
+func PodRedirect(pod *v1.Pod, hostNSEnterExec bool) (*Redirect, error) {
+	pi := podInfoFromPod(pod)
+	if exclusionReason(pi) != "" {
+		return nil, nil
+	}
+	redirect, err := NewRedirect(pi)
+	if err != nil {
+		return nil, fmt.Errorf("redirect failed due to bad params: %v", err)
+	}
+	redirect.hostNSEnterExec = hostNSEnterExec
+	return redirect, nil
+}
+
+// InitContainerRedirect returns the redirection the istio-init container of a pod sets up, from its arguments and
+// environment, or nil if the pod has no istio-init container.
+func InitContainerRedirect(pod *v1.Pod) (*Redirect, error) {
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name != ISTIOINIT {
+			continue
+		}
+		if len(c.Args) == 0 || c.Args[0] != "istio-iptables" {
+			return nil, fmt.Errorf("container %s does not run istio-iptables", c.Name)
+		}
+		var args []string
+		for _, a := range c.Args[1:] {
+			// Logging flags belong to the parent command.
+			if !strings.HasPrefix(a, "--log_") {
+				args = append(args, a)
+			}
+		}
+		flags, err := cmd.ParseArgs(args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s arguments: %v", c.Name, err)
+		}
+		flag := func(name string) string {
+			return flags.Lookup(name).Value.String()
+		}
+		redir := &Redirect{
+			targetPort:           flag(constants.EnvoyPort),
+			redirectMode:         flag(constants.InboundInterceptionMode),
+			noRedirectUID:        flag(constants.ProxyUID),
+			noRedirectGID:        flag(constants.ProxyGID),
+			includeIPCidrs:       flag(constants.ServiceCidr),
+			excludeIPCidrs:       flag(constants.ServiceExcludeCidr),
+			excludeInboundPorts:  flag(constants.LocalExcludePorts),
+			excludeOutboundPorts: flag(constants.LocalOutboundPortsExclude),
+			includeInboundPorts:  flag(constants.InboundPorts),
+			includeOutboundPorts: flag(constants.OutboundPorts),
+			kubevirtInterfaces:   flag(constants.KubeVirtInterfaces),
+			excludeInterfaces:    flag(constants.ExcludeInterfaces),
+		}
+		// Unlike the other flags, an unset port does not fall back to its default once set in viper.
+		if redir.targetPort == "" {
+			redir.targetPort = defaultRedirectToPort
+		}
+		if flag(constants.Backend) == constants.BackendNftables {
+			redir.interceptionBackend = "nftables"
+		}
+		// Unset, the boolean flags default to the proxy metadata passed to the container as environment variables.
+		env := map[string]string{}
+		for _, e := range c.Env {
+			env[e.Name] = e.Value
+		}
+		boolFlag := func(name, envName string) bool {
+			v := env[envName]
+			if flags.Changed(name) {
+				v = flag(name)
+			}
+			b, _ := strconv.ParseBool(v)
+			return b
+		}
+		redir.dnsRedirect = boolFlag(constants.RedirectDNS, "ISTIO_META_DNS_CAPTURE")
+		redir.invalidDrop = boolFlag(constants.DropInvalid, "INVALID_DROP")
+		return redir, nil
+	}
+	return nil, nil
+}
@@ -28,6 +28,7 @@ type InterceptRuleMgrCtor func() InterceptRuleMgr
 
 var InterceptRuleMgrTypes = map[string]InterceptRuleMgrCtor{
 	"iptables": IptablesInterceptRuleMgrCtor,
+	"nftables": NftablesInterceptRuleMgrCtor,
 }
 
 // Constructor factory for known types of InterceptRuleMgr's
@@ -39,3 +40,8 @@ func GetInterceptRuleMgrCtor(interceptType string) InterceptRuleMgrCtor {
 func IptablesInterceptRuleMgrCtor() InterceptRuleMgr {
 	return newIPTables()
 }
+
+// Constructor for nftables InterceptRuleMgr
+func NftablesInterceptRuleMgrCtor() InterceptRuleMgr {
+	return newNftables()
+}
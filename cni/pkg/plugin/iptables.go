@@ -16,8 +16,17 @@
 // parses prevResult according to the cniVersion
 package plugin
 
-type iptables struct{}
+import "istio.io/istio/tools/istio-iptables/pkg/constants"
+
+type iptables struct {
+	// backend is the istio-iptables backend applying the rules.
+	backend string
+}
 
 func newIPTables() InterceptRuleMgr {
-	return &iptables{}
+	return &iptables{backend: constants.BackendAuto}
+}
+
+func newNftables() InterceptRuleMgr {
+	return &iptables{backend: constants.BackendNftables}
 }
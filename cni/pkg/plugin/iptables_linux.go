@@ -23,6 +23,7 @@ import (
 	"github.com/spf13/viper"
 
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
 	"istio.io/istio/tools/istio-iptables/pkg/cmd"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
 	"istio.io/istio/tools/istio-iptables/pkg/dependencies"
@@ -34,6 +35,32 @@ var getNs = ns.GetNS
 // Program defines a method which programs iptables based on the parameters
 // provided in Redirect.
 func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
+	ipt.configure(netns, rdrct)
+
+	netNs, err := getNs(netns)
+	if err != nil {
+		err = fmt.Errorf("failed to open netns %q: %s", netns, err)
+		return err
+	}
+	defer netNs.Close()
+
+	if err = netNs.Do(func(_ ns.NetNS) error {
+		iptablesCmd := cmd.GetCommand()
+		log.Infof("============= Start iptables configuration for %v =============", podName)
+		defer log.Infof("============= End iptables configuration for %v =============", podName)
+		if err := iptablesCmd.Execute(); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// configure sets the istio-iptables configuration for the pod.
+func (ipt *iptables) configure(netns string, rdrct *Redirect) {
 	viper.Set(constants.CNIMode, true)
 	viper.Set(constants.HostNSEnterExec, rdrct.hostNSEnterExec)
 	viper.Set(constants.NetworkNamespace, netns)
@@ -53,25 +80,25 @@ func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
 	viper.Set(constants.RedirectDNS, rdrct.dnsRedirect)
 	viper.Set(constants.CaptureAllDNS, rdrct.dnsRedirect)
 	viper.Set(constants.DropInvalid, rdrct.invalidDrop)
+	viper.Set(constants.Backend, ipt.backend)
+}
 
-	netNs, err := getNs(netns)
-	if err != nil {
-		err = fmt.Errorf("failed to open netns %q: %s", netns, err)
+// Verify compares the rules in place in the pod network namespace with the ones Program applies.
+func (ipt *iptables) Verify(netns string, rdrct *Redirect) (builder.RulesDiff, error) {
+	ipt.configure(netns, rdrct)
+	var diff builder.RulesDiff
+	err := ns.WithNetNSPath(netns, func(ns.NetNS) error {
+		var err error
+		diff, err = cmd.VerifyRules()
 		return err
-	}
-	defer netNs.Close()
-
-	if err = netNs.Do(func(_ ns.NetNS) error {
-		iptablesCmd := cmd.GetCommand()
-		log.Infof("============= Start iptables configuration for %v =============", podName)
-		defer log.Infof("============= End iptables configuration for %v =============", podName)
-		if err := iptablesCmd.Execute(); err != nil {
-			return err
-		}
-		return nil
-	}); err != nil {
-		return err
-	}
+	})
+	return diff, err
+}
 
-	return nil
+// Repair replaces the rules in place in the pod network namespace with the ones Program applies.
+func (ipt *iptables) Repair(netns string, rdrct *Redirect) error {
+	ipt.configure(netns, rdrct)
+	return ns.WithNetNSPath(netns, func(ns.NetNS) error {
+		return cmd.RepairRules()
+	})
 }
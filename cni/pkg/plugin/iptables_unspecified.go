@@ -19,7 +19,11 @@
 // parses prevResult according to the cniVersion
 package plugin
 
-import "errors"
+import (
+	"errors"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+)
 
 // ErrNotImplemented is returned when a requested feature is not implemented.
 var ErrNotImplemented = errors.New("not implemented")
@@ -29,3 +33,13 @@ var ErrNotImplemented = errors.New("not implemented")
 func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
 	return ErrNotImplemented
 }
+
+// Verify compares the rules in place in the pod network namespace with the ones Program applies.
+func (ipt *iptables) Verify(netns string, rdrct *Redirect) (builder.RulesDiff, error) {
+	return builder.RulesDiff{}, ErrNotImplemented
+}
+
+// Repair replaces the rules in place in the pod network namespace with the ones Program applies.
+func (ipt *iptables) Repair(netns string, rdrct *Redirect) error {
+	return ErrNotImplemented
+}
@@ -68,6 +68,14 @@ func getK8sPodInfo(client *kubernetes.Clientset, podName, podNamespace string) (
 		return nil, err
 	}
 
+	pi := podInfoFromPod(pod)
+	log.Debugf("Pod %v/%v info: \n%+v", podNamespace, podName, pi)
+
+	return pi, nil
+}
+
+// podInfoFromPod extracts the information relevant to the redirection from a pod.
+func podInfoFromPod(pod *v1.Pod) *PodInfo {
 	pi := &PodInfo{
 		Containers:        sets.New[string](),
 		Labels:            pod.Labels,
@@ -91,9 +99,7 @@ func getK8sPodInfo(client *kubernetes.Clientset, podName, podNamespace string) (
 			}
 		}
 	}
-	log.Debugf("Pod %v/%v info: \n%+v", podNamespace, podName, pi)
-
-	return pi, nil
+	return pi
 }
 
 // containers fetches all containers in the pod.
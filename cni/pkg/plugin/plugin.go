@@ -264,27 +264,57 @@ func doRun(args *skel.CmdArgs, conf *Config) error {
 		return k8sErr
 	}
 
+	if reason := exclusionReason(pi); reason != "" {
+		log.Infof("excluded %s", reason)
+		return nil
+	}
+
+	log.Debugf("Setting up redirect")
+
+	redirect, err := NewRedirect(pi)
+	if err != nil {
+		log.Errorf("redirect failed due to bad params: %v", err)
+		return err
+	}
+
+	if redirect.interceptionBackend != "" {
+		interceptRuleMgrType = redirect.interceptionBackend
+	}
+	// Get the constructor for the configured type of InterceptRuleMgr
+	interceptMgrCtor := GetInterceptRuleMgrCtor(interceptRuleMgrType)
+	if interceptMgrCtor == nil {
+		log.Errorf("Pod redirect failed due to unavailable InterceptRuleMgr of type %s", interceptRuleMgrType)
+		return fmt.Errorf("redirect failed to find InterceptRuleMgr")
+	}
+
+	redirect.hostNSEnterExec = conf.HostNSEnterExec
+	rulesMgr := interceptMgrCtor()
+	if err := rulesMgr.Program(podName, args.Netns, redirect); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// exclusionReason returns why the plugin does not set up the redirection of a pod, or an empty string if it does.
+func exclusionReason(pi *PodInfo) string {
 	// Check if istio-init container is present; in that case exclude pod
 	if pi.Containers.Contains(ISTIOINIT) {
-		log.Infof("excluded due to being already injected with istio-init container")
-		return nil
+		return "due to being already injected with istio-init container"
 	}
 
 	if val, ok := pi.ProxyEnvironments["DISABLE_ENVOY"]; ok {
 		if val, err := strconv.ParseBool(val); err == nil && val {
-			log.Infof("excluded due to DISABLE_ENVOY on istio-proxy", podNamespace, podName)
-			return nil
+			return "due to DISABLE_ENVOY on istio-proxy"
 		}
 	}
 
 	if !pi.Containers.Contains(ISTIOPROXY) {
-		log.Infof("excluded because it does not have istio-proxy container (have %v)", sets.SortedList(pi.Containers))
-		return nil
+		return fmt.Sprintf("because it does not have istio-proxy container (have %v)", sets.SortedList(pi.Containers))
 	}
 
 	if pi.ProxyType != "" && pi.ProxyType != "sidecar" {
-		log.Infof("excluded because it has proxy type %v", podNamespace, podName, pi.ProxyType)
-		return nil
+		return fmt.Sprintf("because it has proxy type %v", pi.ProxyType)
 	}
 
 	val := pi.Annotations[injectAnnotationKey]
@@ -293,42 +323,15 @@ func doRun(args *skel.CmdArgs, conf *Config) error {
 		val = lbl
 	}
 	if val != "" {
-		log.Debugf("contains inject annotation: %s", val)
-		if injectEnabled, err := strconv.ParseBool(val); err == nil {
-			if !injectEnabled {
-				log.Infof("excluded due to inject-disabled annotation")
-				return nil
-			}
+		if injectEnabled, err := strconv.ParseBool(val); err == nil && !injectEnabled {
+			return "due to inject-disabled annotation"
 		}
 	}
 
 	if _, ok := pi.Annotations[sidecarStatusKey]; !ok {
-		log.Infof("excluded due to not containing sidecar annotation")
-		return nil
-	}
-
-	log.Debugf("Setting up redirect")
-
-	redirect, err := NewRedirect(pi)
-	if err != nil {
-		log.Errorf("redirect failed due to bad params: %v", err)
-		return err
-	}
-
-	// Get the constructor for the configured type of InterceptRuleMgr
-	interceptMgrCtor := GetInterceptRuleMgrCtor(interceptRuleMgrType)
-	if interceptMgrCtor == nil {
-		log.Errorf("Pod redirect failed due to unavailable InterceptRuleMgr of type %s", interceptRuleMgrType)
-		return fmt.Errorf("redirect failed to find InterceptRuleMgr")
-	}
-
-	redirect.hostNSEnterExec = conf.HostNSEnterExec
-	rulesMgr := interceptMgrCtor()
-	if err := rulesMgr.Program(podName, args.Netns, redirect); err != nil {
-		return err
+		return "due to not containing sidecar annotation"
 	}
-
-	return nil
+	return ""
 }
 
 func setupLogging(conf *Config) {
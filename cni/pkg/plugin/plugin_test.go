@@ -25,6 +25,7 @@ import (
 	"github.com/containernetworking/cni/pkg/types"
 	cniv1 "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"istio.io/api/label"
@@ -427,6 +428,25 @@ func TestCmdAddWithExcludeInterfaces(t *testing.T) {
 	}
 }
 
+func TestCmdAddWithInterceptionBackend(t *testing.T) {
+	defer resetGlobalTestVariables()
+
+	testAnnotations[interceptionBackendKey] = "mock"
+	testContainers = sets.New("mockContainer", "istio-proxy")
+
+	// The annotation overrides the type of InterceptRuleMgr configured for the node.
+	cniConf := fmt.Sprintf(conf, currentVersion, currentVersion, ifname, sandboxDirectory, "iptables")
+	testCmdAddWithStdinData(t, cniConf)
+
+	if !nsenterFuncCalled {
+		t.Fatalf("expected nsenterFunc to be called")
+	}
+	r := singletonMockInterceptRuleMgr.lastRedirect[len(singletonMockInterceptRuleMgr.lastRedirect)-1]
+	if r.interceptionBackend != "mock" {
+		t.Fatalf("expect interceptionBackend to be set by the annotation, actual %v", r.interceptionBackend)
+	}
+}
+
 func TestCmdAddInvalidK8sArgsKeyword(t *testing.T) {
 	defer resetGlobalTestVariables()
 
@@ -528,3 +548,39 @@ func Test_dedupPorts(t *testing.T) {
 		})
 	}
 }
+
+func TestInitContainerRedirect(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{
+				Name: ISTIOINIT,
+				Args: []string{
+					"istio-iptables", "-p", "15001", "-z", "15006", "-u", "1337", "-m", "REDIRECT", "-i", "*", "-x", "",
+					"-b", "*", "-d", "15090,15021,15020", "--log_output_level=default:info",
+				},
+				Env: []corev1.EnvVar{{Name: "ISTIO_META_DNS_CAPTURE", Value: "true"}},
+			}},
+		},
+	}
+	want := &Redirect{
+		targetPort:          "15001",
+		redirectMode:        "REDIRECT",
+		noRedirectUID:       "1337",
+		includeIPCidrs:      "*",
+		includeInboundPorts: "*",
+		excludeInboundPorts: "15090,15021,15020",
+		dnsRedirect:         true,
+	}
+	got, err := InitContainerRedirect(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InitContainerRedirect() = %+v, want %+v", got, want)
+	}
+
+	pod.Spec.InitContainers = nil
+	if got, err := InitContainerRedirect(pod); got != nil || err != nil {
+		t.Errorf("InitContainerRedirect() = %+v, %v, want no redirection", got, err)
+	}
+}
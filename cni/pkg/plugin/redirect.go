@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/tools/istio-iptables/pkg/cmd"
 )
@@ -57,6 +58,8 @@ var (
 
 	kubevirtInterfacesKey = annotation.SidecarTrafficKubevirtInterfaces.Name
 
+	interceptionBackendKey = constants.SidecarInterceptionBackend
+
 	annotationRegistry = map[string]*annotationParam{
 		"inject":               {injectAnnotationKey, "", alwaysValidFunc},
 		"status":               {sidecarStatusKey, "", alwaysValidFunc},
@@ -70,6 +73,7 @@ var (
 		"includeOutboundPorts": {includeOutboundPortsKey, defaultIncludeOutboundPorts, validatePortListWithWildcard},
 		"kubevirtInterfaces":   {kubevirtInterfacesKey, defaultKubevirtInterfaces, alwaysValidFunc},
 		"excludeInterfaces":    {excludeInterfacesKey, defaultExcludeInterfaces, alwaysValidFunc},
+		"interceptionBackend":  {interceptionBackendKey, "", validateInterceptionBackend},
 	}
 )
 
@@ -90,6 +94,8 @@ type Redirect struct {
 	dnsRedirect          bool
 	invalidDrop          bool
 	hostNSEnterExec      bool
+	// interceptionBackend is the type of InterceptRuleMgr requested by the pod, if any.
+	interceptionBackend string
 }
 
 type annotationValidationFunc func(value string) error
@@ -115,6 +121,14 @@ func validateInterceptionMode(mode string) error {
 	return nil
 }
 
+// validateInterceptionBackend validates the interceptionBackend annotation
+func validateInterceptionBackend(backend string) error {
+	if _, ok := InterceptRuleMgrTypes[backend]; !ok {
+		return fmt.Errorf("interceptionBackend invalid: %v", backend)
+	}
+	return nil
+}
+
 func validateCIDRList(cidrs string) error {
 	if len(cidrs) > 0 {
 		for _, cidr := range strings.Split(cidrs, ",") {
@@ -278,6 +292,11 @@ func NewRedirect(pi *PodInfo) (*Redirect, error) {
 		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
 			"kubevirtInterfaces", isFound, valErr)
 	}
+	isFound, redir.interceptionBackend, valErr = getAnnotationOrDefault("interceptionBackend", pi.Annotations)
+	if valErr != nil {
+		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
+			"interceptionBackend", isFound, valErr)
+	}
 	if v, found := pi.ProxyEnvironments["ISTIO_META_DNS_CAPTURE"]; found {
 		// parse and set the bool value of dnsRedirect
 		redir.dnsRedirect, valErr = strconv.ParseBool(v)
@@ -0,0 +1,203 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/cni/pkg/config"
+	"istio.io/istio/cni/pkg/plugin"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	reasonDrifted      = "RedirectionRulesDrifted"
+	reasonRepaired     = "RedirectionRulesRepaired"
+	reasonRepairFailed = "RedirectionRulesRepairFailed"
+)
+
+// DriftController periodically compares the redirection rules in place in the network namespace of the sidecar
+// pods on the node with the rules the CNI plugin programs for them. Drifted pods are reported with metrics and
+// events, and their rules are optionally programmed again.
+type DriftController struct {
+	client   kube.Client
+	pods     kclient.Client[*corev1.Pod]
+	cfg      config.RepairConfig
+	verifier plugin.RulesVerifier
+
+	// netnsByIP returns the network namespaces on the node indexed by the IPs they hold, it is overridden in tests.
+	netnsByIP func() (map[string]string, error)
+	// drifted holds the pods found drifted by the last check, so events are only sent when they start drifting.
+	drifted sets.Set[types.NamespacedName]
+}
+
+func NewDriftController(client kube.Client, cfg config.RepairConfig) (*DriftController, error) {
+	verifier := plugin.GetRulesVerifier(cfg.DriftInterceptType)
+	if verifier == nil {
+		return nil, fmt.Errorf("redirection rules of type %q cannot be verified", cfg.DriftInterceptType)
+	}
+	c := &DriftController{
+		client:    client,
+		cfg:       cfg,
+		verifier:  verifier,
+		netnsByIP: netnsByIP,
+		drifted:   sets.New[types.NamespacedName](),
+	}
+	fieldSelectors := []string{}
+	if cfg.FieldSelectors != "" {
+		fieldSelectors = append(fieldSelectors, cfg.FieldSelectors)
+	}
+	// only the pods on this node can be checked
+	fieldSelectors = append(fieldSelectors, fmt.Sprintf("spec.nodeName=%v", cfg.NodeName))
+	c.pods = kclient.NewFiltered[*corev1.Pod](client, kclient.Filter{
+		LabelSelector: cfg.LabelSelectors,
+		FieldSelector: strings.Join(fieldSelectors, ","),
+	})
+	return c, nil
+}
+
+func (c *DriftController) Run(stop <-chan struct{}) {
+	kube.WaitForCacheSync("drift controller", stop, c.pods.HasSynced)
+	ticker := time.NewTicker(c.cfg.DriftCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.CheckPods()
+		}
+	}
+}
+
+// CheckPods checks the redirection rules of every pod on the node.
+func (c *DriftController) CheckPods() {
+	// The network namespaces are resolved once per check rather than once per pod.
+	namespaces, err := c.netnsByIP()
+	if err != nil {
+		repairLog.Warnf("Failed to list the network namespaces: %v", err)
+		return
+	}
+	drifted := sets.New[types.NamespacedName]()
+	for _, pod := range c.pods.List(metav1.NamespaceAll, klabels.Everything()) {
+		if c.checkPod(pod, namespaces) {
+			drifted.Insert(types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+		}
+	}
+	c.drifted = drifted
+	driftedPods.Record(float64(drifted.Len()))
+}
+
+// checkPod checks the redirection rules of a pod and returns whether they drifted.
+func (c *DriftController) checkPod(pod *corev1.Pod, namespaces map[string]string) bool {
+	if pod.Spec.HostNetwork || pod.Status.Phase != corev1.PodRunning || len(pod.Status.PodIPs) == 0 {
+		return false
+	}
+	if _, ok := pod.Annotations[c.cfg.SidecarAnnotation]; !ok {
+		return false
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	// The rules of the pod are programmed either by its istio-init container or by the plugin.
+	redirect, err := plugin.InitContainerRedirect(pod)
+	if err != nil {
+		repairLog.Debugf("Failed to read the redirection of pod %s: %v", key, err)
+		return false
+	}
+	if redirect == nil {
+		redirect, err = plugin.PodRedirect(pod, false)
+		if err != nil || redirect == nil {
+			// The plugin does not program the rules of this pod.
+			return false
+		}
+	}
+	netns := ""
+	for _, ip := range pod.Status.PodIPs {
+		if netns = namespaces[ip.IP]; netns != "" {
+			break
+		}
+	}
+	if netns == "" {
+		repairLog.Debugf("Failed to find the network namespace of pod %s", key)
+		driftChecks.With(resultLabel.Value(resultFail)).Increment()
+		return false
+	}
+	verifier := plugin.PodRulesVerifier(redirect, c.verifier)
+	diff, err := verifier.Verify(netns, redirect)
+	if err != nil {
+		repairLog.Warnf("Failed to check the redirection rules of pod %s: %v", key, err)
+		driftChecks.With(resultLabel.Value(resultFail)).Increment()
+		return false
+	}
+	if diff.Empty() {
+		driftChecks.With(resultLabel.Value(resultInSync)).Increment()
+		return false
+	}
+	driftChecks.With(resultLabel.Value(resultDrifted)).Increment()
+	repairLog.Infof("Redirection rules of pod %s drifted: %s", key, diff)
+	if !c.drifted.Contains(key) {
+		c.recordEvent(pod, corev1.EventTypeWarning, reasonDrifted, "Redirection rules drifted: "+diff.String())
+	}
+	if !c.cfg.RepairDrift {
+		return true
+	}
+	if err := verifier.Repair(netns, redirect); err != nil {
+		repairLog.Errorf("Failed to repair the redirection rules of pod %s: %v", key, err)
+		driftRepairs.With(resultLabel.Value(resultFail)).Increment()
+		c.recordEvent(pod, corev1.EventTypeWarning, reasonRepairFailed, "Failed to repair redirection rules: "+err.Error())
+		return true
+	}
+	driftRepairs.With(resultLabel.Value(resultSuccess)).Increment()
+	c.recordEvent(pod, corev1.EventTypeNormal, reasonRepaired, "Redirection rules programmed again")
+	// The pod is in sync again, it will be reported again if it drifts once more.
+	return false
+}
+
+func (c *DriftController) recordEvent(pod *corev1.Pod, eventType, reason, message string) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + ".",
+			Namespace:    pod.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "Pod",
+			Namespace:       pod.Namespace,
+			Name:            pod.Name,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "istio-cni", Host: c.cfg.NodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := c.client.Kube().CoreV1().Events(pod.Namespace).Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+		repairLog.Warnf("Failed to record %s event for pod %s/%s: %v", reason, pod.Namespace, pod.Name, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/cni/pkg/config"
+	"istio.io/istio/cni/pkg/plugin"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+)
+
+type fakeVerifier struct {
+	diff     builder.RulesDiff
+	repaired []string
+}
+
+func (f *fakeVerifier) Verify(netns string, _ *plugin.Redirect) (builder.RulesDiff, error) {
+	return f.diff, nil
+}
+
+func (f *fakeVerifier) Repair(netns string, _ *plugin.Redirect) error {
+	f.repaired = append(f.repaired, netns)
+	f.diff = builder.RulesDiff{}
+	return nil
+}
+
+func makeSidecarPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{"sidecar.istio.io/status": "something"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node",
+			Containers: []corev1.Container{
+				{Name: "payload-container"},
+				{Name: "istio-proxy", Args: []string{"proxy", "sidecar"}},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}},
+		},
+	}
+}
+
+func makeInitPod(name string) *corev1.Pod {
+	pod := makeSidecarPod(name)
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name: "istio-init",
+		Args: []string{"istio-iptables", "-p", "15001", "-z", "15006", "-u", "1337", "-m", "REDIRECT", "-i", "*", "-x", "", "-b", "*", "-d", "15090,15021,15020"},
+	}}
+	return pod
+}
+
+func TestDriftController(t *testing.T) {
+	drift := builder.RulesDiff{Missing: []string{"-t nat -A ISTIO_OUTPUT -j ISTIO_REDIRECT"}}
+	tests := []struct {
+		name         string
+		pod          *corev1.Pod
+		diff         builder.RulesDiff
+		repair       bool
+		wantResult   string
+		wantRepaired []string
+		wantReasons  []string
+	}{
+		{
+			name:       "in sync",
+			pod:        makeSidecarPod("in-sync"),
+			wantResult: resultInSync,
+		},
+		{
+			name:        "drifted",
+			pod:         makeSidecarPod("drifted"),
+			diff:        drift,
+			wantResult:  resultDrifted,
+			wantReasons: []string{reasonDrifted},
+		},
+		{
+			name:        "istio-init drifted",
+			pod:         makeInitPod("istio-init"),
+			diff:        drift,
+			wantResult:  resultDrifted,
+			wantReasons: []string{reasonDrifted},
+		},
+		{
+			name:         "drifted and repaired",
+			pod:          makeSidecarPod("repaired"),
+			diff:         drift,
+			repair:       true,
+			wantResult:   resultDrifted,
+			wantRepaired: []string{"/var/run/netns/repaired"},
+			wantReasons:  []string{reasonDrifted, reasonRepaired},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := monitortest.New(t)
+			client := fakeClient(tt.pod)
+			c, err := NewDriftController(client, config.RepairConfig{
+				NodeName:           "node",
+				SidecarAnnotation:  "sidecar.istio.io/status",
+				RepairDrift:        tt.repair,
+				DriftInterceptType: "iptables",
+			})
+			assert.NoError(t, err)
+			verifier := &fakeVerifier{diff: tt.diff}
+			c.verifier = verifier
+			c.netnsByIP = func() (map[string]string, error) {
+				return map[string]string{"10.0.0.1": "/var/run/netns/" + tt.pod.Name}, nil
+			}
+			stop := test.NewStop(t)
+			client.RunAndWait(stop)
+
+			// Checking twice must only report the drift once.
+			c.CheckPods()
+			c.CheckPods()
+
+			mt.Assert(driftChecks.Name(), map[string]string{"result": tt.wantResult}, monitortest.AtLeast(1))
+			assert.Equal(t, verifier.repaired, tt.wantRepaired)
+			events, err := client.Kube().CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+			assert.NoError(t, err)
+			reasons := slices.Map(events.Items, func(e corev1.Event) string {
+				return e.Reason
+			})
+			assert.Equal(t, slices.Sort(reasons), slices.Sort(tt.wantReasons))
+		})
+	}
+}
@@ -27,14 +27,33 @@ var (
 	resultSuccess = "success"
 	resultSkip    = "skip"
 	resultFail    = "fail"
+	resultInSync  = "in_sync"
+	resultDrifted = "drifted"
 
 	podsRepaired = monitoring.NewSum(
 		"istio_cni_repair_pods_repaired_total",
 		"Total number of pods repaired by repair controller",
 		monitoring.WithLabels(typeLabel, resultLabel),
 	)
+
+	driftChecks = monitoring.NewSum(
+		"istio_cni_repair_drift_checks_total",
+		"Total number of checks of the redirection rules of a pod by the drift controller",
+		monitoring.WithLabels(resultLabel),
+	)
+
+	driftedPods = monitoring.NewGauge(
+		"istio_cni_repair_drifted_pods",
+		"Number of pods whose redirection rules drifted, as of the last check",
+	)
+
+	driftRepairs = monitoring.NewSum(
+		"istio_cni_repair_drift_repairs_total",
+		"Total number of pods whose redirection rules were programmed again by the drift controller",
+		monitoring.WithLabels(resultLabel),
+	)
 )
 
 func init() {
-	monitoring.MustRegister(podsRepaired)
+	monitoring.MustRegister(podsRepaired, driftChecks, driftedPods, driftRepairs)
 }
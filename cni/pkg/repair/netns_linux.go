@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	netns "github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+const netnsDir = "/var/run/netns"
+
+// netnsByIP returns the paths of the network namespaces on the node, indexed by the IPs they hold. The container
+// runtime creates the pod network namespaces under /var/run/netns, which must be mounted in the node agent.
+func netnsByIP() (map[string]string, error) {
+	namespaces := map[string]string{}
+	err := filepath.WalkDir(netnsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		err = netns.WithNetNSPath(p, func(netns.NetNS) error {
+			addrs, err := netlink.AddrList(nil, netlink.FAMILY_ALL)
+			if err != nil {
+				return err
+			}
+			for _, a := range addrs {
+				if a.IP.IsLoopback() {
+					continue
+				}
+				namespaces[a.IP.String()] = p
+			}
+			return nil
+		})
+		if err != nil {
+			repairLog.Debugf("failed to list addresses in %s: %v", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
@@ -25,22 +25,35 @@ import (
 var repairLog = log.RegisterScope("repair", "CNI race condition repair")
 
 func StartRepair(ctx context.Context, cfg config.RepairConfig) {
-	if !cfg.Enabled {
-		repairLog.Info("CNI repair is disable.")
+	if !cfg.Enabled && cfg.DriftCheckInterval <= 0 {
+		repairLog.Info("CNI repair and drift detection are disabled.")
 		return
 	}
-	repairLog.Info("Start CNI race condition repair.")
 
 	client, err := clientSetup()
 	if err != nil {
 		repairLog.Fatalf("CNI repair could not construct clientSet: %s", err)
 	}
 
-	rc, err := NewRepairController(client, cfg)
-	if err != nil {
-		repairLog.Fatalf("Fatal error constructing repair controller: %+v", err)
+	if cfg.Enabled {
+		repairLog.Info("Start CNI race condition repair.")
+		rc, err := NewRepairController(client, cfg)
+		if err != nil {
+			repairLog.Fatalf("Fatal error constructing repair controller: %+v", err)
+		}
+		go rc.Run(ctx.Done())
+	} else {
+		repairLog.Info("CNI repair is disabled.")
+	}
+	// Drift detection only inspects the rules in running pods, so it does not depend on the race condition repair.
+	if cfg.DriftCheckInterval > 0 {
+		repairLog.Info("Start CNI redirection drift detection.")
+		dc, err := NewDriftController(client, cfg)
+		if err != nil {
+			repairLog.Fatalf("Fatal error constructing drift controller: %+v", err)
+		}
+		go dc.Run(ctx.Done())
 	}
-	go rc.Run(ctx.Done())
 	client.RunAndWait(ctx.Done())
 }
 
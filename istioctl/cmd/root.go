@@ -27,12 +27,14 @@ import (
 	"istio.io/istio/istioctl/pkg/admin"
 	"istio.io/istio/istioctl/pkg/analyze"
 	"istio.io/istio/istioctl/pkg/authz"
+	"istio.io/istio/istioctl/pkg/capture"
 	"istio.io/istio/istioctl/pkg/checkinject"
 	"istio.io/istio/istioctl/pkg/cli"
 	"istio.io/istio/istioctl/pkg/completion"
 	"istio.io/istio/istioctl/pkg/config"
 	"istio.io/istio/istioctl/pkg/dashboard"
 	"istio.io/istio/istioctl/pkg/describe"
+	"istio.io/istio/istioctl/pkg/graph"
 	"istio.io/istio/istioctl/pkg/injector"
 	"istio.io/istio/istioctl/pkg/install"
 	"istio.io/istio/istioctl/pkg/internaldebug"
@@ -45,12 +47,15 @@ import (
 	"istio.io/istio/istioctl/pkg/revision"
 	"istio.io/istio/istioctl/pkg/root"
 	"istio.io/istio/istioctl/pkg/tag"
+	"istio.io/istio/istioctl/pkg/tap"
+	"istio.io/istio/istioctl/pkg/upgrade"
 	"istio.io/istio/istioctl/pkg/util"
 	"istio.io/istio/istioctl/pkg/validate"
 	"istio.io/istio/istioctl/pkg/version"
 	"istio.io/istio/istioctl/pkg/wait"
 	"istio.io/istio/istioctl/pkg/waypoint"
 	"istio.io/istio/istioctl/pkg/workload"
+	"istio.io/istio/istioctl/pkg/ztunnel"
 	"istio.io/istio/operator/cmd/mesh"
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/collateral"
@@ -212,6 +217,11 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(proxyconfig.StatsConfigCmd(ctx))
 	experimentalCmd.AddCommand(checkinject.Cmd(ctx))
 	experimentalCmd.AddCommand(waypoint.Cmd(ctx))
+	experimentalCmd.AddCommand(tap.Cmd(ctx))
+	experimentalCmd.AddCommand(graph.Cmd(ctx))
+	experimentalCmd.AddCommand(capture.Cmd(ctx))
+	experimentalCmd.AddCommand(ztunnel.Cmd(ctx))
+	experimentalCmd.AddCommand(upgrade.Cmd(ctx))
 
 	analyzeCmd := analyze.Analyze(ctx)
 	hideInheritedFlags(analyzeCmd, cli.FlagIstioNamespace)
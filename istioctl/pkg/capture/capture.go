@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/completion"
+	iptables "istio.io/istio/tools/istio-iptables/pkg/cmd"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// Cmd returns the capture command.
+func Cmd(ctx cli.Context) *cobra.Command {
+	captureCmd := &cobra.Command{
+		Use:   "capture",
+		Short: "Inspect how the traffic of sidecars is captured",
+	}
+	captureCmd.AddCommand(explainCmd(ctx))
+	return captureCmd
+}
+
+func explainCmd(ctx cli.Context) *cobra.Command {
+	var opts iptables.PacketOptions
+	explainCmd := &cobra.Command{
+		Use:   "explain [<type>/]<name>[.<namespace>]",
+		Short: "Explain how the traffic capture rules of a pod handle a packet",
+		Long: `Evaluates a hypothetical packet against the traffic capture rules of the sidecar of a pod, and prints the
+rules matching it and where it ends up. The rules are generated from the arguments of the istio-init (or, with
+Istio CNI, istio-validation) container of the pod, so the include and exclude annotations of the pod are taken
+into account. No rule is read from the pod.`,
+		Example: `  # Is a connection from the application to 10.0.0.5:3306 captured?
+  istioctl x capture explain productpage-v1-7d4f5d6c8c-abcde.default --dst 10.0.0.5:3306
+
+  # Is a connection from a process running as UID 1337 captured?
+  istioctl x capture explain deployment/productpage-v1 --dst 10.0.0.5:3306 --uid 1337
+
+  # Is a connection to port 15020 of the pod captured? The pod IP is used when only a port is given.
+  istioctl x capture explain productpage-v1-7d4f5d6c8c-abcde --direction inbound --dst :15020`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("capture explain requires pod name")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			podName, podNamespace, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+			if err != nil {
+				return err
+			}
+			pod, err := kubeClient.Kube().CoreV1().Pods(podNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			return explain(c.OutOrStdout(), pod, opts)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completion.ValidPodsNameArgs(cmd, ctx, args, toComplete)
+		},
+	}
+	opts.AddFlags(explainCmd.Flags())
+	return explainCmd
+}
+
+func explain(w io.Writer, pod *corev1.Pod, opts iptables.PacketOptions) error {
+	args, err := captureArgs(pod)
+	if err != nil {
+		return err
+	}
+	podIP := podAddress(pod, opts.Dst)
+	if strings.HasPrefix(opts.Dst, ":") {
+		if !podIP.IsValid() {
+			return fmt.Errorf("pod %s.%s has no IP, --dst must include an address", pod.Name, pod.Namespace)
+		}
+		opts.Dst = net.JoinHostPort(podIP.String(), strings.TrimPrefix(opts.Dst, ":"))
+	}
+	if opts.Src == "" && opts.Direction == "outbound" && podIP.IsValid() {
+		// Packets sent by the application are sent from the pod IP.
+		opts.Src = podIP.String()
+	}
+	pkt, err := opts.Packet()
+	if err != nil {
+		return err
+	}
+	return iptables.ExplainArgs(w, args, pkt)
+}
+
+// captureArgs returns the istio-iptables arguments of the pod, from the init container the injector adds. With
+// Istio CNI, the validation container gets the same arguments as the CNI plugin.
+func captureArgs(pod *corev1.Pod) ([]string, error) {
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name != constants.ValidationContainerName && c.Name != "istio-init" {
+			continue
+		}
+		if len(c.Args) == 0 || c.Args[0] != "istio-iptables" {
+			return nil, fmt.Errorf("container %s of pod %s.%s does not run istio-iptables", c.Name, pod.Name, pod.Namespace)
+		}
+		var args []string
+		for _, a := range c.Args[1:] {
+			// Logging flags belong to the parent command.
+			if !strings.HasPrefix(a, "--log_") {
+				args = append(args, a)
+			}
+		}
+		// The proxy metadata is passed to the container as environment variables.
+		for _, e := range c.Env {
+			enabled, _ := strconv.ParseBool(e.Value)
+			if !enabled {
+				continue
+			}
+			switch e.Name {
+			case "ISTIO_META_DNS_CAPTURE":
+				args = append(args, "--"+constants.RedirectDNS)
+			case "INVALID_DROP":
+				args = append(args, "--"+constants.DropInvalid)
+			}
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("pod %s.%s has no istio-init or %s container, its traffic is not captured by a sidecar",
+		pod.Name, pod.Namespace, constants.ValidationContainerName)
+}
+
+// podAddress returns the IP of the pod of the same family as the destination, or the first one if the destination
+// has no address.
+func podAddress(pod *corev1.Pod, dst string) netip.Addr {
+	var want netip.Addr
+	if d, err := netip.ParseAddrPort(dst); err == nil {
+		want = d.Addr()
+	}
+	for _, ip := range pod.Status.PodIPs {
+		addr, err := netip.ParseAddr(ip.IP)
+		if err != nil {
+			continue
+		}
+		if !want.IsValid() || addr.Is6() == want.Is6() {
+			return addr
+		}
+	}
+	return netip.Addr{}
+}
@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test/util/assert"
+	iptables "istio.io/istio/tools/istio-iptables/pkg/cmd"
+)
+
+func makePod(initContainer corev1.Container) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "productpage", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{initContainer},
+			Containers:     []corev1.Container{{Name: "productpage"}, {Name: "istio-proxy"}},
+		},
+		Status: corev1.PodStatus{
+			PodIPs: []corev1.PodIP{{IP: "10.0.0.7"}},
+		},
+	}
+}
+
+var injectedArgs = []string{
+	"istio-iptables", "-p", "15001", "-z", "15006", "-u", "1337", "-m", "REDIRECT", "-i", "*", "-x", "10.0.0.0/16",
+	"-b", "*", "-d", "15090,15021,15020", "--log_output_level=default:info",
+}
+
+func TestCaptureArgs(t *testing.T) {
+	pod := makePod(corev1.Container{
+		Name: "istio-validation",
+		Args: append(append([]string{}, injectedArgs...), "--run-validation", "--skip-rule-apply"),
+		Env:  []corev1.EnvVar{{Name: "ISTIO_META_DNS_CAPTURE", Value: "true"}},
+	})
+	args, err := captureArgs(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, args, []string{
+		"-p", "15001", "-z", "15006", "-u", "1337", "-m", "REDIRECT", "-i", "*", "-x", "10.0.0.0/16",
+		"-b", "*", "-d", "15090,15021,15020", "--run-validation", "--skip-rule-apply", "--redirect-dns",
+	})
+
+	_, err = captureArgs(makePod(corev1.Container{Name: "other"}))
+	assert.Error(t, err)
+}
+
+func TestExplain(t *testing.T) {
+	pod := makePod(corev1.Container{Name: "istio-init", Args: injectedArgs})
+	cases := []struct {
+		name   string
+		opts   iptables.PacketOptions
+		result string
+	}{
+		{
+			name:   "outbound captured",
+			opts:   iptables.PacketOptions{Direction: "outbound", Protocol: "tcp", Dst: "10.1.0.5:3306", CtState: "NEW"},
+			result: "redirected to the outbound listener of the sidecar (15001) at 127.0.0.1:15001",
+		},
+		{
+			name:   "outbound excluded range",
+			opts:   iptables.PacketOptions{Direction: "outbound", Protocol: "tcp", Dst: "10.0.0.5:3306", CtState: "NEW"},
+			result: "not captured, sent to 10.0.0.5:3306 directly",
+		},
+		{
+			name:   "inbound excluded port of the pod",
+			opts:   iptables.PacketOptions{Direction: "inbound", Protocol: "tcp", Dst: ":15020", CtState: "NEW"},
+			result: "not captured, delivered to the application on port 15020",
+		},
+		{
+			name:   "inbound captured",
+			opts:   iptables.PacketOptions{Direction: "inbound", Protocol: "tcp", Dst: ":9080", CtState: "NEW"},
+			result: "redirected to the inbound listener of the sidecar (15006) at 10.0.0.7:15006",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			assert.NoError(t, explain(&out, pod, tt.opts))
+			if !strings.Contains(out.String(), "Result: "+tt.result+"\n") {
+				t.Fatalf("unexpected result:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -35,14 +35,24 @@ import (
 	analyzer_util "istio.io/istio/pkg/config/analysis/analyzers/util"
 )
 
-var labelPairs string
+var (
+	labelPairs      string
+	filename        string
+	namespaceLabels map[string]string
+	webhookFiles    []string
+)
 
 func Cmd(ctx cli.Context) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "check-inject [<type>/]<name>[.<namespace>]",
 		Short: "Check the injection status or inject-ability of a given resource, explains why it is (or will be) injected or not",
 		Long: `
-Checks associated resources of the given resource, and running webhooks to examine whether the pod can be or will be injected or not.`,
+Checks associated resources of the given resource, and running webhooks to examine whether the pod can be or will be injected or not.
+
+With --filename, the pod or workload of the file is checked offline against the given namespace labels: the
+namespaceSelector and objectSelector of every Istio injection webhook are evaluated like the API server does, and
+the revision which would inject its pods is reported, or why none would. The webhooks are read from the cluster, or
+from the files given with --webhooks, like the output of istioctl manifest generate, without accessing the cluster.`,
 		Example: `  # Check the injection status of a pod
   istioctl experimental check-inject details-v1-fcff6c49c-kqnfk.test
 	
@@ -54,8 +64,23 @@ Checks associated resources of the given resource, and running webhooks to exami
 
   # Check the injection status of label pairs in a specific namespace before actual injection 
   istioctl x check-inject -n test -l app=helloworld,version=v1
+
+  # Check which revision would inject the pods of a deployment in a namespace with the given labels
+  istioctl x check-inject -f deployment.yaml -n test --namespace-labels istio.io/rev=canary
+
+  # Check it offline, against the webhooks of an installation manifest
+  istioctl x check-inject -f deployment.yaml --namespace-labels istio-injection=enabled --webhooks manifest.yaml
 `,
 		Args: func(cmd *cobra.Command, args []string) error {
+			if filename != "" {
+				if len(args) > 0 || labelPairs != "" {
+					return fmt.Errorf("--filename cannot be used with a resource name or the labels flag")
+				}
+				return nil
+			}
+			if len(namespaceLabels) > 0 || len(webhookFiles) > 0 {
+				return fmt.Errorf("--namespace-labels and --webhooks require --filename")
+			}
 			if len(args) == 0 && labelPairs == "" || len(args) > 1 {
 				cmd.Println(cmd.UsageString())
 				return fmt.Errorf("check-inject requires only [<resource-type>/]<resource-name>[.<namespace>], or specify labels flag")
@@ -63,6 +88,9 @@ Checks associated resources of the given resource, and running webhooks to exami
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if filename != "" {
+				return checkOffline(ctx, cmd.OutOrStdout())
+			}
 			kubeClient, err := ctx.CLIClient()
 			if err != nil {
 				return err
@@ -107,9 +135,50 @@ Checks associated resources of the given resource, and running webhooks to exami
 	}
 	cmd.PersistentFlags().StringVarP(&labelPairs, "labels", "l", "",
 		"Check namespace and label pairs injection status, split multiple labels by commas")
+	cmd.PersistentFlags().StringVarP(&filename, "filename", "f", "",
+		"Check offline the injection of the pods of the pod or workload in the file")
+	cmd.PersistentFlags().StringToStringVar(&namespaceLabels, "namespace-labels", nil,
+		"Labels of the namespace of the pod or workload given with --filename")
+	cmd.PersistentFlags().StringSliceVar(&webhookFiles, "webhooks", nil,
+		"Files with the MutatingWebhookConfigurations to check the pod or workload given with --filename against, "+
+			"instead of the ones of the cluster")
 	return cmd
 }
 
+// checkOffline checks the injection of the pods of the workload of the file, in a namespace with the given labels.
+func checkOffline(ctx cli.Context, writer io.Writer) error {
+	w, err := readWorkload(filename)
+	if err != nil {
+		return err
+	}
+	namespace := w.namespace
+	if namespace == "" {
+		namespace = ctx.NamespaceOrDefault(ctx.Namespace())
+	}
+	var whs []admitv1.MutatingWebhookConfiguration
+	if len(webhookFiles) > 0 {
+		if whs, err = readWebhooks(webhookFiles); err != nil {
+			return err
+		}
+	} else {
+		kubeClient, err := ctx.CLIClient()
+		if err != nil {
+			return err
+		}
+		list, err := kubeClient.Kube().AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		whs = list.Items
+	}
+	checkResults := analyzeRunningWebhooks(whs, w.template.Labels, offlineNamespaceLabels(namespace, namespaceLabels))
+	if err := printCheckInjectorResults(writer, checkResults); err != nil {
+		return err
+	}
+	printInjectionDecision(writer, w, checkResults)
+	return nil
+}
+
 func printCheckInjectorResults(writer io.Writer, was []webhookAnalysis) error {
 	if len(was) == 0 {
 		fmt.Fprintf(writer, "ERROR: no Istio injection hooks present.\n")
@@ -140,7 +209,7 @@ func printCheckInjectorResults(writer io.Writer, was []webhookAnalysis) error {
 	}
 	w.Flush()
 	if injectedTotal > 1 {
-		fmt.Fprintf(writer, "ERROR: multiple webhooks will inject, which can lead to errors")
+		fmt.Fprintf(writer, "ERROR: multiple webhooks will inject, which can lead to errors\n")
 	}
 	return nil
 }
@@ -185,6 +254,7 @@ func analyzeWebhooksMatchStatus(whs []admitv1.MutatingWebhook, podLabels, nsLabe
 			} else if podLabel != "" {
 				return fmt.Sprintf("Pod label %s matches", podLabel), true
 			}
+			return "Namespace and pod selectors match", true
 		} else if nsMatched {
 			for _, me := range wh.ObjectSelector.MatchExpressions {
 				switch me.Operator {
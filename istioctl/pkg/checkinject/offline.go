@@ -0,0 +1,189 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkinject
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	admitv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+// namespaceNameLabel is the label the API server sets on every namespace to its name, which webhook namespace
+// selectors can match.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// workload is a pod, or a workload creating pods, read from a file.
+type workload struct {
+	kind      string
+	name      string
+	namespace string
+	// template is the pod, or the template of the pods created by the workload.
+	template corev1.PodTemplateSpec
+}
+
+func (w *workload) String() string {
+	return fmt.Sprintf("%s/%s", w.kind, w.name)
+}
+
+// podTemplatePaths are the paths of the pod template of the workload kinds.
+var podTemplatePaths = map[string][]string{
+	"Deployment":            {"spec", "template"},
+	"StatefulSet":           {"spec", "template"},
+	"DaemonSet":             {"spec", "template"},
+	"ReplicaSet":            {"spec", "template"},
+	"ReplicationController": {"spec", "template"},
+	"Job":                   {"spec", "template"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template"},
+}
+
+// readWorkload reads the first pod or workload of a file.
+func readWorkload(filename string) (*workload, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range yml.SplitString(string(b)) {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(doc), &u.Object); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+		}
+		w := &workload{kind: u.GetKind(), name: u.GetName(), namespace: u.GetNamespace()}
+		if w.kind == "Pod" {
+			pod := &corev1.Pod{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod); err != nil {
+				return nil, fmt.Errorf("failed to parse pod %s: %v", w.name, err)
+			}
+			w.template = corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}
+			return w, nil
+		}
+		path, f := podTemplatePaths[w.kind]
+		if !f {
+			continue
+		}
+		template, _, err := unstructured.NestedMap(u.Object, path...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the pod template of %s: %v", w, err)
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, &w.template); err != nil {
+			return nil, fmt.Errorf("failed to parse the pod template of %s: %v", w, err)
+		}
+		return w, nil
+	}
+	return nil, fmt.Errorf("no pod or workload with a pod template found in %s", filename)
+}
+
+// readWebhooks reads the mutating webhook configurations of files, like the output of `istioctl manifest generate`
+// or `istioctl tag generate`. The other resources of the files are ignored.
+func readWebhooks(filenames []string) ([]admitv1.MutatingWebhookConfiguration, error) {
+	var res []admitv1.MutatingWebhookConfiguration
+	for _, filename := range filenames {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range yml.SplitString(string(b)) {
+			u := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(doc), &u.Object); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+			}
+			if u.GetKind() != "MutatingWebhookConfiguration" {
+				continue
+			}
+			wh := admitv1.MutatingWebhookConfiguration{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &wh); err != nil {
+				return nil, fmt.Errorf("failed to parse MutatingWebhookConfiguration %s: %v", u.GetName(), err)
+			}
+			res = append(res, wh)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no MutatingWebhookConfiguration found in %s", strings.Join(filenames, ", "))
+	}
+	return res, nil
+}
+
+// offlineNamespaceLabels returns the labels of the namespace as the API server sees them, including the label it
+// sets to the namespace name.
+func offlineNamespaceLabels(namespace string, nsLabels map[string]string) map[string]string {
+	res := map[string]string{namespaceNameLabel: namespace}
+	for k, v := range nsLabels {
+		res[k] = v
+	}
+	return res
+}
+
+// injectorSkipReason returns why the injector skips the pod even if a webhook calls it, or an empty string.
+func injectorSkipReason(template *corev1.PodTemplateSpec) string {
+	if template.Spec.HostNetwork {
+		return "the pod uses host networking, which the injector never injects"
+	}
+	// The label takes precedence over the deprecated annotation.
+	if _, f := template.Labels[label.SidecarInject.Name]; f {
+		return ""
+	}
+	switch v := template.Annotations[annotation.SidecarInject.Name]; strings.ToLower(v) {
+	case "n", "no", "false", "off":
+		return fmt.Sprintf("the pod has the %s=%s annotation, so the injector skips it", annotation.SidecarInject.Name, v)
+	}
+	return ""
+}
+
+// printInjectionDecision prints which revision injects the pod, or why none does.
+func printInjectionDecision(writer io.Writer, w *workload, was []webhookAnalysis) {
+	var injecting []webhookAnalysis
+	for _, wa := range was {
+		if wa.Injected {
+			injecting = append(injecting, wa)
+		}
+	}
+	switch {
+	case len(was) == 0:
+		return
+	case len(injecting) == 0:
+		fmt.Fprintf(writer, "No revision would inject the pods of %s: no injection webhook matches them.\n", w)
+	case len(injecting) > 1:
+		var revs []string
+		for _, wa := range injecting {
+			revs = append(revs, fmt.Sprintf("%s (%s)", renderRevision(wa.Revision), wa.Name))
+		}
+		fmt.Fprintf(writer, "Several revisions would inject the pods of %s: %s. Make the labels select a single revision.\n",
+			w, strings.Join(revs, ", "))
+	default:
+		if reason := injectorSkipReason(&w.template); reason != "" {
+			fmt.Fprintf(writer, "No revision would inject the pods of %s: the webhook of revision %s matches, but %s.\n",
+				w, renderRevision(injecting[0].Revision), reason)
+			return
+		}
+		fmt.Fprintf(writer, "Revision %s would inject the pods of %s: %s.\n", renderRevision(injecting[0].Revision), w, injecting[0].Reason)
+	}
+}
+
+func renderRevision(rev string) string {
+	if rev == "" {
+		return "default"
+	}
+	return rev
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkinject
+
+import (
+	"bytes"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestOfflineInjectionDecision(t *testing.T) {
+	whs, err := readWebhooks([]string{
+		"testdata/check-inject/default-injector.yaml",
+		"testdata/check-inject/rev-16-injector.yaml",
+		"testdata/check-inject/never-match-injector.yaml",
+		// Only the webhooks of the files are read.
+		"testdata/check-inject/deployment.yaml",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(whs), 3)
+
+	cases := []struct {
+		name      string
+		file      string
+		namespace string
+		nsLabels  map[string]string
+		expected  string
+	}{
+		{
+			name:      "revision label",
+			file:      "testdata/check-inject/deployment.yaml",
+			namespace: "test",
+			nsLabels:  map[string]string{"istio.io/rev": "1-16"},
+			expected:  "Revision 1-16 would inject the pods of Deployment/details-v1: Namespace label istio.io/rev=1-16 matches.\n",
+		},
+		{
+			name:      "no labels",
+			file:      "testdata/check-inject/deployment.yaml",
+			namespace: "test",
+			expected:  "No revision would inject the pods of Deployment/details-v1: no injection webhook matches them.\n",
+		},
+		{
+			name:      "host network",
+			file:      "testdata/check-inject/hostnetwork-pod.yaml",
+			namespace: "agents",
+			nsLabels:  map[string]string{"istio-injection": "enabled"},
+			expected: "No revision would inject the pods of Pod/node-agent: the webhook of revision default matches, " +
+				"but the pod uses host networking, which the injector never injects.\n",
+		},
+		{
+			name:      "pod template label",
+			file:      "testdata/check-inject/cronjob.yaml",
+			namespace: "test",
+			nsLabels:  map[string]string{"istio-injection": "enabled"},
+			expected:  "No revision would inject the pods of CronJob/report: no injection webhook matches them.\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w, err := readWorkload(c.file)
+			assert.NoError(t, err)
+			results := analyzeRunningWebhooks(whs, w.template.Labels, offlineNamespaceLabels(c.namespace, c.nsLabels))
+			var out bytes.Buffer
+			printInjectionDecision(&out, w, results)
+			assert.Equal(t, out.String(), c.expected)
+		})
+	}
+}
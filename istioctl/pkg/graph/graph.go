@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/topology"
+)
+
+// Cmd returns the graph command.
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var namespaces, kinds []string
+	var outputFormat string
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the static service graph of the mesh",
+		Long: `Exports a graph of who can talk to whom in the mesh, derived from configuration rather than observed traffic.
+
+Edges are one of:
+  visibility: the workloads can reach the service according to their Sidecar scope
+  route:      a VirtualService routes the service to the destination
+  allow:      an ALLOW AuthorizationPolicy admits the source to the workloads
+  deny:       a DENY AuthorizationPolicy blocks the source from the workloads
+
+Each edge names the Istio resource it was derived from.`,
+		Example: `  # Render the graph of the default namespace with Graphviz
+  istioctl x graph --namespaces default -o dot | dot -Tsvg > mesh.svg
+
+  # Show only authorization edges as a Mermaid flowchart
+  istioctl x graph --kinds allow,deny -o mermaid`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("graph takes no arguments")
+			}
+			if !slices.Contains(topology.Formats, topology.Format(outputFormat)) {
+				return fmt.Errorf("unknown output format %q, must be one of %s", outputFormat, formats())
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			res, err := kubeClient.AllDiscoveryDo(context.Background(), ctx.IstioNamespace(), "debug/topologyz")
+			if err != nil {
+				return err
+			}
+			g, err := parseGraph(res)
+			if err != nil {
+				return err
+			}
+			edgeKinds := make([]topology.EdgeKind, 0, len(kinds))
+			for _, k := range kinds {
+				edgeKinds = append(edgeKinds, topology.EdgeKind(k))
+			}
+			return topology.Write(c.OutOrStdout(), g.Filter(namespaces, edgeKinds), topology.Format(outputFormat))
+		},
+	}
+	opts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringSliceVar(&namespaces, "namespaces", nil,
+		"Only show edges to or from these namespaces. All namespaces are shown when empty")
+	cmd.PersistentFlags().StringSliceVar(&kinds, "kinds", nil,
+		"Only show edges of these kinds: visibility, route, allow or deny. All kinds are shown when empty")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", string(topology.JSON), "Output format: one of "+formats())
+	return cmd
+}
+
+// parseGraph returns the graph reported by one of the istiod instances. All instances compute the graph from the
+// same configuration, so the first one in name order is used.
+func parseGraph(responses map[string][]byte) (*topology.Graph, error) {
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no istiod instance returned a topology")
+	}
+	istiod := slices.Sort(maps.Keys(responses))[0]
+	g := &topology.Graph{}
+	if err := json.Unmarshal(responses[istiod], g); err != nil {
+		return nil, fmt.Errorf("failed to parse topology from %s: %v", istiod, err)
+	}
+	return g, nil
+}
+
+func formats() string {
+	out := make([]string, 0, len(topology.Formats))
+	for _, f := range topology.Formats {
+		out = append(out, string(f))
+	}
+	return strings.Join(out, "|")
+}
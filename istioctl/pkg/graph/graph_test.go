@@ -0,0 +1,47 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/topology"
+)
+
+func TestParseGraph(t *testing.T) {
+	res := map[string][]byte{
+		"istiod-b.istio-system": []byte(`{"nodes":[],"edges":[]}`),
+		"istiod-a.istio-system": []byte(`{"nodes":[{"id":"service:a.default.svc.cluster.local","kind":"service",` +
+			`"name":"a.default.svc.cluster.local","namespace":"default"}],"edges":[]}`),
+	}
+	g, err := parseGraph(res)
+	assert.NoError(t, err)
+	assert.Equal(t, g.Nodes, []topology.Node{{
+		ID:        "service:a.default.svc.cluster.local",
+		Kind:      topology.Service,
+		Name:      "a.default.svc.cluster.local",
+		Namespace: "default",
+	}})
+
+	// Graphs parsed from JSON can still be extended without duplicating nodes.
+	g.AddNode(topology.Node{ID: "service:a.default.svc.cluster.local"})
+	assert.Equal(t, len(g.Nodes), 1)
+
+	_, err = parseGraph(nil)
+	assert.Error(t, err)
+	_, err = parseGraph(map[string][]byte{"istiod": []byte("not json")})
+	assert.Error(t, err)
+}
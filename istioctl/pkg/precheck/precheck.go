@@ -42,6 +42,7 @@ import (
 	pkgversion "istio.io/istio/operator/pkg/version"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/analyzers/deprecation"
 	"istio.io/istio/pkg/config/analysis/analyzers/maturity"
 	"istio.io/istio/pkg/config/analysis/diag"
 	"istio.io/istio/pkg/config/analysis/local"
@@ -60,6 +61,7 @@ var revision string
 func Cmd(ctx cli.Context) *cobra.Command {
 	var opts clioptions.ControlPlaneOptions
 	var skipControlPlane bool
+	var targetVersion string
 	// cmd represents the upgradeCheck command
 	cmd := &cobra.Command{
 		Use:   "precheck",
@@ -69,7 +71,16 @@ func Cmd(ctx cli.Context) *cobra.Command {
   istioctl x precheck
 
   # Check only a single namespace
-  istioctl x precheck --namespace default`,
+  istioctl x precheck --namespace default
+
+  # Also report the configuration whose behavior changes when upgrading to Istio 1.21
+  istioctl x precheck --target-version 1.21`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if skipControlPlane && targetVersion != "" {
+				return fmt.Errorf("--target-version checks the control plane, and cannot be used with --skip-controlplane")
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			cli, err := ctx.CLIClientWithRevision(revision)
 			if err != nil {
@@ -78,7 +89,7 @@ func Cmd(ctx cli.Context) *cobra.Command {
 
 			msgs := diag.Messages{}
 			if !skipControlPlane {
-				msgs, err = checkControlPlane(ctx)
+				msgs, err = checkControlPlane(ctx, targetVersion)
 				if err != nil {
 					return err
 				}
@@ -111,11 +122,14 @@ See %s for more information about causes and resolutions.`, url.ConfigAnalysis)
 		},
 	}
 	cmd.PersistentFlags().BoolVar(&skipControlPlane, "skip-controlplane", false, "skip checking the control plane")
+	cmd.PersistentFlags().StringVar(&targetVersion, "target-version", "",
+		"the Istio version to upgrade to. If set, the deprecated fields, and the feature flags, mesh config defaults and "+
+			"EnvoyFilter targets changing in the versions up to it, are checked against the configuration in the cluster")
 	opts.AttachControlPlaneFlags(cmd)
 	return cmd
 }
 
-func checkControlPlane(ctx cli.Context) (diag.Messages, error) {
+func checkControlPlane(ctx cli.Context, targetVersion string) (diag.Messages, error) {
 	cli, err := ctx.CLIClient()
 	if err != nil {
 		return nil, err
@@ -135,10 +149,20 @@ func checkControlPlane(ctx cli.Context) (diag.Messages, error) {
 	}
 	msgs = append(msgs, gwMsg...)
 
+	analyzers := []analysis.Analyzer{&maturity.AlphaAnalyzer{}}
+	if targetVersion != "" {
+		m, err := checkTargetVersion(cli, ctx.IstioNamespace(), targetVersion)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m...)
+		analyzers = append(analyzers, &deprecation.FieldAnalyzer{})
+	}
+
 	// TODO: add more checks
 
 	sa := local.NewSourceAnalyzer(
-		analysis.Combine("upgrade precheck", &maturity.AlphaAnalyzer{}),
+		analysis.Combine("upgrade precheck", analyzers...),
 		resource.Namespace(ctx.Namespace()),
 		resource.Namespace(ctx.IstioNamespace()),
 		nil,
@@ -0,0 +1,269 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package precheck
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	goversion "github.com/hashicorp/go-version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/analysis/msg"
+	kube3 "istio.io/istio/pkg/config/legacy/source/kube"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/xds"
+	"istio.io/istio/pkg/kube"
+)
+
+// behaviorChange is a default changed by an Istio release.
+type behaviorChange struct {
+	// Version is the minor version changing the default, e.g. 1.21.
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	// Impact describes the behavior with the new default.
+	Impact string `json:"impact"`
+	// Source is the upgrade notes announcing the change.
+	Source string `json:"source"`
+}
+
+// upgradeChanges are the defaults changed by the upcoming releases, with their sources.
+type upgradeChanges struct {
+	// FeatureFlags are the Istiod feature flags, set through environment variables, whose default changes.
+	FeatureFlags []behaviorChange `json:"featureFlags"`
+	// MeshConfig are the mesh config fields, as dot separated paths, whose default changes.
+	MeshConfig []behaviorChange `json:"meshConfig"`
+}
+
+//go:embed upgrade-changes.yaml
+var upgradeChangesYAML []byte
+
+var (
+	// changes are the defaults changed by the upcoming releases, read from upgrade-changes.yaml.
+	changes = func() upgradeChanges {
+		var res upgradeChanges
+		if err := yaml.UnmarshalStrict(upgradeChangesYAML, &res); err != nil {
+			panic(fmt.Sprintf("invalid upgrade-changes.yaml: %v", err))
+		}
+		return res
+	}()
+
+	// deprecatedFilterNamesRemoval is the release removing the support for the deprecated Envoy filter names, like
+	// envoy.router, in EnvoyFilter matches.
+	deprecatedFilterNamesRemoval = "1.21"
+)
+
+// checkTargetVersion reports the configuration whose behavior changes when upgrading the control plane in the
+// Istio namespace to the target version.
+func checkTargetVersion(cli kube.CLIClient, istioNamespace, target string) (diag.Messages, error) {
+	targetVersion, err := goversion.NewVersion(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target version %q: %v", target, err)
+	}
+	return checkUpgradeChanges(cli, istioNamespace, currentVersion(cli, istioNamespace), targetVersion)
+}
+
+// currentVersion returns the lowest version of the control plane, or nil if it is not known.
+func currentVersion(cli kube.CLIClient, istioNamespace string) *goversion.Version {
+	meshInfo, err := cli.GetIstioVersions(context.Background(), istioNamespace)
+	if err != nil {
+		return nil
+	}
+	var res *goversion.Version
+	for _, info := range *meshInfo {
+		v, err := goversion.NewVersion(info.Info.Version)
+		if err != nil {
+			continue
+		}
+		if res == nil || v.LessThan(res) {
+			res = v
+		}
+	}
+	return res
+}
+
+// minorVersion truncates v to its minor version.
+func minorVersion(v *goversion.Version) *goversion.Version {
+	s := v.Segments()
+	res, _ := goversion.NewVersion(fmt.Sprintf("%d.%d", s[0], s[1]))
+	return res
+}
+
+// checkUpgradeChanges reports the configuration relying on a default changed after current, up to target. All the
+// changes up to target are checked if current is nil.
+func checkUpgradeChanges(cli kube.CLIClient, istioNamespace string, current, target *goversion.Version) (diag.Messages, error) {
+	targetMinor := minorVersion(target)
+	upgradesTo := func(version string) bool {
+		v := goversion.Must(goversion.NewVersion(version))
+		return !v.GreaterThan(targetMinor) && (current == nil || v.GreaterThan(minorVersion(current)))
+	}
+	msgs := diag.Messages{}
+
+	m, err := checkFeatureFlagChanges(cli, istioNamespace, upgradesTo)
+	if err != nil {
+		return nil, err
+	}
+	msgs.Add(m...)
+	m, err = checkMeshConfigChanges(cli, istioNamespace, upgradesTo)
+	if err != nil {
+		return nil, err
+	}
+	msgs.Add(m...)
+	m, err = checkEnvoyFilters(cli, current, target, upgradesTo)
+	if err != nil {
+		return nil, err
+	}
+	msgs.Add(m...)
+	return msgs, nil
+}
+
+// checkFeatureFlagChanges reports the Istiod deployments relying on the default of a feature flag which changes.
+func checkFeatureFlagChanges(cli kube.CLIClient, istioNamespace string, upgradesTo func(string) bool) (diag.Messages, error) {
+	deployments, err := cli.Kube().AppsV1().Deployments(istioNamespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: "app=istiod",
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := diag.Messages{}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		env := map[string]bool{}
+		for _, c := range d.Spec.Template.Spec.Containers {
+			if c.Name != "discovery" {
+				continue
+			}
+			for _, e := range c.Env {
+				env[e.Name] = true
+			}
+		}
+		for _, c := range changes.FeatureFlags {
+			if !upgradesTo(c.Version) || env[c.Name] {
+				continue
+			}
+			msgs.Add(msg.NewUpgradeBehaviorChange(clusterResource(gvk.Deployment, d),
+				"feature flag "+c.Name, c.Version,
+				fmt.Sprintf("its default changes from %s to %s, so %s. Set %s=%s in the environment of Istiod to keep the current behavior",
+					c.From, c.To, c.Impact, c.Name, c.From)))
+		}
+	}
+	return msgs, nil
+}
+
+// checkMeshConfigChanges reports the mesh configs relying on the default of a field which changes.
+func checkMeshConfigChanges(cli kube.CLIClient, istioNamespace string, upgradesTo func(string) bool) (diag.Messages, error) {
+	cms, err := cli.Kube().CoreV1().ConfigMaps(istioNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	msgs := diag.Messages{}
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		// The mesh config of the default revision is in the istio ConfigMap, the one of other revisions in istio-<rev>.
+		meshConfig, f := cm.Data["mesh"]
+		if !f || (cm.Name != "istio" && !strings.HasPrefix(cm.Name, "istio-")) {
+			continue
+		}
+		mc := map[string]any{}
+		if err := yaml.Unmarshal([]byte(meshConfig), &mc); err != nil {
+			return nil, fmt.Errorf("invalid mesh config in ConfigMap %s/%s: %v", cm.Namespace, cm.Name, err)
+		}
+		for _, c := range changes.MeshConfig {
+			if _, set := fieldValue(mc, strings.Split(c.Name, ".")); !upgradesTo(c.Version) || set {
+				continue
+			}
+			msgs.Add(msg.NewUpgradeBehaviorChange(clusterResource(gvk.ConfigMap, cm),
+				"mesh config field "+c.Name, c.Version,
+				fmt.Sprintf("its default changes from %s to %s, so %s. Set it to %s in the mesh config to keep the current behavior",
+					c.From, c.To, c.Impact, c.From)))
+		}
+	}
+	return msgs, nil
+}
+
+// fieldValue returns the value of the field at path in m, and whether it is set.
+func fieldValue(m map[string]any, path []string) (any, bool) {
+	v, f := m[path[0]]
+	if !f || len(path) == 1 {
+		return v, f
+	}
+	child, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return fieldValue(child, path[1:])
+}
+
+// checkEnvoyFilters reports the EnvoyFilters matching deprecated filter names whose support is removed, and the
+// ones whose proxyVersion matches the proxies of the current version but not the ones of the target version.
+func checkEnvoyFilters(cli kube.CLIClient, current, target *goversion.Version, upgradesTo func(string) bool) (diag.Messages, error) {
+	efs, err := cli.Istio().NetworkingV1alpha3().EnvoyFilters(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// Proxies report their full version, e.g. 1.21.0.
+	s := target.Segments()
+	targetProxyVersion := fmt.Sprintf("%d.%d.%d", s[0], s[1], s[2])
+	msgs := diag.Messages{}
+	for _, ef := range efs.Items {
+		r := clusterResource(gvk.EnvoyFilter, ef)
+		for i, patch := range ef.Spec.ConfigPatches {
+			filter := patch.GetMatch().GetListener().GetFilterChain().GetFilter()
+			for _, name := range []string{filter.GetName(), filter.GetSubFilter().GetName()} {
+				if canonical, f := xds.ReverseDeprecatedFilterNames[name]; f && upgradesTo(deprecatedFilterNamesRemoval) {
+					msgs.Add(msg.NewUpgradeBehaviorChange(r, fmt.Sprintf("config patch %d", i), deprecatedFilterNamesRemoval,
+						fmt.Sprintf("the deprecated filter name %s no longer matches any filter, use %s instead", name, canonical)))
+				}
+			}
+			proxyVersion := patch.GetMatch().GetProxy().GetProxyVersion()
+			if proxyVersion == "" {
+				continue
+			}
+			re, err := regexp.Compile(proxyVersion)
+			if err != nil {
+				// Reported by the validation of the EnvoyFilter.
+				continue
+			}
+			if re.MatchString(targetProxyVersion) || (current != nil && !re.MatchString(current.String())) {
+				continue
+			}
+			msgs.Add(msg.NewUpgradeBehaviorChange(r, fmt.Sprintf("config patch %d", i), target.Original(),
+				fmt.Sprintf("its proxyVersion %q does not match the proxies of the target version, so the patch is no longer applied once they are upgraded",
+					proxyVersion)))
+		}
+	}
+	return msgs, nil
+}
+
+// clusterResource returns the resource of a cluster object, so that messages refer to it.
+func clusterResource(t config.GroupVersionKind, obj metav1.Object) *resource.Instance {
+	return &resource.Instance{Origin: &kube3.Origin{
+		Type: t,
+		FullName: resource.FullName{
+			Namespace: resource.Namespace(obj.GetNamespace()),
+			Name:      resource.LocalName(obj.GetName()),
+		},
+		ResourceVersion: resource.Version(obj.GetResourceVersion()),
+	}}
+}
@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package precheck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	goversion "github.com/hashicorp/go-version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+	clientnetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config/mesh"
+	pkgenv "istio.io/istio/pkg/env"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+func TestCheckUpgradeChanges(t *testing.T) {
+	cli := kube.NewFakeClient(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "istiod", Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "discovery",
+				Env:  []corev1.EnvVar{{Name: "ENABLE_AUTO_SNI", Value: "false"}},
+			}}}}},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+			Data:       map[string]string{"mesh": "defaultConfig:\n  proxyMetadata: {}\n"},
+		},
+	)
+	_, err := cli.Istio().NetworkingV1alpha3().EnvoyFilters("default").Create(context.Background(), &clientnetworking.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{Name: "router", Namespace: "default"},
+		Spec: networking.EnvoyFilter{ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{{
+			Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+				Proxy: &networking.EnvoyFilter_ProxyMatch{ProxyVersion: `^1\.19.*`},
+				ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{Listener: &networking.EnvoyFilter_ListenerMatch{
+					FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+						Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{Name: "envoy.router"},
+					},
+				}},
+			},
+		}}},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	codes := func(current, target string) []string {
+		t.Helper()
+		var cur *goversion.Version
+		if current != "" {
+			cur = goversion.Must(goversion.NewVersion(current))
+		}
+		msgs, err := checkUpgradeChanges(cli, "istio-system", cur, goversion.Must(goversion.NewVersion(target)))
+		assert.NoError(t, err)
+		var res []string
+		for _, m := range msgs {
+			res = append(res, m.Resource.Origin.FriendlyName()+": "+m.Parameters[0].(string))
+		}
+		return res
+	}
+
+	assert.Equal(t, codes("1.19.3", "1.19.4"), nil)
+	// The feature flag set explicitly is not reported.
+	assert.Equal(t, codes("1.19.3", "1.20"), []string{
+		"Deployment istio-system/istiod: feature flag VERIFY_CERTIFICATE_AT_CLIENT",
+		"EnvoyFilter default/router: config patch 0",
+	})
+	// The changes after the target are not reported.
+	assert.Equal(t, codes("1.20.0", "1.21.1"), []string{
+		"EnvoyFilter default/router: config patch 0",
+	})
+	assert.Equal(t, codes("1.21.0", "1.22"), []string{
+		"Deployment istio-system/istiod: feature flag ENABLE_ENHANCED_RESOURCE_SCOPING",
+		"ConfigMap istio-system/istio: mesh config field defaultConfig.proxyMetadata.ISTIO_DELTA_XDS",
+	})
+	assert.Equal(t, len(codes("", "1.22")), 5)
+}
+
+// TestUpgradeChangesDefaults checks the defaults changed by the upcoming releases are the defaults of this tree, so
+// that upgrade-changes.yaml is updated along with pilot/pkg/features and the mesh config defaults.
+func TestUpgradeChangesDefaults(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(env.IstioSrc, "VERSION"))
+	assert.NoError(t, err)
+	treeVersion := goversion.Must(goversion.NewVersion(strings.TrimSpace(string(b))))
+	// The default of a change already made in this tree is the new one.
+	expected := func(c behaviorChange) string {
+		if goversion.Must(goversion.NewVersion(c.Version)).GreaterThan(treeVersion) {
+			return c.From
+		}
+		return c.To
+	}
+
+	flags := map[string]string{}
+	// The variables of pilot/pkg/features are registered when it is imported.
+	_ = features.DeltaXds
+	for _, v := range pkgenv.VarDescriptions() {
+		flags[v.Name] = v.DefaultValue
+	}
+	for _, c := range changes.FeatureFlags {
+		def, f := flags[c.Name]
+		if !f {
+			t.Errorf("feature flag %s is not defined in pilot/pkg/features", c.Name)
+			continue
+		}
+		assert.Equal(t, def, expected(c), "default of feature flag "+c.Name)
+		assert.Equal(t, c.Source != "", true, "source of feature flag "+c.Name)
+	}
+
+	mc, err := protomarshal.ToJSONMap(mesh.DefaultMeshConfig())
+	assert.NoError(t, err)
+	for _, c := range changes.MeshConfig {
+		path := strings.Split(c.Name, ".")
+		def, f := fieldValue(mc, path)
+		if !f && len(path) == 3 && path[0] == "defaultConfig" && path[1] == "proxyMetadata" {
+			// Proxy metadata is read by the proxies from their environment.
+			def, f = flags[path[2]]
+		}
+		if !f {
+			t.Errorf("mesh config field %s has no default", c.Name)
+			continue
+		}
+		assert.Equal(t, fmt.Sprint(def), expected(c), "default of mesh config field "+c.Name)
+		assert.Equal(t, c.Source != "", true, "source of mesh config field "+c.Name)
+	}
+}
+
+func TestTargetVersionSkipControlPlane(t *testing.T) {
+	cmd := Cmd(cli.NewFakeContext(nil))
+	cmd.SetArgs([]string{"--target-version", "1.21", "--skip-controlplane"})
+	cmd.SilenceUsage = true
+	assert.Error(t, cmd.Execute())
+}
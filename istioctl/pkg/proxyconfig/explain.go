@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyconfig
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/completion"
+	"istio.io/istio/istioctl/pkg/writer/envoy/explain"
+	"istio.io/istio/pkg/kube"
+)
+
+var (
+	explainURL     string
+	explainHeaders []string
+	explainMethod  string
+	explainInbound bool
+)
+
+func explainCmd(ctx cli.Context) *cobra.Command {
+	var podName, podNamespace string
+
+	explainCmd := &cobra.Command{
+		Use:   "explain [<type>/]<name>[.<namespace>]",
+		Short: "Explains how the Envoy in the specified pod handles a request",
+		Long: `Walks a request through the listeners, filter chains, routes and clusters of the Envoy instance in the
+specified pod, and prints each decision along with the Istio resource it was generated from.
+
+The request is matched the same way Envoy does, but only using the config dump: iptables, authorization and
+filters that change the request, such as EnvoyFilter patches adding Lua or Wasm, are not evaluated.`,
+		Example: `  # Explain where a GET to reviews:9080/v2 sent by a pod is routed.
+  istioctl proxy-config explain <pod-name[.namespace]> --url http://reviews:9080/v2
+
+  # Explain a request with a header, matching header based routes.
+  istioctl proxy-config explain <pod-name[.namespace]> --url http://reviews:9080/v2 -H x-user=jason
+
+  # Explain a raw TCP connection to a service IP.
+  istioctl proxy-config explain <pod-name[.namespace]> --url tcp://10.96.10.2:3306
+
+  # Explain a request arriving at the pod from another pod in the mesh.
+  istioctl proxy-config explain <pod-name[.namespace]> --url http://reviews:9080/ --inbound
+
+  # Explain a request without using Kubernetes API
+  ssh <user@hostname> 'curl localhost:15000/config_dump?include_eds=true' > envoy-config.json
+  istioctl proxy-config explain --file envoy-config.json --url http://reviews:9080/v2
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1) != (configDumpFile == "") {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("explain requires pod name or --file parameter")
+			}
+			if explainURL == "" {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("explain requires --url")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			req, err := explain.NewRequest(explainURL, explainHeaders, explainMethod, address, explainInbound)
+			if err != nil {
+				return err
+			}
+			var configWriter *explain.ConfigWriter
+			kubeClient, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				if podName, podNamespace, err = getPodName(ctx, args[0]); err != nil {
+					return err
+				}
+				configWriter, err = setupPodExplainWriter(kubeClient, podName, podNamespace, c.OutOrStdout())
+			} else {
+				configWriter, err = setupFileExplainWriter(configDumpFile, c.OutOrStdout())
+			}
+			if err != nil {
+				return err
+			}
+			switch outputFormat {
+			case summaryOutput, jsonOutput, yamlOutput:
+				return configWriter.PrintExplanation(req, outputFormat)
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completion.ValidPodsNameArgs(cmd, ctx, args, toComplete)
+		},
+	}
+
+	explainCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|yaml|short")
+	explainCmd.PersistentFlags().StringVar(&explainURL, "url", "",
+		"URL of the request, with an http, https or tcp scheme. The port defaults to 80 for http and 443 for https")
+	explainCmd.PersistentFlags().StringSliceVarP(&explainHeaders, "header", "H", nil,
+		"Request header, as name=value. May be repeated. A host header overrides the host of the URL")
+	explainCmd.PersistentFlags().StringVarP(&explainMethod, "method", "X", "GET", "Request method")
+	explainCmd.PersistentFlags().StringVar(&address, "address", "",
+		"Destination IP of the request. Defaults to the host of the URL if it is an IP, otherwise only wildcard listeners match")
+	explainCmd.PersistentFlags().BoolVar(&explainInbound, "inbound", false,
+		"Explain a request arriving at the pod over mTLS from another workload in the mesh")
+	explainCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file")
+
+	return explainCmd
+}
+
+func setupPodExplainWriter(kubeClient kube.CLIClient, podName, podNamespace string, out io.Writer) (*explain.ConfigWriter, error) {
+	debug, err := extractConfigDump(kubeClient, podName, podNamespace, true)
+	if err != nil {
+		return nil, err
+	}
+	return setupExplainWriter(debug, out)
+}
+
+func setupFileExplainWriter(filename string, out io.Writer) (*explain.ConfigWriter, error) {
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return setupExplainWriter(data, out)
+}
+
+func setupExplainWriter(debug []byte, out io.Writer) (*explain.ConfigWriter, error) {
+	cw := &explain.ConfigWriter{Stdout: out}
+	err := cw.Prime(debug)
+	if err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
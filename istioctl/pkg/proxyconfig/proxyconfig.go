@@ -1460,6 +1460,7 @@ func ProxyConfig(ctx cli.Context) *cobra.Command {
 	configCmd.AddCommand(rootCACompareConfigCmd(ctx))
 	configCmd.AddCommand(ecdsConfigCmd(ctx))
 	configCmd.AddCommand(workloadConfigCmd(ctx))
+	configCmd.AddCommand(explainCmd(ctx))
 
 	return configCmd
 }
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/spf13/cobra"
@@ -29,10 +30,17 @@ import (
 	"istio.io/istio/istioctl/pkg/writer/compare"
 	"istio.io/istio/istioctl/pkg/writer/pilot"
 	pilotxds "istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/log"
 )
 
-var configDumpFile string
+var (
+	configDumpFile string
+
+	drift          bool
+	skipConfigDiff bool
+	driftOutput    string
+)
 
 func StatusCommand(ctx cli.Context) *cobra.Command {
 	var opts clioptions.ControlPlaneOptions
@@ -43,6 +51,9 @@ func StatusCommand(ctx cli.Context) *cobra.Command {
 		Long: `
 Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in the mesh
 
+With --drift, every proxy connected to any Istiod instance is grouped by the kind of drift of its configuration:
+rejected (NACKED) with the error reported by the proxy, not acknowledged (STALE), different from the configuration
+generated by Istiod (CONFIG MISMATCH), or running an older Istio version than its Istiod (VERSION SKEW).
 `,
 		Example: `  # Retrieve sync status for all Envoys in a mesh
   istioctl proxy-status
@@ -57,6 +68,12 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
   kubectl port-forward -n istio-system istio-egressgateway-59585c5b9c-ndc59 15000 &
   curl localhost:15000/config_dump > cd.json
   istioctl proxy-status istio-egressgateway-59585c5b9c-ndc59.istio-system --file cd.json
+
+  # Group every proxy connected to any Istiod by how its configuration drifted, with the NACK errors
+  istioctl proxy-status --drift
+
+  # Only use the sync status reported by Istiod, without fetching the config dump of each proxy
+  istioctl proxy-status --drift --skip-config-diff -o json
 `,
 		Aliases: []string{"ps"},
 		Args: func(cmd *cobra.Command, args []string) error {
@@ -64,6 +81,10 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
 				cmd.Println(cmd.UsageString())
 				return fmt.Errorf("--file can only be used when pod-name is specified")
 			}
+			if (len(args) > 0) && drift {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("--drift reports on all proxies and cannot be used when pod-name is specified")
+			}
 			return nil
 		},
 		RunE: func(c *cobra.Command, args []string) error {
@@ -102,6 +123,9 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
 			if err != nil {
 				return err
 			}
+			if drift {
+				return printDrift(c.OutOrStdout(), kubeClient, ctx.IstioNamespace(), statuses)
+			}
 			sw := pilot.StatusWriter{Writer: c.OutOrStdout()}
 			return sw.PrintAll(statuses)
 		},
@@ -110,10 +134,52 @@ Retrieves last sent and last acknowledged xDS sync from Istiod to each Envoy in
 	opts.AttachControlPlaneFlags(statusCmd)
 	statusCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"Envoy config dump JSON file")
+	statusCmd.PersistentFlags().BoolVar(&drift, "drift", false,
+		"Group the proxies connected to every Istiod instance by the kind of drift of their configuration")
+	statusCmd.PersistentFlags().BoolVar(&skipConfigDiff, "skip-config-diff", false,
+		"With --drift, do not compare the configuration of each proxy with the one generated by Istiod")
+	statusCmd.PersistentFlags().StringVarP(&driftOutput, "output", "o", "short",
+		"Output format for --drift: one of json|short")
 
 	return statusCmd
 }
 
+// printDrift reports the drift of every proxy in the syncz responses. Unless skipped, the config dump of each
+// proxy is compared with the one Istiod generated for it, which requires a port forward per proxy.
+func printDrift(w io.Writer, kubeClient kube.CLIClient, istioNamespace string, statuses map[string][]byte) error {
+	versions, err := kubeClient.AllDiscoveryDo(context.TODO(), istioNamespace, "version")
+	if err != nil {
+		return err
+	}
+	dw := pilot.DriftWriter{Writer: w, IstiodVersions: map[string]string{}}
+	for istiod, v := range versions {
+		dw.IstiodVersions[istiod] = strings.TrimSpace(string(v))
+	}
+	if !skipConfigDiff {
+		dw.Diff = func(proxyID string) ([]compare.ResourceDiff, error) {
+			podName, ns, ok := strings.Cut(proxyID, ".")
+			if !ok {
+				return nil, fmt.Errorf("unexpected proxy ID %q", proxyID)
+			}
+			envoyDump, err := kubeClient.EnvoyDo(context.TODO(), podName, ns, "GET", "config_dump")
+			if err != nil {
+				return nil, fmt.Errorf("could not contact sidecar: %v", err)
+			}
+			path := fmt.Sprintf("debug/config_dump?proxyID=%s", proxyID)
+			istiodDumps, err := kubeClient.AllDiscoveryDo(context.TODO(), istioNamespace, path)
+			if err != nil {
+				return nil, err
+			}
+			c, err := compare.NewComparator(io.Discard, istiodDumps, envoyDump)
+			if err != nil {
+				return nil, err
+			}
+			return c.SemanticDiff()
+		}
+	}
+	return dw.PrintAll(statuses, driftOutput)
+}
+
 func readConfigFile(filename string) ([]byte, error) {
 	file := os.Stdin
 	if filename != "-" {
@@ -73,6 +73,10 @@ func TestProxyStatus(t *testing.T) {
 			args:          strings.Split("serviceaccount/sleep", " "),
 			wantException: true,
 		},
+		{ // case 8: --drift reports on all proxies
+			args:          strings.Split("--drift deployment/productpage-v1", " "),
+			wantException: true,
+		},
 	}
 
 	for i, c := range cases {
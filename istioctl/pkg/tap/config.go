@@ -0,0 +1,294 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+)
+
+const (
+	tapFilterType  = "type.googleapis.com/envoy.extensions.filters.http.tap.v3.Tap"
+	routerFilter   = "envoy.filters.http.router"
+	hcmFilter      = "envoy.filters.network.http_connection_manager"
+	managedByLabel = "istioctl.istio.io/managed-by"
+	managedByTap   = "proxy-tap"
+	// tapLabel selects the tapped pod, and only it, in the EnvoyFilter.
+	tapLabel        = "istioctl.istio.io/tap"
+	envoyFilterKind = "EnvoyFilter"
+)
+
+// MatchOptions selects which requests are tapped. Empty fields match everything.
+type MatchOptions struct {
+	// PathPrefix matches requests whose :path starts with the prefix.
+	PathPrefix string
+	// Headers are exact request header matches, all of which must match.
+	Headers map[string]string
+	// ResponseCode matches responses with the given :status. Zero matches any status.
+	ResponseCode int
+	// MaxBodyBytes is the number of request and response body bytes to capture. Zero omits bodies.
+	MaxBodyBytes int
+}
+
+// configID returns the tap config ID used for the given pod. Envoy routes admin /tap requests to the filter
+// instance with the matching ID.
+func configID(pod, namespace string) string {
+	return "istioctl-tap-" + namespace + "-" + pod
+}
+
+// envoyFilterName returns the name of the EnvoyFilter which installs the tap filter for the pod.
+func envoyFilterName(pod string) string {
+	name := "istioctl-tap-" + pod
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return name
+}
+
+// tapLabelValue returns the value of the tapLabel set on the pod, which only the tapped pod carries so the
+// EnvoyFilter does not select the other replicas of its workload.
+func tapLabelValue(pod, namespace string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(namespace + "/" + pod))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// buildEnvoyFilter returns an EnvoyFilter which inserts an admin controlled tap filter in every HTTP filter chain of
+// the pod carrying the tap label. The filter references its configuration through ECDS, so the listeners only carry
+// the reference. The filter is inert until a tap request is sent to the admin API.
+func buildEnvoyFilter(pod, namespace string) *unstructured.Unstructured {
+	id := configID(pod, namespace)
+	filter := func(context string) map[string]any {
+		return map[string]any{
+			"applyTo": "HTTP_FILTER",
+			"match": map[string]any{
+				"context": context,
+				"listener": map[string]any{
+					"filterChain": map[string]any{
+						"filter": map[string]any{
+							"name":      hcmFilter,
+							"subFilter": map[string]any{"name": routerFilter},
+						},
+					},
+				},
+			},
+			"patch": map[string]any{
+				"operation": "INSERT_BEFORE",
+				"value": map[string]any{
+					"name": id,
+					"config_discovery": map[string]any{
+						"config_source": map[string]any{
+							"ads":                   map[string]any{},
+							"initial_fetch_timeout": "0s",
+						},
+						"type_urls": []any{tapFilterType},
+					},
+				},
+			},
+		}
+	}
+	extensionConfig := map[string]any{
+		"applyTo": "EXTENSION_CONFIG",
+		"patch": map[string]any{
+			"operation": "ADD",
+			"value": map[string]any{
+				"name": id,
+				"typed_config": map[string]any{
+					"@type": tapFilterType,
+					"common_config": map[string]any{
+						"admin_config": map[string]any{
+							"config_id": id,
+						},
+					},
+				},
+			},
+		},
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": gvr.EnvoyFilter.GroupVersion().String(),
+		"kind":       envoyFilterKind,
+		"metadata": map[string]any{
+			"name":      envoyFilterName(pod),
+			"namespace": namespace,
+			"labels": map[string]any{
+				managedByLabel: managedByTap,
+			},
+		},
+		"spec": map[string]any{
+			"workloadSelector": map[string]any{
+				"labels": map[string]any{tapLabel: tapLabelValue(pod, namespace)},
+			},
+			"configPatches": []any{
+				extensionConfig,
+				filter("SIDECAR_INBOUND"),
+				filter("SIDECAR_OUTBOUND"),
+				filter("GATEWAY"),
+			},
+		},
+	}}
+}
+
+// buildTapRequest returns the body of an Envoy admin /tap request which streams traces matching opts.
+func buildTapRequest(id string, opts MatchOptions) ([]byte, error) {
+	var rules []any
+	if opts.PathPrefix != "" {
+		rules = append(rules, map[string]any{
+			"http_request_headers_match": map[string]any{
+				"headers": []any{headerMatcher(":path", "prefix", opts.PathPrefix)},
+			},
+		})
+	}
+	if len(opts.Headers) > 0 {
+		var headers []any
+		for _, k := range slices.Sort(maps.Keys(opts.Headers)) {
+			headers = append(headers, headerMatcher(strings.ToLower(k), "exact", opts.Headers[k]))
+		}
+		rules = append(rules, map[string]any{
+			"http_request_headers_match": map[string]any{"headers": headers},
+		})
+	}
+	if opts.ResponseCode != 0 {
+		rules = append(rules, map[string]any{
+			"http_response_headers_match": map[string]any{
+				"headers": []any{headerMatcher(":status", "exact", strconv.Itoa(opts.ResponseCode))},
+			},
+		})
+	}
+	var match map[string]any
+	switch len(rules) {
+	case 0:
+		match = map[string]any{"any_match": true}
+	case 1:
+		match = rules[0].(map[string]any)
+	default:
+		match = map[string]any{"and_match": map[string]any{"rules": rules}}
+	}
+	req := map[string]any{
+		"config_id": id,
+		"tap_config": map[string]any{
+			"match": match,
+			"output_config": map[string]any{
+				"sinks": []any{
+					map[string]any{
+						"format":          "JSON_BODY_AS_STRING",
+						"streaming_admin": map[string]any{},
+					},
+				},
+				"max_buffered_rx_bytes": opts.MaxBodyBytes,
+				"max_buffered_tx_bytes": opts.MaxBodyBytes,
+			},
+		},
+	}
+	return json.Marshal(req)
+}
+
+func headerMatcher(name, kind, value string) map[string]any {
+	return map[string]any{
+		"name":         name,
+		"string_match": map[string]any{kind: value},
+	}
+}
+
+// trace is the subset of envoy.data.tap.v3.TraceWrapper which is printed.
+type trace struct {
+	HTTPBufferedTrace *struct {
+		Request  message `json:"request"`
+		Response message `json:"response"`
+	} `json:"http_buffered_trace"`
+}
+
+type message struct {
+	Headers []header `json:"headers"`
+	Body    *struct {
+		AsString  string `json:"as_string"`
+		AsBytes   string `json:"as_bytes"`
+		Truncated bool   `json:"truncated"`
+	} `json:"body"`
+}
+
+type header struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (m message) header(key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// printTraces decodes the stream of traces in r and writes them to w, until r is exhausted.
+func printTraces(r io.Reader, w io.Writer, raw bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m json.RawMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if raw {
+			fmt.Fprintln(w, string(m))
+			continue
+		}
+		var t trace
+		if err := json.Unmarshal(m, &t); err != nil {
+			return err
+		}
+		if t.HTTPBufferedTrace == nil {
+			continue
+		}
+		writeTrace(w, t.HTTPBufferedTrace.Request, t.HTTPBufferedTrace.Response)
+	}
+}
+
+func writeTrace(w io.Writer, req, resp message) {
+	fmt.Fprintf(w, "%s %s%s -> %s\n", req.header(":method"), req.header(":authority"), req.header(":path"), resp.header(":status"))
+	writeMessage(w, "request", req)
+	writeMessage(w, "response", resp)
+	fmt.Fprintln(w)
+}
+
+func writeMessage(w io.Writer, kind string, m message) {
+	fmt.Fprintf(w, "  %s headers:\n", kind)
+	for _, h := range m.Headers {
+		fmt.Fprintf(w, "    %s: %s\n", h.Key, h.Value)
+	}
+	if m.Body == nil {
+		return
+	}
+	body := m.Body.AsString
+	if body == "" {
+		body = m.Body.AsBytes
+	}
+	if m.Body.Truncated {
+		body += "...(truncated)"
+	}
+	fmt.Fprintf(w, "  %s body:\n    %s\n", kind, body)
+}
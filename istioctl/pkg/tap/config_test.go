@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tap
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestBuildEnvoyFilter(t *testing.T) {
+	ef := buildEnvoyFilter("productpage-v1-abc", "default")
+	assert.Equal(t, ef.GetName(), "istioctl-tap-productpage-v1-abc")
+	assert.Equal(t, ef.GetNamespace(), "default")
+	assert.Equal(t, ef.GetKind(), "EnvoyFilter")
+	assert.Equal(t, ef.GetAPIVersion(), "networking.istio.io/v1alpha3")
+
+	b, err := json.Marshal(ef.Object["spec"])
+	assert.NoError(t, err)
+	spec := string(b)
+	for _, want := range []string{
+		`"workloadSelector":{"labels":{"istioctl.istio.io/tap":"` + tapLabelValue("productpage-v1-abc", "default") + `"}}`,
+		`"applyTo":"EXTENSION_CONFIG"`,
+		`"config_id":"istioctl-tap-default-productpage-v1-abc"`,
+		`"config_discovery":{"config_source":{"ads":{},"initial_fetch_timeout":"0s"},` +
+			`"type_urls":["type.googleapis.com/envoy.extensions.filters.http.tap.v3.Tap"]}`,
+		`"context":"SIDECAR_INBOUND"`,
+		`"context":"SIDECAR_OUTBOUND"`,
+		`"operation":"INSERT_BEFORE"`,
+	} {
+		if !strings.Contains(spec, want) {
+			t.Errorf("spec %s does not contain %s", spec, want)
+		}
+	}
+	// Other pods of the workload get a different label value, so they are not selected.
+	assert.Equal(t, tapLabelValue("productpage-v1-abc", "default") == tapLabelValue("productpage-v1-def", "default"), false)
+}
+
+func TestBuildTapRequest(t *testing.T) {
+	cases := []struct {
+		name string
+		opts MatchOptions
+		want string
+	}{
+		{
+			name: "match all",
+			opts: MatchOptions{},
+			want: `{"config_id":"id","tap_config":{"match":{"any_match":true},"output_config":{"max_buffered_rx_bytes":0,` +
+				`"max_buffered_tx_bytes":0,"sinks":[{"format":"JSON_BODY_AS_STRING","streaming_admin":{}}]}}}`,
+		},
+		{
+			name: "path only",
+			opts: MatchOptions{PathPrefix: "/v2", MaxBodyBytes: 512},
+			want: `{"config_id":"id","tap_config":{"match":{"http_request_headers_match":{"headers":[{"name":":path",` +
+				`"string_match":{"prefix":"/v2"}}]}},"output_config":{"max_buffered_rx_bytes":512,"max_buffered_tx_bytes":512,` +
+				`"sinks":[{"format":"JSON_BODY_AS_STRING","streaming_admin":{}}]}}}`,
+		},
+		{
+			name: "all criteria",
+			opts: MatchOptions{PathPrefix: "/v2", Headers: map[string]string{"X-User": "jason", "a": "b"}, ResponseCode: 503},
+			want: `{"config_id":"id","tap_config":{"match":{"and_match":{"rules":[` +
+				`{"http_request_headers_match":{"headers":[{"name":":path","string_match":{"prefix":"/v2"}}]}},` +
+				`{"http_request_headers_match":{"headers":[{"name":"x-user","string_match":{"exact":"jason"}},` +
+				`{"name":"a","string_match":{"exact":"b"}}]}},` +
+				`{"http_response_headers_match":{"headers":[{"name":":status","string_match":{"exact":"503"}}]}}]}},` +
+				`"output_config":{"max_buffered_rx_bytes":0,"max_buffered_tx_bytes":0,` +
+				`"sinks":[{"format":"JSON_BODY_AS_STRING","streaming_admin":{}}]}}}`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTapRequest("id", tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, string(got), tt.want)
+		})
+	}
+}
+
+func TestPrintTraces(t *testing.T) {
+	stream := `{"http_buffered_trace":{"request":{"headers":[{"key":":method","value":"GET"},` +
+		`{"key":":authority","value":"reviews:9080"},{"key":":path","value":"/v2"}]},` +
+		`"response":{"headers":[{"key":":status","value":"503"}],"body":{"as_string":"upstream conn","truncated":true}}}}
+{"http_buffered_trace":{"request":{"headers":[{"key":":method","value":"POST"},{"key":":path","value":"/"}]},` +
+		`"response":{"headers":[{"key":":status","value":"200"}]}}}
+`
+	var out bytes.Buffer
+	assert.NoError(t, printTraces(strings.NewReader(stream), &out, false))
+	assert.Equal(t, out.String(), `GET reviews:9080/v2 -> 503
+  request headers:
+    :method: GET
+    :authority: reviews:9080
+    :path: /v2
+  response headers:
+    :status: 503
+  response body:
+    upstream conn...(truncated)
+
+POST / -> 200
+  request headers:
+    :method: POST
+    :path: /
+  response headers:
+    :status: 200
+
+`)
+
+	out.Reset()
+	assert.NoError(t, printTraces(strings.NewReader(stream), &out, true))
+	assert.Equal(t, strings.Count(out.String(), "\n"), 2)
+}
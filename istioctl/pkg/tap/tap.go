@@ -0,0 +1,265 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/completion"
+	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	defaultProxyAdminPort = 15000
+	jsonOutput            = "json"
+	summaryOutput         = "short"
+)
+
+var (
+	pathPrefix     string
+	headers        []string
+	responseCode   int
+	maxBodyBytes   int
+	duration       time.Duration
+	readyTimeout   time.Duration
+	outputFormat   string
+	proxyAdminPort int
+)
+
+// Cmd returns the proxy-tap command.
+func Cmd(ctx cli.Context) *cobra.Command {
+	tapCmd := &cobra.Command{
+		Use:   "proxy-tap [<type>/]<name>[.<namespace>]",
+		Short: "Stream HTTP requests and responses seen by the Envoy in the specified pod",
+		Long: `Temporarily installs an Envoy tap filter in the specified pod and streams the matching request and
+response headers, and optionally truncated bodies, through a port-forward to the Envoy admin API.
+
+The pod is labeled with a label unique to it, and the tap filter is added with an EnvoyFilter selecting that
+label, so the other replicas of the workload are not reconfigured. The filter configuration is delivered through
+ECDS. The EnvoyFilter and the label are removed when the command exits.`,
+		Example: `  # Stream all requests handled by a pod
+  istioctl x proxy-tap productpage-v1-7d4f5d6c8c-abcde.default
+
+  # Only show requests to /api whose response code is 503, including up to 1KiB of each body
+  istioctl x proxy-tap deployment/productpage-v1 --path /api --response-code 503 --max-body-bytes 1024
+
+  # Match on a request header and stop after one minute
+  istioctl x proxy-tap productpage-v1-7d4f5d6c8c-abcde -H x-user=jason --duration 1m`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("proxy-tap requires pod name")
+			}
+			if outputFormat != summaryOutput && outputFormat != jsonOutput {
+				return fmt.Errorf("unknown output format %q, must be one of %s|%s", outputFormat, summaryOutput, jsonOutput)
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			podName, podNamespace, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+			if err != nil {
+				return err
+			}
+			opts, err := matchOptions()
+			if err != nil {
+				return err
+			}
+			return runTap(kubeClient, podName, podNamespace, opts, c.OutOrStdout())
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completion.ValidPodsNameArgs(cmd, ctx, args, toComplete)
+		},
+	}
+	tapCmd.PersistentFlags().StringVar(&pathPrefix, "path", "", "Only tap requests whose path starts with this prefix")
+	tapCmd.PersistentFlags().StringSliceVarP(&headers, "header", "H", nil,
+		"Only tap requests with this header, as name=value. May be repeated, all headers must match")
+	tapCmd.PersistentFlags().IntVar(&responseCode, "response-code", 0, "Only tap requests with this response code")
+	tapCmd.PersistentFlags().IntVar(&maxBodyBytes, "max-body-bytes", 0,
+		"Number of request and response body bytes to capture. Bodies are omitted when 0")
+	tapCmd.PersistentFlags().DurationVar(&duration, "duration", 0, "Stop tapping after this long. Runs until interrupted when 0")
+	tapCmd.PersistentFlags().DurationVar(&readyTimeout, "timeout", 30*time.Second,
+		"Maximum time to wait for the tap filter to be installed in the proxy")
+	tapCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	tapCmd.PersistentFlags().IntVar(&proxyAdminPort, "proxy-admin-port", defaultProxyAdminPort, "Envoy proxy admin port")
+	return tapCmd
+}
+
+func matchOptions() (MatchOptions, error) {
+	opts := MatchOptions{PathPrefix: pathPrefix, ResponseCode: responseCode, MaxBodyBytes: maxBodyBytes}
+	if maxBodyBytes < 0 {
+		return opts, fmt.Errorf("--max-body-bytes must not be negative")
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, "=")
+		if !ok || k == "" {
+			return opts, fmt.Errorf("invalid header %q, must be name=value", h)
+		}
+		if opts.Headers == nil {
+			opts.Headers = map[string]string{}
+		}
+		opts.Headers[k] = v
+	}
+	return opts, nil
+}
+
+func runTap(kubeClient kube.CLIClient, podName, podNamespace string, opts MatchOptions, w io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	go cmd.WaitSignalFunc(cancel)
+
+	cleanup, err := installTap(ctx, kubeClient, podName, podNamespace)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	id := configID(podName, podNamespace)
+	fmt.Fprintf(w, "Waiting for the tap filter to be installed in %s.%s...\n", podName, podNamespace)
+	if err := waitForTapFilter(ctx, kubeClient, podName, podNamespace, id); err != nil {
+		return err
+	}
+
+	body, err := buildTapRequest(id, opts)
+	if err != nil {
+		return err
+	}
+	fw, err := kubeClient.NewPortForwarder(podName, podNamespace, "", 0, proxyAdminPort)
+	if err != nil {
+		return err
+	}
+	if err := fw.Start(); err != nil {
+		return fmt.Errorf("failure running port forward process: %v", err)
+	}
+	defer fw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/tap", fw.Address()), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("tap request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("tap request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	fmt.Fprintf(w, "Tapping %s.%s, press Ctrl+C to stop.\n\n", podName, podNamespace)
+	if err := printTraces(resp.Body, w, outputFormat == jsonOutput); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// installTap creates the tap EnvoyFilter of the pod, then labels the pod so the filter applies to it. The returned
+// function deletes the filter and removes the label. The filter name is unique per pod, so it cannot be created while
+// another session taps the pod, whose label is left untouched.
+func installTap(ctx context.Context, kubeClient kube.CLIClient, podName, podNamespace string) (func(), error) {
+	ef := buildEnvoyFilter(podName, podNamespace)
+	filters := kubeClient.Dynamic().Resource(gvr.EnvoyFilter).Namespace(podNamespace)
+	if _, err := filters.Create(ctx, ef, metav1.CreateOptions{}); err != nil {
+		if kerrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("pod %s.%s is already being tapped, EnvoyFilter %s exists", podName, podNamespace, ef.GetName())
+		}
+		return nil, fmt.Errorf("failed to create EnvoyFilter %s.%s: %v", ef.GetName(), podNamespace, err)
+	}
+	pods := kubeClient.Kube().CoreV1().Pods(podNamespace)
+	// ctx may already be canceled, so cleanup gets its own deadline. The label is removed once the EnvoyFilter is gone.
+	deleteFilter := func(ctx context.Context) {
+		if err := filters.Delete(ctx, ef.GetName(), metav1.DeleteOptions{}); err != nil {
+			log.Warnf("failed to delete EnvoyFilter %s.%s, remove it manually: %v", ef.GetName(), podNamespace, err)
+		}
+	}
+	if err := patchTapLabel(ctx, pods, podName, tapLabelValue(podName, podNamespace)); err != nil {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		deleteFilter(cleanupCtx)
+		return nil, fmt.Errorf("failed to label pod %s.%s: %v", podName, podNamespace, err)
+	}
+	return func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		deleteFilter(cleanupCtx)
+		if err := patchTapLabel(cleanupCtx, pods, podName, nil); err != nil {
+			log.Warnf("failed to remove label %s from pod %s.%s, remove it manually: %v", tapLabel, podName, podNamespace, err)
+		}
+	}, nil
+}
+
+// patchTapLabel sets the tap label of the pod to value, or removes it when value is nil.
+func patchTapLabel(ctx context.Context, pods corev1client.PodInterface, podName string, value any) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]any{tapLabel: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = pods.Patch(ctx, podName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// waitForTapFilter waits until the proxy has received the configuration of the tap filter with the given config ID
+// through ECDS.
+func waitForTapFilter(ctx context.Context, kubeClient kube.CLIClient, podName, podNamespace, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		dump, err := kubeClient.EnvoyDoWithPort(ctx, podName, podNamespace, "GET", "config_dump?resource=ecds_filters",
+			proxyAdminPort)
+		if err == nil && bytes.Contains(dump, []byte(id)) {
+			return nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("tap filter was not installed in %s.%s: %v", podName, podNamespace, lastErr)
+			}
+			return fmt.Errorf("tap filter was not installed in %s.%s within %v", podName, podNamespace, readyTimeout)
+		case <-ticker.C:
+		}
+	}
+}
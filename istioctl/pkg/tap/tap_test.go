@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestInstallTap(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "productpage-v1-abc", Namespace: "default"}}
+	podLabel := func(c kube.CLIClient) string {
+		p, err := c.Kube().CoreV1().Pods("default").Get(ctx, pod.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		return p.Labels[tapLabel]
+	}
+	getFilter := func(c kube.CLIClient) error {
+		_, err := c.Dynamic().Resource(gvr.EnvoyFilter).Namespace("default").Get(ctx, "istioctl-tap-"+pod.Name, metav1.GetOptions{})
+		return err
+	}
+
+	t.Run("install and cleanup", func(t *testing.T) {
+		c := kube.NewFakeClient(pod)
+		cleanup, err := installTap(ctx, c, pod.Name, pod.Namespace)
+		assert.NoError(t, err)
+		assert.NoError(t, getFilter(c))
+		assert.Equal(t, podLabel(c), tapLabelValue(pod.Name, pod.Namespace))
+
+		cleanup()
+		assert.Equal(t, kerrors.IsNotFound(getFilter(c)), true)
+		assert.Equal(t, podLabel(c), "")
+	})
+
+	t.Run("already tapped", func(t *testing.T) {
+		c := kube.NewFakeClient(pod)
+		cleanup, err := installTap(ctx, c, pod.Name, pod.Namespace)
+		assert.NoError(t, err)
+		defer cleanup()
+
+		// A concurrent session fails without touching the filter and label of the first one.
+		_, err = installTap(ctx, c, pod.Name, pod.Namespace)
+		assert.Error(t, err)
+		if !strings.Contains(err.Error(), "already being tapped") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assert.NoError(t, getFilter(c))
+		assert.Equal(t, podLabel(c), tapLabelValue(pod.Name, pod.Namespace))
+	})
+}
@@ -0,0 +1,421 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/label"
+	revtag "istio.io/istio/istioctl/pkg/tag"
+	analyzer_util "istio.io/istio/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/slices"
+)
+
+// canaryUpgrade moves the workloads of the mesh from one control plane revision to another. Every step is
+// checkpointed in the cluster so an interrupted upgrade is resumed where it stopped.
+type canaryUpgrade struct {
+	client         kubernetes.Interface
+	istioNamespace string
+	from, to       string
+	// tags restricts the revision tags moved to the new revision. By default, every tag of the previous revision
+	// is moved.
+	tags []string
+	// keepPrevious stops the upgrade before removing the previous revision.
+	keepPrevious bool
+	// timeout is the time a migrated namespace has to become healthy before the upgrade is rolled back.
+	timeout  time.Duration
+	interval time.Duration
+	out      io.Writer
+
+	install   func() error
+	uninstall func() error
+	setTag    func(tag, revision string) error
+	checks    []healthCheck
+	// previousProxies returns the proxies still connected to the previous revision, which is only removed once
+	// there are none.
+	previousProxies func(ctx context.Context) ([]string, error)
+}
+
+func (c *canaryUpgrade) run(ctx context.Context) error {
+	st, err := loadState(ctx, c.client, c.istioNamespace)
+	if err != nil {
+		return err
+	}
+	switch {
+	case st != nil && st.Phase == PhaseComplete && st.From == c.from && st.To == c.to:
+		fmt.Fprintf(c.out, "The canary upgrade from revision %q to %q is already complete.\n", c.from, c.to)
+		return nil
+	case st == nil || st.Phase == PhaseComplete || st.Phase == PhaseRolledBack:
+		whs, err := revtag.GetWebhooksWithRevision(ctx, c.client, c.from)
+		if err != nil {
+			return err
+		}
+		if len(whs) == 0 {
+			return fmt.Errorf("revision %q is not installed", c.from)
+		}
+		st = &State{From: c.from, To: c.to, Phase: PhaseInstall}
+	case st.From != c.from || st.To != c.to:
+		return fmt.Errorf("the canary upgrade from revision %q to %q is in progress, resume it or discard it with --reset",
+			st.From, st.To)
+	default:
+		fmt.Fprintf(c.out, "Resuming the canary upgrade from revision %q to %q at phase %s.\n", c.from, c.to, st.Phase)
+	}
+
+	steps := map[Phase]func(context.Context, *State) error{
+		PhaseInstall:           c.installRevision,
+		PhasePlan:              c.planMigration,
+		PhaseMigrateNamespaces: c.migrateNamespaces,
+		PhaseVerify:            c.verify,
+		PhaseMoveTags:          c.moveTags,
+		PhaseRemovePrevious:    c.removePrevious,
+	}
+	start := -1
+	for i, p := range phases {
+		if p == st.Phase {
+			start = i
+		}
+	}
+	if start < 0 {
+		return fmt.Errorf("unknown canary upgrade phase %q, discard the upgrade with --reset", st.Phase)
+	}
+	for i := start; phases[i] != PhaseComplete; i++ {
+		if err := c.save(ctx, st); err != nil {
+			return err
+		}
+		if err := steps[phases[i]](ctx, st); err != nil {
+			return err
+		}
+		st.Phase = phases[i+1]
+	}
+	if err := c.save(ctx, st); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "The canary upgrade from revision %q to %q is complete.\n", c.from, c.to)
+	return nil
+}
+
+func (c *canaryUpgrade) save(ctx context.Context, st *State) error {
+	return saveState(ctx, c.client, c.istioNamespace, st)
+}
+
+func (c *canaryUpgrade) installRevision(_ context.Context, st *State) error {
+	fmt.Fprintf(c.out, "Installing revision %q.\n", st.To)
+	if err := c.install(); err != nil {
+		return fmt.Errorf("failed to install revision %q: %v", st.To, err)
+	}
+	return nil
+}
+
+// planMigration records the tags to move and the namespaces and workloads to migrate in the state. A namespace
+// labeled with istio-injection or istio.io/rev selects the revision of its pods; in other namespaces, each pod
+// selects its revision with its own labels, like the gateways of the Istio namespace.
+func (c *canaryUpgrade) planMigration(ctx context.Context, st *State) error {
+	whs, err := revtag.GetTagWebhooks(ctx, c.client)
+	if err != nil {
+		return err
+	}
+	st.Tags = map[string]string{}
+	for _, wh := range whs {
+		tag, _ := revtag.GetWebhookTagName(wh)
+		rev, _ := revtag.GetWebhookRevision(wh)
+		if rev == st.From && (len(c.tags) == 0 || slices.Contains(c.tags, tag)) {
+			st.Tags[tag] = rev
+		}
+	}
+	for _, tag := range c.tags {
+		if _, f := st.Tags[tag]; !f {
+			return fmt.Errorf("revision tag %q does not point to revision %q", tag, st.From)
+		}
+	}
+	// selects returns whether a revision label selects the previous revision, and the tag it selects it through.
+	selects := func(rev string) (bool, string) {
+		if _, tagged := st.Tags[rev]; tagged {
+			return true, rev
+		}
+		return rev == st.From, ""
+	}
+
+	namespaces, err := c.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	workloads, err := listWorkloads(ctx, c.client, metav1.NamespaceAll)
+	if err != nil {
+		return err
+	}
+	byNamespace := map[string][]workload{}
+	for _, w := range workloads {
+		if w.template.Labels[label.SidecarInject.Name] == "false" {
+			continue
+		}
+		byNamespace[w.namespace] = append(byNamespace[w.namespace], w)
+	}
+
+	st.Namespaces = nil
+	for _, ns := range namespaces.Items {
+		nsState := NamespaceState{Name: ns.Name}
+		rev, revLabeled := ns.Labels[label.IoIstioRev.Name]
+		injection, injectionLabeled := ns.Labels[analyzer_util.InjectionLabelName]
+		switch {
+		case injectionLabeled:
+			// The istio-injection label takes precedence over istio.io/rev, and only selects the default tag.
+			if _, defaultTagged := st.Tags[revtag.DefaultRevisionName]; !defaultTagged || injection != "enabled" {
+				continue
+			}
+			nsState.Selected, nsState.Tag, nsState.Injection = true, revtag.DefaultRevisionName, true
+		case revLabeled:
+			selected, tag := selects(rev)
+			if !selected {
+				continue
+			}
+			nsState.Selected, nsState.Tag = true, tag
+		}
+		for _, w := range byNamespace[ns.Name] {
+			if nsState.Selected {
+				nsState.Workloads = append(nsState.Workloads, WorkloadState{Kind: w.kind, Name: w.name})
+				continue
+			}
+			podRev, podRevLabeled := w.template.Labels[label.IoIstioRev.Name]
+			selected, tag := selects(podRev)
+			if !podRevLabeled {
+				// Pods opting in to injection without a revision are injected by the default tag.
+				_, defaultTagged := st.Tags[revtag.DefaultRevisionName]
+				selected, tag = defaultTagged && w.template.Labels[label.SidecarInject.Name] == "true", revtag.DefaultRevisionName
+			}
+			if selected {
+				nsState.Workloads = append(nsState.Workloads, WorkloadState{Kind: w.kind, Name: w.name, Relabel: true, Revision: podRev, Tag: tag})
+			}
+		}
+		if nsState.Selected || len(nsState.Workloads) > 0 {
+			st.Namespaces = append(st.Namespaces, nsState)
+		}
+	}
+	sort.Slice(st.Namespaces, func(i, j int) bool {
+		return st.Namespaces[i].Name < st.Namespaces[j].Name
+	})
+	return nil
+}
+
+// migrateNamespaces moves the namespaces to the new revision one at a time, by selecting the new revision by its
+// name, as the revision tags are only moved once every namespace is verified. Each namespace must be healthy before
+// the next one is migrated, otherwise the upgrade is rolled back.
+func (c *canaryUpgrade) migrateNamespaces(ctx context.Context, st *State) error {
+	for i := range st.Namespaces {
+		ns := &st.Namespaces[i]
+		if ns.Migrated {
+			continue
+		}
+		fmt.Fprintf(c.out, "Migrating namespace %s to revision %q.\n", ns.Name, st.To)
+		if ns.Selected {
+			if err := setNamespaceRevision(ctx, c.client, ns.Name, st.To, false); err != nil {
+				return c.rollback(ctx, st, ns.Name, nil, err)
+			}
+		}
+		for _, w := range ns.Workloads {
+			relabel := ptr.Of(st.To)
+			if !w.Relabel {
+				relabel = nil
+			}
+			if err := restartWorkload(ctx, c.client, ns.Name, w, relabel); err != nil {
+				return c.rollback(ctx, st, ns.Name, nil, err)
+			}
+		}
+		if err := c.waitHealthy(ctx, *ns); err != nil {
+			return c.rollback(ctx, st, ns.Name, nil, fmt.Errorf("namespace %s is not healthy: %v", ns.Name, err))
+		}
+		ns.Migrated = true
+		if err := c.save(ctx, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitHealthy waits for every health check to pass on a namespace, and returns the last failure on timeout.
+func (c *canaryUpgrade) waitHealthy(ctx context.Context, ns NamespaceState) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	for {
+		err := c.check(ctx, ns)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func (c *canaryUpgrade) check(ctx context.Context, ns NamespaceState) error {
+	for _, check := range c.checks {
+		if err := check(ctx, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks the migrated namespaces are still healthy before the revision tags are moved. A failure stops the
+// upgrade without rolling it back, as every namespace was healthy once; running the upgrade again resumes it.
+func (c *canaryUpgrade) verify(ctx context.Context, st *State) error {
+	fmt.Fprintf(c.out, "Verifying the migrated namespaces.\n")
+	for _, ns := range st.Namespaces {
+		if err := c.check(ctx, ns); err != nil {
+			return fmt.Errorf("namespace %s is not healthy: %v; run the upgrade again to resume it", ns.Name, err)
+		}
+	}
+	return nil
+}
+
+// moveTags points the revision tags of the previous revision to the new one, now that the namespaces using them
+// are verified, and sets the labels selecting the new revision during the migration back to the tags. Relabeled
+// pod templates are restarted, and must be healthy again before the next tag is moved. If a tag cannot be moved or a
+// namespace is not healthy once it moved, the upgrade is rolled back, moving the tags back to the previous revision.
+func (c *canaryUpgrade) moveTags(ctx context.Context, st *State) error {
+	// The tags are moved in order, so on resume the tags moved before the interruption are moved again and rolled
+	// back along with the others on failure.
+	var moved []string
+	for _, tag := range slices.Sort(maps.Keys(st.Tags)) {
+		fmt.Fprintf(c.out, "Moving revision tag %q from revision %q to %q.\n", tag, st.Tags[tag], st.To)
+		moved = append(moved, tag)
+		if err := c.setTag(tag, st.To); err != nil {
+			return c.rollback(ctx, st, "", moved, fmt.Errorf("failed to move revision tag %q: %v", tag, err))
+		}
+		for _, ns := range st.Namespaces {
+			restarted := false
+			if ns.Selected && ns.Tag == tag {
+				if err := setNamespaceRevision(ctx, c.client, ns.Name, tag, ns.Injection); err != nil {
+					return c.rollback(ctx, st, "", moved, err)
+				}
+			}
+			for _, w := range ns.Workloads {
+				if !w.Relabel || w.Tag != tag {
+					continue
+				}
+				if err := restartWorkload(ctx, c.client, ns.Name, w, ptr.Of(w.Revision)); err != nil {
+					return c.rollback(ctx, st, "", moved, err)
+				}
+				restarted = true
+			}
+			if !restarted {
+				continue
+			}
+			if err := c.waitHealthy(ctx, ns); err != nil {
+				return c.rollback(ctx, st, "", moved, fmt.Errorf("namespace %s is not healthy: %v", ns.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// removePrevious removes the previous revision once no proxy is connected to it anymore.
+func (c *canaryUpgrade) removePrevious(ctx context.Context, st *State) error {
+	if c.keepPrevious {
+		fmt.Fprintf(c.out, "Keeping revision %q, remove it with `istioctl uninstall --revision %s`.\n", st.From, st.From)
+		return nil
+	}
+	proxies, err := c.previousProxies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the proxies connected to revision %q: %v", st.From, err)
+	}
+	if len(proxies) > 0 {
+		return fmt.Errorf("%d proxies are still connected to revision %q: %s; migrate them and run the upgrade again to "+
+			"remove it", len(proxies), st.From, strings.Join(proxies, ", "))
+	}
+	fmt.Fprintf(c.out, "Removing revision %q.\n", st.From)
+	if err := c.uninstall(); err != nil {
+		return fmt.Errorf("failed to remove revision %q: %v", st.From, err)
+	}
+	return nil
+}
+
+// rollback points the moved revision tags back to the previous revision, then selects the previous revision again
+// in the namespaces migrated so far, including the failed one, and restarts their workloads so they are injected
+// with the proxy of the previous revision again.
+func (c *canaryUpgrade) rollback(ctx context.Context, st *State, failed string, movedTags []string, cause error) error {
+	fmt.Fprintf(c.out, "The canary upgrade failed: %v\nRolling back to revision %q.\n", cause, st.From)
+	var errs error
+	for _, tag := range movedTags {
+		fmt.Fprintf(c.out, "Moving revision tag %q back to revision %q.\n", tag, st.Tags[tag])
+		if err := c.setTag(tag, st.Tags[tag]); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to move revision tag %q back: %v", tag, err))
+		}
+	}
+	for _, ns := range st.Namespaces {
+		if !ns.Migrated && ns.Name != failed {
+			continue
+		}
+		if ns.Selected {
+			rev := ns.Tag
+			if rev == "" {
+				rev = st.From
+			}
+			if err := setNamespaceRevision(ctx, c.client, ns.Name, rev, ns.Injection); err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+		}
+		for _, w := range ns.Workloads {
+			var relabel *string
+			if w.Relabel {
+				relabel = ptr.Of(w.Revision)
+			}
+			if err := restartWorkload(ctx, c.client, ns.Name, w, relabel); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	st.Phase = PhaseRolledBack
+	st.Error = cause.Error()
+	if err := c.save(ctx, st); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if errs != nil {
+		return fmt.Errorf("%v; rollback to revision %q failed: %v", cause, st.From, errs)
+	}
+	return fmt.Errorf("%v; rolled back to revision %q", cause, st.From)
+}
+
+// setNamespaceRevision labels a namespace with a revision. If injection is set, the namespace is labeled with
+// istio-injection=enabled instead, which selects the default tag.
+func setNamespaceRevision(ctx context.Context, client kubernetes.Interface, namespace, revision string, injection bool) error {
+	labels := map[string]any{label.IoIstioRev.Name: revision, analyzer_util.InjectionLabelName: nil}
+	if injection {
+		labels = map[string]any{label.IoIstioRev.Name: nil, analyzer_util.InjectionLabelName: "enabled"}
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": labels}})
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Namespaces().Patch(ctx, namespace, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to label namespace %s with revision %q: %v", namespace, revision, err)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	admitv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+type fakeUpgrade struct {
+	*canaryUpgrade
+	tagMoves    []string
+	checked     []string
+	proxies     []string
+	uninstalled bool
+}
+
+func newFakeUpgrade() *fakeUpgrade {
+	objects := []runtime.Object{
+		webhook("istio-sidecar-injector-1-0", map[string]string{"istio.io/rev": "1-0"}),
+		webhook("istio-revision-tag-default", map[string]string{"istio.io/rev": "1-0", "istio.io/tag": "default"}),
+		webhook("istio-revision-tag-prod", map[string]string{"istio.io/rev": "1-0", "istio.io/tag": "prod"}),
+		webhook("istio-revision-tag-other", map[string]string{"istio.io/rev": "0-9", "istio.io/tag": "other"}),
+		namespace("relabeled", map[string]string{"istio.io/rev": "1-0"}),
+		namespace("tagged", map[string]string{"istio.io/rev": "prod"}),
+		namespace("injected", map[string]string{"istio-injection": "enabled"}),
+		namespace("disabled", map[string]string{"istio-injection": "disabled", "istio.io/rev": "1-0"}),
+		namespace("other", map[string]string{"istio.io/rev": "other"}),
+		namespace("unrelated", nil),
+		namespace("istio-system", nil),
+		deployment("relabeled", "app", nil),
+		deployment("tagged", "app", nil),
+		deployment("disabled", "app", nil),
+		// The label of the namespace takes precedence over the label of the pod.
+		deployment("other", "app", map[string]string{"istio.io/rev": "1-0"}),
+		deployment("unrelated", "app", nil),
+		deployment("unrelated", "canary", map[string]string{"istio.io/rev": "prod"}),
+		deployment("unrelated", "optin", map[string]string{"sidecar.istio.io/inject": "true"}),
+		deployment("istio-system", "istiod-1-0", map[string]string{"istio.io/rev": "1-0", "sidecar.istio.io/inject": "false"}),
+		deployment("istio-system", "ingressgateway", map[string]string{"istio.io/rev": "1-0"}),
+	}
+	f := &fakeUpgrade{}
+	f.canaryUpgrade = &canaryUpgrade{
+		client:         fake.NewSimpleClientset(objects...),
+		istioNamespace: "istio-system",
+		from:           "1-0",
+		to:             "1-1",
+		timeout:        100 * time.Millisecond,
+		interval:       10 * time.Millisecond,
+		out:            io.Discard,
+		install:        func() error { return nil },
+		uninstall: func() error {
+			f.uninstalled = true
+			return nil
+		},
+		setTag: func(tag, revision string) error {
+			f.tagMoves = append(f.tagMoves, tag+"="+revision)
+			return nil
+		},
+		previousProxies: func(context.Context) ([]string, error) {
+			return f.proxies, nil
+		},
+	}
+	f.checks = []healthCheck{func(_ context.Context, ns NamespaceState) error {
+		f.checked = append(f.checked, ns.Name)
+		return nil
+	}}
+	return f
+}
+
+func webhook(name string, labels map[string]string) *admitv1.MutatingWebhookConfiguration {
+	return &admitv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func deployment(namespace, name string, podLabels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: podLabels}},
+		},
+	}
+}
+
+func namespaceLabels(t *testing.T, client kubernetes.Interface, name string) map[string]string {
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	return ns.Labels
+}
+
+func podRevision(t *testing.T, client kubernetes.Interface, namespace, name string) (string, bool) {
+	d, err := client.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, restarted := d.Spec.Template.Annotations[restartedAtAnnotation]
+	return d.Spec.Template.Labels["istio.io/rev"], restarted
+}
+
+func TestCanaryUpgrade(t *testing.T) {
+	f := newFakeUpgrade()
+	assert.NoError(t, f.run(context.Background()))
+
+	assert.Equal(t, f.tagMoves, []string{"default=1-1", "prod=1-1"})
+	namespaces := []string{"injected", "istio-system", "relabeled", "tagged", "unrelated"}
+	// Every namespace is checked once migrated, and verified before the tags are moved. The workloads relabeled
+	// with a tag are checked again once the tag is moved.
+	assert.Equal(t, f.checked, append(append(namespaces, namespaces...), "unrelated", "unrelated"))
+	assert.Equal(t, namespaceLabels(t, f.client, "relabeled"), map[string]string{"istio.io/rev": "1-1"})
+	assert.Equal(t, namespaceLabels(t, f.client, "tagged"), map[string]string{"istio.io/rev": "prod"})
+	assert.Equal(t, namespaceLabels(t, f.client, "injected"), map[string]string{"istio-injection": "enabled"})
+	assert.Equal(t, f.uninstalled, true)
+
+	for _, tt := range []struct {
+		namespace, name string
+		revision        string
+		restarted       bool
+	}{
+		{"relabeled", "app", "", true},
+		{"tagged", "app", "", true},
+		{"unrelated", "app", "", false},
+		{"unrelated", "canary", "prod", true},
+		{"unrelated", "optin", "", true},
+		{"istio-system", "ingressgateway", "1-1", true},
+		{"istio-system", "istiod-1-0", "1-0", false},
+		{"other", "app", "1-0", false},
+		{"disabled", "app", "", false},
+	} {
+		rev, restarted := podRevision(t, f.client, tt.namespace, tt.name)
+		assert.Equal(t, rev, tt.revision, tt.namespace+"/"+tt.name)
+		assert.Equal(t, restarted, tt.restarted, tt.namespace+"/"+tt.name)
+	}
+
+	st, err := loadState(context.Background(), f.client, "istio-system")
+	assert.NoError(t, err)
+	assert.Equal(t, st.Phase, PhaseComplete)
+	assert.Equal(t, st.Tags, map[string]string{"default": "1-0", "prod": "1-0"})
+	assert.Equal(t, st.Namespaces, []NamespaceState{
+		{Name: "injected", Selected: true, Tag: "default", Injection: true, Migrated: true},
+		{
+			Name:      "istio-system",
+			Workloads: []WorkloadState{{Kind: "Deployment", Name: "ingressgateway", Relabel: true, Revision: "1-0"}},
+			Migrated:  true,
+		},
+		{Name: "relabeled", Selected: true, Workloads: []WorkloadState{{Kind: "Deployment", Name: "app"}}, Migrated: true},
+		{Name: "tagged", Selected: true, Tag: "prod", Workloads: []WorkloadState{{Kind: "Deployment", Name: "app"}}, Migrated: true},
+		{
+			Name: "unrelated",
+			Workloads: []WorkloadState{
+				{Kind: "Deployment", Name: "canary", Relabel: true, Revision: "prod", Tag: "prod"},
+				{Kind: "Deployment", Name: "optin", Relabel: true, Tag: "default"},
+			},
+			Migrated: true,
+		},
+	})
+
+	// Running it again is a no-op.
+	f.tagMoves = nil
+	assert.NoError(t, f.run(context.Background()))
+	assert.Equal(t, f.tagMoves, nil)
+}
+
+func TestCanaryUpgradeRollback(t *testing.T) {
+	f := newFakeUpgrade()
+	f.keepPrevious = true
+	f.checks = append(f.checks, func(_ context.Context, ns NamespaceState) error {
+		if ns.Name == "tagged" {
+			return fmt.Errorf("proxy of pod app is not connected to revision \"1-1\"")
+		}
+		return nil
+	})
+	err := f.run(context.Background())
+	assert.Error(t, err)
+
+	// The tags are only moved once every namespace is verified.
+	assert.Equal(t, f.tagMoves, nil)
+	assert.Equal(t, namespaceLabels(t, f.client, "relabeled"), map[string]string{"istio.io/rev": "1-0"})
+	assert.Equal(t, namespaceLabels(t, f.client, "tagged"), map[string]string{"istio.io/rev": "prod"})
+	assert.Equal(t, namespaceLabels(t, f.client, "injected"), map[string]string{"istio-injection": "enabled"})
+	rev, _ := podRevision(t, f.client, "istio-system", "ingressgateway")
+	assert.Equal(t, rev, "1-0")
+	// The namespace after the failed one was not migrated.
+	_, restarted := podRevision(t, f.client, "unrelated", "canary")
+	assert.Equal(t, restarted, false)
+	assert.Equal(t, f.uninstalled, false)
+	st, err := loadState(context.Background(), f.client, "istio-system")
+	assert.NoError(t, err)
+	assert.Equal(t, st.Phase, PhaseRolledBack)
+	assert.Equal(t, st.Error, "namespace tagged is not healthy: proxy of pod app is not connected to revision \"1-1\"")
+}
+
+func TestCanaryUpgradeMoveTagsRollback(t *testing.T) {
+	f := newFakeUpgrade()
+	f.checks = append(f.checks, func(_ context.Context, ns NamespaceState) error {
+		if ns.Name == "unrelated" && slices.Contains(f.tagMoves, "prod=1-1") {
+			return fmt.Errorf("proxy of pod canary is not connected to revision \"1-1\"")
+		}
+		return nil
+	})
+	err := f.run(context.Background())
+	assert.Error(t, err)
+
+	// The tags moved so far are moved back to the previous revision.
+	assert.Equal(t, f.tagMoves, []string{"default=1-1", "prod=1-1", "default=1-0", "prod=1-0"})
+	assert.Equal(t, namespaceLabels(t, f.client, "relabeled"), map[string]string{"istio.io/rev": "1-0"})
+	assert.Equal(t, namespaceLabels(t, f.client, "tagged"), map[string]string{"istio.io/rev": "prod"})
+	assert.Equal(t, namespaceLabels(t, f.client, "injected"), map[string]string{"istio-injection": "enabled"})
+	for _, tt := range []struct {
+		namespace, name string
+		revision        string
+	}{
+		{"istio-system", "ingressgateway", "1-0"},
+		{"unrelated", "canary", "prod"},
+		{"unrelated", "optin", ""},
+	} {
+		rev, _ := podRevision(t, f.client, tt.namespace, tt.name)
+		assert.Equal(t, rev, tt.revision, tt.namespace+"/"+tt.name)
+	}
+	assert.Equal(t, f.uninstalled, false)
+	st, err := loadState(context.Background(), f.client, "istio-system")
+	assert.NoError(t, err)
+	assert.Equal(t, st.Phase, PhaseRolledBack)
+	assert.Equal(t, st.Error, "namespace unrelated is not healthy: proxy of pod canary is not connected to revision \"1-1\"")
+
+	// A tag that fails to move is moved back as well.
+	f = newFakeUpgrade()
+	f.setTag = func(tag, revision string) error {
+		if tag == "prod" && revision == "1-1" {
+			return fmt.Errorf("webhook not found")
+		}
+		f.tagMoves = append(f.tagMoves, tag+"="+revision)
+		return nil
+	}
+	assert.Error(t, f.run(context.Background()))
+	assert.Equal(t, f.tagMoves, []string{"default=1-1", "default=1-0", "prod=1-0"})
+	rev, _ := podRevision(t, f.client, "unrelated", "optin")
+	assert.Equal(t, rev, "")
+	st, err = loadState(context.Background(), f.client, "istio-system")
+	assert.NoError(t, err)
+	assert.Equal(t, st.Phase, PhaseRolledBack)
+}
+
+func TestCanaryUpgradeResume(t *testing.T) {
+	f := newFakeUpgrade()
+	assert.NoError(t, saveState(context.Background(), f.client, "istio-system", &State{
+		From:  "1-0",
+		To:    "1-1",
+		Phase: PhaseMigrateNamespaces,
+		Tags:  map[string]string{"prod": "1-0"},
+		Namespaces: []NamespaceState{
+			{Name: "relabeled", Selected: true, Workloads: []WorkloadState{{Kind: "Deployment", Name: "app"}}, Migrated: true},
+			{Name: "tagged", Selected: true, Tag: "prod", Workloads: []WorkloadState{{Kind: "Deployment", Name: "app"}}},
+		},
+	}))
+	assert.NoError(t, f.run(context.Background()))
+	assert.Equal(t, f.tagMoves, []string{"prod=1-1"})
+	// The migrated namespace is only verified.
+	assert.Equal(t, f.checked, []string{"tagged", "relabeled", "tagged"})
+	assert.Equal(t, namespaceLabels(t, f.client, "tagged"), map[string]string{"istio.io/rev": "prod"})
+
+	// An upgrade to another revision is not started while one is in progress.
+	assert.NoError(t, saveState(context.Background(), f.client, "istio-system", &State{
+		From: "1-0", To: "1-1", Phase: PhaseVerify,
+	}))
+	f.to = "1-2"
+	assert.Error(t, f.run(context.Background()))
+}
+
+func TestCanaryUpgradeProxiesConnected(t *testing.T) {
+	f := newFakeUpgrade()
+	f.proxies = []string{"app-7d4b9c.legacy"}
+	assert.Error(t, f.run(context.Background()))
+	assert.Equal(t, f.uninstalled, false)
+	st, err := loadState(context.Background(), f.client, "istio-system")
+	assert.NoError(t, err)
+	assert.Equal(t, st.Phase, PhaseRemovePrevious)
+
+	// The previous revision is removed once its last proxy is gone.
+	f.proxies = nil
+	assert.NoError(t, f.run(context.Background()))
+	assert.Equal(t, f.uninstalled, true)
+}
@@ -0,0 +1,232 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/istioctl/pkg/writer/pilot"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/slices"
+)
+
+// healthCheck returns an error describing why the migrated workloads of a namespace are not healthy, or nil if they
+// are.
+type healthCheck func(ctx context.Context, ns NamespaceState) error
+
+// rolloutCheck checks the Deployments, StatefulSets and DaemonSets migrated in a namespace finished rolling out.
+func rolloutCheck(client kubernetes.Interface) healthCheck {
+	return func(ctx context.Context, ns NamespaceState) error {
+		deployments, err := client.AppsV1().Deployments(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, d := range deployments.Items {
+			if !ns.hasWorkload(kindDeployment, d.Name) {
+				continue
+			}
+			replicas := ptr.OrDefault(d.Spec.Replicas, 1)
+			if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas < replicas ||
+				d.Status.AvailableReplicas < replicas || d.Status.Replicas > replicas {
+				return fmt.Errorf("deployment %s is rolling out: %d/%d replicas updated and available",
+					d.Name, minInt32(d.Status.UpdatedReplicas, d.Status.AvailableReplicas), replicas)
+			}
+		}
+		statefulSets, err := client.AppsV1().StatefulSets(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, s := range statefulSets.Items {
+			if !ns.hasWorkload(kindStatefulSet, s.Name) {
+				continue
+			}
+			replicas := ptr.OrDefault(s.Spec.Replicas, 1)
+			if s.Status.ObservedGeneration < s.Generation || s.Status.UpdatedReplicas < replicas ||
+				s.Status.ReadyReplicas < replicas || s.Status.UpdateRevision != s.Status.CurrentRevision {
+				return fmt.Errorf("statefulset %s is rolling out: %d/%d replicas updated and ready",
+					s.Name, minInt32(s.Status.UpdatedReplicas, s.Status.ReadyReplicas), replicas)
+			}
+		}
+		daemonSets, err := client.AppsV1().DaemonSets(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, d := range daemonSets.Items {
+			if !ns.hasWorkload(kindDaemonSet, d.Name) {
+				continue
+			}
+			desired := d.Status.DesiredNumberScheduled
+			if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedNumberScheduled < desired ||
+				d.Status.NumberAvailable < desired {
+				return fmt.Errorf("daemonset %s is rolling out: %d/%d pods updated and available",
+					d.Name, minInt32(d.Status.UpdatedNumberScheduled, d.Status.NumberAvailable), desired)
+			}
+		}
+		return nil
+	}
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// proxySyncCheck checks every sidecar of the migrated workloads of a namespace runs the proxy of the given revision,
+// is connected to the Istiod of the revision and has acknowledged its configuration, like `istioctl proxy-status`.
+// client must be a client for the revision.
+func proxySyncCheck(client kube.CLIClient, istioNamespace, revision string) healthCheck {
+	return func(ctx context.Context, ns NamespaceState) error {
+		pods, err := workloadPods(ctx, client.Kube(), ns)
+		if err != nil {
+			return err
+		}
+		proxies, err := syncStatus(ctx, client, istioNamespace)
+		if err != nil {
+			return fmt.Errorf("failed to get the sync status from revision %q: %v", revision, err)
+		}
+		for _, pod := range pods {
+			if _, injected := pod.Annotations[annotation.SidecarStatus.Name]; !injected ||
+				pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			if rev := pod.Labels[label.IoIstioRev.Name]; rev != revision {
+				return fmt.Errorf("pod %s runs the proxy of revision %q", pod.Name, rev)
+			}
+			kinds, f := proxies[pod.Name+"."+pod.Namespace]
+			switch {
+			case !f:
+				return fmt.Errorf("proxy of pod %s is not connected to revision %q", pod.Name, revision)
+			case slices.Contains(kinds, pilot.DriftNacked):
+				return fmt.Errorf("proxy of pod %s rejected its configuration", pod.Name)
+			case slices.Contains(kinds, pilot.DriftStale):
+				return fmt.Errorf("proxy of pod %s has not acknowledged its configuration", pod.Name)
+			}
+		}
+		return nil
+	}
+}
+
+// connectedProxies returns the IDs of the proxies connected to the Istiod of a revision. client must be a client for
+// the revision.
+func connectedProxies(client kube.CLIClient, istioNamespace string) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		proxies, err := syncStatus(ctx, client, istioNamespace)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Sort(maps.Keys(proxies)), nil
+	}
+}
+
+// syncStatus maps the proxies connected to the Istiod of the revision of client to the kinds of drift of their
+// configuration.
+func syncStatus(ctx context.Context, client kube.CLIClient, istioNamespace string) (map[string][]pilot.DriftKind, error) {
+	statuses, err := client.AllDiscoveryDo(ctx, istioNamespace, "debug/syncz")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := (&pilot.DriftWriter{}).Analyze(statuses)
+	if err != nil {
+		return nil, err
+	}
+	proxies := map[string][]pilot.DriftKind{}
+	for _, g := range groups {
+		for _, p := range g.Proxies {
+			proxies[p.ProxyID] = append(proxies[p.ProxyID], g.Kind)
+		}
+	}
+	return proxies, nil
+}
+
+// workloadPods returns the pods of the migrated workloads of a namespace.
+func workloadPods(ctx context.Context, client kubernetes.Interface, ns NamespaceState) ([]corev1.Pod, error) {
+	workloads, err := listWorkloads(ctx, client, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	var selectors []klabels.Selector
+	for _, w := range workloads {
+		if !ns.hasWorkload(w.kind, w.name) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(w.selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of %s %s: %v", strings.ToLower(w.kind), w.name, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+	pods, err := client.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return slices.FilterInPlace(pods.Items, func(pod corev1.Pod) bool {
+		return slices.FindFunc(selectors, func(s klabels.Selector) bool {
+			return s.Matches(klabels.Set(pod.Labels))
+		}) != nil
+	}), nil
+}
+
+// errorRateCheck checks the rate of 5xx responses of the migrated workloads of a namespace, as reported by their
+// proxies to Prometheus, does not exceed maxRate. Workloads without traffic are healthy.
+func errorRateCheck(promAPI promv1.API, maxRate float64, window time.Duration) healthCheck {
+	return func(ctx context.Context, ns NamespaceState) error {
+		if len(ns.Workloads) == 0 {
+			return nil
+		}
+		names := slices.Map(ns.Workloads, func(w WorkloadState) string {
+			return w.Name
+		})
+		selector := fmt.Sprintf(`reporter="destination",destination_workload_namespace=%q,destination_workload=~%q`,
+			ns.Name, strings.Join(names, "|"))
+		query := fmt.Sprintf(`sum(rate(istio_requests_total{%s,response_code=~"5.."}[%s])) / sum(rate(istio_requests_total{%s}[%s]))`,
+			selector, model.Duration(window), selector, model.Duration(window))
+		val, _, err := promAPI.Query(ctx, query, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to query the error rate from Prometheus: %v", err)
+		}
+		v, ok := val.(model.Vector)
+		if !ok {
+			return fmt.Errorf("bad metric value type returned for query %q", query)
+		}
+		if v.Len() == 0 {
+			return nil
+		}
+		rate := float64(v[0].Value)
+		if math.IsNaN(rate) || rate <= maxRate {
+			return nil
+		}
+		return fmt.Errorf("error rate is %.2f%%, above %.2f%%", rate*100, maxRate*100)
+	}
+}
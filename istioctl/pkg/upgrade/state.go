@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// stateConfigMapName is the ConfigMap, in the Istio namespace, holding the progress of the canary upgrade.
+	stateConfigMapName = "istio-canary-upgrade"
+	stateKey           = "state"
+)
+
+// Phase is a checkpoint of the canary upgrade. A resumed upgrade starts over the phase it was interrupted in, so
+// every phase must be safe to run again.
+type Phase string
+
+const (
+	PhaseInstall           Phase = "Install"
+	PhasePlan              Phase = "Plan"
+	PhaseMigrateNamespaces Phase = "MigrateNamespaces"
+	PhaseVerify            Phase = "Verify"
+	PhaseMoveTags          Phase = "MoveTags"
+	PhaseRemovePrevious    Phase = "RemovePrevious"
+	PhaseComplete          Phase = "Complete"
+	PhaseRolledBack        Phase = "RolledBack"
+)
+
+// phases are the phases of an upgrade, in order.
+var phases = []Phase{
+	PhaseInstall, PhasePlan, PhaseMigrateNamespaces, PhaseVerify, PhaseMoveTags, PhaseRemovePrevious, PhaseComplete,
+}
+
+// State is the progress of a canary upgrade.
+type State struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Phase Phase  `json:"phase"`
+	// Tags maps the revision tags moved to the new revision to the revision they pointed to before.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Namespaces are migrated in order.
+	Namespaces []NamespaceState `json:"namespaces,omitempty"`
+	// Error is the cause of the rollback of the upgrade.
+	Error string `json:"error,omitempty"`
+}
+
+// NamespaceState is the progress of the migration of a namespace.
+type NamespaceState struct {
+	Name string `json:"name"`
+	// Selected is set for namespaces whose labels select the previous revision. They are labeled with the new
+	// revision while migrated. Otherwise, only the workloads selecting the previous revision with the labels of
+	// their pods are migrated.
+	Selected bool `json:"selected,omitempty"`
+	// Tag is the revision tag the namespace selects the previous revision through, if any. The label of the
+	// namespace is set back to the tag once it is moved.
+	Tag string `json:"tag,omitempty"`
+	// Injection is set for namespaces selecting the default tag with the istio-injection label.
+	Injection bool `json:"injection,omitempty"`
+	// Workloads are the workloads restarted and checked when migrating the namespace.
+	Workloads []WorkloadState `json:"workloads,omitempty"`
+	Migrated  bool            `json:"migrated,omitempty"`
+}
+
+// WorkloadState is a workload to migrate.
+type WorkloadState struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Relabel is set for workloads selecting the previous revision with the istio.io/rev label of their pod
+	// template, which is set to the new revision while migrated.
+	Relabel bool `json:"relabel,omitempty"`
+	// Revision is the istio.io/rev label of the pod template before the upgrade, empty for workloads selecting the
+	// default tag with the sidecar.istio.io/inject label.
+	Revision string `json:"revision,omitempty"`
+	// Tag is the revision tag the pod template selects the previous revision through, if any.
+	Tag string `json:"tag,omitempty"`
+}
+
+// loadState returns the state persisted in the cluster, or nil if there is none.
+func loadState(ctx context.Context, client kubernetes.Interface, istioNamespace string) (*State, error) {
+	cm, err := client.CoreV1().ConfigMaps(istioNamespace).Get(ctx, stateConfigMapName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the canary upgrade state: %v", err)
+	}
+	st := &State{}
+	if err := json.Unmarshal([]byte(cm.Data[stateKey]), st); err != nil {
+		return nil, fmt.Errorf("invalid canary upgrade state in ConfigMap %s/%s: %v", istioNamespace, stateConfigMapName, err)
+	}
+	return st, nil
+}
+
+func saveState(ctx context.Context, client kubernetes.Interface, istioNamespace string, st *State) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: stateConfigMapName, Namespace: istioNamespace},
+		Data:       map[string]string{stateKey: string(b)},
+	}
+	_, err = client.CoreV1().ConfigMaps(istioNamespace).Update(ctx, cm, metav1.UpdateOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = client.CoreV1().ConfigMaps(istioNamespace).Create(ctx, cm, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save the canary upgrade state: %v", err)
+	}
+	return nil
+}
+
+func deleteState(ctx context.Context, client kubernetes.Interface, istioNamespace string) error {
+	err := client.CoreV1().ConfigMaps(istioNamespace).Delete(ctx, stateConfigMapName, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/dashboard"
+	revtag "istio.io/istio/istioctl/pkg/tag"
+	"istio.io/istio/operator/cmd/mesh"
+	"istio.io/istio/operator/pkg/util/clog"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/kube"
+)
+
+// Cmd returns the upgrade command.
+func Cmd(ctx cli.Context) *cobra.Command {
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the Istio control plane and the workloads of the mesh",
+	}
+	upgradeCmd.AddCommand(canaryCmd(ctx))
+	return upgradeCmd
+}
+
+type canaryArgs struct {
+	from, to     string
+	filenames    []string
+	set          []string
+	force        bool
+	skipInstall  bool
+	tags         []string
+	keepPrevious bool
+	reset        bool
+	timeout      time.Duration
+	maxErrorRate float64
+	window       time.Duration
+}
+
+func canaryCmd(ctx cli.Context) *cobra.Command {
+	args := &canaryArgs{}
+	cmd := &cobra.Command{
+		Use:   "canary",
+		Short: "Move the mesh to a new control plane revision, one namespace at a time",
+		Long: `Drives a revision based canary upgrade: installs the new revision, migrates each namespace using the
+previous revision by labeling it with the new revision and restarting its workloads, verifies the migrated
+namespaces, moves the revision tags of the previous revision to the new one and removes the previous revision.
+In namespaces without an istio-injection or istio.io/rev label, like the Istio namespace, only the workloads
+selecting the previous revision with the labels of their pods are migrated.
+
+A namespace is migrated once its workloads rolled out, every sidecar is connected to the new revision and has
+acknowledged its configuration, and, when Prometheus is installed in the Istio namespace, the rate of 5xx responses
+of its workloads is below --max-error-rate. If a namespace is not healthy within --timeout, the namespaces migrated
+so far select the previous revision again and their workloads are restarted. Namespaces and pods selecting a
+revision tag are labeled with the tag again once it is moved. The previous revision is only removed once no proxy
+is connected to it anymore.
+
+The progress is saved in the istio-canary-upgrade ConfigMap of the Istio namespace: running the command again
+resumes an interrupted upgrade.`,
+		Example: `  # Upgrade from revision 1-20 to 1-21, installed with the given IstioOperator
+  istioctl x upgrade canary --from 1-20 --to 1-21 -f iop.yaml
+
+  # Only move the prod tag, and keep revision 1-20 installed
+  istioctl x upgrade canary --from 1-20 --to 1-21 --skip-install --tags prod --keep-previous
+
+  # Discard an interrupted upgrade and start a new one
+  istioctl x upgrade canary --from 1-20 --to 1-21 -f iop.yaml --reset`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			if args.from == "" || args.to == "" {
+				return fmt.Errorf("--from and --to are required")
+			}
+			if args.from == args.to {
+				return fmt.Errorf("--from and --to must be different revisions")
+			}
+			if !labels.IsDNS1123Label(args.to) {
+				return fmt.Errorf("invalid revision specified: %v", args.to)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runCanary(cmd, ctx, args)
+		},
+	}
+	cmd.Flags().StringVar(&args.from, "from", "", "The revision to upgrade from")
+	cmd.Flags().StringVar(&args.to, "to", "", "The revision to upgrade to")
+	cmd.Flags().StringSliceVarP(&args.filenames, "filename", "f", nil,
+		"Path to the IstioOperator files installing the new revision")
+	cmd.Flags().StringArrayVarP(&args.set, "set", "s", nil,
+		"Override an IstioOperator value of the new revision, e.g. to choose a profile (--set profile=demo)")
+	cmd.Flags().BoolVar(&args.force, "force", false, "Proceed even with validation errors in the IstioOperator")
+	cmd.Flags().BoolVar(&args.skipInstall, "skip-install", false, "Do not install the new revision, which must be installed already")
+	cmd.Flags().StringSliceVar(&args.tags, "tags", nil,
+		"The revision tags to move to the new revision. By default, every tag of the previous revision is moved.")
+	cmd.Flags().BoolVar(&args.keepPrevious, "keep-previous", false, "Do not remove the previous revision once the upgrade succeeded")
+	cmd.Flags().BoolVar(&args.reset, "reset", false, "Discard the progress of an interrupted upgrade, without undoing it")
+	cmd.Flags().DurationVar(&args.timeout, "timeout", 5*time.Minute,
+		"Maximum time to wait for the new revision, or for a migrated namespace, to be ready")
+	cmd.Flags().Float64Var(&args.maxErrorRate, "max-error-rate", 0.05,
+		"Maximum rate of 5xx responses of a migrated namespace, as a fraction of its requests")
+	cmd.Flags().DurationVar(&args.window, "error-rate-window", time.Minute, "Duration the error rate is computed over")
+	return cmd
+}
+
+func runCanary(cmd *cobra.Command, ctx cli.Context, args *canaryArgs) error {
+	kubeClient, err := ctx.CLIClient()
+	if err != nil {
+		return err
+	}
+	revClient, err := ctx.CLIClientWithRevision(args.to)
+	if err != nil {
+		return err
+	}
+	previousClient, err := ctx.CLIClientWithRevision(args.from)
+	if err != nil {
+		return err
+	}
+	crClient, err := client.New(kubeClient.RESTConfig(), client.Options{Scheme: kube.IstioScheme})
+	if err != nil {
+		return err
+	}
+	istioNamespace := ctx.IstioNamespace()
+	if args.reset {
+		if err := deleteState(context.Background(), kubeClient.Kube(), istioNamespace); err != nil {
+			return fmt.Errorf("failed to discard the canary upgrade state: %v", err)
+		}
+	}
+
+	l := clog.NewConsoleLogger(cmd.OutOrStdout(), cmd.ErrOrStderr(), nil)
+	c := &canaryUpgrade{
+		client:         kubeClient.Kube(),
+		istioNamespace: istioNamespace,
+		from:           args.from,
+		to:             args.to,
+		tags:           args.tags,
+		keepPrevious:   args.keepPrevious,
+		timeout:        args.timeout,
+		interval:       5 * time.Second,
+		out:            cmd.OutOrStdout(),
+		install: func() error {
+			if args.skipInstall {
+				return nil
+			}
+			_, err := mesh.InstallRevision(kubeClient, crClient, args.filenames, args.set, args.to, args.force, args.timeout, l)
+			return err
+		},
+		uninstall: func() error {
+			// The proxies of the previous revision are gone, anything else still using it must stop the removal.
+			return mesh.UninstallRevision(kubeClient, crClient, istioNamespace, args.from, false, l)
+		},
+		setTag: func(tag, revision string) error {
+			manifests, err := revtag.Generate(context.Background(), kubeClient,
+				&revtag.GenerateOptions{Tag: tag, Revision: revision, Overwrite: true}, istioNamespace)
+			if err != nil {
+				return err
+			}
+			return revtag.Create(kubeClient, manifests, istioNamespace)
+		},
+		checks:          []healthCheck{rolloutCheck(kubeClient.Kube()), proxySyncCheck(revClient, istioNamespace, args.to)},
+		previousProxies: connectedProxies(previousClient, istioNamespace),
+	}
+
+	promAPI, closePrometheus, err := prometheusAPI(kubeClient, istioNamespace)
+	if err != nil {
+		return err
+	}
+	if promAPI != nil {
+		defer closePrometheus()
+		c.checks = append(c.checks, errorRateCheck(promAPI, args.maxErrorRate, args.window))
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "Prometheus is not installed in namespace %s, error rates are not checked.\n", istioNamespace)
+	}
+	return c.run(context.Background())
+}
+
+// prometheusAPI returns a client for the Prometheus of the Istio namespace, through a port forward closed by the
+// returned function, or nil if Prometheus is not installed.
+func prometheusAPI(kubeClient kube.CLIClient, istioNamespace string) (promv1.API, func(), error) {
+	pl, err := kubeClient.PodsForSelector(context.TODO(), istioNamespace, "app=prometheus")
+	if err != nil {
+		return nil, nil, fmt.Errorf("not able to locate Prometheus pod: %v", err)
+	}
+	if len(pl.Items) == 0 {
+		return nil, nil, nil
+	}
+	fw, err := kubeClient.NewPortForwarder(pl.Items[0].Name, istioNamespace, "", 0, 9090)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not build port forwarder for prometheus: %v", err)
+	}
+	if err := fw.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failure running port forward process: %v", err)
+	}
+	dashboard.ClosePortForwarderOnInterrupt(fw)
+	promClient, err := api.NewClient(api.Config{Address: fmt.Sprintf("http://%s", fw.Address())})
+	if err != nil {
+		fw.Close()
+		return nil, nil, fmt.Errorf("could not build prometheus client: %v", err)
+	}
+	return promv1.NewAPI(promClient), fw.Close, nil
+}
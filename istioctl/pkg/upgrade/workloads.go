@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/label"
+)
+
+const (
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+	kindDaemonSet   = "DaemonSet"
+)
+
+// workload is a Deployment, StatefulSet or DaemonSet.
+type workload struct {
+	kind, name, namespace string
+	template              metav1.ObjectMeta
+	selector              *metav1.LabelSelector
+}
+
+// listWorkloads returns the Deployments, StatefulSets and DaemonSets of a namespace, or of every namespace for
+// metav1.NamespaceAll.
+func listWorkloads(ctx context.Context, client kubernetes.Interface, namespace string) ([]workload, error) {
+	var res []workload
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		res = append(res, workload{kindDeployment, d.Name, d.Namespace, d.Spec.Template.ObjectMeta, d.Spec.Selector})
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		res = append(res, workload{kindStatefulSet, s.Name, s.Namespace, s.Spec.Template.ObjectMeta, s.Spec.Selector})
+	}
+	daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daemonSets.Items {
+		res = append(res, workload{kindDaemonSet, d.Name, d.Namespace, d.Spec.Template.ObjectMeta, d.Spec.Selector})
+	}
+	return res, nil
+}
+
+func (ns NamespaceState) hasWorkload(kind, name string) bool {
+	for _, w := range ns.Workloads {
+		if w.Kind == kind && w.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// restartWorkload restarts a workload, like `kubectl rollout restart`, so its pods are injected again. If revision
+// is set, the istio.io/rev label of its pod template is set to it first, or removed if it is empty.
+func restartWorkload(ctx context.Context, client kubernetes.Interface, namespace string, w WorkloadState, revision *string) error {
+	metadata := map[string]any{
+		"annotations": map[string]string{restartedAtAnnotation: time.Now().Format(time.RFC3339)},
+	}
+	if revision != nil {
+		var rev any
+		if *revision != "" {
+			rev = *revision
+		}
+		metadata["labels"] = map[string]any{label.IoIstioRev.Name: rev}
+	}
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"template": map[string]any{"metadata": metadata}}})
+	if err != nil {
+		return err
+	}
+	switch w.Kind {
+	case kindDeployment:
+		_, err = client.AppsV1().Deployments(namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case kindStatefulSet:
+		_, err = client.AppsV1().StatefulSets(namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case kindDaemonSet:
+		_, err = client.AppsV1().DaemonSets(namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	default:
+		return fmt.Errorf("unknown workload kind %q", w.Kind)
+	}
+	// A workload deleted since it was listed has nothing to restart.
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to restart %s %s/%s: %v", strings.ToLower(w.Kind), namespace, w.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+	"sort"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// Change is the way a resource held by Envoy differs from the one generated by Istiod.
+type Change string
+
+const (
+	// Missing resources were generated by Istiod but are not in Envoy.
+	Missing Change = "Missing"
+	// Unexpected resources are in Envoy but were not generated by Istiod.
+	Unexpected Change = "Unexpected"
+	// Modified resources are in both, with different contents.
+	Modified Change = "Modified"
+)
+
+// ResourceDiff describes a single resource that differs between Istiod and Envoy.
+type ResourceDiff struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Change Change `json:"change"`
+}
+
+func (d ResourceDiff) String() string {
+	return fmt.Sprintf("%s %s %s", d.Type, d.Name, d.Change)
+}
+
+// SemanticDiff returns the dynamic clusters, listeners and routes that differ between Istiod and Envoy. Unlike
+// Diff, it ignores the order of the resources and their version info, so only changes in content are reported.
+// The result is sorted by type and name.
+func (c *Comparator) SemanticDiff() ([]ResourceDiff, error) {
+	return SemanticDiff(c.istiod, c.envoy)
+}
+
+// SemanticDiff compares the dynamic clusters, listeners and routes of two config dumps.
+func SemanticDiff(istiod, envoy *configdump.Wrapper) ([]ResourceDiff, error) {
+	var diffs []ResourceDiff
+	for _, typ := range []struct {
+		name    string
+		extract func(*configdump.Wrapper) (map[string]proto.Message, error)
+	}{
+		{"Cluster", dynamicClusters},
+		{"Listener", dynamicListeners},
+		{"Route", dynamicRoutes},
+	} {
+		want, err := typ.extract(istiod)
+		if err != nil {
+			return nil, fmt.Errorf("istiod %s dump: %v", typ.name, err)
+		}
+		got, err := typ.extract(envoy)
+		if err != nil {
+			return nil, fmt.Errorf("envoy %s dump: %v", typ.name, err)
+		}
+		diffs = append(diffs, diffResources(typ.name, want, got)...)
+	}
+	return diffs, nil
+}
+
+func diffResources(typ string, want, got map[string]proto.Message) []ResourceDiff {
+	var diffs []ResourceDiff
+	for name, w := range want {
+		g, ok := got[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, ResourceDiff{Type: typ, Name: name, Change: Missing})
+		case !proto.Equal(w, g):
+			diffs = append(diffs, ResourceDiff{Type: typ, Name: name, Change: Modified})
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			diffs = append(diffs, ResourceDiff{Type: typ, Name: name, Change: Unexpected})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+func dynamicClusters(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	dump, err := w.GetDynamicClusterDump(true)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]proto.Message{}
+	for _, c := range dump.GetDynamicActiveClusters() {
+		if c.GetCluster() == nil {
+			continue
+		}
+		cl := &cluster.Cluster{}
+		c.Cluster.TypeUrl = v3.ClusterType
+		if err := c.Cluster.UnmarshalTo(cl); err != nil {
+			return nil, err
+		}
+		out[cl.GetName()] = cl
+	}
+	return out, nil
+}
+
+func dynamicListeners(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	dump, err := w.GetDynamicListenerDump(true)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]proto.Message{}
+	for _, l := range dump.GetDynamicListeners() {
+		li := &listener.Listener{}
+		if err := l.GetActiveState().GetListener().UnmarshalTo(li); err != nil {
+			return nil, err
+		}
+		out[li.GetName()] = li
+	}
+	return out, nil
+}
+
+func dynamicRoutes(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	// GetDynamicRouteDump also sorts the virtual hosts, whose order is not significant.
+	dump, err := w.GetDynamicRouteDump(true)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]proto.Message{}
+	for _, r := range dump.GetDynamicRouteConfigs() {
+		rc := &route.RouteConfiguration{}
+		if err := r.GetRouteConfig().UnmarshalTo(rc); err != nil {
+			return nil, err
+		}
+		out[rc.GetName()] = rc
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"testing"
+
+	admin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+type dumpBuilder struct {
+	version   string
+	clusters  []*cluster.Cluster
+	listeners []*listener.Listener
+	routes    []*route.RouteConfiguration
+}
+
+func (b dumpBuilder) build() *configdump.Wrapper {
+	cds := &admin.ClustersConfigDump{}
+	for _, c := range b.clusters {
+		cds.DynamicActiveClusters = append(cds.DynamicActiveClusters, &admin.ClustersConfigDump_DynamicCluster{
+			VersionInfo: b.version,
+			Cluster:     protoconv.MessageToAny(c),
+			LastUpdated: timestamppb.Now(),
+		})
+	}
+	lds := &admin.ListenersConfigDump{}
+	for _, l := range b.listeners {
+		lds.DynamicListeners = append(lds.DynamicListeners, &admin.ListenersConfigDump_DynamicListener{
+			Name: l.Name,
+			ActiveState: &admin.ListenersConfigDump_DynamicListenerState{
+				VersionInfo: b.version,
+				Listener:    protoconv.MessageToAny(l),
+				LastUpdated: timestamppb.Now(),
+			},
+		})
+	}
+	rds := &admin.RoutesConfigDump{}
+	for _, r := range b.routes {
+		rds.DynamicRouteConfigs = append(rds.DynamicRouteConfigs, &admin.RoutesConfigDump_DynamicRouteConfig{
+			VersionInfo: b.version,
+			RouteConfig: protoconv.MessageToAny(r),
+			LastUpdated: timestamppb.Now(),
+		})
+	}
+	return &configdump.Wrapper{ConfigDump: &admin.ConfigDump{
+		Configs: []*anypb.Any{protoconv.MessageToAny(cds), protoconv.MessageToAny(lds), protoconv.MessageToAny(rds)},
+	}}
+}
+
+func TestSemanticDiff(t *testing.T) {
+	reviews := &cluster.Cluster{Name: "outbound|9080||reviews.default.svc.cluster.local", ConnectTimeout: durationpb.New(10)}
+	ratings := &cluster.Cluster{Name: "outbound|9080||ratings.default.svc.cluster.local"}
+	details := &cluster.Cluster{Name: "outbound|9080||details.default.svc.cluster.local"}
+	virtualInbound := &listener.Listener{Name: "virtualInbound"}
+	virtualOutbound := &listener.Listener{Name: "virtualOutbound"}
+	route9080 := &route.RouteConfiguration{Name: "9080", VirtualHosts: []*route.VirtualHost{
+		{Name: "ratings:9080", Domains: []string{"ratings"}},
+		{Name: "reviews:9080", Domains: []string{"reviews"}},
+	}}
+	route9080Reordered := &route.RouteConfiguration{Name: "9080", VirtualHosts: []*route.VirtualHost{
+		{Name: "reviews:9080", Domains: []string{"reviews"}},
+		{Name: "ratings:9080", Domains: []string{"ratings"}},
+	}}
+
+	istiod := dumpBuilder{
+		version:   "2023-01-01T00:00:00Z/2",
+		clusters:  []*cluster.Cluster{reviews, ratings},
+		listeners: []*listener.Listener{virtualInbound, virtualOutbound},
+		routes:    []*route.RouteConfiguration{route9080},
+	}
+
+	cases := []struct {
+		name  string
+		envoy dumpBuilder
+		want  []ResourceDiff
+	}{
+		{
+			name: "reordered with other versions",
+			envoy: dumpBuilder{
+				version:   "2023-01-01T00:00:00Z/1",
+				clusters:  []*cluster.Cluster{ratings, reviews},
+				listeners: []*listener.Listener{virtualOutbound, virtualInbound},
+				routes:    []*route.RouteConfiguration{route9080Reordered},
+			},
+		},
+		{
+			name: "drifted",
+			envoy: dumpBuilder{
+				clusters: []*cluster.Cluster{
+					{Name: "outbound|9080||reviews.default.svc.cluster.local", ConnectTimeout: durationpb.New(20)},
+					details,
+				},
+				listeners: []*listener.Listener{virtualOutbound, virtualInbound},
+				routes: []*route.RouteConfiguration{{Name: "9080", VirtualHosts: []*route.VirtualHost{
+					{Name: "reviews:9080", Domains: []string{"reviews"}},
+				}}},
+			},
+			want: []ResourceDiff{
+				{Type: "Cluster", Name: "outbound|9080||details.default.svc.cluster.local", Change: Unexpected},
+				{Type: "Cluster", Name: "outbound|9080||ratings.default.svc.cluster.local", Change: Missing},
+				{Type: "Cluster", Name: "outbound|9080||reviews.default.svc.cluster.local", Change: Modified},
+				{Type: "Route", Name: "9080", Change: Modified},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SemanticDiff(istiod.build(), tt.envoy.build())
+			assert.NoError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
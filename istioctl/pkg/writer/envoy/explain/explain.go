@@ -0,0 +1,512 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package explain walks a request through an Envoy config dump, with the matching pilot/pkg/simulation uses for
+// generated config, and reports each decision along the way.
+package explain
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pkg/envoy/match"
+)
+
+const (
+	virtualInboundListenerName = "virtualInbound"
+
+	tlsTransportProtocol       = "tls"
+	rawBufferTransportProtocol = "raw_buffer"
+
+	// istioMetadataKey is the filter metadata key istiod uses to record the config a resource was generated from.
+	istioMetadataKey = "istio"
+)
+
+var (
+	ErrNoListener          = errors.New("no listener matched")
+	ErrNoFilterChain       = match.ErrNoFilterChain
+	ErrMultipleFilterChain = match.ErrMultipleFilterChain
+	ErrNoRouteConfig       = errors.New("route configuration not found")
+	ErrNoVirtualHost       = errors.New("no virtual host matched")
+	ErrNoRoute             = errors.New("no route matched")
+	ErrTLSRedirect         = errors.New("tls required, sending 301")
+	ErrNoCluster           = errors.New("cluster not found")
+	// ErrProtocolError happens when sending TLS/TCP request to HCM, for example
+	ErrProtocolError = errors.New("protocol error")
+	ErrTLSError      = errors.New("invalid TLS")
+)
+
+// Request describes the connection or HTTP request to explain.
+type Request struct {
+	// Address is the destination IP. If empty, only listeners bound to the wildcard address match.
+	Address string
+	Port    int
+	// Host is the HTTP host header. Without the port, it is also the SNI of TLS requests.
+	Host    string
+	Path    string
+	Method  string
+	Headers http.Header
+	// HTTP is false for raw TCP connections.
+	HTTP bool
+	// TLS is set when the application itself sends TLS.
+	TLS bool
+	// Inbound explains a request arriving at the pod from a mesh peer, rather than one sent by the pod.
+	Inbound bool
+}
+
+// Hop is a single decision along the path of a request.
+type Hop struct {
+	// Kind is the type of decision, such as listener, route or cluster.
+	Kind string `json:"kind"`
+	// Name is the name of the matched Envoy resource.
+	Name string `json:"name"`
+	// Detail explains why the resource matched, or what it does with the request.
+	Detail string `json:"detail,omitempty"`
+	// Resource is the Istio resource the Envoy config was generated from, if known.
+	Resource string `json:"resource,omitempty"`
+}
+
+// Explainer explains requests against the Envoy config of a single proxy.
+type Explainer struct {
+	listeners []*listener.Listener
+	routes    map[string]*route.RouteConfiguration
+	clusters  map[string]*cluster.Cluster
+	// endpoints is nil if the config dump did not include EDS.
+	endpoints map[string]*endpoint.ClusterLoadAssignment
+}
+
+// call is the internal state of a request as it passes through listener filters.
+type call struct {
+	Request
+	alpn      string
+	sni       string
+	transport string
+}
+
+func (c call) matchRequest() match.Request {
+	return match.Request{Host: c.Host, Path: c.Path, Method: c.Method, Headers: c.Headers, TLS: c.TLS}
+}
+
+// listener returns the listener with the given name.
+func (e *Explainer) listener(name string) *listener.Listener {
+	for _, l := range e.listeners {
+		if l.GetName() == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Explain returns the decisions Envoy would make for the request. If the request is rejected or cannot be followed,
+// the hops up to that point are returned along with the reason.
+func (e *Explainer) Explain(req Request) ([]Hop, error) {
+	c := call{Request: req}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	if c.TLS || c.Inbound {
+		// The SNI never includes the port.
+		c.sni = c.Host
+		if h, _, err := net.SplitHostPort(c.Host); err == nil {
+			c.sni = h
+		}
+	}
+	var hops []Hop
+
+	var l *listener.Listener
+	if c.Inbound {
+		l = e.listener(virtualInboundListenerName)
+	} else {
+		l = match.Listener(e.listeners, c.Address, c.Port)
+	}
+	if l == nil {
+		return hops, ErrNoListener
+	}
+	hops = append(hops, Hop{Kind: "listener", Name: l.GetName(), Detail: describeAddress(l.GetAddress())})
+
+	hasTLSInspector := match.HasFilterOnPort(l, wellknown.TLSInspector, c.Port)
+	if hasTLSInspector {
+		switch {
+		case c.Inbound:
+			c.alpn = mtlsALPN(c.HTTP)
+		case c.TLS && c.HTTP:
+			c.alpn = "http/1.1"
+		}
+	}
+	// Without the TLS inspector, Envoy does not read the ALPN, but the HTTP inspector may still set it.
+	if match.HasFilterOnPort(l, wellknown.HTTPInspector, c.Port) && c.HTTP && !c.TLS && !c.Inbound {
+		c.alpn = "http/1.1"
+	}
+	tlsSent := c.TLS || c.Inbound
+	// Without the TLS inspector, the transport protocol is always raw buffer.
+	c.transport = rawBufferTransportProtocol
+	if hasTLSInspector && tlsSent {
+		c.transport = tlsTransportProtocol
+	}
+
+	conn := match.Connection{Address: c.Address, Port: c.Port, SNI: c.sni, TransportProtocol: c.transport, ALPN: c.alpn}
+	fc, err := match.FilterChain(l.GetFilterChains(), l.GetDefaultFilterChain(), conn)
+	if errors.Is(err, match.ErrMultipleFilterChain) {
+		var names []string
+		for _, fc := range match.FilterChains(l.GetFilterChains(), conn) {
+			names = append(names, fc.GetName())
+		}
+		return hops, fmt.Errorf("%w: %s", err, strings.Join(names, ", "))
+	}
+	if err != nil {
+		return hops, err
+	}
+	hops = append(hops, Hop{
+		Kind:     "filter chain",
+		Name:     fc.GetName(),
+		Detail:   describeFilterChainMatch(fc.GetFilterChainMatch()),
+		Resource: configSource(fc.GetMetadata()),
+	})
+
+	if fc.GetTransportSocket() != nil {
+		if !tlsSent {
+			return hops, ErrTLSError
+		}
+		hops = append(hops, Hop{Kind: "tls", Name: fc.GetName(), Detail: describeDownstreamTLS(fc)})
+	}
+
+	if h := extractHTTPConnectionManager(fc); h != nil {
+		// We matched HCM and didn't terminate TLS, but we are sending TLS traffic - decoding will fail
+		if tlsSent && fc.GetTransportSocket() == nil {
+			return hops, ErrProtocolError
+		}
+		if !c.HTTP {
+			return hops, ErrProtocolError
+		}
+		return e.explainHTTP(hops, h, c)
+	}
+	if tcp := extractTCPProxy(fc); tcp != nil {
+		return e.explainTCP(hops, tcp)
+	}
+	return hops, fmt.Errorf("filter chain %q has no http_connection_manager or tcp_proxy filter", fc.GetName())
+}
+
+func (e *Explainer) explainHTTP(hops []Hop, h *hcm.HttpConnectionManager, c call) ([]Hop, error) {
+	rc := h.GetRouteConfig()
+	if rc == nil {
+		routeName := h.GetRds().GetRouteConfigName()
+		rc = e.routes[routeName]
+		if rc == nil {
+			return hops, fmt.Errorf("%w: %q", ErrNoRouteConfig, routeName)
+		}
+		hops = append(hops, Hop{Kind: "route config", Name: routeName, Detail: "RDS"})
+	} else {
+		hops = append(hops, Hop{Kind: "route config", Name: rc.GetName(), Detail: "inline"})
+	}
+
+	vh := match.VirtualHost(rc, c.Host)
+	if vh == nil {
+		return hops, ErrNoVirtualHost
+	}
+	hops = append(hops, Hop{
+		Kind:   "virtual host",
+		Name:   vh.GetName(),
+		Detail: "host " + strconv.Quote(c.Host),
+	})
+	if vh.GetRequireTls() == route.VirtualHost_ALL && !c.TLS {
+		return hops, ErrTLSRedirect
+	}
+
+	r := match.Route(vh, c.matchRequest())
+	if r == nil {
+		return hops, ErrNoRoute
+	}
+	hops = append(hops, Hop{
+		Kind:     "route",
+		Name:     r.GetName(),
+		Detail:   describeRouteMatch(r.GetMatch()),
+		Resource: configSource(r.GetMetadata()),
+	})
+
+	switch action := r.GetAction().(type) {
+	case *route.Route_Route:
+		return e.explainClusters(hops, action.Route.GetCluster(), action.Route.GetWeightedClusters().GetClusters(),
+			action.Route.GetClusterHeader(), c.Headers)
+	case *route.Route_Redirect:
+		return append(hops, Hop{Kind: "redirect", Name: r.GetName(), Detail: describeRedirect(action.Redirect)}), nil
+	case *route.Route_DirectResponse:
+		return append(hops, Hop{
+			Kind:   "direct response",
+			Name:   r.GetName(),
+			Detail: fmt.Sprintf("status %d", action.DirectResponse.GetStatus()),
+		}), nil
+	default:
+		return hops, fmt.Errorf("route %q has unsupported action %T", r.GetName(), action)
+	}
+}
+
+func (e *Explainer) explainTCP(hops []Hop, tcp *tcpproxy.TcpProxy) ([]Hop, error) {
+	var weighted []*route.WeightedCluster_ClusterWeight
+	for _, wc := range tcp.GetWeightedClusters().GetClusters() {
+		weighted = append(weighted, &route.WeightedCluster_ClusterWeight{Name: wc.GetName(), Weight: wrapperspb.UInt32(wc.GetWeight())})
+	}
+	return e.explainClusters(hops, tcp.GetCluster(), weighted, "", nil)
+}
+
+// explainClusters adds the hops for each upstream cluster the request may be sent to.
+func (e *Explainer) explainClusters(hops []Hop, name string, weighted []*route.WeightedCluster_ClusterWeight,
+	clusterHeader string, headers http.Header,
+) ([]Hop, error) {
+	if clusterHeader != "" {
+		name = headers.Get(clusterHeader)
+		if name == "" {
+			return hops, fmt.Errorf("%w: header %q is not set", ErrNoCluster, clusterHeader)
+		}
+	}
+	if name != "" {
+		return e.explainCluster(hops, name, "")
+	}
+	total := uint32(0)
+	for _, wc := range weighted {
+		total += wc.GetWeight().GetValue()
+	}
+	var err error
+	for _, wc := range weighted {
+		detail := fmt.Sprintf("weight %d/%d", wc.GetWeight().GetValue(), total)
+		if hops, err = e.explainCluster(hops, wc.GetName(), detail); err != nil {
+			return hops, err
+		}
+	}
+	return hops, nil
+}
+
+func (e *Explainer) explainCluster(hops []Hop, name, weight string) ([]Hop, error) {
+	c := e.clusters[name]
+	if c == nil {
+		return hops, fmt.Errorf("%w: %q", ErrNoCluster, name)
+	}
+	detail := describeDiscoveryType(c)
+	if weight != "" {
+		detail += ", " + weight
+	}
+	hops = append(hops,
+		Hop{Kind: "cluster", Name: name, Detail: detail, Resource: configSource(c.GetMetadata())},
+		Hop{Kind: "upstream tls", Name: name, Detail: describeUpstreamTLS(c)})
+	if c.GetType() == cluster.Cluster_ORIGINAL_DST {
+		return append(hops, Hop{Kind: "endpoints", Name: name, Detail: "original destination of the connection"}), nil
+	}
+	return append(hops, Hop{Kind: "endpoints", Name: name, Detail: e.describeEndpoints(c)}), nil
+}
+
+func (e *Explainer) describeEndpoints(c *cluster.Cluster) string {
+	cla := c.GetLoadAssignment()
+	if c.GetType() == cluster.Cluster_EDS {
+		if e.endpoints == nil {
+			return "unknown, the config dump does not include EDS"
+		}
+		name := c.GetEdsClusterConfig().GetServiceName()
+		if name == "" {
+			name = c.GetName()
+		}
+		cla = e.endpoints[name]
+	}
+	var out []string
+	for _, lep := range cla.GetEndpoints() {
+		for _, ep := range lep.GetLbEndpoints() {
+			addr := describeAddress(ep.GetEndpoint().GetAddress())
+			if addr == "" {
+				continue
+			}
+			out = append(out, addr+" "+ep.GetHealthStatus().String())
+		}
+	}
+	if len(out) == 0 {
+		return "none"
+	}
+	return strings.Join(out, ", ")
+}
+
+func mtlsALPN(isHTTP bool) string {
+	if isHTTP {
+		return "istio-http/1.1"
+	}
+	return "istio"
+}
+
+func extractHTTPConnectionManager(fc *listener.FilterChain) *hcm.HttpConnectionManager {
+	for _, f := range fc.GetFilters() {
+		if f.GetName() != wellknown.HTTPConnectionManager {
+			continue
+		}
+		h := &hcm.HttpConnectionManager{}
+		if err := f.GetTypedConfig().UnmarshalTo(h); err != nil {
+			return nil
+		}
+		return h
+	}
+	return nil
+}
+
+func extractTCPProxy(fc *listener.FilterChain) *tcpproxy.TcpProxy {
+	for _, f := range fc.GetFilters() {
+		if f.GetName() != wellknown.TCPProxy {
+			continue
+		}
+		t := &tcpproxy.TcpProxy{}
+		if err := f.GetTypedConfig().UnmarshalTo(t); err != nil {
+			return nil
+		}
+		return t
+	}
+	return nil
+}
+
+func describeDownstreamTLS(fc *listener.FilterChain) string {
+	t := &tls.DownstreamTlsContext{}
+	if err := fc.GetTransportSocket().GetTypedConfig().UnmarshalTo(t); err != nil {
+		return "terminates TLS"
+	}
+	if t.GetRequireClientCertificate().GetValue() {
+		return "terminates mutual TLS, client certificate required"
+	}
+	return "terminates TLS"
+}
+
+func describeUpstreamTLS(c *cluster.Cluster) string {
+	if c.GetTransportSocket() != nil {
+		return describeUpstreamTLSContext(c.GetTransportSocket())
+	}
+	for _, m := range c.GetTransportSocketMatches() {
+		if len(m.GetMatch().GetFields()) == 0 {
+			continue
+		}
+		var labels []string
+		for k, v := range m.GetMatch().GetFields() {
+			labels = append(labels, k+"="+v.GetStringValue())
+		}
+		// Istio auto mTLS: a match on the tlsMode label, with a plaintext fallback.
+		return fmt.Sprintf("%s to endpoints labeled %s, plaintext otherwise",
+			describeUpstreamTLSContext(m.GetTransportSocket()), strings.Join(labels, ","))
+	}
+	return "plaintext"
+}
+
+func describeUpstreamTLSContext(ts *core.TransportSocket) string {
+	t := &tls.UpstreamTlsContext{}
+	if err := ts.GetTypedConfig().UnmarshalTo(t); err != nil {
+		return "plaintext"
+	}
+	mutual := len(t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()) > 0 ||
+		len(t.GetCommonTlsContext().GetTlsCertificates()) > 0
+	out := "TLS"
+	if mutual {
+		out = "mutual TLS"
+	}
+	if t.GetSni() != "" {
+		out += " with SNI " + t.GetSni()
+	}
+	return out
+}
+
+func describeDiscoveryType(c *cluster.Cluster) string {
+	if c.GetClusterType() != nil {
+		return c.GetClusterType().GetName()
+	}
+	return c.GetType().String()
+}
+
+func describeAddress(a *core.Address) string {
+	if p := a.GetPipe().GetPath(); p != "" {
+		return p
+	}
+	if a.GetEnvoyInternalAddress() != nil {
+		return "internal " + a.GetEnvoyInternalAddress().GetServerListenerName()
+	}
+	sa := a.GetSocketAddress()
+	if sa == nil {
+		return ""
+	}
+	return net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))
+}
+
+func describeFilterChainMatch(m *listener.FilterChainMatch) string {
+	var out []string
+	if m.GetDestinationPort() != nil {
+		out = append(out, fmt.Sprintf("port %d", m.GetDestinationPort().GetValue()))
+	}
+	for _, r := range m.GetPrefixRanges() {
+		out = append(out, fmt.Sprintf("address %s/%d", r.GetAddressPrefix(), r.GetPrefixLen().GetValue()))
+	}
+	if len(m.GetServerNames()) > 0 {
+		out = append(out, "sni "+strings.Join(m.GetServerNames(), ","))
+	}
+	if m.GetTransportProtocol() != "" {
+		out = append(out, "transport "+m.GetTransportProtocol())
+	}
+	if len(m.GetApplicationProtocols()) > 0 {
+		out = append(out, "alpn "+strings.Join(m.GetApplicationProtocols(), ","))
+	}
+	if len(out) == 0 {
+		return "default"
+	}
+	return strings.Join(out, ", ")
+}
+
+func describeRedirect(r *route.RedirectAction) string {
+	var out []string
+	if r.GetSchemeRedirect() != "" {
+		out = append(out, "scheme "+r.GetSchemeRedirect())
+	}
+	if r.GetHttpsRedirect() {
+		out = append(out, "scheme https")
+	}
+	if r.GetHostRedirect() != "" {
+		out = append(out, "host "+r.GetHostRedirect())
+	}
+	if r.GetPathRedirect() != "" {
+		out = append(out, "path "+r.GetPathRedirect())
+	}
+	return strings.Join(out, ", ")
+}
+
+// configSource returns the Istio resource recorded in the metadata of a generated Envoy resource, such as
+// "VirtualService reviews.default".
+func configSource(md *core.Metadata) string {
+	cfg := md.GetFilterMetadata()[istioMetadataKey].GetFields()["config"].GetStringValue()
+	// The config is recorded as /apis/<group>/<version>/namespaces/<namespace>/<kebab-case-kind>/<name>
+	pieces := strings.Split(cfg, "/")
+	if len(pieces) != 8 || pieces[1] != "apis" || pieces[4] != "namespaces" {
+		return ""
+	}
+	kind := ""
+	for _, part := range strings.Split(pieces[6], "-") {
+		if part != "" {
+			kind += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return fmt.Sprintf("%s %s.%s", kind, pieces[7], pieces[5])
+}
@@ -0,0 +1,399 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	admin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+const (
+	reviewsV1 = "outbound|9080|v1|reviews.default.svc.cluster.local"
+	reviewsV2 = "outbound|9080|v2|reviews.default.svc.cluster.local"
+)
+
+func socketAddress(address string, port uint32) *core.Address {
+	return &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{
+		Address:       address,
+		PortSpecifier: &core.SocketAddress_PortValue{PortValue: port},
+	}}}
+}
+
+func istioMetadata(config string) *core.Metadata {
+	return &core.Metadata{FilterMetadata: map[string]*structpb.Struct{
+		istioMetadataKey: {Fields: map[string]*structpb.Value{"config": structpb.NewStringValue(config)}},
+	}}
+}
+
+func tcpProxyFilter(cluster string) *listener.Filter {
+	return &listener.Filter{
+		Name: wellknown.TCPProxy,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(&tcpproxy.TcpProxy{
+			StatPrefix:       cluster,
+			ClusterSpecifier: &tcpproxy.TcpProxy_Cluster{Cluster: cluster},
+		})},
+	}
+}
+
+func edsCluster(name string) *cluster.Cluster {
+	mtls := &tls.UpstreamTlsContext{
+		Sni: name,
+		CommonTlsContext: &tls.CommonTlsContext{
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{{Name: "default"}},
+		},
+	}
+	return &cluster.Cluster{
+		Name:                 name,
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+		Metadata:             istioMetadata("/apis/networking.istio.io/v1alpha3/namespaces/default/destination-rule/reviews"),
+		TransportSocketMatches: []*cluster.Cluster_TransportSocketMatch{
+			{
+				Name:  "tlsMode-istio",
+				Match: &structpb.Struct{Fields: map[string]*structpb.Value{"tlsMode": structpb.NewStringValue("istio")}},
+				TransportSocket: &core.TransportSocket{
+					Name:       wellknown.TransportSocketTls,
+					ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(mtls)},
+				},
+			},
+			{
+				Name:            "tlsMode-disabled",
+				Match:           &structpb.Struct{},
+				TransportSocket: &core.TransportSocket{Name: wellknown.TransportSocketRawBuffer},
+			},
+		},
+	}
+}
+
+func loadAssignment(name, address string) *endpoint.ClusterLoadAssignment {
+	return &endpoint.ClusterLoadAssignment{
+		ClusterName: name,
+		Endpoints: []*endpoint.LocalityLbEndpoints{{
+			LbEndpoints: []*endpoint.LbEndpoint{{
+				HostIdentifier: &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{Address: socketAddress(address, 9080)}},
+				HealthStatus:   core.HealthStatus_HEALTHY,
+			}},
+		}},
+	}
+}
+
+// buildConfigDump returns the config dump of a sidecar with a single HTTP service, reviews:9080, whose requests
+// for /v2 from the user jason are split between two subsets.
+func buildConfigDump(t *testing.T) []byte {
+	httpListener := &listener.Listener{
+		Name:    "0.0.0.0_9080",
+		Address: socketAddress("0.0.0.0", 9080),
+		ListenerFilters: []*listener.ListenerFilter{
+			{Name: wellknown.TLSInspector},
+			{Name: wellknown.HTTPInspector},
+		},
+		FilterChains: []*listener.FilterChain{{
+			Name: "0.0.0.0_9080",
+			FilterChainMatch: &listener.FilterChainMatch{
+				TransportProtocol:    rawBufferTransportProtocol,
+				ApplicationProtocols: []string{"http/1.1", "h2c"},
+			},
+			Filters: []*listener.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(&hcm.HttpConnectionManager{
+					StatPrefix: "outbound_0.0.0.0_9080",
+					RouteSpecifier: &hcm.HttpConnectionManager_Rds{Rds: &hcm.Rds{
+						RouteConfigName: "9080",
+					}},
+				})},
+			}},
+		}},
+		DefaultFilterChain: &listener.FilterChain{
+			Name:    "PassthroughFilterChain",
+			Filters: []*listener.Filter{tcpProxyFilter("PassthroughCluster")},
+		},
+	}
+	virtualOutbound := &listener.Listener{
+		Name:    "virtualOutbound",
+		Address: socketAddress("0.0.0.0", 15001),
+		FilterChains: []*listener.FilterChain{{
+			Name:    "PassthroughFilterChain",
+			Filters: []*listener.Filter{tcpProxyFilter("PassthroughCluster")},
+		}},
+	}
+	routes := &route.RouteConfiguration{
+		Name: "9080",
+		VirtualHosts: []*route.VirtualHost{
+			{
+				Name:    "reviews.default.svc.cluster.local:9080",
+				Domains: []string{"reviews.default.svc.cluster.local", "reviews", "reviews:9080"},
+				Routes: []*route.Route{
+					{
+						Name: "jason",
+						Match: &route.RouteMatch{
+							PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/v2"},
+							Headers: []*route.HeaderMatcher{{
+								Name: "x-user",
+								HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{StringMatch: &matcher.StringMatcher{
+									MatchPattern: &matcher.StringMatcher_Exact{Exact: "jason"},
+								}},
+							}},
+						},
+						Action: &route.Route_Route{Route: &route.RouteAction{
+							ClusterSpecifier: &route.RouteAction_WeightedClusters{WeightedClusters: &route.WeightedCluster{
+								Clusters: []*route.WeightedCluster_ClusterWeight{
+									{Name: reviewsV1, Weight: wrapperspb.UInt32(80)},
+									{Name: reviewsV2, Weight: wrapperspb.UInt32(20)},
+								},
+							}},
+						}},
+						Metadata: istioMetadata("/apis/networking.istio.io/v1alpha3/namespaces/default/virtual-service/reviews"),
+					},
+					{
+						Name:  "default",
+						Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+						Action: &route.Route_Route{Route: &route.RouteAction{
+							ClusterSpecifier: &route.RouteAction_Cluster{Cluster: reviewsV1},
+						}},
+						Metadata: istioMetadata("/apis/networking.istio.io/v1alpha3/namespaces/default/virtual-service/reviews"),
+					},
+				},
+			},
+			{
+				Name:    "secure.default.svc.cluster.local:9080",
+				Domains: []string{"secure.default.svc.cluster.local", "secure.default.svc.cluster.local:9080"},
+				Routes: []*route.Route{{
+					Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+					Action: &route.Route_Redirect{Redirect: &route.RedirectAction{
+						SchemeRewriteSpecifier: &route.RedirectAction_HttpsRedirect{HttpsRedirect: true},
+					}},
+				}},
+			},
+		},
+	}
+	passthrough := &cluster.Cluster{
+		Name:                 "PassthroughCluster",
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_ORIGINAL_DST},
+	}
+
+	dump := &admin.ConfigDump{Configs: []*anypb.Any{
+		protoconv.MessageToAny(&admin.ListenersConfigDump{DynamicListeners: []*admin.ListenersConfigDump_DynamicListener{
+			{Name: httpListener.Name, ActiveState: &admin.ListenersConfigDump_DynamicListenerState{
+				Listener: protoconv.MessageToAny(httpListener),
+			}},
+			{Name: virtualOutbound.Name, ActiveState: &admin.ListenersConfigDump_DynamicListenerState{
+				Listener: protoconv.MessageToAny(virtualOutbound),
+			}},
+		}}),
+		protoconv.MessageToAny(&admin.RoutesConfigDump{DynamicRouteConfigs: []*admin.RoutesConfigDump_DynamicRouteConfig{
+			{RouteConfig: protoconv.MessageToAny(routes)},
+		}}),
+		protoconv.MessageToAny(&admin.ClustersConfigDump{DynamicActiveClusters: []*admin.ClustersConfigDump_DynamicCluster{
+			{Cluster: protoconv.MessageToAny(edsCluster(reviewsV1))},
+			{Cluster: protoconv.MessageToAny(edsCluster(reviewsV2))},
+			{Cluster: protoconv.MessageToAny(passthrough)},
+		}}),
+		protoconv.MessageToAny(&admin.EndpointsConfigDump{DynamicEndpointConfigs: []*admin.EndpointsConfigDump_DynamicEndpointConfig{
+			{EndpointConfig: protoconv.MessageToAny(loadAssignment(reviewsV1, "10.0.0.1"))},
+			{EndpointConfig: protoconv.MessageToAny(loadAssignment(reviewsV2, "10.0.0.2"))},
+		}}),
+	}}
+	b, err := protomarshal.Marshal(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func join(parts ...[]Hop) []Hop {
+	var out []Hop
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestExplain(t *testing.T) {
+	cw := &ConfigWriter{}
+	assert.NoError(t, cw.Prime(buildConfigDump(t)))
+
+	reviewsV1Hops := []Hop{
+		{
+			Kind:     "cluster",
+			Name:     reviewsV1,
+			Detail:   "EDS",
+			Resource: "DestinationRule reviews.default",
+		},
+		{
+			Kind:   "upstream tls",
+			Name:   reviewsV1,
+			Detail: "mutual TLS with SNI " + reviewsV1 + " to endpoints labeled tlsMode=istio, plaintext otherwise",
+		},
+		{Kind: "endpoints", Name: reviewsV1, Detail: "10.0.0.1:9080 HEALTHY"},
+	}
+	httpHops := []Hop{
+		{Kind: "listener", Name: "0.0.0.0_9080", Detail: "0.0.0.0:9080"},
+		{Kind: "filter chain", Name: "0.0.0.0_9080", Detail: "transport raw_buffer, alpn http/1.1,h2c"},
+		{Kind: "route config", Name: "9080", Detail: "RDS"},
+	}
+	cases := []struct {
+		name    string
+		url     string
+		headers []string
+		want    []Hop
+		err     error
+	}{
+		{
+			name: "default route",
+			url:  "http://reviews:9080/v2",
+			want: join(httpHops, []Hop{
+				{Kind: "virtual host", Name: "reviews.default.svc.cluster.local:9080", Detail: `host "reviews:9080"`},
+				{Kind: "route", Name: "default", Detail: "prefix /", Resource: "VirtualService reviews.default"},
+			}, reviewsV1Hops),
+		},
+		{
+			name:    "weighted route",
+			url:     "http://reviews:9080/v2/ratings",
+			headers: []string{"X-User=jason"},
+			want: join(httpHops, []Hop{
+				{Kind: "virtual host", Name: "reviews.default.svc.cluster.local:9080", Detail: `host "reviews:9080"`},
+				{Kind: "route", Name: "jason", Detail: "prefix /v2, header x-user", Resource: "VirtualService reviews.default"},
+				{Kind: "cluster", Name: reviewsV1, Detail: "EDS, weight 80/100", Resource: "DestinationRule reviews.default"},
+				reviewsV1Hops[1],
+				reviewsV1Hops[2],
+				{Kind: "cluster", Name: reviewsV2, Detail: "EDS, weight 20/100", Resource: "DestinationRule reviews.default"},
+				{
+					Kind:   "upstream tls",
+					Name:   reviewsV2,
+					Detail: "mutual TLS with SNI " + reviewsV2 + " to endpoints labeled tlsMode=istio, plaintext otherwise",
+				},
+				{Kind: "endpoints", Name: reviewsV2, Detail: "10.0.0.2:9080 HEALTHY"},
+			}),
+		},
+		{
+			name: "redirect",
+			url:  "http://secure.default.svc.cluster.local:9080/",
+			want: join(httpHops, []Hop{
+				{Kind: "virtual host", Name: "secure.default.svc.cluster.local:9080", Detail: `host "secure.default.svc.cluster.local:9080"`},
+				{Kind: "route", Detail: "prefix /"},
+				{Kind: "redirect", Detail: "scheme https"},
+			}),
+		},
+		{
+			name: "unknown host",
+			url:  "http://ratings:9080/",
+			want: httpHops,
+			err:  ErrNoVirtualHost,
+		},
+		{
+			name: "tls to http port",
+			url:  "https://reviews:9080/",
+			want: []Hop{
+				{Kind: "listener", Name: "0.0.0.0_9080", Detail: "0.0.0.0:9080"},
+				{Kind: "filter chain", Name: "PassthroughFilterChain", Detail: "default"},
+				{Kind: "cluster", Name: "PassthroughCluster", Detail: "ORIGINAL_DST"},
+				{Kind: "upstream tls", Name: "PassthroughCluster", Detail: "plaintext"},
+				{Kind: "endpoints", Name: "PassthroughCluster", Detail: "original destination of the connection"},
+			},
+		},
+		{
+			name: "tcp passthrough",
+			url:  "tcp://10.1.1.1:3306",
+			want: []Hop{
+				{Kind: "listener", Name: "virtualOutbound", Detail: "0.0.0.0:15001"},
+				{Kind: "filter chain", Name: "PassthroughFilterChain", Detail: "default"},
+				{Kind: "cluster", Name: "PassthroughCluster", Detail: "ORIGINAL_DST"},
+				{Kind: "upstream tls", Name: "PassthroughCluster", Detail: "plaintext"},
+				{Kind: "endpoints", Name: "PassthroughCluster", Detail: "original destination of the connection"},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewRequest(tt.url, tt.headers, "", "", false)
+			assert.NoError(t, err)
+			got, err := cw.explainer.Explain(req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestPrintExplanation(t *testing.T) {
+	var out bytes.Buffer
+	cw := &ConfigWriter{Stdout: &out}
+	assert.NoError(t, cw.Prime(buildConfigDump(t)))
+	req, err := NewRequest("http://ratings:9080/", nil, "", "", false)
+	assert.NoError(t, err)
+	err = cw.PrintExplanation(req, "short")
+	assert.Equal(t, errors.Is(err, ErrNoVirtualHost), true)
+	lines := strings.Split(out.String(), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	assert.Equal(t, lines, []string{
+		"HOP              NAME             DETAIL                                      RESOURCE",
+		"listener         0.0.0.0_9080     0.0.0.0:9080",
+		"filter chain     0.0.0.0_9080     transport raw_buffer, alpn http/1.1,h2c",
+		"route config     9080             RDS",
+		"",
+	})
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest("http://reviews:9080/v2?user=jason", []string{"x-user=jason", "Host: reviews.default"}, "POST", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, req, Request{
+		Port:    9080,
+		Host:    "reviews.default",
+		Path:    "/v2?user=jason",
+		Method:  "POST",
+		Headers: http.Header{"X-User": []string{"jason"}},
+		HTTP:    true,
+	})
+
+	req, err = NewRequest("https://10.0.0.1/", nil, "", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, req.Port, 443)
+	assert.Equal(t, req.Address, "10.0.0.1")
+	assert.Equal(t, req.TLS, true)
+
+	for _, bad := range []string{"ftp://reviews:21/", "tcp://db", "http:///path", "http://reviews:port/"} {
+		if _, err := NewRequest(bad, nil, "", "", false); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if _, err := NewRequest("http://reviews", []string{"novalue"}, "", "", false); err == nil {
+		t.Errorf("expected error for invalid header")
+	}
+}
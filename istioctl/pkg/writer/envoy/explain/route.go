@@ -0,0 +1,42 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+func describeRouteMatch(m *route.RouteMatch) string {
+	var out []string
+	switch pt := m.GetPathSpecifier().(type) {
+	case *route.RouteMatch_Prefix:
+		out = append(out, "prefix "+pt.Prefix)
+	case *route.RouteMatch_Path:
+		out = append(out, "path "+pt.Path)
+	case *route.RouteMatch_PathSeparatedPrefix:
+		out = append(out, "path prefix "+pt.PathSeparatedPrefix)
+	case *route.RouteMatch_SafeRegex:
+		out = append(out, "regex "+pt.SafeRegex.GetRegex())
+	}
+	for _, h := range m.GetHeaders() {
+		out = append(out, "header "+h.GetName())
+	}
+	for _, q := range m.GetQueryParameters() {
+		out = append(out, "query "+q.GetName())
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,264 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	adminv3 "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// ConfigWriter explains requests against the responses from the Envoy Admin config_dump endpoint
+type ConfigWriter struct {
+	Stdout    io.Writer
+	explainer *Explainer
+}
+
+// Prime loads the config dump into the writer ready for explaining requests
+func (c *ConfigWriter) Prime(b []byte) error {
+	cd := &adminv3.ConfigDump{}
+	err := protomarshal.UnmarshalWithGlobalTypesResolver(b, cd)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling config dump response from Envoy: %v", err)
+	}
+	c.explainer, err = NewExplainer(&configdump.Wrapper{ConfigDump: cd})
+	return err
+}
+
+// PrintExplanation prints the path of the request through the proxy. It returns an error if the request does not
+// make it to an upstream cluster.
+func (c *ConfigWriter) PrintExplanation(req Request, outputFormat string) error {
+	if c.explainer == nil {
+		return fmt.Errorf("config writer has not been primed")
+	}
+	hops, explainErr := c.explainer.Explain(req)
+	switch outputFormat {
+	case "json", "yaml":
+		result := struct {
+			Hops  []Hop  `json:"hops"`
+			Error string `json:"error,omitempty"`
+		}{Hops: hops}
+		if explainErr != nil {
+			result.Error = explainErr.Error()
+		}
+		out, err := json.MarshalIndent(result, "", "    ")
+		if err != nil {
+			return err
+		}
+		if outputFormat == "yaml" {
+			if out, err = yaml.JSONToYAML(out); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(c.Stdout, string(out))
+	default:
+		w := new(tabwriter.Writer).Init(c.Stdout, 0, 8, 5, ' ', 0)
+		fmt.Fprintln(w, "HOP\tNAME\tDETAIL\tRESOURCE")
+		for _, h := range hops {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Kind, h.Name, h.Detail, h.Resource)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return explainErr
+}
+
+// NewExplainer indexes the listeners, routes, clusters and, if present, endpoints of a config dump.
+func NewExplainer(dump *configdump.Wrapper) (*Explainer, error) {
+	e := &Explainer{
+		routes:   map[string]*route.RouteConfiguration{},
+		clusters: map[string]*cluster.Cluster{},
+	}
+
+	listenerDump, err := dump.GetListenerConfigDump()
+	if err != nil {
+		return nil, fmt.Errorf("listener dump: %v", err)
+	}
+	for _, l := range listenerDump.GetDynamicListeners() {
+		if l.GetActiveState().GetListener() == nil {
+			continue
+		}
+		lt := &listener.Listener{}
+		// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+		l.ActiveState.Listener.TypeUrl = v3.ListenerType
+		if err := l.ActiveState.Listener.UnmarshalTo(lt); err != nil {
+			return nil, fmt.Errorf("unmarshal listener: %v", err)
+		}
+		e.listeners = append(e.listeners, lt)
+	}
+	for _, l := range listenerDump.GetStaticListeners() {
+		if l.GetListener() == nil {
+			continue
+		}
+		lt := &listener.Listener{}
+		l.Listener.TypeUrl = v3.ListenerType
+		if err := l.Listener.UnmarshalTo(lt); err != nil {
+			return nil, fmt.Errorf("unmarshal listener: %v", err)
+		}
+		e.listeners = append(e.listeners, lt)
+	}
+
+	routeDump, err := dump.GetRouteConfigDump()
+	if err != nil {
+		return nil, fmt.Errorf("route dump: %v", err)
+	}
+	for _, r := range routeDump.GetDynamicRouteConfigs() {
+		if err := e.addRoute(r.GetRouteConfig()); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range routeDump.GetStaticRouteConfigs() {
+		if err := e.addRoute(r.GetRouteConfig()); err != nil {
+			return nil, err
+		}
+	}
+
+	clusterDump, err := dump.GetClusterConfigDump()
+	if err != nil {
+		return nil, fmt.Errorf("cluster dump: %v", err)
+	}
+	for _, c := range clusterDump.GetDynamicActiveClusters() {
+		if c.GetCluster() == nil {
+			continue
+		}
+		ct := &cluster.Cluster{}
+		c.Cluster.TypeUrl = v3.ClusterType
+		if err := c.Cluster.UnmarshalTo(ct); err != nil {
+			return nil, fmt.Errorf("unmarshal cluster: %v", err)
+		}
+		e.clusters[ct.GetName()] = ct
+	}
+	for _, c := range clusterDump.GetStaticClusters() {
+		if c.GetCluster() == nil {
+			continue
+		}
+		ct := &cluster.Cluster{}
+		c.Cluster.TypeUrl = v3.ClusterType
+		if err := c.Cluster.UnmarshalTo(ct); err != nil {
+			return nil, fmt.Errorf("unmarshal cluster: %v", err)
+		}
+		e.clusters[ct.GetName()] = ct
+	}
+
+	// Endpoints are only in the dump when include_eds is set; without them the endpoints hop is left unresolved.
+	if endpointDump, err := dump.GetEndpointsConfigDump(); err == nil {
+		e.endpoints = map[string]*endpoint.ClusterLoadAssignment{}
+		for _, ep := range endpointDump.GetDynamicEndpointConfigs() {
+			cla := &endpoint.ClusterLoadAssignment{}
+			if err := ep.GetEndpointConfig().UnmarshalTo(cla); err != nil {
+				return nil, fmt.Errorf("unmarshal endpoints: %v", err)
+			}
+			e.endpoints[cla.GetClusterName()] = cla
+		}
+		for _, ep := range endpointDump.GetStaticEndpointConfigs() {
+			cla := &endpoint.ClusterLoadAssignment{}
+			if err := ep.GetEndpointConfig().UnmarshalTo(cla); err != nil {
+				return nil, fmt.Errorf("unmarshal endpoints: %v", err)
+			}
+			e.endpoints[cla.GetClusterName()] = cla
+		}
+	}
+	return e, nil
+}
+
+func (e *Explainer) addRoute(a *anypb.Any) error {
+	if a == nil {
+		return nil
+	}
+	rt := &route.RouteConfiguration{}
+	// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+	a.TypeUrl = v3.RouteType
+	if err := a.UnmarshalTo(rt); err != nil {
+		return fmt.Errorf("unmarshal route: %v", err)
+	}
+	e.routes[rt.GetName()] = rt
+	return nil
+}
+
+// NewRequest builds a request from a URL and headers given as name=value. The scheme selects the protocol: http,
+// https, or tcp for raw TCP connections. If address is empty and the URL host is an IP, it is used as the address.
+func NewRequest(rawURL string, headers []string, method, address string, inbound bool) (Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid url %q: %v", rawURL, err)
+	}
+	req := Request{
+		Address: address,
+		Host:    u.Host,
+		Path:    u.RequestURI(),
+		Method:  method,
+		Headers: http.Header{},
+		Inbound: inbound,
+	}
+	defaultPort := 0
+	switch u.Scheme {
+	case "http":
+		req.HTTP, defaultPort = true, 80
+	case "https":
+		req.HTTP, req.TLS, defaultPort = true, true, 443
+	case "tcp":
+	default:
+		return Request{}, fmt.Errorf("unsupported scheme %q in %q, must be one of http, https or tcp", u.Scheme, rawURL)
+	}
+	if u.Hostname() == "" {
+		return Request{}, fmt.Errorf("url %q has no host", rawURL)
+	}
+	req.Port = defaultPort
+	if p := u.Port(); p != "" {
+		if req.Port, err = strconv.Atoi(p); err != nil {
+			return Request{}, fmt.Errorf("invalid port in %q: %v", rawURL, err)
+		}
+	} else if req.Port == 0 {
+		return Request{}, fmt.Errorf("url %q must have a port", rawURL)
+	}
+	if req.Address == "" && net.ParseIP(u.Hostname()) != nil {
+		req.Address = u.Hostname()
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, "=")
+		if !ok {
+			k, v, ok = strings.Cut(h, ":")
+		}
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return Request{}, fmt.Errorf("invalid header %q, must be name=value", h)
+		}
+		if strings.EqualFold(k, "host") {
+			req.Host = strings.TrimSpace(v)
+			continue
+		}
+		req.Headers.Add(k, strings.TrimSpace(v))
+	}
+	return req, nil
+}
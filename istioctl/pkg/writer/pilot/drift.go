@@ -0,0 +1,237 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"istio.io/istio/istioctl/pkg/writer/compare"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+)
+
+// DriftKind classifies why the configuration of a proxy is not the one Istiod intends it to have.
+type DriftKind string
+
+const (
+	// DriftNacked proxies rejected the last configuration pushed for at least one type.
+	DriftNacked DriftKind = "NACKED"
+	// DriftStale proxies have not acknowledged the last configuration pushed, without rejecting it.
+	DriftStale DriftKind = "STALE"
+	// DriftMismatch proxies hold configuration that differs from the one generated by Istiod.
+	DriftMismatch DriftKind = "CONFIG MISMATCH"
+	// DriftVersionSkew proxies run an older minor version of Istio than the Istiod they are connected to.
+	DriftVersionSkew DriftKind = "VERSION SKEW"
+	// DriftNone proxies are in sync.
+	DriftNone DriftKind = "SYNCED"
+)
+
+// driftOrder is the order drift kinds are reported in, most severe first.
+var driftOrder = []DriftKind{DriftNacked, DriftStale, DriftMismatch, DriftVersionSkew, DriftNone}
+
+// ProxyDrift describes how a single proxy drifted from Istiod.
+type ProxyDrift struct {
+	ProxyID       string                 `json:"proxy"`
+	ClusterID     string                 `json:"cluster_id,omitempty"`
+	Istiod        string                 `json:"istiod"`
+	IstiodVersion string                 `json:"istiod_version,omitempty"`
+	IstioVersion  string                 `json:"istio_version,omitempty"`
+	Kinds         []DriftKind            `json:"kinds"`
+	Nacks         map[string]string      `json:"nacks,omitempty"`
+	Stale         []string               `json:"stale,omitempty"`
+	Diffs         []compare.ResourceDiff `json:"diffs,omitempty"`
+	DiffError     string                 `json:"diff_error,omitempty"`
+}
+
+// DriftGroup is the set of proxies sharing a kind of drift. A proxy drifting in several ways is in several groups.
+type DriftGroup struct {
+	Kind    DriftKind     `json:"kind"`
+	Proxies []*ProxyDrift `json:"proxies"`
+}
+
+// DriftWriter groups the proxies connected to every Istiod by the kind of drift of their configuration
+type DriftWriter struct {
+	Writer io.Writer
+	// IstiodVersions maps the Istiod instances to the version they report. Version skew is only detected for
+	// proxies connected to an Istiod listed here.
+	IstiodVersions map[string]string
+	// Diff, if set, returns the semantic difference between the configuration of the proxy and the one generated
+	// by Istiod. It is not called for proxies that do not run Envoy.
+	Diff func(proxyID string) ([]compare.ResourceDiff, error)
+}
+
+// Analyze classifies the proxies in the syncz responses of each Istiod
+func (s *DriftWriter) Analyze(statuses map[string][]byte) ([]DriftGroup, error) {
+	groups := map[DriftKind][]*ProxyDrift{}
+	for _, istiod := range slices.Sort(maps.Keys(statuses)) {
+		var ss []*writerStatus
+		if err := json.Unmarshal(statuses[istiod], &ss); err != nil {
+			return nil, err
+		}
+		for _, st := range ss {
+			st.pilot = istiod
+			d := s.analyzeProxy(st)
+			for _, k := range d.Kinds {
+				groups[k] = append(groups[k], d)
+			}
+		}
+	}
+	res := make([]DriftGroup, 0, len(groups))
+	for _, k := range driftOrder {
+		proxies := groups[k]
+		if len(proxies) == 0 {
+			continue
+		}
+		sort.Slice(proxies, func(i, j int) bool {
+			if proxies[i].ClusterID != proxies[j].ClusterID {
+				return proxies[i].ClusterID < proxies[j].ClusterID
+			}
+			if proxies[i].ProxyID != proxies[j].ProxyID {
+				return proxies[i].ProxyID < proxies[j].ProxyID
+			}
+			return proxies[i].Istiod < proxies[j].Istiod
+		})
+		res = append(res, DriftGroup{Kind: k, Proxies: proxies})
+	}
+	return res, nil
+}
+
+func (s *DriftWriter) analyzeProxy(st *writerStatus) *ProxyDrift {
+	d := &ProxyDrift{
+		ProxyID:       st.ProxyID,
+		ClusterID:     st.ClusterID,
+		Istiod:        st.pilot,
+		IstiodVersion: s.IstiodVersions[st.pilot],
+		IstioVersion:  st.IstioVersion,
+		Nacks:         st.Nacks,
+	}
+	for _, t := range []struct {
+		name        string
+		sent, acked string
+	}{
+		{"CDS", st.ClusterSent, st.ClusterAcked},
+		{"LDS", st.ListenerSent, st.ListenerAcked},
+		{"EDS", st.EndpointSent, st.EndpointAcked},
+		{"RDS", st.RouteSent, st.RouteAcked},
+		{"ECDS", st.ExtensionConfigSent, st.ExtensionConfigAcked},
+	} {
+		// A rejected type is never acknowledged, it is reported as NACKED rather than STALE.
+		if _, nacked := st.Nacks[t.name]; !nacked && t.sent != "" && t.sent != t.acked {
+			d.Stale = append(d.Stale, t.name)
+		}
+	}
+	if len(d.Nacks) > 0 {
+		d.Kinds = append(d.Kinds, DriftNacked)
+	}
+	if len(d.Stale) > 0 {
+		d.Kinds = append(d.Kinds, DriftStale)
+	}
+	if s.Diff != nil && st.ProxyType != model.Ztunnel {
+		diffs, err := s.Diff(st.ProxyID)
+		if err != nil {
+			d.DiffError = err.Error()
+		}
+		d.Diffs = diffs
+		if len(diffs) > 0 {
+			d.Kinds = append(d.Kinds, DriftMismatch)
+		}
+	}
+	if olderMinorVersion(d.IstioVersion, d.IstiodVersion) {
+		d.Kinds = append(d.Kinds, DriftVersionSkew)
+	}
+	if len(d.Kinds) == 0 {
+		d.Kinds = append(d.Kinds, DriftNone)
+	}
+	return d
+}
+
+// olderMinorVersion returns true if the proxy runs an older major or minor version than Istiod. Versions that
+// cannot be parsed are never considered older.
+func olderMinorVersion(proxy, istiod string) bool {
+	if proxy == "" || istiod == "" {
+		return false
+	}
+	pv, iv := model.ParseIstioVersion(proxy), model.ParseIstioVersion(istiod)
+	if pv == model.MaxIstioVersion || iv == model.MaxIstioVersion {
+		return false
+	}
+	return pv.Compare(&model.IstioVersion{Major: iv.Major, Minor: iv.Minor, Patch: -1}) < 0
+}
+
+// PrintAll prints the proxies in the syncz responses grouped by the kind of drift, in the given output format:
+// either "json" or a table.
+func (s *DriftWriter) PrintAll(statuses map[string][]byte, outputFormat string) error {
+	groups, err := s.Analyze(statuses)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(s.Writer, string(out))
+		return err
+	}
+	w := new(tabwriter.Writer).Init(s.Writer, 0, 9, 5, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DRIFT\tNAME\tCLUSTER\tISTIOD\tVERSION\tDETAILS")
+	for _, g := range groups {
+		for _, d := range g.Proxies {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+				g.Kind, d.ProxyID, d.ClusterID, d.Istiod, d.IstioVersion, driftDetails(g.Kind, d))
+		}
+	}
+	return w.Flush()
+}
+
+// maxDetailDiffs is the number of differing resources listed in the table output.
+const maxDetailDiffs = 3
+
+func driftDetails(kind DriftKind, d *ProxyDrift) string {
+	switch kind {
+	case DriftNacked:
+		details := make([]string, 0, len(d.Nacks))
+		for _, t := range slices.Sort(maps.Keys(d.Nacks)) {
+			// Envoy NACK messages can span several lines.
+			details = append(details, fmt.Sprintf("%s: %s", t, strings.Join(strings.Fields(d.Nacks[t]), " ")))
+		}
+		return strings.Join(details, "; ")
+	case DriftStale:
+		return "not acknowledged: " + strings.Join(d.Stale, ",")
+	case DriftMismatch:
+		details := make([]string, 0, maxDetailDiffs+1)
+		for i, diff := range d.Diffs {
+			if i == maxDetailDiffs {
+				details = append(details, fmt.Sprintf("and %d more", len(d.Diffs)-maxDetailDiffs))
+				break
+			}
+			details = append(details, diff.String())
+		}
+		return strings.Join(details, ", ")
+	case DriftVersionSkew:
+		return fmt.Sprintf("proxy %s, istiod %s", d.IstioVersion, d.IstiodVersion)
+	case DriftNone:
+		if d.DiffError != "" {
+			return "diff failed: " + d.DiffError
+		}
+	}
+	return ""
+}
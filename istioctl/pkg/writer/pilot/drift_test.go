@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"istio.io/istio/istioctl/pkg/writer/compare"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func driftStatuses(t *testing.T) map[string][]byte {
+	t.Helper()
+	synced := func(id, version string) xds.SyncStatus {
+		return xds.SyncStatus{
+			ProxyID:       id,
+			ClusterID:     "cluster1",
+			ProxyType:     model.SidecarProxy,
+			IstioVersion:  version,
+			ClusterSent:   "n1",
+			ClusterAcked:  "n1",
+			ListenerSent:  "n2",
+			ListenerAcked: "n2",
+			RouteSent:     "n3",
+			RouteAcked:    "n3",
+			EndpointSent:  "n4",
+			EndpointAcked: "n4",
+		}
+	}
+	nacked := synced("nacked.default", "1.20.1")
+	nacked.ListenerSent = "n5"
+	nacked.Nacks = map[string]string{"LDS": "Error adding/updating listener(s) 0.0.0.0_8080:\n duplicate filter chain"}
+	stale := synced("stale.default", "1.20.1")
+	stale.ClusterSent, stale.EndpointSent = "n6", "n7"
+	ztunnel := xds.SyncStatus{ProxyID: "ztunnel-abcde.istio-system", ClusterID: "cluster1", ProxyType: model.Ztunnel}
+
+	statuses := map[string][]xds.SyncStatus{
+		"istiod-1-20": {synced("synced.default", "1.20.1"), nacked, stale, ztunnel},
+		"istiod-1-21": {synced("drifted.default", "1.21.0"), synced("old.default", "1.20.1")},
+	}
+	res := map[string][]byte{}
+	for istiod, ss := range statuses {
+		b, err := json.Marshal(ss)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[istiod] = b
+	}
+	return res
+}
+
+func TestDriftWriter_Analyze(t *testing.T) {
+	var diffed []string
+	dw := DriftWriter{
+		IstiodVersions: map[string]string{
+			"istiod-1-20": "1.20-dev.b9d0fa5d3fa7a22fb1b8a31cc2f1a3f2fc5dd5e7-Clean",
+			"istiod-1-21": "1.21.0-b9d0fa5d3fa7a22fb1b8a31cc2f1a3f2fc5dd5e7-Clean",
+		},
+		Diff: func(proxyID string) ([]compare.ResourceDiff, error) {
+			diffed = append(diffed, proxyID)
+			switch proxyID {
+			case "drifted.default":
+				return []compare.ResourceDiff{{Type: "Cluster", Name: "outbound|80||a.default.svc.cluster.local", Change: compare.Missing}}, nil
+			case "stale.default":
+				return nil, fmt.Errorf("could not contact sidecar")
+			}
+			return nil, nil
+		},
+	}
+	groups, err := dw.Analyze(driftStatuses(t))
+	assert.NoError(t, err)
+
+	got := map[DriftKind][]string{}
+	var kinds []DriftKind
+	for _, g := range groups {
+		kinds = append(kinds, g.Kind)
+		for _, p := range g.Proxies {
+			got[g.Kind] = append(got[g.Kind], p.ProxyID)
+		}
+	}
+	assert.Equal(t, kinds, []DriftKind{DriftNacked, DriftStale, DriftMismatch, DriftVersionSkew, DriftNone})
+	assert.Equal(t, got, map[DriftKind][]string{
+		DriftNacked:      {"nacked.default"},
+		DriftStale:       {"stale.default"},
+		DriftMismatch:    {"drifted.default"},
+		DriftVersionSkew: {"old.default"},
+		DriftNone:        {"synced.default", "ztunnel-abcde.istio-system"},
+	})
+	assert.Equal(t, groups[1].Proxies[0].Stale, []string{"CDS", "EDS"})
+	assert.Equal(t, groups[1].Proxies[0].DiffError, "could not contact sidecar")
+	// NACKed types are not reported as stale, and ztunnel has no Envoy configuration to diff.
+	assert.Equal(t, groups[0].Proxies[0].Stale, nil)
+	assert.Equal(t, len(diffed), 5)
+}
+
+func TestDriftWriter_PrintAll(t *testing.T) {
+	out := &bytes.Buffer{}
+	dw := DriftWriter{
+		Writer:         out,
+		IstiodVersions: map[string]string{"istiod-1-21": "1.21.0"},
+	}
+	assert.NoError(t, dw.PrintAll(driftStatuses(t), ""))
+	want := []string{
+		"DRIFT            NAME                           CLUSTER      ISTIOD          VERSION     DETAILS",
+		"NACKED           nacked.default                 cluster1     istiod-1-20     1.20.1      " +
+			"LDS: Error adding/updating listener(s) 0.0.0.0_8080: duplicate filter chain",
+		"STALE            stale.default                  cluster1     istiod-1-20     1.20.1      not acknowledged: CDS,EDS",
+		"VERSION SKEW     old.default                    cluster1     istiod-1-21     1.20.1      proxy 1.20.1, istiod 1.21.0",
+		"SYNCED           drifted.default                cluster1     istiod-1-21     1.21.0",
+		"SYNCED           synced.default                 cluster1     istiod-1-20     1.20.1",
+		"SYNCED           ztunnel-abcde.istio-system     cluster1     istiod-1-20",
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		lines = append(lines, strings.TrimRight(l, " "))
+	}
+	assert.Equal(t, lines, want)
+
+	out.Reset()
+	assert.NoError(t, dw.PrintAll(driftStatuses(t), "json"))
+	var groups []DriftGroup
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &groups))
+	assert.Equal(t, groups[0].Proxies[0].Nacks["LDS"], "Error adding/updating listener(s) 0.0.0.0_8080:\n duplicate filter chain")
+}
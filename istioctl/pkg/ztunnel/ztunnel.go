@@ -0,0 +1,225 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ztunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/cni/pkg/ambient/status"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/config/constants"
+)
+
+const (
+	stateRedirected = "Redirected"
+	stateFailed     = "Failed"
+	statePending    = "Pending"
+)
+
+var (
+	allNamespaces bool
+	showAll       bool
+)
+
+// Cmd returns the ztunnel command.
+func Cmd(ctx cli.Context) *cobra.Command {
+	ztunnelCmd := &cobra.Command{
+		Use:   "ztunnel",
+		Short: "Inspect the ambient data plane",
+		Long:  "A group of commands used to inspect how the pods of the mesh are handled by ztunnel",
+	}
+	ztunnelCmd.AddCommand(statusCmd(ctx))
+	return ztunnelCmd
+}
+
+func statusCmd(ctx cli.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "List the ambient pods whose traffic is not redirected to ztunnel",
+		Long: `Lists the pods of namespaces labeled for ambient whose traffic is not redirected to ztunnel.
+
+The Istio CNI node agent records the outcome of the redirection of each pod in the
+ambient.istio.io/redirection-status annotation: the redirection mode, the ztunnel pod that was active, the
+error if it failed, and when it happened. Pods without the annotation were not handled by the node agent yet.
+The node agent also serves the status of the pods on its node on port 8000, at /ambient/status.`,
+		Example: `  # List the pods of the current namespace that are not redirected
+  istioctl x ztunnel status
+
+  # List the status of every ambient pod in the cluster
+  istioctl x ztunnel status -A --all`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClient()
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %v", err)
+			}
+			ambientSelector := klabels.Set{constants.DataplaneMode: constants.DataplaneModeAmbient}.String()
+			var namespaces []corev1.Namespace
+			if allNamespaces {
+				nsList, err := kubeClient.Kube().CoreV1().Namespaces().List(context.Background(),
+					metav1.ListOptions{LabelSelector: ambientSelector})
+				if err != nil {
+					return err
+				}
+				namespaces = nsList.Items
+			} else {
+				ns, err := kubeClient.Kube().CoreV1().Namespaces().Get(context.Background(),
+					ctx.NamespaceOrDefault(ctx.Namespace()), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				namespaces = []corev1.Namespace{*ns}
+			}
+			var pods []podStatus
+			for i := range namespaces {
+				ns := &namespaces[i]
+				podList, err := kubeClient.Kube().CoreV1().Pods(ns.Name).List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					return err
+				}
+				for j := range podList.Items {
+					pod := &podList.Items[j]
+					if ambientPod(ns, pod) {
+						pods = append(pods, enrollment(pod))
+					}
+				}
+			}
+			return printStatus(c.OutOrStdout(), pods, time.Now())
+		},
+	}
+	cmd.PersistentFlags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the pods of all namespaces")
+	cmd.PersistentFlags().BoolVar(&showAll, "all", false, "Also list the pods whose traffic is redirected")
+	return cmd
+}
+
+// podStatus is the redirection state of a pod, along with the status recorded by the node agent, if any.
+type podStatus struct {
+	pod    *corev1.Pod
+	state  string
+	status *status.Enrollment
+	reason string
+}
+
+// ambientPod returns true if the traffic of the pod should be redirected to ztunnel. This mirrors the checks of
+// the node agent.
+func ambientPod(ns *corev1.Namespace, pod *corev1.Pod) bool {
+	if ns.Labels[constants.DataplaneMode] != constants.DataplaneModeAmbient {
+		return false
+	}
+	if pod.Spec.HostNetwork || pod.Labels["app"] == "ztunnel" {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, f := pod.Annotations[annotation.SidecarStatus.Name]; f {
+		return false
+	}
+	return pod.Annotations[constants.AmbientRedirection] != constants.AmbientRedirectionDisabled
+}
+
+func enrollment(pod *corev1.Pod) podStatus {
+	ps := podStatus{pod: pod}
+	enabled := pod.Annotations[constants.AmbientRedirection] == constants.AmbientRedirectionEnabled
+	v, ok := pod.Annotations[constants.AmbientRedirectionStatus]
+	if !ok {
+		switch {
+		case enabled:
+			// Node agents predating the status annotation only mark the pods they redirected.
+			ps.state = stateRedirected
+		case pod.Status.PodIP == "":
+			ps.state, ps.reason = statePending, "pod IP not yet allocated"
+		default:
+			ps.state, ps.reason = statePending, "not handled by the node agent yet"
+		}
+		return ps
+	}
+	st, err := status.Parse(v)
+	if err != nil {
+		ps.state, ps.reason = stateFailed, fmt.Sprintf("invalid %s annotation: %v", constants.AmbientRedirectionStatus, err)
+		return ps
+	}
+	ps.status = st
+	if st.Redirected {
+		ps.state = stateRedirected
+	} else {
+		ps.state, ps.reason = stateFailed, st.Error
+	}
+	return ps
+}
+
+func printStatus(writer io.Writer, pods []podStatus, now time.Time) error {
+	var shown []podStatus
+	for _, ps := range pods {
+		if showAll || ps.state != stateRedirected {
+			shown = append(shown, ps)
+		}
+	}
+	sort.Slice(shown, func(i, j int) bool {
+		if shown[i].pod.Namespace != shown[j].pod.Namespace {
+			return shown[i].pod.Namespace < shown[j].pod.Namespace
+		}
+		return shown[i].pod.Name < shown[j].pod.Name
+	})
+	if len(shown) == 0 {
+		if len(pods) == 0 {
+			_, _ = fmt.Fprintln(writer, "No ambient pods found.")
+		} else {
+			_, _ = fmt.Fprintf(writer, "All %d ambient pods are redirected to ztunnel.\n", len(pods))
+		}
+		return nil
+	}
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 3, ' ', 0)
+	header := []string{"NAME", "NODE", "STATUS", "MODE", "ZTUNNEL", "LAST UPDATE", "REASON"}
+	if allNamespaces {
+		header = append([]string{"NAMESPACE"}, header...)
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, ps := range shown {
+		mode, ztunnel, updated := "-", "-", "-"
+		if ps.status != nil {
+			mode = ps.status.Mode
+			if ps.status.Ztunnel != "" {
+				ztunnel = ps.status.Ztunnel
+			}
+			if !ps.status.LastUpdateTime.IsZero() {
+				updated = duration.HumanDuration(now.Sub(ps.status.LastUpdateTime)) + " ago"
+			}
+		}
+		reason := ps.reason
+		if reason == "" {
+			reason = "-"
+		}
+		row := []string{ps.pod.Name, ps.pod.Spec.NodeName, ps.state, mode, ztunnel, updated, reason}
+		if allNamespaces {
+			row = append([]string{ps.pod.Namespace}, row...)
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ztunnel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/cni/pkg/ambient/status"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+var now = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func makePod(name string, annotations map[string]string, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func TestAmbientPod(t *testing.T) {
+	ambientNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "default",
+		Labels: map[string]string{constants.DataplaneMode: constants.DataplaneModeAmbient},
+	}}
+	sidecarNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	pod := makePod("app", nil, "10.0.0.1")
+	assert.Equal(t, ambientPod(ambientNs, pod), true)
+	assert.Equal(t, ambientPod(sidecarNs, pod), false)
+
+	optOut := makePod("app", map[string]string{constants.AmbientRedirection: constants.AmbientRedirectionDisabled}, "10.0.0.1")
+	assert.Equal(t, ambientPod(ambientNs, optOut), false)
+
+	sidecar := makePod("app", map[string]string{"sidecar.istio.io/status": "{}"}, "10.0.0.1")
+	assert.Equal(t, ambientPod(ambientNs, sidecar), false)
+
+	completed := makePod("job", nil, "10.0.0.1")
+	completed.Status.Phase = corev1.PodSucceeded
+	assert.Equal(t, ambientPod(ambientNs, completed), false)
+
+	hostNetwork := makePod("agent", nil, "10.0.0.1")
+	hostNetwork.Spec.HostNetwork = true
+	assert.Equal(t, ambientPod(ambientNs, hostNetwork), false)
+}
+
+func TestPrintStatus(t *testing.T) {
+	redirected := status.Enrollment{Redirected: true, Mode: "iptables", Ztunnel: "ztunnel-abcde", LastUpdateTime: now.Add(-time.Hour)}
+	failed := status.Enrollment{
+		Mode:           "iptables",
+		Ztunnel:        "ztunnel-abcde",
+		Error:          "failed to add pod to ipset: exit status 1",
+		LastUpdateTime: now.Add(-5 * time.Minute),
+	}
+	pods := []podStatus{
+		enrollment(makePod("redirected", map[string]string{
+			constants.AmbientRedirection:       constants.AmbientRedirectionEnabled,
+			constants.AmbientRedirectionStatus: redirected.String(),
+		}, "10.0.0.1")),
+		enrollment(makePod("legacy", map[string]string{constants.AmbientRedirection: constants.AmbientRedirectionEnabled}, "10.0.0.2")),
+		enrollment(makePod("failed", map[string]string{constants.AmbientRedirectionStatus: failed.String()}, "10.0.0.3")),
+		enrollment(makePod("starting", nil, "")),
+		enrollment(makePod("invalid", map[string]string{constants.AmbientRedirectionStatus: "yes"}, "10.0.0.4")),
+	}
+	assert.Equal(t, pods[0].state, stateRedirected)
+	assert.Equal(t, pods[1].state, stateRedirected)
+
+	var out bytes.Buffer
+	assert.NoError(t, printStatus(&out, pods, now))
+	assert.Equal(t, out.String(), `NAME       NODE     STATUS    MODE       ZTUNNEL         LAST UPDATE   REASON
+failed     node-1   Failed    iptables   ztunnel-abcde   5m ago        failed to add pod to ipset: exit status 1
+invalid    node-1   Failed    -          -               -             invalid ambient.istio.io/redirection-status annotation: invalid character 'y' looking for beginning of value
+starting   node-1   Pending   -          -               -             pod IP not yet allocated
+`)
+
+	out.Reset()
+	assert.NoError(t, printStatus(&out, pods[:2], now))
+	assert.Equal(t, out.String(), "All 2 ambient pods are redirected to ztunnel.\n")
+
+	out.Reset()
+	assert.NoError(t, printStatus(&out, nil, now))
+	assert.Equal(t, out.String(), "No ambient pods found.\n")
+}
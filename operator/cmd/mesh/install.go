@@ -38,6 +38,7 @@ import (
 	"istio.io/istio/operator/pkg/helmreconciler"
 	"istio.io/istio/operator/pkg/manifest"
 	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/postrender"
 	"istio.io/istio/operator/pkg/translate"
 	"istio.io/istio/operator/pkg/util/clog"
 	"istio.io/istio/operator/pkg/util/progress"
@@ -73,6 +74,14 @@ type InstallArgs struct {
 	ManifestsPath string
 	// Revision is the Istio control plane revision the command targets.
 	Revision string
+	// Plan prints the changes the install makes to the cluster, without making them.
+	Plan bool
+	// Overlays selects the fragments of overlay libraries to overlay on the profile.
+	Overlays OverlayArgs
+	// PostRender selects a post-render hook transforming the generated objects before they are applied and pruned.
+	PostRender PostRenderArgs
+	// NodeAgentRollout selects how the DaemonSets of the node agents, ztunnel and istio-cni, are upgraded.
+	NodeAgentRollout string
 }
 
 func (a *InstallArgs) String() string {
@@ -87,6 +96,9 @@ func (a *InstallArgs) String() string {
 	b.WriteString("Set:              " + fmt.Sprint(a.Set) + "\n")
 	b.WriteString("ManifestsPath:    " + a.ManifestsPath + "\n")
 	b.WriteString("Revision:         " + a.Revision + "\n")
+	b.WriteString("Plan:             " + fmt.Sprint(a.Plan) + "\n")
+	b.WriteString("Overlays:         " + fmt.Sprint(a.Overlays.Names) + "\n")
+	b.WriteString("NodeAgentRollout: " + a.NodeAgentRollout + "\n")
 	return b.String()
 }
 
@@ -103,6 +115,9 @@ func addInstallFlags(cmd *cobra.Command, args *InstallArgs) {
 	cmd.PersistentFlags().StringVarP(&args.ManifestsPath, "charts", "", "", ChartsDeprecatedStr)
 	cmd.PersistentFlags().StringVarP(&args.ManifestsPath, "manifests", "d", "", ManifestsFlagHelpStr)
 	cmd.PersistentFlags().StringVarP(&args.Revision, "revision", "r", "", revisionFlagHelpStr)
+	addOverlayFlags(cmd, &args.Overlays)
+	addPostRenderFlags(cmd, &args.PostRender)
+	cmd.PersistentFlags().StringVar(&args.NodeAgentRollout, "node-agent-rollout", "rolling", nodeAgentRolloutFlagHelpStr)
 }
 
 // InstallCmdWithArgs generates an Istio install manifest and applies it to a cluster
@@ -124,6 +139,12 @@ func InstallCmdWithArgs(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Opt
 
   # To override a setting that includes dots, escape them with a backslash (\).  Your shell may require enclosing quotes.
   istioctl install --set "values.sidecarInjectorWebhook.injectedAnnotations.container\.apparmor\.security\.beta\.kubernetes\.io/istio-proxy=runtime/default"
+
+  # Upgrade ztunnel and istio-cni one node at a time, evicting the ambient pods of each node first
+  istioctl install --set profile=ambient --node-agent-rollout drain
+
+  # Preview the changes the demo profile makes to the cluster, without making them
+  istioctl install --set profile=demo --plan
 `,
 		Args: cobra.ExactArgs(0),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
@@ -141,6 +162,9 @@ func InstallCmdWithArgs(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Opt
 
 	addFlags(ic, rootArgs)
 	addInstallFlags(ic, iArgs)
+	ic.PersistentFlags().BoolVar(&iArgs.Plan, "plan", false,
+		"Server-side dry-run every object against the cluster and print the objects that would be created, updated "+
+			"or pruned, along with the workloads, webhooks, CRDs and revision tags affected, without changing anything.")
 	return ic
 }
 
@@ -168,8 +192,17 @@ func Install(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Options, stdOu
 	}
 
 	setFlags := applyFlagAliases(iArgs.Set, iArgs.ManifestsPath, iArgs.Revision)
+	postRenderer, err := iArgs.PostRender.postRenderer()
+	if err != nil {
+		return err
+	}
+	nodeRollout, err := helmreconciler.ParseNodeRolloutStrategy(iArgs.NodeAgentRollout)
+	if err != nil {
+		return err
+	}
 
-	_, iop, err := manifest.GenerateConfig(iArgs.InFilenames, setFlags, iArgs.Force, kubeClient, l)
+	_, iop, err := manifest.GenerateConfigWithOverlays(iArgs.InFilenames, iArgs.Overlays.overlays(), setFlags, iArgs.Force,
+		kubeClient, l)
 	if err != nil {
 		return fmt.Errorf("generate config: %v", err)
 	}
@@ -184,7 +217,7 @@ func Install(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Options, stdOu
 	_ = detectIstioVersionDiff(p, tag, ns, kubeClient, setFlags)
 
 	// Warn users if they use `istioctl install` without any config args.
-	if !rootArgs.DryRun && !iArgs.SkipConfirmation {
+	if !rootArgs.DryRun && !iArgs.Plan && !iArgs.SkipConfirmation {
 		prompt := fmt.Sprintf("This will install the Istio %s %q profile (with components: %s) into the cluster. Proceed? (y/N)",
 			tag, profile, humanReadableJoin(enabledComponents))
 		if profile == "empty" {
@@ -201,9 +234,19 @@ func Install(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Options, stdOu
 
 	iop.Name = savedIOPName(iop)
 
+	if iArgs.Plan {
+		plan, err := PlanManifests(iop, iArgs.Force, postRenderer, kubeClient, client, l)
+		if err != nil {
+			return fmt.Errorf("failed to plan install: %v", err)
+		}
+		plan.Write(stdOut)
+		return nil
+	}
+
 	// Detect whether previous installation exists prior to performing the installation.
 	exists := revtag.PreviousInstallExists(context.Background(), kubeClient.Kube())
-	iop, err = InstallManifests(iop, iArgs.Force, rootArgs.DryRun, kubeClient, client, iArgs.ReadinessTimeout, l)
+	iop, err = InstallManifests(iop, iArgs.Force, rootArgs.DryRun, postRenderer, nodeRollout, kubeClient, client,
+		iArgs.ReadinessTimeout, l)
 	if err != nil {
 		return fmt.Errorf("failed to install manifests: %v", err)
 	}
@@ -242,18 +285,21 @@ func Install(rootArgs *RootArgs, iArgs *InstallArgs, logOpts *log.Options, stdOu
 // InstallManifests generates manifests from the given istiooperator instance and applies them to the
 // cluster. See GenManifests for more description of the manifest generation process.
 //
-//	force   validation warnings are written to logger but command is not aborted
-//	DryRun  all operations are done but nothing is written
+//	force         validation warnings are written to logger but command is not aborted
+//	DryRun        all operations are done but nothing is written
+//	postRenderer  if set, transforms the rendered objects before they are applied and pruned
+//	nodeRollout   selects how the DaemonSets of the node agents are upgraded
 //
 // Returns final IstioOperator after installation if successful.
-func InstallManifests(iop *v1alpha12.IstioOperator, force bool, dryRun bool, kubeClient kube.Client, client client.Client,
-	waitTimeout time.Duration, l clog.Logger,
+func InstallManifests(iop *v1alpha12.IstioOperator, force bool, dryRun bool, postRenderer postrender.PostRenderer,
+	nodeRollout helmreconciler.NodeRolloutStrategy, kubeClient kube.Client, client client.Client, waitTimeout time.Duration,
+	l clog.Logger,
 ) (*v1alpha12.IstioOperator, error) {
 	// Needed in case we are running a test through this path that doesn't start a new process.
 	cache.FlushObjectCaches()
 	opts := &helmreconciler.Options{
 		DryRun: dryRun, Log: l, WaitTimeout: waitTimeout, ProgressLog: progress.NewLog(),
-		Force: force,
+		Force: force, PostRenderer: postRenderer, NodeRollout: nodeRollout,
 	}
 	reconciler, err := helmreconciler.NewHelmReconciler(client, kubeClient, iop, opts)
 	if err != nil {
@@ -282,6 +328,35 @@ func InstallManifests(iop *v1alpha12.IstioOperator, force bool, dryRun bool, kub
 	return iop, saveIOPToCluster(reconciler, string(iopStr))
 }
 
+// InstallRevision installs the control plane of a revision from the given IstioOperator files and set flags, like
+// `istioctl install --revision` without the confirmation and processing of the default webhook.
+func InstallRevision(kubeClient kube.CLIClient, client client.Client, inFilenames, setFlags []string, revision string,
+	force bool, waitTimeout time.Duration, l clog.Logger,
+) (*v1alpha12.IstioOperator, error) {
+	_, iop, err := manifest.GenerateConfig(inFilenames, applyFlagAliases(setFlags, "", revision), force, kubeClient, l)
+	if err != nil {
+		return nil, fmt.Errorf("generate config: %v", err)
+	}
+	iop.Name = savedIOPName(iop)
+	return InstallManifests(iop, force, false, nil, helmreconciler.NodeRolloutRolling, kubeClient, client, waitTimeout, l)
+}
+
+// PlanManifests generates manifests from the given istiooperator instance and computes the changes applying them
+// makes to the cluster, without changing it.
+func PlanManifests(iop *v1alpha12.IstioOperator, force bool, postRenderer postrender.PostRenderer, kubeClient kube.Client,
+	client client.Client, l clog.Logger,
+) (*helmreconciler.InstallPlan, error) {
+	cache.FlushObjectCaches()
+	opts := &helmreconciler.Options{
+		DryRun: true, Log: l, ProgressLog: progress.NewLog(), Force: force, PostRenderer: postRenderer,
+	}
+	reconciler, err := helmreconciler.NewHelmReconciler(client, kubeClient, iop, opts)
+	if err != nil {
+		return nil, err
+	}
+	return reconciler.Plan()
+}
+
 func savedIOPName(iop *v1alpha12.IstioOperator) string {
 	ret := name.InstalledSpecCRPrefix
 	if iop.Name != "" {
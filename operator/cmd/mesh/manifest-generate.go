@@ -27,6 +27,7 @@ import (
 	"istio.io/istio/operator/pkg/manifest"
 	"istio.io/istio/operator/pkg/name"
 	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/postrender"
 	"istio.io/istio/operator/pkg/util/clog"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/log"
@@ -59,6 +60,10 @@ type ManifestGenerateArgs struct {
 	Components []string
 	// Filter is the list of components to render
 	Filter []string
+	// Overlays selects the fragments of overlay libraries to overlay on the profile.
+	Overlays OverlayArgs
+	// PostRender selects a post-render hook transforming the generated objects.
+	PostRender PostRenderArgs
 }
 
 func (a *ManifestGenerateArgs) String() string {
@@ -70,6 +75,7 @@ func (a *ManifestGenerateArgs) String() string {
 	b.WriteString("ManifestsPath: " + a.ManifestsPath + "\n")
 	b.WriteString("Revision:      " + a.Revision + "\n")
 	b.WriteString("Components:    " + fmt.Sprint(a.Components) + "\n")
+	b.WriteString("Overlays:      " + fmt.Sprint(a.Overlays.Names) + "\n")
 	return b.String()
 }
 
@@ -84,6 +90,8 @@ func addManifestGenerateFlags(cmd *cobra.Command, args *ManifestGenerateArgs) {
 	cmd.PersistentFlags().StringSliceVar(&args.Components, "component", nil, ComponentFlagHelpStr)
 	cmd.PersistentFlags().StringSliceVar(&args.Filter, "filter", nil, "")
 	_ = cmd.PersistentFlags().MarkHidden("filter")
+	addOverlayFlags(cmd, &args.Overlays)
+	addPostRenderFlags(cmd, &args.PostRender)
 
 	cmd.PersistentFlags().StringVarP(&args.KubeConfigPath, "kubeconfig", "c", "", KubeConfigFlagHelpStr+" Requires --cluster-specific.")
 	cmd.PersistentFlags().StringVar(&args.Context, "context", "", ContextFlagHelpStr+" Requires --cluster-specific.")
@@ -106,6 +114,12 @@ func ManifestGenerateCmd(rootArgs *RootArgs, mgArgs *ManifestGenerateArgs, logOp
   # Generate the demo profile
   istioctl manifest generate --set profile=demo
 
+  # Transform the generated objects with a kustomization
+  istioctl manifest generate --kustomize ./kustomize
+
+  # Compose the default profile with fragments of an overlay library
+  istioctl manifest generate --overlay-path ./overlays --overlay observability --overlay sizing/large --overlay-var replicas=3
+
   # To override a setting that includes dots, escape them with a backslash (\).  Your shell may require enclosing quotes.
   istioctl manifest generate --set "values.sidecarInjectorWebhook.injectedAnnotations.container\.apparmor\.security\.beta\.kubernetes\.io/istio-proxy=runtime/default"
 `,
@@ -136,11 +150,20 @@ func ManifestGenerate(args *RootArgs, mgArgs *ManifestGenerateArgs, logopts *log
 		kubeClient = kc
 	}
 
-	manifests, _, err := manifest.GenManifests(mgArgs.InFilenames, applyFlagAliases(mgArgs.Set, mgArgs.ManifestsPath, mgArgs.Revision),
-		mgArgs.Force, mgArgs.Filter, kubeClient, l)
+	manifests, _, err := manifest.GenManifestsWithOverlays(mgArgs.InFilenames, mgArgs.Overlays.overlays(),
+		applyFlagAliases(mgArgs.Set, mgArgs.ManifestsPath, mgArgs.Revision), mgArgs.Force, mgArgs.Filter, kubeClient, l)
 	if err != nil {
 		return err
 	}
+	pr, err := mgArgs.PostRender.postRenderer()
+	if err != nil {
+		return err
+	}
+	if pr != nil {
+		if manifests, err = postrender.Apply(pr, manifests); err != nil {
+			return fmt.Errorf("post-render: %v", err)
+		}
+	}
 
 	if len(mgArgs.Components) != 0 {
 		filteredManifests := name.ManifestMap{}
@@ -0,0 +1,558 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/api/operator/v1alpha1"
+	"istio.io/istio/operator/pkg/compare"
+	"istio.io/istio/operator/pkg/helm"
+	"istio.io/istio/operator/pkg/manifest"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/tpath"
+	"istio.io/istio/operator/pkg/translate"
+	"istio.io/istio/operator/pkg/util"
+	"istio.io/istio/operator/pkg/util/clog"
+	"istio.io/istio/pkg/slices"
+)
+
+type manifestTranslateArgs struct {
+	// inFilenames are the IstioOperator files translated to Helm values.
+	inFilenames []string
+	// set are the --set overrides of the IstioOperator.
+	set []string
+	// helmValues maps chart names to the Helm values files translated to an IstioOperator.
+	helmValues map[string]string
+	// output is the directory the Helm values files are written to, or the file the IstioOperator is written to.
+	output string
+	// manifestsPath is a path to a charts and profiles directory in the local filesystem.
+	manifestsPath string
+	// revision is the revision of the IstioOperator.
+	revision string
+	// force proceeds even if there are validation errors.
+	force bool
+	// skipVerify skips rendering both sides of the translation and diffing them.
+	skipVerify bool
+	// verbose generates verbose diffs.
+	verbose bool
+}
+
+func addManifestTranslateFlags(cmd *cobra.Command, args *manifestTranslateArgs) {
+	cmd.PersistentFlags().StringSliceVarP(&args.inFilenames, "filename", "f", nil,
+		"Path to the IstioOperator files to translate to Helm values.")
+	cmd.PersistentFlags().StringArrayVarP(&args.set, "set", "s", nil, setFlagHelpStr)
+	cmd.PersistentFlags().StringToStringVar(&args.helmValues, "helm-values", nil,
+		"Helm values files to translate to an IstioOperator, as chart=path. Charts are "+strings.Join(translateChartNames(), ", ")+".")
+	cmd.PersistentFlags().StringVarP(&args.output, "output", "o", "",
+		"Directory the Helm values files are written to, or file the IstioOperator is written to. Defaults to the console.")
+	cmd.PersistentFlags().StringVarP(&args.manifestsPath, "manifests", "d", "", ManifestsFlagHelpStr)
+	cmd.PersistentFlags().StringVarP(&args.revision, "revision", "r", "", revisionFlagHelpStr)
+	cmd.PersistentFlags().BoolVar(&args.force, "force", false, ForceFlagHelpStr)
+	cmd.PersistentFlags().BoolVar(&args.skipVerify, "skip-verify", false,
+		"Do not verify the translation by rendering and diffing the manifests of both sides.")
+	cmd.PersistentFlags().BoolVarP(&args.verbose, "verbose", "v", false, "Verbose output of the verification diff.")
+}
+
+func manifestTranslateCmd(args *manifestTranslateArgs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "translate",
+		Short: "Translates between an IstioOperator and the Helm values of the Istio charts",
+		Long: `The translate subcommand translates an IstioOperator to a Helm values file per chart of its enabled components,
+or Helm values files of the Istio charts to an IstioOperator. Settings without an equivalent on the other side are
+reported as warnings. The translation is verified by rendering the manifests of both sides and diffing them.`,
+		Example: `  # Translate an IstioOperator to Helm values files in the values directory
+  istioctl manifest translate -f iop.yaml -o values
+
+  # Translate Helm values files to an IstioOperator
+  istioctl manifest translate --helm-values base=base.yaml,istiod=istiod.yaml,ingress=ingress.yaml -o iop.yaml`,
+		Args: func(cmd *cobra.Command, a []string) error {
+			if len(a) != 0 {
+				return fmt.Errorf("translate accepts no positional arguments, got %#v", a)
+			}
+			if len(args.helmValues) > 0 && (len(args.inFilenames) > 0 || len(args.set) > 0) {
+				return fmt.Errorf("--helm-values cannot be used with --filename or --set")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			l := clog.NewConsoleLogger(cmd.OutOrStdout(), cmd.ErrOrStderr(), installerScope)
+			if len(args.helmValues) > 0 {
+				return translateHelmValuesToIOP(args, l)
+			}
+			return translateIOPToHelmValues(args, l)
+		},
+	}
+}
+
+// translateCharts are the charts installed by the IstioOperator components, by the names manifest translate uses
+// for them.
+var translateCharts = []struct {
+	chart     string
+	component name.ComponentName
+}{
+	{"base", name.IstioBaseComponentName},
+	{"istiod", name.PilotComponentName},
+	{"istiod-remote", name.IstiodRemoteComponentName},
+	{"cni", name.CNIComponentName},
+	{"ztunnel", name.ZtunnelComponentName},
+	{"ingress", name.IngressComponentName},
+	{"egress", name.EgressComponentName},
+}
+
+func translateChartNames() []string {
+	var res []string
+	for _, c := range translateCharts {
+		res = append(res, c.chart)
+	}
+	return res
+}
+
+// chartK8sSettings are the K8s settings of components which their chart sets from the Helm values of the same name,
+// under the values root of the component. The other K8s settings have no Helm values equivalent.
+var chartK8sSettings = map[name.ComponentName][]string{
+	name.PilotComponentName:   {"env", "nodeSelector", "podAnnotations", "replicaCount", "resources", "serviceAnnotations"},
+	name.IngressComponentName: {"env", "nodeSelector", "podAnnotations", "resources", "serviceAnnotations", "tolerations"},
+	name.EgressComponentName:  {"env", "nodeSelector", "podAnnotations", "resources", "serviceAnnotations", "tolerations"},
+	name.CNIComponentName:     {"podAnnotations", "resources"},
+	name.ZtunnelComponentName: {"env", "podAnnotations", "resources"},
+}
+
+// chartValues are the Helm values of a chart installing an IstioOperator component.
+type chartValues struct {
+	chart     string
+	component name.ComponentName
+	// gateway is the name of the gateway, for gateway components.
+	gateway   string
+	namespace string
+	values    map[string]any
+}
+
+func (c *chartValues) filename() string {
+	if c.gateway == "" {
+		return c.chart + YAMLSuffix
+	}
+	return c.chart + "-" + c.gateway + YAMLSuffix
+}
+
+func translateIOPToHelmValues(args *manifestTranslateArgs, l clog.Logger) error {
+	manifests, iop, err := manifest.GenManifests(args.inFilenames, applyFlagAliases(args.set, args.manifestsPath, args.revision),
+		args.force, nil, nil, l)
+	if err != nil {
+		return err
+	}
+	charts, warnings, err := iopToChartValues(iop.Spec)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		l.LogAndErrorf("! %s", w)
+	}
+
+	if args.output == "" {
+		for _, c := range charts {
+			l.Print(fmt.Sprintf("# %s\n%s%s", c.filename(), util.ToYAML(c.values), object.YAMLSeparator))
+		}
+	} else {
+		if err := os.MkdirAll(args.output, os.ModePerm); err != nil {
+			return err
+		}
+		for _, c := range charts {
+			if err := os.WriteFile(filepath.Join(args.output, c.filename()), []byte(util.ToYAML(c.values)), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range charts {
+		l.LogAndErrorf("Install %s with: helm install <release> %s -n %s -f %s", c.filename(),
+			filepath.Join("manifests", helm.ChartsSubdirName, chartSubdir(c.component)), c.namespace, c.filename())
+	}
+
+	if args.skipVerify {
+		return nil
+	}
+	return verifyTranslation(manifests, charts, iop.Spec.InstallPackagePath, args.verbose, l)
+}
+
+// iopToChartValues translates the enabled components of iop to the Helm values of their charts, and returns
+// warnings for the settings without a Helm values equivalent.
+func iopToChartValues(iop *v1alpha1.IstioOperatorSpec) ([]*chartValues, []string, error) {
+	t := translate.NewTranslator()
+	var res []*chartValues
+	var warnings []string
+	for _, c := range translateCharts {
+		if c.component.IsGateway() {
+			gateways := iop.GetComponents().GetIngressGateways()
+			path := "components.ingressGateways"
+			if c.component == name.EgressComponentName {
+				gateways = iop.GetComponents().GetEgressGateways()
+				path = "components.egressGateways"
+			}
+			for _, gw := range gateways {
+				if !gw.GetEnabled().GetValue() {
+					continue
+				}
+				cv, w, err := componentChartValues(t, iop, c.chart, c.component, gw, gw.GetK8S(), fmt.Sprintf("%s[name=%s]", path, gw.Name))
+				if err != nil {
+					return nil, nil, err
+				}
+				cv.gateway = gw.Name
+				if gw.Namespace != "" {
+					cv.namespace = gw.Namespace
+				}
+				res = append(res, cv)
+				warnings = append(warnings, w...)
+			}
+			continue
+		}
+		enabled, err := t.IsComponentEnabled(c.component, iop)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !enabled {
+			continue
+		}
+		spec, _, err := tpath.GetFromStructPath(iop, "Components."+string(c.component))
+		if err != nil {
+			return nil, nil, err
+		}
+		// The base component has its own spec type.
+		var k8s *v1alpha1.KubernetesResourcesSpec
+		if cs, ok := spec.(interface {
+			GetK8S() *v1alpha1.KubernetesResourcesSpec
+		}); ok {
+			k8s = cs.GetK8S()
+		}
+		cv, w, err := componentChartValues(t, iop, c.chart, c.component, spec, k8s, "components."+firstCharToLower(string(c.component)))
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, cv)
+		warnings = append(warnings, w...)
+	}
+	return res, warnings, nil
+}
+
+// componentChartValues returns the Helm values of the chart of a component, including its K8s settings with a
+// Helm values equivalent, and warnings for the others.
+func componentChartValues(t *translate.Translator, iop *v1alpha1.IstioOperatorSpec, chart string, cn name.ComponentName,
+	spec any, k8s *v1alpha1.KubernetesResourcesSpec, path string,
+) (*chartValues, []string, error) {
+	valuesYAML, err := t.TranslateHelmValues(iop, spec, cn)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal([]byte(valuesYAML), &values); err != nil {
+		return nil, nil, err
+	}
+	namespace, err := name.Namespace(cn, iop)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	if k8s != nil {
+		settings := map[string]any{}
+		if err := yaml.Unmarshal([]byte(util.ToYAMLWithJSONPB(k8s)), &settings); err != nil {
+			return nil, nil, err
+		}
+		cm := t.ComponentMap(string(cn))
+		for _, k := range sortedKeys(settings) {
+			v := settings[k]
+			f := slices.Contains(chartK8sSettings[cn], k)
+			if f && k == "env" {
+				v, f = envToHelmValues(v)
+			}
+			if !f {
+				warnings = append(warnings, fmt.Sprintf("%s.k8s.%s has no Helm values equivalent and is not translated", path, k))
+				continue
+			}
+			key := k
+			if !cm.FlattenValues {
+				key = cm.ToHelmValuesTreeRoot + "." + k
+			}
+			if err := tpath.WriteNode(values, util.PathFromString(key), v); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return &chartValues{chart: chart, component: cn, namespace: namespace, values: values}, warnings, nil
+}
+
+// envToHelmValues converts K8s env vars to the env map of Helm values. Env vars with a valueFrom have no
+// equivalent.
+func envToHelmValues(v any) (map[string]any, bool) {
+	res := map[string]any{}
+	envs, _ := v.([]any)
+	for _, e := range envs {
+		env, _ := e.(map[string]any)
+		if _, f := env["valueFrom"]; f {
+			return nil, false
+		}
+		res[fmt.Sprint(env["name"])] = env["value"]
+	}
+	return res, true
+}
+
+func translateHelmValuesToIOP(args *manifestTranslateArgs, l clog.Logger) error {
+	charts, err := readChartValues(args.helmValues)
+	if err != nil {
+		return err
+	}
+	iopYAML, warnings, err := chartValuesToIOP(charts, args.revision, args.force)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		l.LogAndErrorf("! %s", w)
+	}
+	if args.output == "" {
+		l.Print(iopYAML)
+	} else if err := os.WriteFile(args.output, []byte(iopYAML), 0o644); err != nil {
+		return err
+	}
+
+	if args.skipVerify {
+		return nil
+	}
+	f, err := os.CreateTemp("", "istio-operator-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(iopYAML); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	setFlags := applyFlagAliases(nil, args.manifestsPath, "")
+	manifests, _, err := manifest.GenManifests([]string{f.Name()}, setFlags, true, nil, nil, l)
+	if err != nil {
+		return fmt.Errorf("failed to render the translated IstioOperator: %v", err)
+	}
+	return verifyTranslation(manifests, charts, args.manifestsPath, args.verbose, l)
+}
+
+// readChartValues reads the Helm values files, keyed by chart name.
+func readChartValues(files map[string]string) ([]*chartValues, error) {
+	var res []*chartValues
+	for _, c := range translateCharts {
+		file, f := files[c.chart]
+		if !f {
+			continue
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]any{}
+		if err := yaml.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("could not parse the Helm values of chart %s: %v", c.chart, err)
+		}
+		cv := &chartValues{chart: c.chart, component: c.component, namespace: "istio-system", values: values}
+		if ns, f, _ := tpath.Find(values, util.PathFromString("global.istioNamespace")); f && ns != nil {
+			cv.namespace = fmt.Sprint(ns)
+		}
+		if c.component.IsGateway() {
+			gw, _, _ := tpath.Find(values, util.PathFromString(
+				translate.NewTranslator().ComponentMap(string(c.component)).ToHelmValuesTreeRoot+".name"))
+			if gw != nil {
+				cv.gateway = fmt.Sprint(gw)
+			}
+		}
+		res = append(res, cv)
+	}
+	for chart := range files {
+		found := false
+		for _, c := range translateCharts {
+			found = found || c.chart == chart
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown chart %q, charts are %s", chart, strings.Join(translateChartNames(), ", "))
+		}
+	}
+	return res, nil
+}
+
+// chartValuesToIOP translates the Helm values of charts to an IstioOperator enabling the components of the charts,
+// and returns warnings for the values without an IstioOperator equivalent and the ones set differently by several
+// charts.
+func chartValuesToIOP(charts []*chartValues, revision string, force bool) (string, []string, error) {
+	rt := translate.NewReverseTranslator()
+	spec := map[string]any{"profile": "empty"}
+	if revision != "" {
+		spec["revision"] = revision
+	}
+	components := map[string]any{}
+	var gateways map[string][]any
+	var warnings []string
+	// setBy records the chart setting each leaf of spec, to report conflicts.
+	setBy := map[string]string{}
+	for _, c := range charts {
+		b, err := yaml.Marshal(c.values)
+		if err != nil {
+			return "", nil, err
+		}
+		cs, err := rt.TranslateFromValueToSpec(b, false)
+		if err != nil {
+			if !force {
+				return "", nil, fmt.Errorf("the Helm values of chart %s have no IstioOperator equivalent, use --force to drop them: %v",
+					c.chart, err)
+			}
+			warnings = append(warnings, fmt.Sprintf("dropped the Helm values of chart %s without an IstioOperator equivalent: %v", c.chart, err))
+			if cs, err = rt.TranslateFromValueToSpec(b, true); err != nil {
+				return "", nil, err
+			}
+		}
+		tree := map[string]any{}
+		if err := yaml.Unmarshal([]byte(util.ToYAMLWithJSONPB(cs)), &tree); err != nil {
+			return "", nil, err
+		}
+		translated, _ := tree["components"].(map[string]any)
+		delete(tree, "components")
+
+		if c.component.IsGateway() {
+			key := "ingressGateways"
+			if c.component == name.EgressComponentName {
+				key = "egressGateways"
+			}
+			gws, _ := translated[key].([]any)
+			for _, gw := range gws {
+				if gwm, ok := gw.(map[string]any); ok {
+					gwm["enabled"] = true
+					if c.namespace != "" {
+						gwm["namespace"] = c.namespace
+					}
+				}
+			}
+			if gateways == nil {
+				gateways = map[string][]any{}
+			}
+			gateways[key] = append(gateways[key], gws...)
+		} else {
+			key := firstCharToLower(string(c.component))
+			component, _ := translated[key].(map[string]any)
+			if component == nil {
+				component = map[string]any{}
+			}
+			component["enabled"] = true
+			components[key] = component
+		}
+		warnings = append(warnings, mergeChartTree(spec, tree, nil, c.chart, setBy)...)
+	}
+	for key, gws := range gateways {
+		components[key] = gws
+	}
+	spec["components"] = components
+	iop := map[string]any{
+		"apiVersion": "install.istio.io/v1alpha1",
+		"kind":       "IstioOperator",
+		"spec":       spec,
+	}
+	return util.ToYAML(iop), warnings, nil
+}
+
+// mergeChartTree merges the tree translated from the values of a chart into spec, and returns warnings for the
+// leaves set differently by another chart. The first chart setting a leaf wins.
+func mergeChartTree(spec, tree map[string]any, path util.Path, chart string, setBy map[string]string) []string {
+	var warnings []string
+	for _, k := range sortedKeys(tree) {
+		v := tree[k]
+		p := append(path[:len(path):len(path)], k)
+		sub, isMap := v.(map[string]any)
+		existing, f := spec[k]
+		if isMap {
+			existingMap, ok := existing.(map[string]any)
+			if !f || !ok {
+				existingMap = map[string]any{}
+				spec[k] = existingMap
+			}
+			warnings = append(warnings, mergeChartTree(existingMap, sub, p, chart, setBy)...)
+			continue
+		}
+		if !f {
+			spec[k] = v
+			setBy[p.String()] = chart
+			continue
+		}
+		if !reflect.DeepEqual(existing, v) {
+			warnings = append(warnings, fmt.Sprintf("%s is set to %v by chart %s and to %v by chart %s, using %v",
+				p, existing, setBy[p.String()], v, chart, existing))
+		}
+	}
+	return warnings
+}
+
+// verifyTranslation renders the charts with their Helm values and diffs the result with the manifests rendered from
+// the IstioOperator.
+func verifyTranslation(iopManifests name.ManifestMap, charts []*chartValues, manifestsPath string, verbose bool, l clog.Logger) error {
+	var helmManifests []string
+	for _, c := range charts {
+		r := helm.NewHelmRenderer(manifestsPath, chartSubdir(c.component), string(c.component), c.namespace, nil)
+		if err := r.Run(); err != nil {
+			return fmt.Errorf("failed to load chart %s: %v", c.chart, err)
+		}
+		m, err := r.RenderManifest(util.ToYAML(c.values))
+		if err != nil {
+			return fmt.Errorf("failed to render chart %s with %s: %v", c.chart, c.filename(), err)
+		}
+		helmManifests = append(helmManifests, m)
+	}
+	var iopAll []string
+	for _, ms := range iopManifests {
+		iopAll = append(iopAll, ms...)
+	}
+	diff, err := compare.ManifestDiff(name.MergeManifestSlices(iopAll), name.MergeManifestSlices(helmManifests), verbose)
+	if err != nil {
+		return err
+	}
+	if diff != "" {
+		l.Print(diff + "\n")
+		return fmt.Errorf("the manifests rendered from the IstioOperator and from the Helm values differ")
+	}
+	l.LogAndErrorf("✔ Verified the translation: the IstioOperator and the Helm values render the same manifests.")
+	return nil
+}
+
+func chartSubdir(cn name.ComponentName) string {
+	return translate.NewTranslator().ComponentMap(string(cn)).HelmSubdir
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstCharToLower(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
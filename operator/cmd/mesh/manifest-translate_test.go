@@ -0,0 +1,67 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestEnvToHelmValues(t *testing.T) {
+	env, ok := envToHelmValues([]any{
+		map[string]any{"name": "A", "value": "1"},
+		map[string]any{"name": "B", "value": "2"},
+	})
+	assert.Equal(t, ok, true)
+	assert.Equal(t, env, map[string]any{"A": "1", "B": "2"})
+
+	_, ok = envToHelmValues([]any{
+		map[string]any{"name": "A", "value": "1"},
+		map[string]any{"name": "POD", "valueFrom": map[string]any{"fieldRef": map[string]any{"fieldPath": "metadata.name"}}},
+	})
+	assert.Equal(t, ok, false)
+}
+
+func TestMergeChartTree(t *testing.T) {
+	spec := map[string]any{"profile": "empty"}
+	setBy := map[string]string{}
+	warnings := mergeChartTree(spec, map[string]any{
+		"hub": "docker.io/istio",
+		"values": map[string]any{
+			"global": map[string]any{"istioNamespace": "istio-system", "logAsJson": true},
+		},
+	}, nil, "base", setBy)
+	assert.Equal(t, len(warnings), 0)
+
+	warnings = mergeChartTree(spec, map[string]any{
+		"hub": "docker.io/istio",
+		"values": map[string]any{
+			"global": map[string]any{"istioNamespace": "istio-system", "logAsJson": false},
+			"pilot":  map[string]any{"traceSampling": 1.0},
+		},
+	}, nil, "istiod", setBy)
+	assert.Equal(t, warnings, []string{
+		"values.global.logAsJson is set to true by chart base and to false by chart istiod, using true",
+	})
+	assert.Equal(t, spec, map[string]any{
+		"profile": "empty",
+		"hub":     "docker.io/istio",
+		"values": map[string]any{
+			"global": map[string]any{"istioNamespace": "istio-system", "logAsJson": true},
+			"pilot":  map[string]any{"traceSampling": 1.0},
+		},
+	})
+}
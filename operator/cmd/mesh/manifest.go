@@ -25,16 +25,18 @@ func ManifestCmd(logOpts *log.Options) *cobra.Command {
 	mc := &cobra.Command{
 		Use:   "manifest",
 		Short: "Commands related to Istio manifests",
-		Long:  "The manifest command generates and diffs Istio manifests.",
+		Long:  "The manifest command generates, diffs and translates Istio manifests.",
 	}
 
 	mgcArgs := &ManifestGenerateArgs{}
 	mdcArgs := &manifestDiffArgs{}
+	mtcArgs := &manifestTranslateArgs{}
 
 	args := &RootArgs{}
 
 	mgc := ManifestGenerateCmd(args, mgcArgs, logOpts)
 	mdc := manifestDiffCmd(args, mdcArgs)
+	mtc := manifestTranslateCmd(mtcArgs)
 	ic := InstallCmd(logOpts)
 
 	addFlags(mc, args)
@@ -43,9 +45,11 @@ func ManifestCmd(logOpts *log.Options) *cobra.Command {
 
 	addManifestGenerateFlags(mgc, mgcArgs)
 	addManifestDiffFlags(mdc, mdcArgs)
+	addManifestTranslateFlags(mtc, mtcArgs)
 
 	mc.AddCommand(mgc)
 	mc.AddCommand(mdc)
+	mc.AddCommand(mtc)
 	mc.AddCommand(ic)
 
 	return mc
@@ -39,6 +39,10 @@ type profileDumpArgs struct {
 	outputFormat string
 	// manifestsPath is a path to a charts and profiles directory in the local filesystem with a release tgz.
 	manifestsPath string
+	// overlays selects the fragments of overlay libraries to overlay on the profile.
+	overlays OverlayArgs
+	// provenance annotates each field with the layer setting it.
+	provenance bool
 }
 
 const (
@@ -47,6 +51,10 @@ const (
 	flagsOutput = "flags"
 )
 
+// generatedSource is the provenance of the fields set by none of the layers, like the hub and tag filled in at build
+// time.
+const generatedSource = "generated"
+
 const (
 	istioOperatorTreeString = `
 apiVersion: install.istio.io/v1alpha1
@@ -62,6 +70,9 @@ func addProfileDumpFlags(cmd *cobra.Command, args *profileDumpArgs) {
 		"Output format: one of json|yaml|flags")
 	cmd.PersistentFlags().StringVarP(&args.manifestsPath, "charts", "", "", ChartsDeprecatedStr)
 	cmd.PersistentFlags().StringVarP(&args.manifestsPath, "manifests", "d", "", ManifestsFlagHelpStr)
+	addOverlayFlags(cmd, &args.overlays)
+	cmd.PersistentFlags().BoolVar(&args.provenance, "provenance", false,
+		"Annotate each field with the layer setting it: the profile, an overlay, a file or --set. Requires -o flags.")
 }
 
 func profileDumpCmd(rootArgs *RootArgs, pdArgs *profileDumpArgs, logOpts *log.Options) *cobra.Command {
@@ -129,6 +140,9 @@ func profileDump(args []string, rootArgs *RootArgs, pdArgs *profileDumpArgs, l c
 	if err := validateProfileOutputFormatFlag(pdArgs.outputFormat); err != nil {
 		return err
 	}
+	if pdArgs.provenance && pdArgs.outputFormat != flagsOutput {
+		return fmt.Errorf("--provenance requires the %s output format", flagsOutput)
+	}
 
 	setFlags := applyFlagAliases(make([]string, 0), pdArgs.manifestsPath, "")
 	if len(args) == 1 {
@@ -139,10 +153,23 @@ func profileDump(args []string, rootArgs *RootArgs, pdArgs *profileDumpArgs, l c
 		return fmt.Errorf("could not configure logs: %s", err)
 	}
 
-	y, _, err := manifest.GenerateConfig(pdArgs.inFilenames, setFlags, true, nil, l)
+	overlays := pdArgs.overlays.overlays()
+	y, _, err := manifest.GenerateConfigWithOverlays(pdArgs.inFilenames, overlays, setFlags, true, nil, l)
 	if err != nil {
 		return err
 	}
+	if pdArgs.provenance {
+		layers, err := manifest.ConfigLayers(pdArgs.inFilenames, overlays, setFlags)
+		if err != nil {
+			return err
+		}
+		f, err := provenanceFlags(y, layers, pdArgs.configPath)
+		if err != nil {
+			return err
+		}
+		l.Print(strings.Join(f, "\n") + "\n")
+		return nil
+	}
 	y, err = tpath.GetConfigSubtree(y, "spec")
 	if err != nil {
 		return err
@@ -232,32 +259,105 @@ func yamlToFlags(yml string) ([]string, error) {
 }
 
 func walk(path, separator string, obj any) ([]string, error) {
+	accum := make([]string, 0)
+	walkLeaves(path, separator, obj, func(path, value string) {
+		accum = append(accum, path+"="+value)
+	})
+	return accum, nil
+}
+
+// walkLeaves calls fn with the --set flag path and value of each leaf of obj.
+func walkLeaves(path, separator string, obj any, fn func(path, value string)) {
 	switch v := obj.(type) {
 	case map[string]any:
-		accum := make([]string, 0)
 		for key, vv := range v {
-			childwalk, err := walk(fmt.Sprintf("%s%s%s", path, separator, pathComponent(key)), ".", vv)
-			if err != nil {
-				return accum, err
-			}
-			accum = append(accum, childwalk...)
+			walkLeaves(fmt.Sprintf("%s%s%s", path, separator, pathComponent(key)), ".", vv, fn)
 		}
-		return accum, nil
 	case []any:
-		accum := make([]string, 0)
 		for idx, vv := range v {
-			indexwalk, err := walk(fmt.Sprintf("%s[%d]", path, idx), ".", vv)
-			if err != nil {
-				return accum, err
-			}
-			accum = append(accum, indexwalk...)
+			walkLeaves(fmt.Sprintf("%s[%d]", path, idx), ".", vv, fn)
 		}
-		return accum, nil
 	case string:
-		return []string{fmt.Sprintf("%s=%q", path, v)}, nil
+		fn(path, fmt.Sprintf("%q", v))
 	default:
-		return []string{fmt.Sprintf("%s=%v", path, v)}, nil
+		fn(path, fmt.Sprintf("%v", v))
+	}
+}
+
+// flagValues returns the value of each leaf of the configuration subtree at configPath of an IstioOperator, by --set
+// flag path. The whole spec is used if configPath is empty.
+func flagValues(iopYAML, configPath string) (map[string]string, error) {
+	tree := map[string]any{}
+	if err := yaml.Unmarshal([]byte(iopYAML), &tree); err != nil {
+		return nil, err
+	}
+	root := "spec"
+	if configPath != "" {
+		root += "." + configPath
+	}
+	res := map[string]string{}
+	node, found, err := tpath.Find(tree, util.PathFromString(root))
+	if err != nil || !found {
+		return res, err
+	}
+	walkLeaves("", "", node, func(path, value string) {
+		res[path] = value
+	})
+	return res, nil
+}
+
+// fieldSources returns the source of the layer setting each field, by --set flag path, along with the value of the
+// fields once all the layers are overlaid. A field is set by the last layer setting it or changing its value, which
+// also accounts for the list entries merged by key.
+func fieldSources(layers []manifest.Layer, configPath string) (map[string]string, map[string]string, error) {
+	sources := map[string]string{}
+	values := map[string]string{}
+	merged := ""
+	for _, layer := range layers {
+		var err error
+		if merged, err = util.OverlayIOP(merged, layer.YAML); err != nil {
+			return nil, nil, fmt.Errorf("could not overlay %s: %v", layer.Source, err)
+		}
+		own, err := flagValues(layer.YAML, configPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read %s: %v", layer.Source, err)
+		}
+		current, err := flagValues(merged, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for path, v := range current {
+			_, set := own[path]
+			if prev, f := values[path]; set || !f || prev != v {
+				sources[path] = layer.Source
+			}
+		}
+		values = current
+	}
+	return sources, values, nil
+}
+
+// provenanceFlags returns the --set flags of the generated configuration iopYAML, each annotated with the layer
+// setting it. The fields set by none of the layers, or whose value was changed after overlaying them, are generated.
+func provenanceFlags(iopYAML string, layers []manifest.Layer, configPath string) ([]string, error) {
+	final, err := flagValues(iopYAML, configPath)
+	if err != nil {
+		return nil, err
+	}
+	sources, values, err := fieldSources(layers, configPath)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(final))
+	for path, v := range final {
+		source, f := sources[path]
+		if !f || values[path] != v {
+			source = generatedSource
+		}
+		res = append(res, fmt.Sprintf("%s=%s\t# %s", path, v, source))
 	}
+	sort.Strings(res)
+	return res, nil
 }
 
 func pathComponent(component string) string {
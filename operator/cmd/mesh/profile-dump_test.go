@@ -22,7 +22,9 @@ import (
 
 	"github.com/kylelemons/godebug/diff"
 
+	"istio.io/istio/operator/pkg/manifest"
 	"istio.io/istio/operator/pkg/util"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 func TestProfileDump(t *testing.T) {
@@ -136,3 +138,70 @@ func TestProfileDumpFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestProvenanceFlags(t *testing.T) {
+	layers := []manifest.Layer{
+		{Source: "profile default", YAML: `
+spec:
+  profile: default
+  hub: docker.io/istio
+  components:
+    ingressGateways:
+    - name: istio-ingressgateway
+      enabled: true
+  values:
+    global:
+      logging:
+        level: info
+`},
+		{Source: "overlay observability", YAML: `
+spec:
+  meshConfig:
+    enableTracing: true
+  values:
+    global:
+      logging:
+        level: debug
+`},
+		{Source: "file prod.yaml", YAML: `
+spec:
+  components:
+    ingressGateways:
+    - name: istio-ingressgateway
+      enabled: false
+  meshConfig:
+    enableTracing: true
+`},
+	}
+	generated := `
+spec:
+  profile: default
+  hub: gcr.io/istio-release
+  tag: 1.19.0
+  components:
+    ingressGateways:
+    - name: istio-ingressgateway
+      enabled: false
+  meshConfig:
+    enableTracing: true
+  values:
+    global:
+      logging:
+        level: debug
+`
+	got, err := provenanceFlags(generated, layers, "")
+	assert.NoError(t, err)
+	assert.Equal(t, got, []string{
+		"components.ingressGateways[0].enabled=false\t# file prod.yaml",
+		`components.ingressGateways[0].name="istio-ingressgateway"` + "\t# file prod.yaml",
+		`hub="gcr.io/istio-release"` + "\t# generated",
+		"meshConfig.enableTracing=true\t# file prod.yaml",
+		`profile="default"` + "\t# profile default",
+		`tag="1.19.0"` + "\t# generated",
+		`values.global.logging.level="debug"` + "\t# overlay observability",
+	})
+
+	got, err = provenanceFlags(generated, layers, "values.global")
+	assert.NoError(t, err)
+	assert.Equal(t, got, []string{`logging.level="debug"` + "\t# overlay observability"})
+}
@@ -16,9 +16,12 @@ package mesh
 
 import (
 	"flag"
+	"fmt"
 
 	"github.com/spf13/cobra"
 
+	"istio.io/istio/operator/pkg/manifest"
+	"istio.io/istio/operator/pkg/postrender"
 	binversion "istio.io/istio/operator/version"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/url"
@@ -44,6 +47,14 @@ const (
 If set to true, the user is not prompted and a Yes response is assumed in all cases.`
 	filenameFlagHelpStr = `Path to file containing IstioOperator custom resource
 This flag can be specified multiple times to overlay multiple files. Multiple files are overlaid in left to right order.`
+	nodeAgentRolloutFlagHelpStr = `How the DaemonSets of the node agents, ztunnel and istio-cni, are upgraded: rolling, node, cordon or drain.
+rolling leaves it to the rolling update of the DaemonSets. The others upgrade both agents one node at a time, istio-cni
+then ztunnel, moving on once the new agents of the node are ready: node waits for the ambient pods of the node to be
+redirected to the new ztunnel, synced with istiod, cordon also cordons the node meanwhile, and drain evicts the ambient
+pods of the node first instead. The rollout stops at the first node failing within --readiness-timeout; the nodes not
+upgraded keep the previous agents until the install is run again.`
+	overlayFlagHelpStr = `Name of a fragment of the overlay libraries, e.g. observability/tracing, overlaid on the profile before the
+files. This flag can be specified multiple times, overlays are overlaid in left to right order.`
 	installationCompleteStr            = `Installation complete`
 	ForceFlagHelpStr                   = `Proceed even with validation errors.`
 	MaxConcurrentReconcilesFlagHelpStr = `Defines the concurrency limit for operator to reconcile IstioOperatorSpec in parallel. Default value is 1.`
@@ -70,6 +81,70 @@ func addFlags(cmd *cobra.Command, rootArgs *RootArgs) {
 		false, "Console/log output only, make no changes.")
 }
 
+// OverlayArgs selects the fragments of overlay libraries to overlay on the profile, before the files.
+type OverlayArgs struct {
+	// Names are the names of the fragments, overlaid in order.
+	Names []string
+	// Paths are the directories of the overlay libraries.
+	Paths []string
+	// Vars are the values of the variables of the fragments.
+	Vars map[string]string
+}
+
+func addOverlayFlags(cmd *cobra.Command, args *OverlayArgs) {
+	cmd.PersistentFlags().StringSliceVar(&args.Names, "overlay", nil, overlayFlagHelpStr)
+	cmd.PersistentFlags().StringSliceVar(&args.Paths, "overlay-path", nil,
+		"Directory of an overlay library, holding a <name>.yaml IstioOperator fragment per overlay. "+
+			"Directories are searched in order.")
+	cmd.PersistentFlags().StringToStringVar(&args.Vars, "overlay-var", nil,
+		"Set a variable of the overlays, written ${name} or ${name:-default} in the fragments, e.g. --overlay-var replicas=3.")
+}
+
+// PostRenderArgs selects a post-render hook, transforming the objects rendered from the charts before they are
+// applied.
+type PostRenderArgs struct {
+	// Binary is an executable reading the rendered objects from stdin and writing the transformed ones to stdout.
+	Binary string
+	// Args are the arguments of Binary.
+	Args []string
+	// KustomizeDir is a directory with a kustomization, built with the rendered objects added to its resources.
+	KustomizeDir string
+}
+
+func addPostRenderFlags(cmd *cobra.Command, args *PostRenderArgs) {
+	cmd.PersistentFlags().StringVar(&args.Binary, "post-renderer", "",
+		"Path to an executable transforming the rendered objects before they are applied. It reads them from stdin "+
+			"and writes the transformed objects to stdout, like a Helm post-renderer.")
+	cmd.PersistentFlags().StringArrayVar(&args.Args, "post-renderer-args", nil,
+		"An argument of the --post-renderer executable. This flag can be specified multiple times.")
+	cmd.PersistentFlags().StringVar(&args.KustomizeDir, "kustomize", "",
+		"Path to a directory with a kustomization transforming the rendered objects before they are applied. The "+
+			"rendered objects are added to its resources, so its patches and transformers apply to them.")
+}
+
+// postRenderer returns the selected post-renderer, or nil if none is.
+func (a *PostRenderArgs) postRenderer() (postrender.PostRenderer, error) {
+	switch {
+	case a.Binary != "" && a.KustomizeDir != "":
+		return nil, fmt.Errorf("--post-renderer and --kustomize cannot be used together")
+	case a.Binary != "":
+		return postrender.NewExec(a.Binary, a.Args...)
+	case a.KustomizeDir != "":
+		return postrender.NewKustomize(a.KustomizeDir)
+	case len(a.Args) > 0:
+		return nil, fmt.Errorf("--post-renderer-args requires --post-renderer")
+	}
+	return nil, nil
+}
+
+// overlays returns the selected overlays, or nil if none is.
+func (a *OverlayArgs) overlays() *manifest.Overlays {
+	if len(a.Names) == 0 {
+		return nil
+	}
+	return &manifest.Overlays{Paths: a.Paths, Names: a.Names, Vars: a.Vars}
+}
+
 // GetRootCmd returns the root of the cobra command-tree.
 func GetRootCmd(args []string) *cobra.Command {
 	rootCmd := &cobra.Command{
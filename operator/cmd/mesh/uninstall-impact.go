@@ -0,0 +1,279 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/istioctl/pkg/tag"
+	"istio.io/istio/pkg/kube/multicluster"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// maxImpactedPods bounds the number of pods listed in the impact report, the others are only counted.
+const maxImpactedPods = 30
+
+// revisionImpact is the blast radius of removing a control plane revision: what still depends on it.
+type revisionImpact struct {
+	// revision is the removed revision, or empty for a purge.
+	revision string
+	// tags are the revision tags pointing to the revision.
+	tags []string
+	// namespaces are the namespaces whose injection selects the revision, directly or through a tag, with the label
+	// selecting it.
+	namespaces map[string]string
+	// pods are the pods injected by or pointing to the revision, as namespace/name, excluding gateways.
+	pods []string
+	// gateways are the gateways injected by or installed with the revision, as namespace/name.
+	gateways []string
+	// remoteClusters are the remote clusters which lose their primary control plane, as no other revision remains.
+	remoteClusters []string
+}
+
+func (r *revisionImpact) empty() bool {
+	return len(r.tags) == 0 && len(r.namespaces) == 0 && len(r.pods) == 0 && len(r.gateways) == 0 && len(r.remoteClusters) == 0
+}
+
+// computeRevisionImpact computes what still depends on revision in the cluster. All the revisions are considered
+// removed if purge is set.
+func computeRevisionImpact(ctx context.Context, client kubernetes.Interface, istioNamespace, revision string,
+	purge bool,
+) (*revisionImpact, error) {
+	if revision == "" {
+		revision = tag.DefaultRevisionName
+	}
+	res := &revisionImpact{revision: revision, namespaces: map[string]string{}}
+	if purge {
+		res.revision = ""
+	}
+	removed := func(rev string) bool {
+		if rev == "" {
+			rev = tag.DefaultRevisionName
+		}
+		return purge || rev == revision
+	}
+
+	webhooks, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhooks: %v", err)
+	}
+	remaining := sets.New[string]()
+	hasDefaultTag := false
+	for _, wh := range webhooks.Items {
+		rev, f := wh.Labels[label.IoIstioRev.Name]
+		tagName, tagged := wh.Labels[tag.IstioTagLabel]
+		switch {
+		case tagged:
+			hasDefaultTag = hasDefaultTag || tagName == tag.DefaultRevisionName
+			if removed(rev) {
+				res.tags = append(res.tags, tagName)
+			}
+		case f && !removed(rev):
+			remaining.Insert(rev)
+		}
+	}
+	sort.Strings(res.tags)
+	removedTags := sets.New(res.tags...)
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	for _, ns := range namespaces.Items {
+		if rev := ns.Labels[label.IoIstioRev.Name]; rev != "" {
+			if removed(rev) || removedTags.Contains(rev) {
+				res.namespaces[ns.Name] = fmt.Sprintf("%s=%s", label.IoIstioRev.Name, rev)
+			}
+			continue
+		}
+		// Without a revision label, istio-injection=enabled selects the default tag, or the default revision.
+		if ns.Labels["istio-injection"] != "enabled" {
+			continue
+		}
+		if removedTags.Contains(tag.DefaultRevisionName) || (!hasDefaultTag && removed(tag.DefaultRevisionName)) {
+			res.namespaces[ns.Name] = "istio-injection=enabled"
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	gateways := sets.New[string]()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Namespace == istioNamespace && pod.Labels["app"] == "istiod" {
+			continue
+		}
+		rev, f := podRevision(pod)
+		if !f || !removed(rev) {
+			continue
+		}
+		if isGatewayPod(pod) {
+			gateways.Insert(pod.Namespace + "/" + gatewayName(pod))
+			continue
+		}
+		res.pods = append(res.pods, pod.Namespace+"/"+pod.Name)
+	}
+	sort.Strings(res.pods)
+	res.gateways = sets.SortedList(gateways)
+
+	if remaining.Len() == 0 {
+		secrets, err := client.CoreV1().Secrets(istioNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: multicluster.MultiClusterSecretLabel + "=true",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list remote secrets: %v", err)
+		}
+		clusters := sets.New[string]()
+		for _, s := range secrets.Items {
+			for cluster := range s.Data {
+				clusters.Insert(cluster)
+			}
+		}
+		res.remoteClusters = sets.SortedList(clusters)
+	}
+	return res, nil
+}
+
+// podRevision returns the revision which injected the pod, or the one it points to through its revision label.
+func podRevision(pod *corev1.Pod) (string, bool) {
+	if status, f := pod.Annotations[annotation.SidecarStatus.Name]; f {
+		var s struct {
+			Revision string `json:"revision"`
+		}
+		if err := json.Unmarshal([]byte(status), &s); err == nil {
+			return s.Revision, true
+		}
+	}
+	rev, f := pod.Labels[label.IoIstioRev.Name]
+	return rev, f
+}
+
+// isGatewayPod reports whether the pod is a gateway, either installed by the operator or injected with the gateway
+// template.
+func isGatewayPod(pod *corev1.Pod) bool {
+	if strings.HasSuffix(pod.Labels["operator.istio.io/component"], "Gateways") {
+		return true
+	}
+	return strings.Contains(pod.Annotations[annotation.InjectTemplates.Name], "gateway")
+}
+
+func gatewayName(pod *corev1.Pod) string {
+	if n := pod.Labels["service.istio.io/canonical-name"]; n != "" {
+		return n
+	}
+	if n := pod.Labels["app"]; n != "" {
+		return n
+	}
+	return pod.Name
+}
+
+// String reports what depends on the revision.
+func (r *revisionImpact) String() string {
+	target := fmt.Sprintf("control plane revision %q", r.revision)
+	if r.revision == "" {
+		target = "the control planes"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Removing %s impacts:\n", target)
+	if len(r.tags) > 0 {
+		fmt.Fprintf(&sb, "  Revision tags pointing to it: %s\n", strings.Join(r.tags, ", "))
+	}
+	if len(r.namespaces) > 0 {
+		fmt.Fprintf(&sb, "  Namespaces injected by it: %d\n", len(r.namespaces))
+		for _, ns := range slices.Sort(maps.Keys(r.namespaces)) {
+			fmt.Fprintf(&sb, "    %s (%s)\n", ns, r.namespaces[ns])
+		}
+	}
+	if len(r.pods) > 0 {
+		fmt.Fprintf(&sb, "  Pods injected by it, whose proxies become detached from any control plane: %d\n", len(r.pods))
+		for i, p := range r.pods {
+			if i == maxImpactedPods {
+				fmt.Fprintf(&sb, "    and %d more\n", len(r.pods)-i)
+				break
+			}
+			fmt.Fprintf(&sb, "    %s\n", p)
+		}
+	}
+	if len(r.gateways) > 0 {
+		fmt.Fprintf(&sb, "  Gateways served by it: %s\n", strings.Join(r.gateways, ", "))
+	}
+	if len(r.remoteClusters) > 0 {
+		fmt.Fprintf(&sb, "  Remote clusters using it as their primary: %s\n", strings.Join(r.remoteClusters, ", "))
+	}
+	return sb.String()
+}
+
+// migrationPlan returns the steps moving what depends on the revision to another revision, in the order to run them.
+func (r *revisionImpact) migrationPlan() []string {
+	newRev := "<new-revision>"
+	var steps []string
+	if r.revision != "" {
+		steps = append(steps, fmt.Sprintf("Install the revision to migrate to, if not done yet: istioctl install --revision %s", newRev))
+	}
+	for _, t := range r.tags {
+		steps = append(steps, fmt.Sprintf("Point revision tag %s to the new revision: istioctl tag set %s --revision %s --overwrite",
+			t, t, newRev))
+	}
+	podsByNamespace := map[string]int{}
+	for _, p := range r.pods {
+		podsByNamespace[strings.SplitN(p, "/", 2)[0]]++
+	}
+	for _, ns := range sets.SortedList(sets.New(maps.Keys(r.namespaces)...).InsertAll(maps.Keys(podsByNamespace)...)) {
+		if sel, f := r.namespaces[ns]; f && (r.revision == "" || sel == fmt.Sprintf("%s=%s", label.IoIstioRev.Name, r.revision)) {
+			steps = append(steps, fmt.Sprintf("Label namespace %s with the new revision: kubectl label namespace %s %s=%s --overwrite",
+				ns, ns, label.IoIstioRev.Name, newRev))
+		}
+		if podsByNamespace[ns] > 0 {
+			steps = append(steps, fmt.Sprintf("Restart the %d workload pods of namespace %s so they are injected by the new revision: "+
+				"kubectl rollout restart deployment,statefulset,daemonset -n %s", podsByNamespace[ns], ns, ns))
+		}
+	}
+	for _, gw := range r.gateways {
+		steps = append(steps, fmt.Sprintf("Reinstall or restart gateway %s with the new revision", gw))
+	}
+	for _, c := range r.remoteClusters {
+		steps = append(steps, fmt.Sprintf("Point remote cluster %s to another primary control plane, or remove its remote secret", c))
+	}
+	if r.revision != "" {
+		steps = append(steps, fmt.Sprintf("Uninstall revision %s once nothing depends on it: istioctl uninstall --revision %s",
+			r.revision, r.revision))
+	}
+	return steps
+}
+
+// report prints the impact and the migration plan.
+func (r *revisionImpact) report() string {
+	var sb strings.Builder
+	sb.WriteString(r.String())
+	sb.WriteString("Migrate them first:\n")
+	for i, s := range r.migrationPlan() {
+		fmt.Fprintf(&sb, "  %d. %s\n", i+1, s)
+	}
+	return sb.String()
+}
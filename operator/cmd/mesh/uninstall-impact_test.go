@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	admitv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/operator/pkg/util/clog"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestComputeRevisionImpact(t *testing.T) {
+	webhook := func(name string, labels map[string]string) runtime.Object {
+		return &admitv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	namespace := func(name string, labels map[string]string) runtime.Object {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	pod := func(namespace, name, revision string, labels, annotations map[string]string) runtime.Object {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		if revision != "" {
+			annotations["sidecar.istio.io/status"] = `{"containers":["istio-proxy"],"revision":"` + revision + `"}`
+		}
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations}}
+	}
+	objects := []runtime.Object{
+		webhook("istio-sidecar-injector-1-0", map[string]string{"istio.io/rev": "1-0"}),
+		webhook("istio-sidecar-injector-1-1", map[string]string{"istio.io/rev": "1-1"}),
+		webhook("istio-revision-tag-default", map[string]string{"istio.io/rev": "1-0", "istio.io/tag": "default"}),
+		webhook("istio-revision-tag-prod", map[string]string{"istio.io/rev": "1-1", "istio.io/tag": "prod"}),
+		namespace("relabeled", map[string]string{"istio.io/rev": "1-0"}),
+		namespace("injected", map[string]string{"istio-injection": "enabled"}),
+		namespace("prod", map[string]string{"istio.io/rev": "prod"}),
+		namespace("unrelated", nil),
+		pod("relabeled", "app-1", "1-0", nil, nil),
+		pod("injected", "app-2", "1-0", nil, nil),
+		pod("prod", "app-3", "1-1", nil, nil),
+		pod("unrelated", "app-4", "", nil, nil),
+		pod("istio-system", "istiod-1-0-abc", "", map[string]string{"app": "istiod", "istio.io/rev": "1-0"}, nil),
+		pod("istio-system", "istio-ingressgateway-abc", "",
+			map[string]string{"istio.io/rev": "1-0", "operator.istio.io/component": "IngressGateways", "app": "istio-ingressgateway"}, nil),
+		pod("gateways", "gw-abc", "1-0", map[string]string{"service.istio.io/canonical-name": "gw"},
+			map[string]string{"inject.istio.io/templates": "gateway"}),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-remote-secret-remote", Namespace: "istio-system", Labels: map[string]string{
+				"istio/multiCluster": "true",
+			}},
+			Data: map[string][]byte{"remote": nil},
+		},
+	}
+	client := fake.NewSimpleClientset(objects...)
+
+	impact, err := computeRevisionImpact(context.Background(), client, "istio-system", "1-0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, impact.tags, []string{"default"})
+	assert.Equal(t, impact.namespaces, map[string]string{
+		"relabeled": "istio.io/rev=1-0",
+		"injected":  "istio-injection=enabled",
+	})
+	assert.Equal(t, impact.pods, []string{"injected/app-2", "relabeled/app-1"})
+	assert.Equal(t, impact.gateways, []string{"gateways/gw", "istio-system/istio-ingressgateway"})
+	// Revision 1-1 remains, so the remote clusters may still have a primary.
+	assert.Equal(t, len(impact.remoteClusters), 0)
+	assert.Equal(t, impact.migrationPlan(), []string{
+		"Install the revision to migrate to, if not done yet: istioctl install --revision <new-revision>",
+		"Point revision tag default to the new revision: istioctl tag set default --revision <new-revision> --overwrite",
+		"Restart the 1 workload pods of namespace injected so they are injected by the new revision: " +
+			"kubectl rollout restart deployment,statefulset,daemonset -n injected",
+		"Label namespace relabeled with the new revision: kubectl label namespace relabeled istio.io/rev=<new-revision> --overwrite",
+		"Restart the 1 workload pods of namespace relabeled so they are injected by the new revision: " +
+			"kubectl rollout restart deployment,statefulset,daemonset -n relabeled",
+		"Reinstall or restart gateway gateways/gw with the new revision",
+		"Reinstall or restart gateway istio-system/istio-ingressgateway with the new revision",
+		"Uninstall revision 1-0 once nothing depends on it: istioctl uninstall --revision 1-0",
+	})
+
+	impact, err = computeRevisionImpact(context.Background(), client, "istio-system", "1-1", false)
+	assert.NoError(t, err)
+	assert.Equal(t, impact.tags, []string{"prod"})
+	assert.Equal(t, impact.namespaces, map[string]string{"prod": "istio.io/rev=prod"})
+	assert.Equal(t, impact.pods, []string{"prod/app-3"})
+
+	impact, err = computeRevisionImpact(context.Background(), client, "istio-system", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, impact.tags, []string{"default", "prod"})
+	assert.Equal(t, len(impact.namespaces), 3)
+	assert.Equal(t, impact.pods, []string{"injected/app-2", "prod/app-3", "relabeled/app-1"})
+	assert.Equal(t, impact.remoteClusters, []string{"remote"})
+
+	impact, err = computeRevisionImpact(context.Background(), client, "istio-system", "0-9", false)
+	assert.NoError(t, err)
+	assert.Equal(t, impact.empty(), true)
+}
+
+func TestUninstallRevisionImpact(t *testing.T) {
+	cli := kube.NewFakeClient(
+		&admitv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{
+			Name:   "istio-revision-tag-default",
+			Labels: map[string]string{"istio.io/rev": "1-0", "istio.io/tag": "default"},
+		}},
+	)
+	l := clog.NewConsoleLogger(io.Discard, io.Discard, nil)
+
+	assert.Error(t, checkRevisionImpact(cli, "istio-system", "1-0", false, false, l))
+	assert.NoError(t, checkRevisionImpact(cli, "istio-system", "1-0", false, true, l))
+	assert.NoError(t, checkRevisionImpact(cli, "istio-system", "0-9", false, false, l))
+	// A revision still in use is not removed by callers of UninstallRevision either, like the canary upgrade.
+	assert.Error(t, UninstallRevision(cli, nil, "istio-system", "1-0", false, l))
+}
@@ -15,6 +15,7 @@
 package mesh
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"istio.io/api/operator/v1alpha1"
 	"istio.io/istio/istioctl/pkg/tag"
@@ -48,7 +50,7 @@ type uninstallArgs struct {
 	// skipConfirmation determines whether the user is prompted for confirmation.
 	// If set to true, the user is not prompted and a Yes response is assumed in all cases.
 	skipConfirmation bool
-	// force proceeds even if there are validation errors
+	// force proceeds even if there are validation errors, or dependents still using the removed control plane.
 	force bool
 	// purge results in deletion of all Istio resources.
 	purge bool
@@ -98,7 +100,11 @@ func UninstallCmd(logOpts *log.Options) *cobra.Command {
 	uicmd := &cobra.Command{
 		Use:   "uninstall",
 		Short: "Uninstall Istio from a cluster",
-		Long:  "The uninstall command uninstalls Istio from a cluster",
+		Long: `The uninstall command uninstalls Istio from a cluster.
+
+Before removing anything, it computes what still depends on the removed control plane: the revision tags pointing
+to it, the namespaces and pods it injects, the gateways it serves and the remote clusters using it as their primary.
+If anything does, it prints a migration plan and refuses to proceed unless --force is set.`,
 		Example: `  # Uninstall a single control plane by revision
   istioctl uninstall --revision foo
 
@@ -180,6 +186,10 @@ func uninstall(cmd *cobra.Command, rootArgs *RootArgs, uiArgs *uninstallArgs, lo
 		if err != nil {
 			return err
 		}
+		if err := checkRevisionImpact(kubeClient, uiArgs.istioNamespace, uiArgs.revision, uiArgs.purge,
+			uiArgs.force || rootArgs.DryRun, l); err != nil {
+			return err
+		}
 		preCheckWarnings(cmd, kubeClientWithRev, uiArgs, uiArgs.revision, objectsList, nil, l)
 
 		if err := h.DeleteObjectsList(objectsList, ""); err != nil {
@@ -198,6 +208,10 @@ func uninstall(cmd *cobra.Command, rootArgs *RootArgs, uiArgs *uninstallArgs, lo
 	if err != nil {
 		return err
 	}
+	if err := checkRevisionImpact(kubeClient, uiArgs.istioNamespace, iop.Spec.Revision, uiArgs.purge,
+		uiArgs.force || rootArgs.DryRun, l); err != nil {
+		return err
+	}
 	preCheckWarnings(cmd, kubeClientWithRev, uiArgs, iop.Spec.Revision, nil, cpObjects, l)
 	h, err = helmreconciler.NewHelmReconciler(client, kubeClient, iop, opts)
 	if err != nil {
@@ -210,6 +224,53 @@ func uninstall(cmd *cobra.Command, rootArgs *RootArgs, uiArgs *uninstallArgs, lo
 	return nil
 }
 
+// UninstallRevision removes the control plane resources of a revision, like `istioctl uninstall --revision` without
+// the confirmation. Like the command, it refuses to remove a revision still in use unless force is set.
+func UninstallRevision(kubeClient kube.CLIClient, client client.Client, istioNamespace, revision string, force bool,
+	l clog.Logger,
+) error {
+	if err := checkRevisionImpact(kubeClient, istioNamespace, revision, false, force, l); err != nil {
+		return err
+	}
+	cache.FlushObjectCaches()
+	emptyiops := &v1alpha1.IstioOperatorSpec{Profile: "empty", Revision: revision}
+	iop, err := translate.IOPStoIOP(emptyiops, "empty", iopv1alpha1.Namespace(emptyiops))
+	if err != nil {
+		return err
+	}
+	opts := &helmreconciler.Options{Log: l, ProgressLog: progress.NewLog()}
+	h, err := helmreconciler.NewHelmReconciler(client, kubeClient, iop, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create reconciler: %v", err)
+	}
+	objectsList, err := h.GetPrunedResources(revision, false, "")
+	if err != nil {
+		return err
+	}
+	if err := h.DeleteObjectsList(objectsList, ""); err != nil {
+		return fmt.Errorf("failed to delete control plane resources by revision: %v", err)
+	}
+	opts.ProgressLog.SetState(progress.StateUninstallComplete)
+	return nil
+}
+
+// checkRevisionImpact prints what still depends on the removed control plane revision with a plan to migrate it,
+// and refuses to proceed in that case unless forced.
+func checkRevisionImpact(kubeClient kube.CLIClient, istioNamespace, revision string, purge, force bool, l clog.Logger) error {
+	impact, err := computeRevisionImpact(context.Background(), kubeClient.Kube(), istioNamespace, revision, purge)
+	if err != nil {
+		return fmt.Errorf("failed to compute the impact of the uninstall: %v", err)
+	}
+	if impact.empty() {
+		return nil
+	}
+	l.LogAndPrint(impact.report())
+	if force {
+		return nil
+	}
+	return errors.New("refusing to uninstall a control plane still in use, migrate its dependents first or use --force to proceed")
+}
+
 // preCheckWarnings checks possible breaking changes and issue warnings to users, it checks the following:
 // 1. checks proxies still pointing to the target control plane revision.
 // 2. lists to be pruned resources if user uninstall by --revision flag.
@@ -56,6 +56,8 @@ type serverArgs struct {
 	force bool
 	// maxConcurrentReconciles defines the concurrency limit for operator to reconcile IstioOperatorSpec in parallel
 	maxConcurrentReconciles int
+	// driftCheckInterval is the interval at which the resources owned by each IstioOperator are checked for drift
+	driftCheckInterval time.Duration
 
 	monitoring monitoringArgs
 }
@@ -63,6 +65,10 @@ type serverArgs struct {
 func addServerFlags(cmd *cobra.Command, args *serverArgs) {
 	cmd.PersistentFlags().BoolVar(&args.force, "force", false, root.ForceFlagHelpStr)
 	cmd.PersistentFlags().IntVar(&args.maxConcurrentReconciles, "max-concurrent-reconciles", 1, root.MaxConcurrentReconcilesFlagHelpStr)
+	cmd.PersistentFlags().DurationVar(&args.driftCheckInterval, "drift-check-interval", 5*time.Minute,
+		"Interval at which the resources owned by each IstioOperator are compared against the rendered manifest. "+
+			"Drifted resources are reported on the IstioOperator, and reverted if it is annotated with "+
+			istiocontrolplane.DriftPolicyAnnotation+"="+istiocontrolplane.DriftPolicyRevert+". Set to 0 to disable drift detection.")
 	cmd.PersistentFlags().StringVar(&args.monitoring.host, "monitoring-host", metricsHost, "HTTP host to use for operator's self-monitoring information")
 	cmd.PersistentFlags().Uint32Var(&args.monitoring.port, "monitoring-port", metricsPort, "HTTP port to use for operator's self-monitoring information")
 }
@@ -205,7 +211,11 @@ func run(sArgs *serverArgs) {
 	}
 
 	// Setup all Controllers
-	options := &istiocontrolplane.Options{Force: sArgs.force, MaxConcurrentReconciles: sArgs.maxConcurrentReconciles}
+	options := &istiocontrolplane.Options{
+		Force:                   sArgs.force,
+		MaxConcurrentReconciles: sArgs.maxConcurrentReconciles,
+		DriftCheckInterval:      sArgs.driftCheckInterval,
+	}
 	if err := controller.AddToManager(mgr, options); err != nil {
 		log.Fatalf("Could not add all controllers to operator manager: %v", err)
 	}
@@ -15,6 +15,7 @@
 package compare
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -1491,3 +1492,94 @@ Object Deployment:istio-system:istio-citadel is missing in B:
 		})
 	}
 }
+
+func TestDriftedFields(t *testing.T) {
+	desired := map[string]any{
+		"metadata": map[string]any{
+			"name":              "istiod",
+			"labels":            map[string]any{"app": "istiod"},
+			"creationTimestamp": nil,
+		},
+		"spec": map[string]any{
+			"replicas": 1,
+			"paused":   false,
+			"template": map[string]any{
+				"spec": map[string]any{
+					"containers": []any{
+						map[string]any{"name": "discovery", "image": "istiod:1.20", "args": []any{"discovery"}},
+					},
+				},
+			},
+		},
+		"webhooks": []any{
+			map[string]any{"clientConfig": map[string]any{"caBundle": ""}},
+		},
+	}
+	live := map[string]any{
+		"metadata": map[string]any{
+			"name":              "istiod",
+			"labels":            map[string]any{"app": "istiod", "extra": "label"},
+			"creationTimestamp": "2023-01-01T00:00:00Z",
+		},
+		"spec": map[string]any{
+			"replicas": int64(1),
+			"template": map[string]any{
+				"spec": map[string]any{
+					"containers": []any{
+						map[string]any{"name": "discovery", "image": "istiod:debug", "args": []any{"discovery", "--debug"}},
+					},
+				},
+			},
+		},
+		"webhooks": []any{
+			map[string]any{"clientConfig": map[string]any{"caBundle": "Y2VydA=="}},
+		},
+		"status": map[string]any{"replicas": 1},
+	}
+	got := DriftedFields(desired, live, nil)
+	want := []string{
+		"spec.template.spec.containers.[0].args",
+		"spec.template.spec.containers.[0].image",
+		"webhooks.[0].clientConfig.caBundle",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DriftedFields() = %v, want %v", got, want)
+	}
+
+	got = DriftedFields(desired, live, []string{"spec.template.spec.containers.*.image", "webhooks.*.clientConfig.caBundle"})
+	want = []string{"spec.template.spec.containers.[0].args"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DriftedFields() with ignored paths = %v, want %v", got, want)
+	}
+
+	if got := DriftedFields(desired, map[string]any{}, nil); !reflect.DeepEqual(got, []string{"metadata", "spec", "webhooks"}) {
+		t.Errorf("DriftedFields() of an empty object = %v", got)
+	}
+}
+
+func TestDriftedFieldsQuantities(t *testing.T) {
+	resources := func(memory, cpu any) map[string]any {
+		return map[string]any{"spec": map[string]any{"containers": []any{map[string]any{
+			"resources": map[string]any{"requests": map[string]any{"memory": memory, "cpu": cpu}},
+		}}}}
+	}
+	// The API server stores quantities in canonical form.
+	if got := DriftedFields(resources("2048Mi", 1), resources("2Gi", "1"), nil); len(got) != 0 {
+		t.Errorf("DriftedFields() of canonicalized quantities = %v", got)
+	}
+	if got := DriftedFields(resources("500m", "0.5"), resources("500m", "500m"), nil); len(got) != 0 {
+		t.Errorf("DriftedFields() of canonicalized quantities = %v", got)
+	}
+	got := DriftedFields(resources("2048Mi", "500m"), resources("1Gi", "1"), nil)
+	want := []string{"spec.containers.[0].resources.requests.cpu", "spec.containers.[0].resources.requests.memory"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DriftedFields() of changed quantities = %v, want %v", got, want)
+	}
+	// Other fields are compared as is.
+	label := func(v string) map[string]any {
+		return map[string]any{"metadata": map[string]any{"labels": map[string]any{"v": v}}}
+	}
+	if got := DriftedFields(label("1k"), label("1000"), nil); !reflect.DeepEqual(got, []string{"metadata.labels.v"}) {
+		t.Errorf("DriftedFields() of a label = %v", got)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DriftedFields returns the paths of the fields set in desired which hold another value in live, e.g. because live
+// was edited out of band. Fields only set in live, like the defaults filled in by the API server or the status, are
+// not compared, and neither are null desired values or empty ones missing from live. Resource quantities, which the
+// API server stores in canonical form (e.g. 2048Mi as 2Gi), are compared by value.
+// Paths are dot separated, with list indices written as [i], e.g. spec.template.spec.containers.[0].image. Paths
+// matching one of ignorePaths, where * matches any single element, are skipped.
+func DriftedFields(desired, live map[string]any, ignorePaths []string) []string {
+	ignore := make([][]string, 0, len(ignorePaths))
+	for _, p := range ignorePaths {
+		ignore = append(ignore, strings.Split(p, "."))
+	}
+	var res []string
+	driftedFields(normalizeJSON(desired), normalizeJSON(live), nil, ignore, &res)
+	return res
+}
+
+func driftedFields(desired, live any, path []string, ignore [][]string, res *[]string) {
+	if matchesAnyPath(path, ignore) {
+		return
+	}
+	if desired == nil {
+		return
+	}
+	// Zero values are omitted when serialized, so they are missing from live objects.
+	if live == nil && (!isValidAndNonEmpty(reflect.ValueOf(desired)) || reflect.ValueOf(desired).IsZero()) {
+		return
+	}
+	switch d := desired.(type) {
+	case map[string]any:
+		if len(d) == 0 {
+			return
+		}
+		l, ok := live.(map[string]any)
+		if !ok {
+			*res = append(*res, strings.Join(path, "."))
+			return
+		}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			driftedFields(d[k], l[k], append(path[:len(path):len(path)], k), ignore, res)
+		}
+	case []any:
+		l, ok := live.([]any)
+		if !ok || len(l) != len(d) {
+			*res = append(*res, strings.Join(path, "."))
+			return
+		}
+		for i := range d {
+			driftedFields(d[i], l[i], append(path[:len(path):len(path)], fmt.Sprintf("[%d]", i)), ignore, res)
+		}
+	default:
+		if !reflect.DeepEqual(desired, live) && !(isQuantityPath(path) && equalQuantities(desired, live)) {
+			*res = append(*res, strings.Join(path, "."))
+		}
+	}
+}
+
+// isQuantityPath reports whether path holds a resource quantity, i.e. is under resources, like the requests and
+// limits of containers, or is the size limit of an emptyDir volume.
+func isQuantityPath(path []string) bool {
+	if len(path) > 0 && path[len(path)-1] == "sizeLimit" {
+		return true
+	}
+	for _, p := range path {
+		if p == "resources" {
+			return true
+		}
+	}
+	return false
+}
+
+// equalQuantities reports whether a and b are quantities, written as strings or numbers, of the same value.
+func equalQuantities(a, b any) bool {
+	qa, ok := parseQuantity(a)
+	if !ok {
+		return false
+	}
+	qb, ok := parseQuantity(b)
+	return ok && qa.Cmp(qb) == 0
+}
+
+func parseQuantity(v any) (resource.Quantity, bool) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return resource.Quantity{}, false
+	}
+	q, err := resource.ParseQuantity(s)
+	return q, err == nil
+}
+
+// matchesAnyPath reports whether path matches one of the patterns.
+func matchesAnyPath(path []string, patterns [][]string) bool {
+	for _, p := range patterns {
+		if len(p) != len(path) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if p[i] != "*" && p[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON round trips v through JSON, so that numbers have the same type regardless of how v was decoded.
+func normalizeJSON(v map[string]any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istiocontrolplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iopv1alpha1 "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/helmreconciler"
+)
+
+const (
+	// DriftPolicyAnnotation is annotation of IstioOperator CR selecting what the operator does when the resources it
+	// owns drift from the rendered manifest: DriftPolicyReport, the default, or DriftPolicyRevert.
+	DriftPolicyAnnotation = "install.istio.io/drift-policy"
+	// DriftPolicyReport only reports the drift, through the Drifted condition and events.
+	DriftPolicyReport = "report"
+	// DriftPolicyRevert applies the rendered manifest of the drifted resources again.
+	DriftPolicyRevert = "revert"
+
+	// DriftedCondition is the condition type reporting whether the resources owned by an IstioOperator drifted.
+	DriftedCondition = "Drifted"
+
+	reasonNoDrift       = "NoDrift"
+	reasonDriftDetected = "DriftDetected"
+	reasonDriftReverted = "DriftReverted"
+	reasonRevertFailed  = "RevertFailed"
+
+	// maxDriftedObjectsInMessage bounds the number of objects listed in the condition message.
+	maxDriftedObjectsInMessage = 5
+)
+
+// processDrift detects the drift of the resources owned by iop, reverts it if its policy says so, and reports it
+// on iop.
+func (r *ReconcileIstioOperator) processDrift(iop *iopv1alpha1.IstioOperator, reconciler *helmreconciler.HelmReconciler) error {
+	drifted, err := reconciler.Drift()
+	if err != nil {
+		return fmt.Errorf("failed to detect drift: %v", err)
+	}
+	cond := metav1.Condition{
+		Type:               DriftedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonNoDrift,
+		Message:            "The resources match the rendered manifest",
+		ObservedGeneration: iop.Generation,
+	}
+	if len(drifted) > 0 {
+		for _, d := range drifted {
+			r.event(iop, corev1.EventTypeWarning, reasonDriftDetected, fmt.Sprintf("%s drifted: %s", d, d.Details()))
+		}
+		switch policy := iop.Annotations[DriftPolicyAnnotation]; policy {
+		case DriftPolicyRevert:
+			if err := reconciler.RevertDrift(drifted); err != nil {
+				cond.Status = metav1.ConditionTrue
+				cond.Reason = reasonRevertFailed
+				cond.Message = fmt.Sprintf("Failed to revert the drift: %v", err)
+				break
+			}
+			for _, d := range drifted {
+				r.event(iop, corev1.EventTypeNormal, reasonDriftReverted, fmt.Sprintf("%s reverted", d))
+			}
+			cond.Reason = reasonDriftReverted
+			cond.Message = "Reverted " + driftSummary(drifted)
+		default:
+			if policy != "" && policy != DriftPolicyReport {
+				scope.Warnf("unknown %s %q on IstioOperator %s/%s, only reporting drift",
+					DriftPolicyAnnotation, policy, iop.Namespace, iop.Name)
+			}
+			cond.Status = metav1.ConditionTrue
+			cond.Reason = reasonDriftDetected
+			cond.Message = "Drifted " + driftSummary(drifted)
+		}
+	}
+	return r.setCondition(iop, cond)
+}
+
+// driftSummary lists the first drifted objects and how they drifted.
+func driftSummary(drifted []*helmreconciler.DriftedObject) string {
+	var items []string
+	for i, d := range drifted {
+		if i == maxDriftedObjectsInMessage {
+			items = append(items, fmt.Sprintf("and %d more", len(drifted)-i))
+			break
+		}
+		items = append(items, fmt.Sprintf("%s (%s)", d, d.Details()))
+	}
+	return fmt.Sprintf("%d resources: %s", len(drifted), strings.Join(items, "; "))
+}
+
+func (r *ReconcileIstioOperator) event(iop *iopv1alpha1.IstioOperator, eventType, reason, message string) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Event(iop, eventType, reason, message)
+}
+
+// setCondition sets cond in the status conditions of iop. The conditions are not part of the typed status, so they
+// are patched as unstructured content.
+func (r *ReconcileIstioOperator) setCondition(iop *iopv1alpha1.IstioOperator, cond metav1.Condition) error {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(iopv1alpha1.IstioOperatorGVK)
+	key := types.NamespacedName{Namespace: iop.Namespace, Name: iop.Name}
+	if err := r.client.Get(context.TODO(), key, u); err != nil {
+		return fmt.Errorf("failed to get IstioOperator before updating its conditions: %v", err)
+	}
+	var conditions []metav1.Condition
+	if raw, found, _ := unstructured.NestedSlice(u.Object, "status", "conditions"); found {
+		b, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(b, &conditions)
+		}
+		if err != nil {
+			scope.Warnf("ignoring invalid conditions of IstioOperator %s: %v", key, err)
+		}
+	}
+	// The transition time is only updated when the status of the condition changes.
+	meta.SetStatusCondition(&conditions, cond)
+	patch, err := json.Marshal(map[string]any{"status": map[string]any{"conditions": conditions}})
+	if err != nil {
+		return err
+	}
+	return r.client.Status().Patch(context.TODO(), u, client.RawPatch(types.MergePatchType, patch))
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -29,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	kubeversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	cache2 "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -80,6 +82,9 @@ var (
 type Options struct {
 	Force                   bool
 	MaxConcurrentReconciles int
+	// DriftCheckInterval is the interval at which the resources owned by each IstioOperator are compared against
+	// the rendered manifest. Drift detection is disabled if it is 0.
+	DriftCheckInterval time.Duration
 }
 
 const (
@@ -235,6 +240,7 @@ type ReconcileIstioOperator struct {
 	kubeClient kube.Client
 	scheme     *runtime.Scheme
 	options    *Options
+	recorder   record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a IstioOperator object and makes changes based on the state read
@@ -382,6 +388,7 @@ func (r *ReconcileIstioOperator) Reconcile(_ context.Context, request reconcile.
 	if r.options != nil {
 		helmReconcilerOptions.Force = r.options.Force
 	}
+	r.setNodeRolloutOptions(iopMerged, helmReconcilerOptions)
 	exists := revtag.PreviousInstallExists(context.Background(), r.kubeClient.Kube())
 	reconciler, err := helmreconciler.NewHelmReconciler(r.client, r.kubeClient, iopMerged, helmReconcilerOptions)
 	if err != nil {
@@ -406,7 +413,14 @@ func (r *ReconcileIstioOperator) Reconcile(_ context.Context, request reconcile.
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, err
+	result := reconcile.Result{}
+	if r.options != nil && r.options.DriftCheckInterval > 0 {
+		if err := r.processDrift(iop, reconciler); err != nil {
+			scope.Errorf("Error during drift detection: %s", err)
+		}
+		result.RequeueAfter = r.options.DriftCheckInterval
+	}
+	return result, err
 }
 
 func processDefaultWebhookAfterReconcile(iop *iopv1alpha1.IstioOperator, client kube.Client, exists bool) error {
@@ -494,7 +508,8 @@ func Add(mgr manager.Manager, options *Options) error {
 	if err != nil {
 		return fmt.Errorf("create Kubernetes client: %v", err)
 	}
-	return add(mgr, &ReconcileIstioOperator{client: mgr.GetClient(), scheme: mgr.GetScheme(), kubeClient: kubeClient, options: options}, options)
+	return add(mgr, &ReconcileIstioOperator{client: mgr.GetClient(), scheme: mgr.GetScheme(), kubeClient: kubeClient, options: options,
+		recorder: mgr.GetEventRecorderFor("istio-operator")}, options)
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler along with options for additional configuration.
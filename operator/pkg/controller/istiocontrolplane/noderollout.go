@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istiocontrolplane
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	iopv1alpha1 "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/helmreconciler"
+)
+
+const (
+	// NodeAgentRolloutAnnotation is the annotation of IstioOperator CR selecting how the DaemonSets of the node agents,
+	// ztunnel and istio-cni, are upgraded: rolling, the default, node, cordon or drain.
+	NodeAgentRolloutAnnotation = "install.istio.io/node-agent-rollout"
+
+	// NodeAgentRolloutCondition is the condition type reporting the progress of the node by node rollout of the node
+	// agents. It is true while a rollout is in progress.
+	NodeAgentRolloutCondition = "NodeAgentRollout"
+
+	reasonNodeUpgrading   = "NodeUpgrading"
+	reasonRolloutComplete = "RolloutComplete"
+	reasonRolloutAborted  = "RolloutAborted"
+)
+
+// setNodeRolloutOptions sets the node rollout strategy of iop in opts, reporting the progress of the rollouts
+// through the NodeAgentRollout condition and events of iop.
+func (r *ReconcileIstioOperator) setNodeRolloutOptions(iop *iopv1alpha1.IstioOperator, opts *helmreconciler.Options) {
+	strategy, err := helmreconciler.ParseNodeRolloutStrategy(iop.Annotations[NodeAgentRolloutAnnotation])
+	if err != nil {
+		scope.Warnf("invalid %s on IstioOperator %s/%s, using rolling updates: %v", NodeAgentRolloutAnnotation,
+			iop.Namespace, iop.Name, err)
+		return
+	}
+	opts.NodeRollout = strategy
+	opts.NodeRolloutProgress = func(p helmreconciler.NodeRolloutProgress) {
+		cond := metav1.Condition{
+			Type:               NodeAgentRolloutCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             reasonNodeUpgrading,
+			Message:            p.String(),
+			ObservedGeneration: iop.Generation,
+		}
+		switch {
+		case p.Err != nil:
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = reasonRolloutAborted
+			r.event(iop, corev1.EventTypeWarning, reasonRolloutAborted, p.String())
+		case p.Done:
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = reasonRolloutComplete
+		}
+		if err := r.setCondition(iop, cond); err != nil {
+			scope.Warnf("failed to report the node agent rollout on IstioOperator %s/%s: %v", iop.Namespace, iop.Name, err)
+		}
+	}
+}
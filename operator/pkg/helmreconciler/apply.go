@@ -52,6 +52,14 @@ func (h *HelmReconciler) ApplyManifest(manifest name.Manifest, serverSideApply b
 	if err != nil {
 		return nil, 0, err
 	}
+	if h.nodeRolloutEnabled(cname) {
+		// Set before comparing with the cache, which holds the objects as applied.
+		for _, obj := range object.KindObjects(allObjects, name.DaemonSetStr) {
+			if err := setOnDeleteUpdateStrategy(obj.UnstructuredObject()); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
 
 	objectCache := cache.GetCache(crHash)
 
@@ -130,14 +138,27 @@ func (h *HelmReconciler) ApplyManifest(manifest name.Manifest, serverSideApply b
 			return processedObjects, 0, errs.ToError()
 		}
 
-		err := WaitForResources(processedObjects, h.kubeClient,
+		// The agents of a node must not be replaced concurrently, so the DaemonSets of every node agent component are
+		// rolled out together once all the components are applied, and waited for then.
+		waitObjects := processedObjects
+		daemonSets := object.KindObjects(processedObjects, name.DaemonSetStr)
+		deferred := h.nodeRolloutEnabled(cname) && len(daemonSets) > 0
+		if deferred {
+			waitObjects = object.ObjectsNotInLists(processedObjects, daemonSets)
+		}
+
+		err := WaitForResources(waitObjects, h.kubeClient,
 			h.opts.WaitTimeout, h.opts.DryRun, plog)
 		if err != nil {
 			werr := fmt.Errorf("failed to wait for resource: %v", err)
 			plog.ReportError(werr.Error())
 			return processedObjects, 0, werr
 		}
-		plog.ReportFinished()
+		if deferred {
+			h.deferNodeRollout(name.ComponentName(cname), crHash, daemonSets, plog)
+		} else {
+			plog.ReportFinished()
+		}
 
 	}
 	return processedObjects, deployedObjects, nil
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"istio.io/istio/operator/pkg/compare"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util"
+)
+
+// driftIgnorePaths are the fields, by kind, which are expected to differ from the rendered manifest because they are
+// managed by Istiod.
+var driftIgnorePaths = map[string][]string{
+	name.MutatingWebhookConfigurationStr: {
+		"webhooks.*.clientConfig.caBundle",
+	},
+	name.ValidatingWebhookConfigurationStr: {
+		"webhooks.*.clientConfig.caBundle",
+		"webhooks.*.failurePolicy",
+	},
+}
+
+// DriftedObject is an object owned by the IstioOperator whose live state differs from the rendered manifest.
+type DriftedObject struct {
+	Component name.ComponentName
+	Kind      string
+	Namespace string
+	Name      string
+	// Missing is set if the object was deleted.
+	Missing bool
+	// Fields are the paths of the fields of the rendered manifest holding another value in the cluster.
+	Fields []string
+
+	desired *unstructured.Unstructured
+}
+
+func (d *DriftedObject) String() string {
+	if d.Namespace == "" {
+		return fmt.Sprintf("%s/%s", d.Kind, d.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", d.Kind, d.Namespace, d.Name)
+}
+
+// Details describes how the object drifted.
+func (d *DriftedObject) Details() string {
+	if d.Missing {
+		return "deleted"
+	}
+	return "changed " + strings.Join(d.Fields, ", ")
+}
+
+// Drift compares the objects in the cluster against the rendered manifest and returns those which drifted from it.
+// The manifest rendered by the last Reconcile is used if there is one.
+func (h *HelmReconciler) Drift() ([]*DriftedObject, error) {
+	manifests := h.manifests
+	if manifests == nil {
+		var err error
+		if manifests, err = h.RenderCharts(); err != nil {
+			return nil, err
+		}
+	}
+	var res []*DriftedObject
+	var errs util.Errors
+	for cname, ms := range manifests {
+		for _, m := range ms {
+			objs, err := object.ParseK8sObjectsFromYAMLManifest(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range objs {
+				desired := obj.UnstructuredObject()
+				if err := h.applyLabelsAndAnnotations(desired, string(cname)); err != nil {
+					return nil, err
+				}
+				d, err := h.driftedObject(desired)
+				if err != nil {
+					errs = util.AppendErr(errs, err)
+					continue
+				}
+				if d != nil {
+					d.Component = cname
+					res = append(res, d)
+				}
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+	return res, errs.ToError()
+}
+
+// driftedObject returns how the live object drifted from desired, or nil if it did not.
+func (h *HelmReconciler) driftedObject(desired *unstructured.Unstructured) (*DriftedObject, error) {
+	d := &DriftedObject{
+		Kind:      desired.GetKind(),
+		Namespace: desired.GetNamespace(),
+		Name:      desired.GetName(),
+		desired:   desired,
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(desired.GroupVersionKind())
+	err := h.client.Get(context.TODO(), client.ObjectKeyFromObject(desired), live)
+	if kerrors.IsNotFound(err) {
+		d.Missing = true
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %q: %v", d, err)
+	}
+	d.Fields = compare.DriftedFields(desired.Object, live.Object, driftIgnorePaths[d.Kind])
+	if len(d.Fields) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// RevertDrift applies the rendered manifest of the drifted objects again.
+func (h *HelmReconciler) RevertDrift(drifted []*DriftedObject) error {
+	serverSideApply := h.CheckSSAEnabled()
+	var errs util.Errors
+	for _, d := range drifted {
+		if err := h.ApplyObject(d.desired.DeepCopy(), serverSideApply); err != nil {
+			errs = util.AppendErr(errs, err)
+		}
+	}
+	return errs.ToError()
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1alpha12 "istio.io/api/operator/v1alpha1"
+	"istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestDrift(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/configmap.yaml")
+	assert.NoError(t, err)
+	cl := &fakeClientWrapper{fake.NewClientBuilder().Build()}
+	h := &HelmReconciler{
+		client: cl,
+		opts:   &Options{},
+		iop: &v1alpha1.IstioOperator{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-operator", Namespace: "istio-system"},
+			Spec:       &v1alpha12.IstioOperatorSpec{},
+		},
+		countLock:     &sync.Mutex{},
+		prunedKindSet: map[schema.GroupKind]struct{}{},
+		manifests:     name.ManifestMap{name.PilotComponentName: {string(manifest)}},
+	}
+	drifted := func() []string {
+		t.Helper()
+		d, err := h.Drift()
+		assert.NoError(t, err)
+		var res []string
+		for _, o := range d {
+			res = append(res, o.String()+" "+o.Details())
+		}
+		return res
+	}
+
+	assert.Equal(t, drifted(), []string{"ConfigMap/istio-system/config deleted"})
+	d, err := h.Drift()
+	assert.NoError(t, err)
+	assert.NoError(t, h.RevertDrift(d))
+	assert.Equal(t, drifted(), nil)
+
+	key := types.NamespacedName{Namespace: "istio-system", Name: "config"}
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, cl.Get(context.Background(), key, cm))
+	cm.Data["field"] = "two"
+	cm.Data["added"] = "value"
+	assert.NoError(t, cl.Update(context.Background(), cm))
+	assert.Equal(t, drifted(), []string{"ConfigMap/istio-system/config changed data.field"})
+
+	d, err = h.Drift()
+	assert.NoError(t, err)
+	assert.NoError(t, h.RevertDrift(d))
+	assert.Equal(t, drifted(), nil)
+	assert.NoError(t, cl.Get(context.Background(), key, cm))
+	assert.Equal(t, cm.Data["field"], "one")
+}
@@ -0,0 +1,591 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/cni/pkg/ambient/status"
+	istioV1Alpha1 "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/cache"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util/progress"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// NodeRolloutStrategy selects how the DaemonSets of the node agents, ztunnel and istio-cni, are upgraded.
+type NodeRolloutStrategy string
+
+const (
+	// NodeRolloutRolling leaves the upgrade to the rolling update of the DaemonSets. This is the default.
+	NodeRolloutRolling NodeRolloutStrategy = ""
+	// NodeRolloutNode upgrades the agents one node at a time, verifying each node before moving on. The in-mesh pods
+	// of the node keep running and reconnect to the new agent.
+	NodeRolloutNode NodeRolloutStrategy = "node"
+	// NodeRolloutCordon is NodeRolloutNode, with each node cordoned while its agent is upgraded.
+	NodeRolloutCordon NodeRolloutStrategy = "cordon"
+	// NodeRolloutDrain is NodeRolloutCordon, with the in-mesh pods of each node evicted before its agent is upgraded,
+	// so none of their connections is dropped.
+	NodeRolloutDrain NodeRolloutStrategy = "drain"
+)
+
+const (
+	// templateGenerationAnnotation is the annotation the API server sets on DaemonSets to the generation of their pod
+	// template.
+	templateGenerationAnnotation = "deprecated.daemonset.template.generation"
+	// templateGenerationLabel is the label the DaemonSet controller sets on pods to the generation of their template.
+	templateGenerationLabel = "pod-template-generation"
+	// nodeRolloutPollInterval is how often the state of a node is polled during its rollout.
+	nodeRolloutPollInterval = 2 * time.Second
+	// istiodDebugPort is the port of the debug endpoints of istiod.
+	istiodDebugPort = "15014"
+)
+
+// nodeAgentComponents are the components whose DaemonSets run a node agent.
+var nodeAgentComponents = map[name.ComponentName]bool{
+	name.ZtunnelComponentName: true,
+	name.CNIComponentName:     true,
+}
+
+// ParseNodeRolloutStrategy parses a NodeRolloutStrategy, "rolling" being the default strategy.
+func ParseNodeRolloutStrategy(s string) (NodeRolloutStrategy, error) {
+	switch st := NodeRolloutStrategy(s); st {
+	case NodeRolloutRolling, NodeRolloutNode, NodeRolloutCordon, NodeRolloutDrain:
+		return st, nil
+	case "rolling":
+		return NodeRolloutRolling, nil
+	}
+	return "", fmt.Errorf("unknown node agent rollout strategy %q, must be one of rolling, %s, %s or %s",
+		s, NodeRolloutNode, NodeRolloutCordon, NodeRolloutDrain)
+}
+
+// NodeRolloutProgress is the progress of the node rollout of the node agents.
+type NodeRolloutProgress struct {
+	// DaemonSets are the DaemonSets rolled out, as namespace/name.
+	DaemonSets []string
+	// Node is the node being upgraded, or the one the rollout aborted on.
+	Node string
+	// Upgraded is the number of nodes running the new agents, out of Total.
+	Upgraded int
+	Total    int
+	// Done is set once every node runs the new agents.
+	Done bool
+	// Err is set if the rollout aborted.
+	Err error
+}
+
+func (p NodeRolloutProgress) String() string {
+	daemonSets := strings.Join(p.DaemonSets, ", ")
+	switch {
+	case p.Err != nil:
+		return fmt.Sprintf("rollout of DaemonSets %s aborted on node %s after upgrading %d of %d nodes: %v",
+			daemonSets, p.Node, p.Upgraded, p.Total, p.Err)
+	case p.Done:
+		return fmt.Sprintf("DaemonSets %s upgraded on %d nodes", daemonSets, p.Total)
+	}
+	return fmt.Sprintf("DaemonSets %s upgrading node %s (%d/%d nodes upgraded)", daemonSets, p.Node, p.Upgraded, p.Total)
+}
+
+// nodeRolloutEnabled reports whether the DaemonSets of the component are rolled out node by node.
+func (h *HelmReconciler) nodeRolloutEnabled(cname string) bool {
+	return h.opts.NodeRollout != NodeRolloutRolling && nodeAgentComponents[name.ComponentName(cname)]
+}
+
+// setOnDeleteUpdateStrategy makes the DaemonSet controller leave the pods of obj to the node rollout, which deletes
+// them one node at a time.
+func setOnDeleteUpdateStrategy(obj *unstructured.Unstructured) error {
+	return unstructured.SetNestedMap(obj.Object, map[string]any{"type": string(appsv1.OnDeleteDaemonSetStrategyType)},
+		"spec", "updateStrategy")
+}
+
+// appliedNodeAgents are the DaemonSets of a node agent component applied and waiting for their node rollout.
+type appliedNodeAgents struct {
+	component  name.ComponentName
+	crHash     string
+	daemonSets object.K8sObjects
+	plog       *progress.ManifestLog
+}
+
+// deferNodeRollout records the applied DaemonSets of a node agent component, rolled out by rolloutNodeAgents.
+func (h *HelmReconciler) deferNodeRollout(component name.ComponentName, crHash string, daemonSets object.K8sObjects,
+	plog *progress.ManifestLog,
+) {
+	h.nodeAgentsMu.Lock()
+	defer h.nodeAgentsMu.Unlock()
+	h.nodeAgents = append(h.nodeAgents, appliedNodeAgents{component: component, crHash: crHash, daemonSets: daemonSets, plog: plog})
+}
+
+// nodeRollout upgrades the pods of the DaemonSets of the node agents, using the OnDelete update strategy, one node at
+// a time.
+type nodeRollout struct {
+	cs       kubernetes.Interface
+	strategy NodeRolloutStrategy
+	// istioNamespace is the namespace of istiod, which the ztunnel of an upgraded node must be connected to.
+	istioNamespace string
+	// timeout bounds the wait for the pods of a node to drain, for the new agents of a node to be ready and for the
+	// in-mesh pods of the node to reconnect to them.
+	timeout      time.Duration
+	pollInterval time.Duration
+	progress     func(NodeRolloutProgress)
+}
+
+// agentRollout is the rollout of a DaemonSet.
+type agentRollout struct {
+	ds *appsv1.DaemonSet
+	// generation is the generation of the template of the upgraded pods.
+	generation string
+	// pods are the pods of the DaemonSet by node, before the rollout.
+	pods map[string]*corev1.Pod
+}
+
+func (a *agentRollout) outdated(node string) bool {
+	pod := a.pods[node]
+	return pod != nil && pod.Labels[templateGenerationLabel] != a.generation
+}
+
+// run upgrades the nodes running an outdated pod of one of the DaemonSets, in the order of their names. On each node,
+// the pods of the DaemonSets are replaced in order, so the agents of a node are never replaced concurrently. It stops
+// at the first node failing, leaving the other nodes on the previous version of the agents; running it again resumes
+// the rollout from the nodes not upgraded yet.
+func (r *nodeRollout) run(daemonSets []types.NamespacedName) error {
+	ctx := context.TODO()
+	var rollouts []*agentRollout
+	nodes := sets.New[string]()
+	outdated := sets.New[string]()
+	p := NodeRolloutProgress{}
+	for _, d := range daemonSets {
+		a, err := r.observe(ctx, d)
+		if err != nil {
+			return err
+		}
+		rollouts = append(rollouts, a)
+		p.DaemonSets = append(p.DaemonSets, d.String())
+		for node := range a.pods {
+			nodes.Insert(node)
+			if a.outdated(node) {
+				outdated.Insert(node)
+			}
+		}
+	}
+
+	p.Total, p.Upgraded = nodes.Len(), nodes.Len()-outdated.Len()
+	for _, node := range sets.SortedList(outdated) {
+		p.Node = node
+		r.report(p)
+		if err := r.upgradeNode(ctx, rollouts, node); err != nil {
+			p.Err = err
+			r.report(p)
+			return fmt.Errorf("node agent %s, the nodes not upgraded keep the previous version until the install is run again",
+				p.String())
+		}
+		p.Upgraded++
+	}
+	p.Node, p.Done = "", true
+	r.report(p)
+	return nil
+}
+
+// observe waits for the DaemonSet controller to observe the new template of a DaemonSet, as its pods must not be
+// replaced before, and returns its rollout.
+func (r *nodeRollout) observe(ctx context.Context, d types.NamespacedName) (*agentRollout, error) {
+	var ds *appsv1.DaemonSet
+	err := wait.PollUntilContextTimeout(ctx, r.pollInterval, r.timeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		if ds, err = r.cs.AppsV1().DaemonSets(d.Namespace).Get(ctx, d.Name, metav1.GetOptions{}); err != nil {
+			return false, err
+		}
+		return ds.Status.ObservedGeneration >= ds.Generation, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DaemonSet %s not observed: %v", d, err)
+	}
+	generation, f := ds.Annotations[templateGenerationAnnotation]
+	if !f {
+		return nil, fmt.Errorf("DaemonSet %s has no %s annotation, the upgraded pods cannot be told apart",
+			d, templateGenerationAnnotation)
+	}
+	pods, err := r.daemonSetPods(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	return &agentRollout{ds: ds, generation: generation, pods: pods}, nil
+}
+
+func (r *nodeRollout) report(p NodeRolloutProgress) {
+	scope.Info(p.String())
+	if r.progress != nil {
+		r.progress(p)
+	}
+}
+
+// daemonSetPods returns the pods of the DaemonSet by node.
+func (r *nodeRollout) daemonSetPods(ctx context.Context, ds *appsv1.DaemonSet) (map[string]*corev1.Pod, error) {
+	sel, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := getPods(r.cs, ds.Namespace, sel)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]*corev1.Pod{}
+	for i := range pods {
+		if pod := &pods[i]; pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+			res[pod.Spec.NodeName] = pod
+		}
+	}
+	return res, nil
+}
+
+// upgradeNode replaces the agents of a node, cordoning the node while they are replaced if the strategy says so. A
+// node cordoned for the upgrade is uncordoned only if the upgrade succeeds.
+func (r *nodeRollout) upgradeNode(ctx context.Context, rollouts []*agentRollout, node string) error {
+	cordoned := false
+	if r.strategy == NodeRolloutCordon || r.strategy == NodeRolloutDrain {
+		n, err := r.cs.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !n.Spec.Unschedulable {
+			if err := r.setUnschedulable(ctx, node, true); err != nil {
+				return fmt.Errorf("failed to cordon: %v", err)
+			}
+			cordoned = true
+		}
+	}
+	if err := r.replaceAgents(ctx, rollouts, node); err != nil {
+		return err
+	}
+	if cordoned {
+		if err := r.setUnschedulable(ctx, node, false); err != nil {
+			return fmt.Errorf("failed to uncordon: %v", err)
+		}
+	}
+	return nil
+}
+
+// replaceAgents replaces the outdated agents of a node one after the other, after evicting the in-mesh pods of the
+// node if the strategy says so. Otherwise, the in-mesh pods keep running and must reconnect to the new agents.
+func (r *nodeRollout) replaceAgents(ctx context.Context, rollouts []*agentRollout, node string) error {
+	inMesh, err := r.inMeshPods(ctx, node)
+	if err != nil {
+		return err
+	}
+	if r.strategy == NodeRolloutDrain {
+		if err := r.evict(ctx, inMesh); err != nil {
+			return err
+		}
+		inMesh = nil
+	}
+
+	// The redirection status of the pods is set by the node, truncated to the second.
+	since := time.Now().Truncate(time.Second)
+	for _, a := range rollouts {
+		if !a.outdated(node) {
+			continue
+		}
+		if err := r.replaceAgent(ctx, a, node); err != nil {
+			return err
+		}
+	}
+	if len(inMesh) == 0 {
+		return nil
+	}
+	scope.Infof("waiting for the %d in-mesh pods of node %s to reconnect to the new agents", len(inMesh), node)
+	var last error
+	err = wait.PollUntilContextTimeout(ctx, r.pollInterval, r.timeout, true, func(ctx context.Context) (bool, error) {
+		last = r.checkReconnected(ctx, node, inMesh, since)
+		return last == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("the in-mesh pods did not reconnect to the new agents after %v: %v", r.timeout, last)
+	}
+	return nil
+}
+
+// replaceAgent deletes the agent of a node and waits for the new agent to be ready: ztunnel is ready once it received
+// its configuration from istiod, and istio-cni once the CNI plugin redirecting the traffic of the pods of the node is
+// installed.
+func (r *nodeRollout) replaceAgent(ctx context.Context, a *agentRollout, node string) error {
+	agent := a.pods[node]
+	if err := r.cs.CoreV1().Pods(agent.Namespace).Delete(ctx, agent.Name, metav1.DeleteOptions{}); err != nil &&
+		!kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the agent %s: %v", agent.Name, err)
+	}
+	err := wait.PollUntilContextTimeout(ctx, r.pollInterval, r.timeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := r.daemonSetPods(ctx, a.ds)
+		if err != nil {
+			return false, err
+		}
+		pod := pods[node]
+		return pod != nil && pod.Labels[templateGenerationLabel] == a.generation && isPodReady(pod), nil
+	})
+	if err != nil {
+		reason := extractPodFailureReason(r.cs, a.ds.Namespace, a.ds.Spec.Selector)
+		if reason != "" {
+			reason = ": " + reason
+		}
+		return fmt.Errorf("the new agent %s/%s is not ready after %v%s", a.ds.Namespace, a.ds.Name, r.timeout, reason)
+	}
+	return nil
+}
+
+// checkReconnected returns why the in-mesh pods of a node are not served by the new agents yet, or nil once the
+// istio-cni agent redirected the traffic of every pod still running to the ztunnel of the node, and this ztunnel is
+// connected to istiod and accepted its configuration. The agent redirects the pods already in the mesh again, and
+// updates their redirection status, when it starts and when the active ztunnel of the node changes.
+func (r *nodeRollout) checkReconnected(ctx context.Context, node string, inMesh []corev1.Pod, since time.Time) error {
+	ztunnel, err := r.ztunnelPod(ctx, node)
+	if err != nil {
+		return err
+	}
+	for _, pod := range inMesh {
+		cur, err := r.cs.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) || (err == nil && (cur.UID != pod.UID || cur.DeletionTimestamp != nil)) {
+			// The pod is gone, there is nothing to reconnect.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		v, f := cur.Annotations[constants.AmbientRedirectionStatus]
+		if !f {
+			return fmt.Errorf("pod %s/%s has no redirection status", pod.Namespace, pod.Name)
+		}
+		st, err := status.Parse(v)
+		if err != nil {
+			return fmt.Errorf("invalid redirection status of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+		switch {
+		case !st.Redirected:
+			return fmt.Errorf("the traffic of pod %s/%s is not redirected: %s", pod.Namespace, pod.Name, st.Error)
+		case st.LastUpdateTime.Before(since):
+			return fmt.Errorf("the traffic of pod %s/%s was not redirected by the new agents yet", pod.Namespace, pod.Name)
+		case ztunnel != nil && st.Ztunnel != ztunnel.Name:
+			return fmt.Errorf("the traffic of pod %s/%s is redirected to ztunnel %s rather than %s",
+				pod.Namespace, pod.Name, st.Ztunnel, ztunnel.Name)
+		}
+	}
+	if ztunnel == nil {
+		return nil
+	}
+	return r.checkSynced(ctx, ztunnel)
+}
+
+// ztunnelPod returns the running ztunnel of a node, or nil if the node runs none.
+func (r *nodeRollout) ztunnelPod(ctx context.Context, node string) (*corev1.Pod, error) {
+	pods, err := r.cs.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=ztunnel",
+		FieldSelector: "spec.nodeName=" + node,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ztunnel of the node: %v", err)
+	}
+	for i := range pods.Items {
+		if pod := &pods.Items[i]; pod.Spec.NodeName == node && pod.DeletionTimestamp == nil && isPodReady(pod) {
+			return pod, nil
+		}
+	}
+	return nil, nil
+}
+
+// syncStatus is the part of the sync status of a proxy, as served by the debug/syncz endpoint of istiod, verified
+// for ztunnel.
+type syncStatus struct {
+	ProxyID string            `json:"proxy"`
+	Nacks   map[string]string `json:"nacks,omitempty"`
+}
+
+// checkSynced checks a ztunnel is connected to a ready istiod and did not reject its configuration.
+func (r *nodeRollout) checkSynced(ctx context.Context, ztunnel *corev1.Pod) error {
+	istiods, err := r.cs.CoreV1().Pods(r.istioNamespace).List(ctx, metav1.ListOptions{LabelSelector: "app=istiod"})
+	if err != nil {
+		return fmt.Errorf("failed to list istiod: %v", err)
+	}
+	proxyID := ztunnel.Name + "." + ztunnel.Namespace
+	for i := range istiods.Items {
+		istiod := &istiods.Items[i]
+		if !isPodReady(istiod) {
+			continue
+		}
+		b, err := r.cs.CoreV1().Pods(istiod.Namespace).ProxyGet("http", istiod.Name, istiodDebugPort, "debug/syncz", nil).DoRaw(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the sync status from istiod %s: %v", istiod.Name, err)
+		}
+		var statuses []syncStatus
+		if err := json.Unmarshal(b, &statuses); err != nil {
+			return fmt.Errorf("invalid sync status from istiod %s: %v", istiod.Name, err)
+		}
+		for _, st := range statuses {
+			if st.ProxyID != proxyID {
+				continue
+			}
+			if len(st.Nacks) > 0 {
+				return fmt.Errorf("ztunnel %s rejected its configuration from istiod %s: %v", ztunnel.Name, istiod.Name, st.Nacks)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("ztunnel %s is not connected to istiod", ztunnel.Name)
+}
+
+func (r *nodeRollout) setUnschedulable(ctx context.Context, node string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := r.cs.CoreV1().Nodes().Patch(ctx, node, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// inMeshPods returns the running pods of the node whose traffic is redirected to the node agent.
+func (r *nodeRollout) inMeshPods(ctx context.Context, node string) ([]corev1.Pod, error) {
+	pods, err := r.cs.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=" + node})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods of the node: %v", err)
+	}
+	var res []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != node || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if pod.Annotations[constants.AmbientRedirection] == constants.AmbientRedirectionEnabled {
+			res = append(res, pod)
+		}
+	}
+	return res, nil
+}
+
+// evict evicts the pods, respecting their disruption budgets, and waits for them to be gone.
+func (r *nodeRollout) evict(ctx context.Context, pods []corev1.Pod) error {
+	if len(pods) == 0 {
+		return nil
+	}
+	remaining := map[types.UID]corev1.Pod{}
+	for _, pod := range pods {
+		remaining[pod.UID] = pod
+	}
+	err := wait.PollUntilContextTimeout(ctx, r.pollInterval, r.timeout, true, func(ctx context.Context) (bool, error) {
+		for uid, pod := range remaining {
+			cur, err := r.cs.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if kerrors.IsNotFound(err) || (err == nil && cur.UID != uid) {
+				delete(remaining, uid)
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if cur.DeletionTimestamp != nil {
+				continue
+			}
+			err = r.cs.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			})
+			// Too many requests means a disruption budget does not allow the eviction yet.
+			if err != nil && !kerrors.IsNotFound(err) && !kerrors.IsTooManyRequests(err) {
+				return false, fmt.Errorf("failed to evict pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			}
+		}
+		return len(remaining) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%d in-mesh pods not drained after %v: %v", len(remaining), r.timeout, err)
+	}
+	return nil
+}
+
+// rolloutNodeAgents rolls out the DaemonSets of the node agents applied by the components together, node by node,
+// reporting the progress to the progress log of the components and to the NodeRolloutProgress option. istio-cni is
+// replaced before ztunnel on each node, so the new ztunnel starts with the redirection in place. It returns the error
+// of each component whose DaemonSets were not rolled out.
+func (h *HelmReconciler) rolloutNodeAgents() map[name.ComponentName]error {
+	h.nodeAgentsMu.Lock()
+	applied := h.nodeAgents
+	h.nodeAgents = nil
+	h.nodeAgentsMu.Unlock()
+	if len(applied) == 0 {
+		return nil
+	}
+	sort.SliceStable(applied, func(i, j int) bool {
+		return applied[i].component == name.CNIComponentName && applied[j].component != name.CNIComponentName
+	})
+
+	var err error
+	if !h.opts.DryRun && !TestMode {
+		var daemonSets []types.NamespacedName
+		for _, a := range applied {
+			for _, o := range a.daemonSets {
+				daemonSets = append(daemonSets, types.NamespacedName{Namespace: o.Namespace, Name: o.Name})
+			}
+		}
+		r := &nodeRollout{
+			cs:             h.kubeClient.Kube(),
+			strategy:       h.opts.NodeRollout,
+			istioNamespace: istioV1Alpha1.Namespace(h.iop.Spec),
+			timeout:        h.opts.WaitTimeout,
+			pollInterval:   nodeRolloutPollInterval,
+			progress: func(p NodeRolloutProgress) {
+				if !p.Done && p.Err == nil {
+					for _, a := range applied {
+						a.plog.ReportWaiting([]string{fmt.Sprintf("DaemonSets %s on node %s (%d/%d nodes upgraded)",
+							strings.Join(p.DaemonSets, ", "), p.Node, p.Upgraded, p.Total)})
+					}
+				}
+				if h.opts.NodeRolloutProgress != nil {
+					h.opts.NodeRolloutProgress(p)
+				}
+			},
+		}
+		err = r.run(daemonSets)
+	}
+
+	res := map[name.ComponentName]error{}
+	for _, a := range applied {
+		if err != nil {
+			// Forget the DaemonSets, so the next reconcile applies them again and resumes the rollout.
+			objectCache := cache.GetCache(a.crHash)
+			objectCache.Mu.Lock()
+			for _, obj := range a.daemonSets {
+				delete(objectCache.Cache, obj.Hash())
+			}
+			objectCache.Mu.Unlock()
+			a.plog.ReportError(err.Error())
+			res[a.component] = err
+			continue
+		}
+		if werr := WaitForResources(a.daemonSets, h.kubeClient, h.opts.WaitTimeout, h.opts.DryRun, a.plog); werr != nil {
+			werr = fmt.Errorf("failed to wait for resource: %v", werr)
+			a.plog.ReportError(werr.Error())
+			res[a.component] = werr
+			continue
+		}
+		a.plog.ReportFinished()
+	}
+	return res
+}
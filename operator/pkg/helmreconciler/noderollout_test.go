@@ -0,0 +1,278 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/cni/pkg/ambient/status"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+// rawResponse is the response of a proxied request to a pod.
+type rawResponse []byte
+
+func (r rawResponse) DoRaw(context.Context) ([]byte, error) {
+	return r, nil
+}
+
+func (r rawResponse) Stream(context.Context) (io.ReadCloser, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestNodeRollout(t *testing.T) {
+	podsResource := corev1.SchemeGroupVersion.WithResource("pods")
+	daemonSet := func(app string) *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: app, Namespace: "istio-system", Generation: 2,
+				Annotations: map[string]string{templateGenerationAnnotation: "2"},
+			},
+			Spec:   appsv1.DaemonSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}},
+			Status: appsv1.DaemonSetStatus{ObservedGeneration: 2},
+		}
+	}
+	ztunnel, cni := daemonSet("ztunnel"), daemonSet("istio-cni-node")
+	daemonSets := []types.NamespacedName{{Namespace: "istio-system", Name: "istio-cni-node"}, {Namespace: "istio-system", Name: "ztunnel"}}
+	daemonSetNames := []string{"istio-system/istio-cni-node", "istio-system/ztunnel"}
+	ready := []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	agent := func(app, node, generation string, isReady bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: app + "-" + node + "-" + generation, Namespace: "istio-system",
+				Labels: map[string]string{"app": app, templateGenerationLabel: generation},
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+		}
+		if isReady {
+			pod.Status.Conditions = ready
+		}
+		return pod
+	}
+	istiod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "istiod", Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+		Status:     corev1.PodStatus{Conditions: ready},
+	}
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	appPod := func(name, node string, inMesh bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+		if inMesh {
+			pod.Annotations = map[string]string{
+				constants.AmbientRedirection: constants.AmbientRedirectionEnabled,
+				constants.AmbientRedirectionStatus: status.Enrollment{
+					Redirected: true, Mode: "iptables", Ztunnel: "ztunnel-" + node + "-1", LastUpdateTime: time.Now().Add(-time.Hour),
+				}.String(),
+			}
+		}
+		return pod
+	}
+	type options struct {
+		// newAgentsReady makes the new agents ready.
+		newAgentsReady bool
+		// redirect makes the emulated istio-cni redirect the in-mesh pods of a node to its new ztunnel, and record it
+		// in their status, as the agent does when it resyncs the pods once the active ztunnel changes.
+		redirect bool
+	}
+	// newClient emulates the DaemonSet controller, replacing the deleted agents with pods of the new template,
+	// istio-cni, redirecting the in-mesh pods of a node to its new ztunnel, istiod, serving the sync status of the
+	// ztunnels, and the evictions. It returns the deleted agents, in order.
+	newClient := func(opts options, objs ...runtime.Object) (*fake.Clientset, *[]string) {
+		cs := fake.NewSimpleClientset(objs...)
+		var deleted []string
+		cs.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			da := action.(k8stesting.DeleteAction)
+			obj, err := cs.Tracker().Get(podsResource, da.GetNamespace(), da.GetName())
+			if err != nil {
+				return false, nil, nil
+			}
+			pod := obj.(*corev1.Pod)
+			app := pod.Labels["app"]
+			if app != "ztunnel" && app != "istio-cni-node" {
+				return false, nil, nil
+			}
+			deleted = append(deleted, pod.Name)
+			newAgent := agent(app, pod.Spec.NodeName, "2", opts.newAgentsReady)
+			if err := cs.Tracker().Add(newAgent); err != nil {
+				return true, nil, err
+			}
+			if app != "ztunnel" || !opts.redirect {
+				return false, nil, nil
+			}
+			list, err := cs.Tracker().List(podsResource, corev1.SchemeGroupVersion.WithKind("Pod"), "default")
+			if err != nil {
+				return true, nil, err
+			}
+			for _, p := range list.(*corev1.PodList).Items {
+				if p.Spec.NodeName != pod.Spec.NodeName || p.Annotations[constants.AmbientRedirection] != constants.AmbientRedirectionEnabled {
+					continue
+				}
+				p.Annotations[constants.AmbientRedirectionStatus] = status.Enrollment{
+					Redirected: true, Mode: "iptables", Ztunnel: newAgent.Name, LastUpdateTime: time.Now(),
+				}.String()
+				if err := cs.Tracker().Update(podsResource, &p, p.Namespace); err != nil {
+					return true, nil, err
+				}
+			}
+			return false, nil, nil
+		})
+		cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			ca := action.(k8stesting.CreateAction)
+			eviction := ca.GetObject().(metav1.Object)
+			err := cs.Tracker().Delete(podsResource, ca.GetNamespace(), eviction.GetName())
+			return true, nil, err
+		})
+		cs.PrependProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+			list, err := cs.Tracker().List(podsResource, corev1.SchemeGroupVersion.WithKind("Pod"), "istio-system")
+			if err != nil {
+				return true, nil, err
+			}
+			var statuses []syncStatus
+			for _, p := range list.(*corev1.PodList).Items {
+				if p.Labels["app"] == "ztunnel" {
+					statuses = append(statuses, syncStatus{ProxyID: p.Name + "." + p.Namespace})
+				}
+			}
+			b, err := json.Marshal(statuses)
+			return true, rawResponse(b), err
+		})
+		return cs, &deleted
+	}
+	newRollout := func(cs *fake.Clientset, strategy NodeRolloutStrategy, timeout time.Duration, progress *[]NodeRolloutProgress) *nodeRollout {
+		return &nodeRollout{
+			cs: cs, strategy: strategy, istioNamespace: "istio-system", timeout: timeout, pollInterval: 10 * time.Millisecond,
+			progress: func(p NodeRolloutProgress) {
+				*progress = append(*progress, p)
+			},
+		}
+	}
+	getPod := func(cs *fake.Clientset, namespace, name string) error {
+		_, err := cs.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return err
+	}
+
+	t.Run("node", func(t *testing.T) {
+		cs, deleted := newClient(options{newAgentsReady: true, redirect: true}, ztunnel, cni, istiod, node("n1"), node("n2"), node("n3"),
+			agent("ztunnel", "n1", "1", true), agent("istio-cni-node", "n1", "1", true),
+			agent("ztunnel", "n2", "2", true), agent("istio-cni-node", "n2", "1", true),
+			agent("ztunnel", "n3", "2", true), agent("istio-cni-node", "n3", "2", true),
+			appPod("app", "n1", true))
+		var progress []NodeRolloutProgress
+		assert.NoError(t, newRollout(cs, NodeRolloutNode, time.Second, &progress).run(daemonSets))
+		assert.Equal(t, progress, []NodeRolloutProgress{
+			{DaemonSets: daemonSetNames, Node: "n1", Upgraded: 1, Total: 3},
+			{DaemonSets: daemonSetNames, Node: "n2", Upgraded: 2, Total: 3},
+			{DaemonSets: daemonSetNames, Upgraded: 3, Total: 3, Done: true},
+		})
+		// The agents of a node are replaced one after the other, istio-cni first, and one node at a time.
+		assert.Equal(t, *deleted, []string{"istio-cni-node-n1-1", "ztunnel-n1-1", "istio-cni-node-n2-1"})
+		assert.NoError(t, getPod(cs, "istio-system", "ztunnel-n1-2"))
+		assert.Equal(t, kerrors.IsNotFound(getPod(cs, "istio-system", "ztunnel-n1-1")), true)
+		// The in-mesh pods reconnect to the new agent.
+		assert.NoError(t, getPod(cs, "default", "app"))
+		for _, a := range cs.Actions() {
+			assert.Equal(t, a.GetVerb() == "patch", false)
+		}
+	})
+
+	t.Run("not reconnected", func(t *testing.T) {
+		cs, _ := newClient(options{newAgentsReady: true}, ztunnel, istiod, node("n1"), node("n2"),
+			agent("ztunnel", "n1", "1", true), agent("ztunnel", "n2", "1", true), appPod("app", "n1", true))
+		var progress []NodeRolloutProgress
+		err := newRollout(cs, NodeRolloutNode, 100*time.Millisecond, &progress).run(daemonSets[1:])
+		assert.Error(t, err)
+		last := progress[len(progress)-1]
+		assert.Equal(t, last.Node, "n1")
+		assert.Equal(t, last.Upgraded, 0)
+		// The rollout stops at the node whose in-mesh pods still use the previous agent.
+		assert.NoError(t, getPod(cs, "istio-system", "ztunnel-n2-1"))
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		cs, _ := newClient(options{newAgentsReady: true}, ztunnel, istiod, node("n1"), agent("ztunnel", "n1", "1", true),
+			appPod("app", "n1", true), appPod("not-in-mesh", "n1", false), appPod("other-node", "n2", true))
+		var progress []NodeRolloutProgress
+		assert.NoError(t, newRollout(cs, NodeRolloutDrain, time.Second, &progress).run(daemonSets[1:]))
+		assert.Equal(t, kerrors.IsNotFound(getPod(cs, "default", "app")), true)
+		assert.NoError(t, getPod(cs, "default", "not-in-mesh"))
+		assert.NoError(t, getPod(cs, "default", "other-node"))
+		// Cordoned during the upgrade, and uncordoned once the new agent is ready.
+		patches := 0
+		for _, a := range cs.Actions() {
+			if a.GetVerb() == "patch" && a.GetResource().Resource == "nodes" {
+				patches++
+			}
+		}
+		assert.Equal(t, patches, 2)
+		n, err := cs.CoreV1().Nodes().Get(context.TODO(), "n1", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, n.Spec.Unschedulable, false)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		cs, deleted := newClient(options{}, ztunnel, cni, istiod, node("n1"), node("n2"),
+			agent("ztunnel", "n1", "1", true), agent("istio-cni-node", "n1", "1", true),
+			agent("ztunnel", "n2", "1", true), agent("istio-cni-node", "n2", "1", true))
+		var progress []NodeRolloutProgress
+		err := newRollout(cs, NodeRolloutCordon, 100*time.Millisecond, &progress).run(daemonSets)
+		assert.Error(t, err)
+		last := progress[len(progress)-1]
+		assert.Equal(t, last.Node, "n1")
+		assert.Equal(t, last.Upgraded, 0)
+		assert.Equal(t, last.Err != nil, true)
+		// The rollout stops at the first agent not ready, which stays cordoned, and the other nodes keep the previous
+		// agents.
+		assert.Equal(t, *deleted, []string{"istio-cni-node-n1-1"})
+		assert.NoError(t, getPod(cs, "istio-system", "ztunnel-n2-1"))
+		n, err := cs.CoreV1().Nodes().Get(context.TODO(), "n1", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, n.Spec.Unschedulable, true)
+	})
+}
+
+func TestParseNodeRolloutStrategy(t *testing.T) {
+	for _, s := range []string{"", "rolling"} {
+		st, err := ParseNodeRolloutStrategy(s)
+		assert.NoError(t, err)
+		assert.Equal(t, st, NodeRolloutRolling)
+	}
+	st, err := ParseNodeRolloutStrategy("drain")
+	assert.NoError(t, err)
+	assert.Equal(t, st, NodeRolloutDrain)
+	_, err = ParseNodeRolloutStrategy("fast")
+	assert.Error(t, err)
+}
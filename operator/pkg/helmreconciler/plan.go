@@ -0,0 +1,366 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kubectlutil "k8s.io/kubectl/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"istio.io/api/label"
+	revtag "istio.io/istio/istioctl/pkg/tag"
+	"istio.io/istio/operator/pkg/compare"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util"
+)
+
+// PlanAction is the action an install takes on an object.
+type PlanAction string
+
+const (
+	PlanCreate    PlanAction = "create"
+	PlanUpdate    PlanAction = "update"
+	PlanPrune     PlanAction = "prune"
+	PlanUnchanged PlanAction = "unchanged"
+)
+
+// planIgnorePaths are the paths set by the API server, which never differ because of the install.
+var planIgnorePaths = []string{
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.generation",
+	"metadata.uid",
+	"metadata.creationTimestamp",
+	"metadata.annotations.kubectl.kubernetes.io/last-applied-configuration",
+	"status",
+}
+
+// PlannedObject is an object and the action an install takes on it.
+type PlannedObject struct {
+	Action    PlanAction
+	Component name.ComponentName
+	Kind      string
+	Namespace string
+	Name      string
+	// Diff is the tree based diff between the live object and the object as updated, for PlanUpdate.
+	Diff string
+
+	// live and desired are the object before and after the install, used to summarize its impact.
+	live    *unstructured.Unstructured
+	desired *unstructured.Unstructured
+}
+
+func (p *PlannedObject) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s/%s", p.Kind, p.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", p.Kind, p.Namespace, p.Name)
+}
+
+// TagMove is a revision tag pointed at another revision by the install. From is empty for a new tag.
+type TagMove struct {
+	Tag  string
+	From string
+	To   string
+}
+
+// InstallPlan is the outcome of an install computed against the live cluster, without changing it.
+type InstallPlan struct {
+	Objects []*PlannedObject
+
+	// RollingWorkloads are the Deployments and DaemonSets whose pods are replaced by the install.
+	RollingWorkloads []*PlannedObject
+	// Webhooks are the created, updated or pruned webhook configurations.
+	Webhooks []*PlannedObject
+	// CRDs are the created, updated or pruned CustomResourceDefinitions.
+	CRDs []*PlannedObject
+	// TagMoves are the revision tags pointed at another revision.
+	TagMoves []TagMove
+}
+
+// Plan computes the changes Reconcile would make to the cluster. Every rendered object is server-side dry-run
+// against the live cluster, so defaulting and admission are taken into account in the diffs.
+func (h *HelmReconciler) Plan() (*InstallPlan, error) {
+	manifests, err := h.RenderCharts()
+	if err != nil {
+		return nil, err
+	}
+	serverSideApply := h.CheckSSAEnabled()
+	plan := &InstallPlan{}
+	var errs util.Errors
+	for cname, ms := range manifests {
+		for _, m := range ms {
+			objs, err := object.ParseK8sObjectsFromYAMLManifest(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range objs {
+				obju := obj.UnstructuredObject()
+				if err := h.applyLabelsAndAnnotations(obju, string(cname)); err != nil {
+					return nil, err
+				}
+				po, err := h.planObject(obju, serverSideApply)
+				if err != nil {
+					errs = util.AppendErr(errs, err)
+					continue
+				}
+				po.Component = cname
+				plan.Objects = append(plan.Objects, po)
+			}
+		}
+	}
+	if err := errs.ToError(); err != nil {
+		return nil, err
+	}
+
+	if !h.opts.SkipPrune {
+		err := h.runForAllTypes(func(labels map[string]string, objects *unstructured.UnstructuredList) error {
+			for cname, manifest := range manifests.Consolidated() {
+				for _, obj := range h.pruneCandidates(object.AllObjectHashes(manifest), labels, cname, objects, false) {
+					po := newPlannedObject(PlanPrune, obj.UnstructuredObject(), nil)
+					po.Component = name.ComponentName(cname)
+					plan.Objects = append(plan.Objects, po)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(plan.Objects, func(i, j int) bool {
+		return plan.Objects[i].String() < plan.Objects[j].String()
+	})
+	plan.summarize()
+	plan.TagMoves = append(plan.TagMoves, h.planDefaultTagMove()...)
+	return plan, nil
+}
+
+// planObject dry-runs the apply of obj, like ApplyObject, and returns the action taken on it.
+func (h *HelmReconciler) planObject(obj *unstructured.Unstructured, serverSideApply bool) (*PlannedObject, error) {
+	objectStr := fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	err := h.client.Get(context.TODO(), client.ObjectKeyFromObject(obj), live)
+	if kerrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		desired := obj.DeepCopy()
+		if err := h.client.Create(context.TODO(), desired, client.DryRunAll); err != nil &&
+			!kerrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			// A missing namespace or kind is created by the install itself, so the object is only validated once
+			// they exist.
+			return nil, fmt.Errorf("failed to create %q: %v", objectStr, err)
+		}
+		return newPlannedObject(PlanCreate, nil, desired), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %q: %v", objectStr, err)
+	}
+
+	var desired *unstructured.Unstructured
+	switch {
+	case serverSideApply:
+		desired = obj.DeepCopy()
+		opts := []client.PatchOption{client.ForceOwnership, client.FieldOwner(fieldOwnerOperator), client.DryRunAll}
+		if err := h.client.Patch(context.TODO(), desired, client.Apply, opts...); err != nil {
+			return nil, fmt.Errorf("failed to update resource with server-side apply for obj %v: %v", objectStr, err)
+		}
+	case strings.EqualFold(obj.GetKind(), "IstioOperator"):
+		desired = obj.DeepCopy()
+		desired.SetResourceVersion(live.GetResourceVersion())
+		if err := h.client.Update(context.TODO(), desired, client.DryRunAll); err != nil {
+			return nil, fmt.Errorf("failed to update %q: %v", objectStr, err)
+		}
+	default:
+		desired = live.DeepCopy()
+		o := obj.DeepCopy()
+		if err := kubectlutil.CreateApplyAnnotation(o, unstructured.UnstructuredJSONScheme); err != nil {
+			scope.Errorf("unexpected error adding apply annotation to object: %s", err)
+		}
+		if err := applyOverlay(desired, o); err != nil {
+			return nil, err
+		}
+		if err := h.client.Update(context.TODO(), desired, client.DryRunAll); err != nil {
+			return nil, fmt.Errorf("failed to update %q: %v", objectStr, err)
+		}
+	}
+
+	po := newPlannedObject(PlanUnchanged, live, desired)
+	if po.Diff = diffObjects(live, desired); po.Diff != "" {
+		po.Action = PlanUpdate
+	}
+	return po, nil
+}
+
+func newPlannedObject(action PlanAction, live, desired *unstructured.Unstructured) *PlannedObject {
+	o := desired
+	if o == nil {
+		o = live
+	}
+	return &PlannedObject{
+		Action:    action,
+		Kind:      o.GetKind(),
+		Namespace: o.GetNamespace(),
+		Name:      o.GetName(),
+		live:      live,
+		desired:   desired,
+	}
+}
+
+// diffObjects returns the tree based diff between two versions of an object, ignoring the fields set by the API
+// server.
+func diffObjects(a, b *unstructured.Unstructured) string {
+	ay, err := util.ToYAMLGeneric(a.Object)
+	if err != nil {
+		return err.Error()
+	}
+	by, err := util.ToYAMLGeneric(b.Object)
+	if err != nil {
+		return err.Error()
+	}
+	return compare.YAMLCmpWithIgnore(string(ay), string(by), planIgnorePaths, "")
+}
+
+// summarize collects the objects of the plan with an impact beyond their own changes.
+func (p *InstallPlan) summarize() {
+	for _, o := range p.Objects {
+		if o.Action == PlanUnchanged {
+			continue
+		}
+		switch o.Kind {
+		case name.DeploymentStr, name.DaemonSetStr:
+			if o.Action == PlanUpdate && !reflect.DeepEqual(podTemplate(o.live), podTemplate(o.desired)) {
+				p.RollingWorkloads = append(p.RollingWorkloads, o)
+			}
+		case name.MutatingWebhookConfigurationStr, name.ValidatingWebhookConfigurationStr:
+			p.Webhooks = append(p.Webhooks, o)
+		case name.CRDStr:
+			p.CRDs = append(p.CRDs, o)
+		}
+		if o.Kind != name.MutatingWebhookConfigurationStr || o.Action == PlanPrune {
+			continue
+		}
+		tag := o.desired.GetLabels()[revtag.IstioTagLabel]
+		if tag == "" {
+			continue
+		}
+		to := o.desired.GetLabels()[label.IoIstioRev.Name]
+		from := ""
+		if o.live != nil {
+			from = o.live.GetLabels()[label.IoIstioRev.Name]
+		}
+		if from != to {
+			p.TagMoves = append(p.TagMoves, TagMove{Tag: tag, From: from, To: to})
+		}
+	}
+}
+
+func podTemplate(o *unstructured.Unstructured) any {
+	t, _, _ := unstructured.NestedFieldNoCopy(o.Object, "spec", "template")
+	return t
+}
+
+// planDefaultTagMove returns the move of the default tag made by ProcessDefaultWebhook after the install.
+func (h *HelmReconciler) planDefaultTagMove() []TagMove {
+	if h.kubeClient == nil {
+		return nil
+	}
+	exists := revtag.PreviousInstallExists(context.Background(), h.kubeClient.Kube())
+	to, ok := defaultWebhookRevision(h.iop, exists)
+	if !ok {
+		return nil
+	}
+	whs, err := revtag.GetWebhooksWithTag(context.Background(), h.kubeClient.Kube(), revtag.DefaultRevisionName)
+	if err != nil {
+		scope.Warnf("failed to get the default tag webhook: %v", err)
+		return nil
+	}
+	from := ""
+	if len(whs) > 0 {
+		from, _ = revtag.GetWebhookRevision(whs[0])
+	}
+	if from == to {
+		return nil
+	}
+	return []TagMove{{Tag: revtag.DefaultRevisionName, From: from, To: to}}
+}
+
+// Write writes the plan in a human readable form.
+func (p *InstallPlan) Write(w io.Writer) {
+	unchanged := 0
+	for _, action := range []PlanAction{PlanCreate, PlanUpdate, PlanPrune} {
+		for _, o := range p.Objects {
+			if o.Action != action {
+				continue
+			}
+			fmt.Fprintf(w, "%-6s %s (%s)\n", action, o, o.Component)
+			if o.Diff != "" {
+				for _, l := range strings.Split(strings.TrimSuffix(o.Diff, "\n"), "\n") {
+					fmt.Fprintf(w, "         %s\n", l)
+				}
+			}
+		}
+	}
+	for _, o := range p.Objects {
+		if o.Action == PlanUnchanged {
+			unchanged++
+		}
+	}
+	fmt.Fprintf(w, "%d objects unchanged.\n", unchanged)
+
+	fmt.Fprintln(w, "\nImpact:")
+	if len(p.RollingWorkloads) == 0 && len(p.Webhooks) == 0 && len(p.CRDs) == 0 && len(p.TagMoves) == 0 {
+		fmt.Fprintln(w, "  None")
+		return
+	}
+	for _, o := range p.RollingWorkloads {
+		fmt.Fprintf(w, "  %s will roll out new pods\n", o)
+	}
+	for _, o := range p.Webhooks {
+		fmt.Fprintf(w, "  webhook %s will be %s\n", o, pastTense(o.Action))
+	}
+	for _, o := range p.CRDs {
+		fmt.Fprintf(w, "  CRD %s will be %s\n", o.Name, pastTense(o.Action))
+	}
+	for _, t := range p.TagMoves {
+		if t.From == "" {
+			fmt.Fprintf(w, "  revision tag %q will point to revision %q\n", t.Tag, t.To)
+		} else {
+			fmt.Fprintf(w, "  revision tag %q will move from revision %q to %q\n", t.Tag, t.From, t.To)
+		}
+	}
+}
+
+func pastTense(a PlanAction) string {
+	switch a {
+	case PlanCreate:
+		return "created"
+	case PlanUpdate:
+		return "updated"
+	case PlanPrune:
+		return "pruned"
+	}
+	return string(a)
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func planTestObject(kind, name string, labels map[string]string, image string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{Object: map[string]any{}}
+	o.SetKind(kind)
+	o.SetName(name)
+	o.SetNamespace("istio-system")
+	o.SetLabels(labels)
+	if image != "" {
+		_ = unstructured.SetNestedField(o.Object, image, "spec", "template", "spec", "image")
+	}
+	return o
+}
+
+func TestInstallPlanSummarize(t *testing.T) {
+	tagLabels := func(rev string) map[string]string {
+		return map[string]string{"istio.io/tag": "prod", "istio.io/rev": rev}
+	}
+	plan := &InstallPlan{Objects: []*PlannedObject{
+		newPlannedObject(PlanUpdate,
+			planTestObject("Deployment", "istiod", nil, "pilot:1.0"),
+			planTestObject("Deployment", "istiod", map[string]string{"a": "b"}, "pilot:1.1")),
+		newPlannedObject(PlanUpdate,
+			planTestObject("Deployment", "relabeled", nil, "pilot:1.0"),
+			planTestObject("Deployment", "relabeled", map[string]string{"a": "b"}, "pilot:1.0")),
+		newPlannedObject(PlanUnchanged,
+			planTestObject("DaemonSet", "cni", nil, "cni:1.0"),
+			planTestObject("DaemonSet", "cni", nil, "cni:1.0")),
+		newPlannedObject(PlanCreate, nil, planTestObject("CustomResourceDefinition", "wasmplugins.extensions.istio.io", nil, "")),
+		newPlannedObject(PlanUpdate,
+			planTestObject("MutatingWebhookConfiguration", "istio-revision-tag-prod", tagLabels("1-0"), ""),
+			planTestObject("MutatingWebhookConfiguration", "istio-revision-tag-prod", tagLabels("1-1"), "")),
+		newPlannedObject(PlanPrune, planTestObject("ValidatingWebhookConfiguration", "istio-validator", nil, ""), nil),
+	}}
+	plan.summarize()
+
+	names := func(objs []*PlannedObject) []string {
+		var res []string
+		for _, o := range objs {
+			res = append(res, o.Name)
+		}
+		return res
+	}
+	assert.Equal(t, names(plan.RollingWorkloads), []string{"istiod"})
+	assert.Equal(t, names(plan.CRDs), []string{"wasmplugins.extensions.istio.io"})
+	assert.Equal(t, names(plan.Webhooks), []string{"istio-revision-tag-prod", "istio-validator"})
+	assert.Equal(t, plan.TagMoves, []TagMove{{Tag: "prod", From: "1-0", To: "1-1"}})
+
+	var out bytes.Buffer
+	plan.Write(&out)
+	for _, want := range []string{
+		"create CustomResourceDefinition/istio-system/wasmplugins.extensions.istio.io",
+		"prune  ValidatingWebhookConfiguration/istio-system/istio-validator",
+		"1 objects unchanged.",
+		"Deployment/istio-system/istiod will roll out new pods",
+		"webhook ValidatingWebhookConfiguration/istio-system/istio-validator will be pruned",
+		`revision tag "prod" will move from revision "1-0" to "1-1"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("plan output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -427,33 +427,45 @@ func (h *HelmReconciler) deleteResources(excluded map[string]bool, coreLabels ma
 	componentName string, objects *unstructured.UnstructuredList, all bool,
 ) error {
 	var errs util.Errors
+	for _, obj := range h.pruneCandidates(excluded, coreLabels, componentName, objects, all) {
+		if err := h.deleteResource(obj, componentName, obj.Hash()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if all {
+		cache.FlushObjectCaches()
+	}
+
+	return errs.ToError()
+}
+
+// pruneCandidates returns the objects from the given component that are not in the excluded map, which are the
+// objects deleteResources deletes.
+func (h *HelmReconciler) pruneCandidates(excluded map[string]bool, coreLabels map[string]string,
+	componentName string, objects *unstructured.UnstructuredList, all bool,
+) []*object.K8sObject {
+	var res []*object.K8sObject
 	labels := h.addComponentLabels(coreLabels, componentName)
 	selector := klabels.Set(labels).AsSelectorPreValidated()
-	for _, o := range objects.Items {
-		obj := object.NewK8sObject(&o, nil, nil)
-		oh := obj.Hash()
+	for i := range objects.Items {
+		o := &objects.Items[i]
+		obj := object.NewK8sObject(o, nil, nil)
 		if !all {
 			// Label mismatch. Provided objects don't select against the component, so this likely means the object
 			// is for another component.
 			if !selector.Matches(klabels.Set(o.GetLabels())) {
 				continue
 			}
-			if excluded[oh] {
+			if excluded[obj.Hash()] {
 				continue
 			}
 			if o.GetLabels()[OwningResourceNotPruned] == "true" {
 				continue
 			}
 		}
-		if err := h.deleteResource(obj, componentName, oh); err != nil {
-			errs = append(errs, err)
-		}
+		res = append(res, obj)
 	}
-	if all {
-		cache.FlushObjectCaches()
-	}
-
-	return errs.ToError()
+	return res
 }
 
 func (h *HelmReconciler) deleteResource(obj *object.K8sObject, componentName, oh string) error {
@@ -41,6 +41,7 @@ import (
 	"istio.io/istio/operator/pkg/metrics"
 	"istio.io/istio/operator/pkg/name"
 	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/postrender"
 	"istio.io/istio/operator/pkg/util"
 	"istio.io/istio/operator/pkg/util/clog"
 	"istio.io/istio/operator/pkg/util/progress"
@@ -67,6 +68,10 @@ type HelmReconciler struct {
 	// dependencyWaitCh is a map of signaling channels. A parent with children ch1...chN will signal
 	// dependencyWaitCh[ch1]...dependencyWaitCh[chN] when it's completely installed.
 	dependencyWaitCh map[name.ComponentName]chan struct{}
+	// nodeAgents are the DaemonSets of the node agents applied by the components, rolled out node by node once every
+	// component is applied.
+	nodeAgents   []appliedNodeAgents
+	nodeAgentsMu sync.Mutex
 
 	// The fields below are for metrics and reporting
 	countLock     *sync.Mutex
@@ -90,6 +95,12 @@ type Options struct {
 	Force bool
 	// SkipPrune will skip pruning
 	SkipPrune bool
+	// PostRenderer transforms the objects rendered from the charts before they are applied and pruned.
+	PostRenderer postrender.PostRenderer
+	// NodeRollout selects how the DaemonSets of the node agents, ztunnel and istio-cni, are upgraded.
+	NodeRollout NodeRolloutStrategy
+	// NodeRolloutProgress, if set, is called with the progress of the node rollouts.
+	NodeRolloutProgress func(NodeRolloutProgress)
 }
 
 var (
@@ -241,6 +252,10 @@ func (h *HelmReconciler) processRecursive(manifests name.ManifestMap) *v1alpha1.
 	}
 	wg.Wait()
 
+	for c, err := range h.rolloutNodeAgents() {
+		setStatus(componentStatus, c, v1alpha1.InstallStatus_ERROR, err)
+	}
+
 	metrics.ReportOwnedResourceCounts()
 
 	out := &v1alpha1.InstallStatus{
@@ -324,6 +339,7 @@ func (h *HelmReconciler) SetStatusBegin() error {
 		}
 		return fmt.Errorf("failed to get IstioOperator before updating status due to %v", err)
 	}
+	orig := isop.DeepCopy()
 	if isop.Status == nil {
 		isop.Status = &v1alpha1.InstallStatus{Status: v1alpha1.InstallStatus_RECONCILING}
 	} else {
@@ -335,7 +351,8 @@ func (h *HelmReconciler) SetStatusBegin() error {
 		}
 		isop.Status.Status = v1alpha1.InstallStatus_RECONCILING
 	}
-	return h.getClient().Status().Update(context.TODO(), isop)
+	// Status fields unknown to the typed object, like the conditions set by the controller, are kept by patching.
+	return h.getClient().Status().Patch(context.TODO(), isop, client.MergeFrom(orig))
 }
 
 // SetStatusComplete updates the status field on the IstioOperator instance based on the resulting err parameter.
@@ -344,8 +361,9 @@ func (h *HelmReconciler) SetStatusComplete(status *v1alpha1.InstallStatus) error
 	if err := h.getClient().Get(context.TODO(), config.NamespacedName(h.iop), iop); err != nil {
 		return fmt.Errorf("failed to get IstioOperator before updating status due to %v", err)
 	}
+	orig := iop.DeepCopy()
 	iop.Status = status
-	return h.getClient().Status().Update(context.TODO(), iop)
+	return h.getClient().Status().Patch(context.TODO(), iop, client.MergeFrom(orig))
 }
 
 // setStatus sets the status for the component with the given name, which is a key in the given map.
@@ -608,14 +626,7 @@ type ProcessDefaultWebhookOptions struct {
 }
 
 func ProcessDefaultWebhook(client kube.Client, iop *istioV1Alpha1.IstioOperator, exists bool, opt *ProcessDefaultWebhookOptions) (processed bool, err error) {
-	// Detect whether previous installation exists prior to performing the installation.
-	rev := iop.Spec.Revision
-	isDefaultInstallation := rev == "" && iop.Spec.Components.Pilot != nil && iop.Spec.Components.Pilot.Enabled.Value
-	operatorManageWebhooks := operatorManageWebhooks(iop)
-	if !operatorManageWebhooks && (!exists || isDefaultInstallation) {
-		if rev == "" {
-			rev = revtag.DefaultRevisionName
-		}
+	if rev, ok := defaultWebhookRevision(iop, exists); ok {
 		autoInjectNamespaces := validateEnableNamespacesByDefault(iop)
 
 		ignorePruneLabel := map[string]string{
@@ -641,6 +652,20 @@ func ProcessDefaultWebhook(client kube.Client, iop *istioV1Alpha1.IstioOperator,
 	return processed, nil
 }
 
+// defaultWebhookRevision returns the revision ProcessDefaultWebhook points the default tag at, if it does. exists is
+// whether a previous installation exists.
+func defaultWebhookRevision(iop *istioV1Alpha1.IstioOperator, exists bool) (string, bool) {
+	rev := iop.Spec.Revision
+	isDefaultInstallation := rev == "" && iop.Spec.Components.Pilot != nil && iop.Spec.Components.Pilot.Enabled.Value
+	if operatorManageWebhooks(iop) || (exists && !isDefaultInstallation) {
+		return "", false
+	}
+	if rev == "" {
+		rev = revtag.DefaultRevisionName
+	}
+	return rev, true
+}
+
 func applyManifests(kubeClient kube.Client, manifests string) error {
 	yamls := strings.Split(manifests, helm.YAMLSeparator)
 	for _, yml := range yamls {
@@ -19,6 +19,7 @@ import (
 
 	"istio.io/istio/operator/pkg/controlplane"
 	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/postrender"
 	"istio.io/istio/operator/pkg/translate"
 	"istio.io/istio/operator/pkg/validate"
 )
@@ -50,6 +51,12 @@ func (h *HelmReconciler) RenderCharts() (name.ManifestMap, error) {
 	if errs != nil {
 		err = errs.ToError()
 	}
+	if err == nil && h.opts.PostRenderer != nil {
+		// Post-render before tracking the manifests, so the transformed objects are the ones applied and pruned.
+		if manifests, err = postrender.Apply(h.opts.PostRenderer, manifests); err != nil {
+			return nil, fmt.Errorf("post-render: %v", err)
+		}
+	}
 
 	h.manifests = manifests
 
@@ -0,0 +1,211 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"istio.io/istio/operator/pkg/helm"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/util"
+	"istio.io/istio/operator/pkg/validate"
+)
+
+// Overlays selects fragments of overlay libraries. An overlay library is a directory of IstioOperator fragments,
+// each in a <name>.yaml file, shared between installations, e.g. observability settings or resource sizing.
+// The selected fragments are overlaid in order on the profile, before the user files.
+type Overlays struct {
+	// Paths are the directories of the overlay libraries, searched in order for each fragment.
+	Paths []string
+	// Names are the names of the fragments to overlay, in order.
+	Names []string
+	// Vars are the values of the variables of the fragments.
+	Vars map[string]string
+}
+
+// Layer is an IstioOperator YAML overlaid to generate the configuration, with where it comes from.
+type Layer struct {
+	// Source describes where the layer comes from, e.g. overlay observability.
+	Source string
+	YAML   string
+}
+
+// yamlSuffix is the suffix of the files of the fragments of overlay libraries.
+const yamlSuffix = ".yaml"
+
+// overlayVarRegexp matches the variables of fragments, written ${name} or ${name:-default}.
+var overlayVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Layers reads the selected fragments and substitutes their variables. It returns no layers if o is nil.
+func (o *Overlays) Layers() ([]Layer, error) {
+	if o == nil {
+		return nil, nil
+	}
+	var res []Layer
+	for _, n := range o.Names {
+		path, err := o.find(n)
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		y, err := substituteOverlayVars(string(b), o.Vars)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %v", n, err)
+		}
+		multiple, err := hasMultipleIOPs(y)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %v", n, err)
+		}
+		if multiple {
+			return nil, fmt.Errorf("overlay %s contains multiple IstioOperator CRs, only one per file is supported", n)
+		}
+		res = append(res, Layer{Source: "overlay " + n, YAML: y})
+	}
+	return res, nil
+}
+
+// find returns the path of the fragment with the given name in the first library having it.
+func (o *Overlays) find(n string) (string, error) {
+	if n == "" || filepath.IsAbs(n) || strings.Contains(filepath.ToSlash(n), "..") {
+		return "", fmt.Errorf("invalid overlay name %q, overlays are referenced by their name in a library", n)
+	}
+	if len(o.Paths) == 0 {
+		return "", fmt.Errorf("overlay %s: no overlay library path set", n)
+	}
+	for _, p := range o.Paths {
+		path := filepath.Join(p, n+yamlSuffix)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("overlay %s not found, available overlays are: %s", n, strings.Join(o.available(), ", "))
+}
+
+// available lists the names of the fragments of the libraries.
+func (o *Overlays) available() []string {
+	names := map[string]bool{}
+	for _, p := range o.Paths {
+		_ = filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != yamlSuffix {
+				return nil
+			}
+			rel, err := filepath.Rel(p, path)
+			if err == nil {
+				names[filepath.ToSlash(strings.TrimSuffix(rel, yamlSuffix))] = true
+			}
+			return nil
+		})
+	}
+	res := make([]string, 0, len(names))
+	for n := range names {
+		res = append(res, n)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// substituteOverlayVars replaces the variables of a fragment with their values, or their default if not set.
+func substituteOverlayVars(y string, vars map[string]string) (string, error) {
+	var missing []string
+	out := overlayVarRegexp.ReplaceAllStringFunc(y, func(m string) string {
+		sm := overlayVarRegexp.FindStringSubmatch(m)
+		if v, f := vars[sm[1]]; f {
+			return v
+		}
+		if sm[2] != "" {
+			return sm[3]
+		}
+		missing = append(missing, sm[1])
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("variables %s are not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// overlayLayers overlays the layers in order.
+func overlayLayers(layers []Layer) (string, error) {
+	var y string
+	for _, l := range layers {
+		var err error
+		if y, err = util.OverlayIOP(y, l.YAML); err != nil {
+			return "", fmt.Errorf("could not overlay %s: %v", l.Source, err)
+		}
+	}
+	return y, nil
+}
+
+// ConfigLayers returns the layers overlaid to generate the configuration, like GenerateConfigWithOverlays does, in
+// order: the profile, the overlays, each user file, and the --set flags. The settings filled in from the cluster or
+// at build time, like the hub and tag, are not part of any layer.
+func ConfigLayers(inFilenames []string, overlays *Overlays, setFlags []string) ([]Layer, error) {
+	layers, err := overlays.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range inFilenames {
+		y, err := ReadLayeredYAMLs([]string{fn})
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, Layer{Source: "file " + fn, YAML: y})
+	}
+	if len(setFlags) > 0 {
+		y, err := overlaySetFlagValues("", setFlags)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, Layer{Source: "--set", YAML: y})
+	}
+
+	// The profile and the path of the profiles are set by the last layer setting them.
+	fy, err := overlayLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	profile := name.DefaultProfileName
+	if p := GetValueForSetFlag(setFlags, "profile"); p != "" {
+		profile = p
+	} else if fy != "" {
+		iop, err := validate.UnmarshalIOP(fy)
+		if err != nil {
+			return nil, err
+		}
+		if iop.Spec != nil && iop.Spec.Profile != "" {
+			profile = iop.Spec.Profile
+		}
+	}
+	installPackagePath, err := getInstallPackagePath(fy)
+	if err != nil {
+		return nil, err
+	}
+	if sfp := GetValueForSetFlag(setFlags, "installPackagePath"); sfp != "" {
+		installPackagePath = sfp
+	}
+	profileYAML, err := helm.GetProfileYAML(installPackagePath, profile)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Layer{{Source: "profile " + profile, YAML: profileYAML}}, layers...), nil
+}
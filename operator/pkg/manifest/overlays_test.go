@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestOverlaysLayers(t *testing.T) {
+	common := t.TempDir()
+	team := t.TempDir()
+	write := func(dir, name, content string) {
+		path := filepath.Join(dir, name+yamlSuffix)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write(common, "observability", "spec:\n  meshConfig:\n    enableTracing: true\n")
+	write(common, "sizing/large", "spec:\n  components:\n    pilot:\n      k8s:\n        replicaCount: ${replicas:-3}\n")
+	write(team, "observability", "spec:\n  meshConfig:\n    enableTracing: false\n")
+	write(team, "hub", "spec:\n  hub: ${hub}\n")
+
+	o := &Overlays{Paths: []string{team, common}, Names: []string{"sizing/large", "observability"}}
+	layers, err := o.Layers()
+	assert.NoError(t, err)
+	assert.Equal(t, layers, []Layer{
+		{Source: "overlay sizing/large", YAML: "spec:\n  components:\n    pilot:\n      k8s:\n        replicaCount: 3\n"},
+		// The first library having the overlay wins.
+		{Source: "overlay observability", YAML: "spec:\n  meshConfig:\n    enableTracing: false\n"},
+	})
+
+	o = &Overlays{Paths: []string{common}, Names: []string{"sizing/large"}, Vars: map[string]string{"replicas": "5"}}
+	layers, err = o.Layers()
+	assert.NoError(t, err)
+	assert.Equal(t, layers[0].YAML, "spec:\n  components:\n    pilot:\n      k8s:\n        replicaCount: 5\n")
+
+	var nilOverlays *Overlays
+	layers, err = nilOverlays.Layers()
+	assert.NoError(t, err)
+	assert.Equal(t, len(layers), 0)
+
+	errCases := []struct {
+		name     string
+		overlays *Overlays
+		want     string
+	}{
+		{
+			name:     "unset variable",
+			overlays: &Overlays{Paths: []string{team}, Names: []string{"hub"}},
+			want:     "overlay hub: variables hub are not set",
+		},
+		{
+			name:     "not found",
+			overlays: &Overlays{Paths: []string{common}, Names: []string{"security"}},
+			want:     "overlay security not found, available overlays are: observability, sizing/large",
+		},
+		{
+			name:     "outside of the library",
+			overlays: &Overlays{Paths: []string{common}, Names: []string{"../observability"}},
+			want:     `invalid overlay name "../observability", overlays are referenced by their name in a library`,
+		},
+		{
+			name:     "no library",
+			overlays: &Overlays{Names: []string{"observability"}},
+			want:     "overlay observability: no overlay library path set",
+		},
+	}
+	for _, c := range errCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := c.overlays.Layers()
+			assert.Error(t, err)
+			assert.Equal(t, err.Error(), c.want)
+		})
+	}
+}
@@ -54,7 +54,15 @@ var installerScope = log.RegisterScope("installer", "installer")
 func GenManifests(inFilename []string, setFlags []string, force bool, filter []string,
 	client kube.Client, l clog.Logger,
 ) (name.ManifestMap, *iopv1alpha1.IstioOperator, error) {
-	mergedYAML, _, err := GenerateConfig(inFilename, setFlags, force, client, l)
+	return GenManifestsWithOverlays(inFilename, nil, setFlags, force, filter, client, l)
+}
+
+// GenManifestsWithOverlays is GenManifests with the fragments of overlay libraries overlaid on the profile, before
+// the user files.
+func GenManifestsWithOverlays(inFilename []string, overlays *Overlays, setFlags []string, force bool, filter []string,
+	client kube.Client, l clog.Logger,
+) (name.ManifestMap, *iopv1alpha1.IstioOperator, error) {
+	mergedYAML, _, err := GenerateConfigWithOverlays(inFilename, overlays, setFlags, force, client, l)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -89,22 +97,35 @@ func GenManifests(inFilename []string, setFlags []string, force bool, filter []s
 // GenerateConfig creates an IstioOperatorSpec from the following sources, overlaid sequentially:
 // 1. Compiled in base, or optionally base from paths pointing to one or multiple ICP/IOP files at inFilenames.
 // 2. Profile overlay, if non-default overlay is selected. This also comes either from compiled in or path specified in IOP contained in inFilenames.
-// 3. User overlays stored in inFilenames.
-// 4. setOverlayYAML, which comes from --set flag passed to manifest command.
+// 3. Fragments of overlay libraries, with GenerateConfigWithOverlays.
+// 4. User overlays stored in inFilenames.
+// 5. setOverlayYAML, which comes from --set flag passed to manifest command.
 //
 // Note that the user overlay at inFilenames can optionally contain a file path to a set of profiles different from the
 // ones that are compiled in. If it does, the starting point will be the base and profile YAMLs at that file path.
 // Otherwise it will be the compiled in profile YAMLs.
-// In step 3, the remaining fields in the same user overlay are applied on the resulting profile base.
+// In step 4, the remaining fields in the same user overlay are applied on the resulting profile base.
 // The force flag causes validation errors not to abort but only emit log/console warnings.
 func GenerateConfig(inFilenames []string, setFlags []string, force bool, client kube.Client,
 	l clog.Logger,
+) (string, *iopv1alpha1.IstioOperator, error) {
+	return GenerateConfigWithOverlays(inFilenames, nil, setFlags, force, client, l)
+}
+
+// GenerateConfigWithOverlays is GenerateConfig with the fragments of overlay libraries overlaid on the profile, before
+// the user files.
+func GenerateConfigWithOverlays(inFilenames []string, overlays *Overlays, setFlags []string, force bool, client kube.Client,
+	l clog.Logger,
 ) (string, *iopv1alpha1.IstioOperator, error) {
 	if err := validateSetFlags(setFlags); err != nil {
 		return "", nil, err
 	}
 
-	fy, profile, err := ReadYamlProfile(inFilenames, setFlags, force, l)
+	layers, err := overlays.Layers()
+	if err != nil {
+		return "", nil, err
+	}
+	fy, profile, err := readYamlProfile(layers, inFilenames, setFlags, force, l)
 	if err != nil {
 		return "", nil, err
 	}
@@ -226,9 +247,13 @@ func GenIOPFromProfile(profileOrPath, fileOverlayYAML string, setFlags []string,
 
 // ReadYamlProfile gets the overlay yaml file from list of files and return profile value from file overlay and set overlay.
 func ReadYamlProfile(inFilenames []string, setFlags []string, force bool, l clog.Logger) (string, string, error) {
+	return readYamlProfile(nil, inFilenames, setFlags, force, l)
+}
+
+func readYamlProfile(layers []Layer, inFilenames []string, setFlags []string, force bool, l clog.Logger) (string, string, error) {
 	profile := name.DefaultProfileName
 	// Get the overlay YAML from the list of files passed in. Also get the profile from the overlay files.
-	fy, fp, err := ParseYAMLFiles(inFilenames, force, l)
+	fy, fp, err := parseYAMLFiles(layers, inFilenames, force, l)
 	if err != nil {
 		return "", "", err
 	}
@@ -246,13 +271,25 @@ func ReadYamlProfile(inFilenames []string, setFlags []string, force bool, l clog
 // ParseYAMLFiles parses the given slice of filenames containing YAML and merges them into a single IstioOperator
 // format YAML strings. It returns the overlay YAML, the profile name and error result.
 func ParseYAMLFiles(inFilenames []string, force bool, l clog.Logger) (overlayYAML string, profile string, err error) {
-	if inFilenames == nil {
+	return parseYAMLFiles(nil, inFilenames, force, l)
+}
+
+// parseYAMLFiles is ParseYAMLFiles with the given layers overlaid before the files.
+func parseYAMLFiles(layers []Layer, inFilenames []string, force bool, l clog.Logger) (overlayYAML string, profile string, err error) {
+	if inFilenames == nil && len(layers) == 0 {
 		return "", "", nil
 	}
-	y, err := ReadLayeredYAMLs(inFilenames)
+	y, err := overlayLayers(layers)
 	if err != nil {
 		return "", "", err
 	}
+	fy, err := ReadLayeredYAMLs(inFilenames)
+	if err != nil {
+		return "", "", err
+	}
+	if y, err = util.OverlayIOP(y, fy); err != nil {
+		return "", "", err
+	}
 	var fileOverlayIOP *iopv1alpha1.IstioOperator
 	fileOverlayIOP, err = validate.UnmarshalIOP(y)
 	if err != nil {
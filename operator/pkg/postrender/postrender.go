@@ -0,0 +1,255 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postrender transforms the objects rendered from the charts before they are applied and pruned, with an
+// executable or a Kustomize kustomization, for customizations the k8s settings and overlays of IstioOperator do
+// not express well, like structural changes.
+package postrender
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+)
+
+const (
+	// componentAnnotation records the component of each object while it is post-rendered, so the objects are
+	// applied and pruned with their component afterwards.
+	componentAnnotation = "install.operator.istio.io/post-render-component"
+	// renderedFile is the file of the rendered objects added to the resources of a kustomization.
+	renderedFile = "istio-rendered.yaml"
+)
+
+// PostRenderer transforms a YAML stream of rendered objects.
+type PostRenderer interface {
+	Run(manifest string) (string, error)
+}
+
+// execPostRenderer runs an executable reading the objects from its stdin and writing the transformed objects to its
+// stdout, like the Helm post-renderers.
+type execPostRenderer struct {
+	binary string
+	args   []string
+}
+
+// NewExec returns a post-renderer running binary with args.
+func NewExec(binary string, args ...string) (PostRenderer, error) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("post-renderer %s not found: %v", binary, err)
+	}
+	return &execPostRenderer{binary: path, args: args}, nil
+}
+
+func (p *execPostRenderer) Run(manifest string) (string, error) {
+	cmd := exec.Command(p.binary, p.args...)
+	cmd.Stdin = strings.NewReader(manifest)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("post-renderer %s failed: %v: %s", p.binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// kustomizePostRenderer builds a kustomization in process, with the rendered objects added to its resources.
+type kustomizePostRenderer struct {
+	dir string
+}
+
+// NewKustomize returns a post-renderer building the kustomization of dir. The rendered objects are added to the
+// resources of the kustomization, so its patches and transformers apply to them. The directory must be
+// self-contained: it is copied to memory and resources outside of it are not found.
+func NewKustomize(dir string) (PostRenderer, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := kustomizationFile(dir); err != nil {
+		return nil, err
+	}
+	return &kustomizePostRenderer{dir: dir}, nil
+}
+
+func (p *kustomizePostRenderer) Run(manifest string) (string, error) {
+	mfs := filesys.MakeFsInMemory()
+	if err := copyToFs(p.dir, mfs); err != nil {
+		return "", fmt.Errorf("failed to read kustomization %s: %v", p.dir, err)
+	}
+	kf, err := kustomizationFile(p.dir)
+	if err != nil {
+		return "", err
+	}
+	b, err := mfs.ReadFile(kf)
+	if err != nil {
+		return "", err
+	}
+	k := &types.Kustomization{}
+	if err := yaml.Unmarshal(b, k); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %v", kf, err)
+	}
+	k.Resources = append([]string{renderedFile}, k.Resources...)
+	if b, err = yaml.Marshal(k); err != nil {
+		return "", err
+	}
+	if err := mfs.WriteFile(kf, b); err != nil {
+		return "", err
+	}
+	if err := mfs.WriteFile(filepath.Join(p.dir, renderedFile), []byte(manifest)); err != nil {
+		return "", err
+	}
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(mfs, p.dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build kustomization %s: %v", p.dir, err)
+	}
+	out, err := rm.AsYaml()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// kustomizationFile returns the path of the kustomization file of dir.
+func kustomizationFile(dir string) (string, error) {
+	for _, n := range konfig.RecognizedKustomizationFileNames() {
+		path := filepath.Join(dir, n)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no kustomization file found in %s, expected one of %s", dir,
+		strings.Join(konfig.RecognizedKustomizationFileNames(), ", "))
+}
+
+// copyToFs copies the files of dir to the same paths of mfs.
+func copyToFs(dir string, mfs filesys.FileSystem) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return mfs.MkdirAll(path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return mfs.WriteFile(path, b)
+	})
+}
+
+// Apply post-renders the manifests of all the components at once, so the post-renderer sees every object, and
+// groups the transformed objects back by component. The objects the post-renderer adds belong to the base
+// component, or to the first rendered component if base is not rendered.
+func Apply(p PostRenderer, manifests name.ManifestMap) (name.ManifestMap, error) {
+	components := make([]name.ComponentName, 0, len(manifests))
+	for c := range manifests {
+		components = append(components, c)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i] < components[j]
+	})
+
+	var in object.K8sObjects
+	for _, c := range components {
+		objs, err := object.ParseK8sObjectsFromYAMLManifest(name.MergeManifestSlices(manifests[c]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the manifest of %s: %v", c, err)
+		}
+		for _, o := range objs {
+			u := o.UnstructuredObject()
+			annotations := u.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[componentAnnotation] = string(c)
+			u.SetAnnotations(annotations)
+			in = append(in, object.NewK8sObject(u, nil, nil))
+		}
+	}
+	if len(in) == 0 {
+		return manifests, nil
+	}
+	inYAML, err := in.YAMLManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	outYAML, err := p.Run(inYAML)
+	if err != nil {
+		return nil, err
+	}
+	out, err := object.ParseK8sObjectsFromYAMLManifest(outYAML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the output of the post-renderer: %v", err)
+	}
+	if len(out) == 0 {
+		// Applying no objects would prune the whole installation.
+		return nil, fmt.Errorf("the post-renderer returned no objects")
+	}
+
+	defaultComponent := components[0]
+	if _, f := manifests[name.IstioBaseComponentName]; f {
+		defaultComponent = name.IstioBaseComponentName
+	}
+	grouped := map[name.ComponentName]object.K8sObjects{}
+	for _, o := range out {
+		u := o.UnstructuredObject()
+		annotations := u.GetAnnotations()
+		c := defaultComponent
+		if v, f := annotations[componentAnnotation]; f {
+			c = name.ComponentName(v)
+			if _, f := manifests[c]; !f {
+				return nil, fmt.Errorf("the post-renderer set an unknown component %s on %s", v, o.FullName())
+			}
+			delete(annotations, componentAnnotation)
+			if len(annotations) == 0 {
+				annotations = nil
+			}
+			u.SetAnnotations(annotations)
+		}
+		grouped[c] = append(grouped[c], object.NewK8sObject(u, nil, nil))
+	}
+
+	res := name.ManifestMap{}
+	for _, c := range components {
+		objs := grouped[c]
+		if len(objs) == 0 {
+			res[c] = nil
+			continue
+		}
+		m, err := objs.YAMLManifest()
+		if err != nil {
+			return nil, err
+		}
+		res[c] = []string{m}
+	}
+	return res, nil
+}
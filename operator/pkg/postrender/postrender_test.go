@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postrender
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const (
+	baseManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: istio-reader-service-account
+  namespace: istio-system
+`
+	pilotManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: istiod
+  namespace: istio-system
+`
+)
+
+type funcPostRenderer func(string) (string, error)
+
+func (f funcPostRenderer) Run(manifest string) (string, error) {
+	return f(manifest)
+}
+
+// objectNames returns the kind/namespace/name of the objects of each component.
+func objectNames(t *testing.T, mm name.ManifestMap) map[name.ComponentName][]string {
+	res := map[name.ComponentName][]string{}
+	for c, ms := range mm {
+		objs, err := object.ParseK8sObjectsFromYAMLManifest(name.MergeManifestSlices(ms))
+		assert.NoError(t, err)
+		for _, o := range objs {
+			assert.Equal(t, o.UnstructuredObject().GetAnnotations()[componentAnnotation], "")
+			res[c] = append(res[c], o.Hash())
+		}
+	}
+	return res
+}
+
+func TestApply(t *testing.T) {
+	manifests := name.ManifestMap{
+		name.IstioBaseComponentName: {baseManifest},
+		name.PilotComponentName:     {pilotManifest},
+	}
+
+	t.Run("exec", func(t *testing.T) {
+		p, err := NewExec("cat")
+		assert.NoError(t, err)
+		got, err := Apply(p, manifests)
+		assert.NoError(t, err)
+		assert.Equal(t, objectNames(t, got), map[name.ComponentName][]string{
+			name.IstioBaseComponentName: {"ServiceAccount:istio-system:istio-reader-service-account"},
+			name.PilotComponentName:     {"Deployment:istio-system:istiod", "Service:istio-system:istiod"},
+		})
+	})
+
+	t.Run("added and removed objects", func(t *testing.T) {
+		p := funcPostRenderer(func(manifest string) (string, error) {
+			// Drop the Service and add a PodDisruptionBudget.
+			objs, err := object.ParseK8sObjectsFromYAMLManifest(manifest)
+			if err != nil {
+				return "", err
+			}
+			objs = object.ObjectsNotInLists(objs, object.KindObjects(objs, "Service"))
+			m, err := objs.YAMLManifest()
+			return m + `apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: istiod-pdb
+  namespace: istio-system
+`, err
+		})
+		got, err := Apply(p, manifests)
+		assert.NoError(t, err)
+		assert.Equal(t, objectNames(t, got), map[name.ComponentName][]string{
+			name.IstioBaseComponentName: {
+				"ServiceAccount:istio-system:istio-reader-service-account",
+				"PodDisruptionBudget:istio-system:istiod-pdb",
+			},
+			name.PilotComponentName: {"Deployment:istio-system:istiod"},
+		})
+	})
+
+	t.Run("no objects", func(t *testing.T) {
+		_, err := Apply(funcPostRenderer(func(string) (string, error) { return "", nil }), manifests)
+		assert.Error(t, err)
+	})
+}
+
+func TestKustomize(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+commonLabels:
+  team: mesh
+patches:
+- path: replicas.yaml
+`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "replicas.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+spec:
+  replicas: 3
+`), 0o644))
+
+	p, err := NewKustomize(dir)
+	assert.NoError(t, err)
+	got, err := Apply(p, name.ManifestMap{name.PilotComponentName: {pilotManifest}})
+	assert.NoError(t, err)
+	objs, err := object.ParseK8sObjectsFromYAMLManifest(name.MergeManifestSlices(got[name.PilotComponentName]))
+	assert.NoError(t, err)
+	assert.Equal(t, len(objs), 2)
+	for _, o := range objs {
+		u := o.UnstructuredObject()
+		assert.Equal(t, u.GetLabels()["team"], "mesh")
+		assert.Equal(t, len(u.GetAnnotations()), 0)
+		if u.GetKind() == "Deployment" {
+			assert.Equal(t, u.Object["spec"].(map[string]any)["replicas"], any(int64(3)))
+		}
+	}
+	// The kustomization on disk is left untouched.
+	b, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Contains(string(b), renderedFile), false)
+
+	_, err = NewKustomize(t.TempDir())
+	assert.Error(t, err)
+}
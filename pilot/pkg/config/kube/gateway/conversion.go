@@ -46,15 +46,9 @@ import (
 // convertResources is the top level entrypoint to our conversion logic, computing the full state based
 // on KubernetesResources inputs.
 func convertResources(r GatewayResources) IstioResources {
-	// sort HTTPRoutes by creation timestamp and namespace/name
-	sort.Slice(r.HTTPRoute, func(i, j int) bool {
-		if r.HTTPRoute[i].CreationTimestamp.Equal(r.HTTPRoute[j].CreationTimestamp) {
-			in := r.HTTPRoute[i].Namespace + "/" + r.HTTPRoute[i].Name
-			jn := r.HTTPRoute[j].Namespace + "/" + r.HTTPRoute[j].Name
-			return in < jn
-		}
-		return r.HTTPRoute[i].CreationTimestamp.Before(r.HTTPRoute[j].CreationTimestamp)
-	})
+	// sort HTTPRoutes and GRPCRoutes by creation timestamp and namespace/name
+	sortRoutesByCreationTime(r.HTTPRoute)
+	sortRoutesByCreationTime(r.GRPCRoute)
 	result := IstioResources{}
 	ctx := configContext{
 		GatewayResources:   r,
@@ -83,6 +77,17 @@ func convertResources(r GatewayResources) IstioResources {
 	return result
 }
 
+func sortRoutesByCreationTime(routes []config.Config) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].CreationTimestamp.Equal(routes[j].CreationTimestamp) {
+			in := routes[i].Namespace + "/" + routes[i].Name
+			jn := routes[j].Namespace + "/" + routes[j].Name
+			return in < jn
+		}
+		return routes[i].CreationTimestamp.Before(routes[j].CreationTimestamp)
+	})
+}
+
 // convertReferencePolicies extracts all ReferencePolicy into an easily accessibly index.
 func convertReferencePolicies(r GatewayResources) AllowedReferences {
 	res := map[Reference]map[Reference]*Grants{}
@@ -106,6 +111,8 @@ func convertReferencePolicies(r GatewayResources) AllowedReferences {
 				fromKey.Kind = gvk.KubernetesGateway
 			} else if string(from.Group) == gvk.HTTPRoute.Group && string(from.Kind) == gvk.HTTPRoute.Kind {
 				fromKey.Kind = gvk.HTTPRoute
+			} else if string(from.Group) == gvk.GRPCRoute.Group && string(from.Kind) == gvk.GRPCRoute.Kind {
+				fromKey.Kind = gvk.GRPCRoute
 			} else if string(from.Group) == gvk.TLSRoute.Group && string(from.Kind) == gvk.TLSRoute.Kind {
 				fromKey.Kind = gvk.TLSRoute
 			} else if string(from.Group) == gvk.TCPRoute.Group && string(from.Kind) == gvk.TCPRoute.Kind {
@@ -163,6 +170,9 @@ func convertVirtualService(r configContext) []config.Config {
 	for _, obj := range r.HTTPRoute {
 		buildHTTPVirtualServices(r, obj, gatewayRoutes, meshRoutes)
 	}
+	for _, obj := range r.GRPCRoute {
+		buildGRPCVirtualServices(r, obj, gatewayRoutes, meshRoutes)
+	}
 	for _, vsByHost := range gatewayRoutes {
 		for _, vsConfig := range vsByHost {
 			result = append(result, *vsConfig)
@@ -232,7 +242,7 @@ func convertHTTPRoute(r k8s.HTTPRouteRule, ctx configContext,
 		case k8sbeta.HTTPRouteFilterRequestRedirect:
 			vs.Redirect = createRedirectFilter(filter.RequestRedirect)
 		case k8sbeta.HTTPRouteFilterRequestMirror:
-			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, obj.Namespace, enforceRefGrant)
+			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, obj.Namespace, enforceRefGrant, gvk.HTTPRoute)
 			if err != nil {
 				return nil, err
 			}
@@ -336,15 +346,31 @@ func buildHTTPVirtualServices(
 		}
 		return res
 	}))
+	mergeHTTPVirtualServices(ctx, obj, obj.Name, route.Hostnames, parentRefs, meshResult.routes, gwResult.routes, gatewayRoutes, meshRoutes)
+}
+
+// mergeHTTPVirtualServices adds the routes converted from an HTTPRoute or GRPCRoute to the VirtualService of each of
+// its parents, creating the VirtualService if it is the first route for the parent and host.
+func mergeHTTPVirtualServices(
+	ctx configContext,
+	obj config.Config,
+	namePrefix string,
+	hostnames []k8s.Hostname,
+	parentRefs []routeParentReference,
+	meshResult []*istio.HTTPRoute,
+	gwResult []*istio.HTTPRoute,
+	gatewayRoutes map[string]map[string]*config.Config,
+	meshRoutes map[string]map[string]*config.Config,
+) {
 	count := 0
 	for _, parent := range filteredReferences(parentRefs) {
 		// for gateway routes, build one VS per gateway+host
 		routeMap := gatewayRoutes
 		routeKey := parent.InternalName
-		vsHosts := hostnameToStringList(route.Hostnames)
-		routes := gwResult.routes
+		vsHosts := hostnameToStringList(hostnames)
+		routes := gwResult
 		if parent.IsMesh() {
-			routes = meshResult.routes
+			routes = meshResult
 			// for mesh routes, build one VS per namespace/port->host
 			routeMap = meshRoutes
 			routeKey = obj.Namespace
@@ -373,7 +399,7 @@ func buildHTTPVirtualServices(
 				cfg.Annotations[constants.InternalParentNames] = fmt.Sprintf("%s,%s/%s.%s",
 					cfg.Annotations[constants.InternalParentNames], obj.GroupVersionKind.Kind, obj.Name, obj.Namespace)
 			} else {
-				name := fmt.Sprintf("%s-%d-%s", obj.Name, count, constants.KubernetesGatewayName)
+				name := fmt.Sprintf("%s-%d-%s", namePrefix, count, constants.KubernetesGatewayName)
 				routeMap[routeKey][h] = &config.Config{
 					Meta: config.Meta{
 						CreationTimestamp: obj.CreationTimestamp,
@@ -407,6 +433,146 @@ func buildHTTPVirtualServices(
 	}
 }
 
+func buildGRPCVirtualServices(
+	ctx configContext,
+	obj config.Config,
+	gatewayRoutes map[string]map[string]*config.Config,
+	meshRoutes map[string]map[string]*config.Config,
+) {
+	route := obj.Spec.(*k8s.GRPCRouteSpec)
+	parentRefs := extractParentReferenceInfo(ctx.GatewayReferences, route.ParentRefs, route.Hostnames, gvk.GRPCRoute, obj.Namespace)
+	reportStatus := func(results []RouteParentResult) {
+		obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
+			rs := s.(*k8s.GRPCRouteStatus)
+			rs.Parents = createRouteStatus(results, obj, rs.Parents)
+			return rs
+		})
+	}
+
+	type conversionResult struct {
+		error  *ConfigError
+		routes []*istio.HTTPRoute
+	}
+	convertRules := func(mesh bool) conversionResult {
+		res := conversionResult{}
+		for n, r := range route.Rules {
+			// split the rule to make sure each rule has up to one match
+			matches := slices.Reference(r.Matches)
+			if len(matches) == 0 {
+				matches = append(matches, nil)
+			}
+			for _, m := range matches {
+				if m != nil {
+					r.Matches = []k8s.GRPCRouteMatch{*m}
+				}
+				vs, err := convertGRPCRoute(r, ctx, obj, n, !mesh)
+				// This was a hard error
+				if vs == nil {
+					return conversionResult{error: err}
+				}
+				// Got an error but also routes
+				if err != nil {
+					res.error = err
+				}
+
+				res.routes = append(res.routes, vs)
+			}
+		}
+		return res
+	}
+	meshResult, gwResult := buildMeshAndGatewayRoutes(parentRefs, convertRules)
+
+	reportStatus(slices.Map(parentRefs, func(r routeParentReference) RouteParentResult {
+		res := RouteParentResult{
+			OriginalReference: r.OriginalReference,
+			DeniedReason:      r.DeniedReason,
+			RouteError:        gwResult.error,
+		}
+		if r.IsMesh() {
+			res.RouteError = meshResult.error
+		}
+		return res
+	}))
+	mergeHTTPVirtualServices(ctx, obj, obj.Name+"-grpc", route.Hostnames, parentRefs, meshResult.routes, gwResult.routes, gatewayRoutes, meshRoutes)
+}
+
+func convertGRPCRoute(r k8s.GRPCRouteRule, ctx configContext,
+	obj config.Config, pos int, enforceRefGrant bool,
+) (*istio.HTTPRoute, *ConfigError) {
+	vs := &istio.HTTPRoute{}
+	// Auto-name the route. The position within the route is unique
+	vs.Name = fmt.Sprintf("%s.%s.%d", obj.Namespace, obj.Name, pos)
+
+	for _, match := range r.Matches {
+		uri, err := createGRPCURIMatch(match)
+		if err != nil {
+			return nil, err
+		}
+		headers, err := createGRPCHeadersMatch(match)
+		if err != nil {
+			return nil, err
+		}
+		vs.Match = append(vs.Match, &istio.HTTPMatchRequest{
+			Uri:     uri,
+			Headers: headers,
+		})
+	}
+	for _, filter := range r.Filters {
+		switch filter.Type {
+		case k8s.GRPCRouteFilterRequestHeaderModifier:
+			h := createHeadersFilter(filter.RequestHeaderModifier)
+			if h == nil {
+				continue
+			}
+			if vs.Headers == nil {
+				vs.Headers = &istio.Headers{}
+			}
+			vs.Headers.Request = h
+		case k8s.GRPCRouteFilterResponseHeaderModifier:
+			h := createHeadersFilter(filter.ResponseHeaderModifier)
+			if h == nil {
+				continue
+			}
+			if vs.Headers == nil {
+				vs.Headers = &istio.Headers{}
+			}
+			vs.Headers.Response = h
+		case k8s.GRPCRouteFilterRequestMirror:
+			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, obj.Namespace, enforceRefGrant, gvk.GRPCRoute)
+			if err != nil {
+				return nil, err
+			}
+			vs.Mirror = mirror
+		default:
+			return nil, &ConfigError{
+				Reason:  InvalidFilter,
+				Message: fmt.Sprintf("unsupported filter type %q", filter.Type),
+			}
+		}
+	}
+
+	if grpcWeightSum(r.BackendRefs) == 0 {
+		// The spec requires us to return UNAVAILABLE when there are no >0 weight backends. The fault is applied
+		// before routing, so the direct response only gives the route a valid action.
+		vs.DirectResponse = &istio.HTTPDirectResponse{
+			Status: 500,
+		}
+		vs.Fault = &istio.HTTPFaultInjection{
+			Abort: &istio.HTTPFaultInjection_Abort{
+				Percentage: &istio.Percent{Value: 100},
+				ErrorType:  &istio.HTTPFaultInjection_Abort_GrpcStatus{GrpcStatus: "UNAVAILABLE"},
+			},
+		}
+		return vs, nil
+	}
+	route, backendErr, err := buildGRPCDestination(ctx, r.BackendRefs, obj.Namespace, enforceRefGrant)
+	if err != nil {
+		return nil, err
+	}
+	vs.Route = route
+	return vs, backendErr
+}
+
 func buildMeshAndGatewayRoutes[T any](parentRefs []routeParentReference, convertRules func(mesh bool) T) (T, T) {
 	var meshResult, gwResult T
 	needMesh, needGw := parentTypes(parentRefs)
@@ -903,7 +1069,7 @@ func convertTCPRoute(ctx configContext, r k8s.TCPRouteRule, obj config.Config, e
 			}},
 		}, nil
 	}
-	dest, backendErr, err := buildTCPDestination(ctx, r.BackendRefs, obj.Namespace, enforceRefGrant)
+	dest, backendErr, err := buildTCPDestination(ctx, r.BackendRefs, obj.Namespace, enforceRefGrant, gvk.TCPRoute)
 	if err != nil {
 		return nil, err
 	}
@@ -927,7 +1093,7 @@ func convertTLSRoute(ctx configContext, r k8s.TLSRouteRule, obj config.Config, e
 			}},
 		}, nil
 	}
-	dest, backendErr, err := buildTCPDestination(ctx, r.BackendRefs, obj.Namespace, enforceRefGrant)
+	dest, backendErr, err := buildTCPDestination(ctx, r.BackendRefs, obj.Namespace, enforceRefGrant, gvk.TLSRoute)
 	if err != nil {
 		return nil, err
 	}
@@ -942,6 +1108,7 @@ func buildTCPDestination(
 	forwardTo []k8s.BackendRef,
 	ns string,
 	enforceRefGrant bool,
+	k config.GroupVersionKind,
 ) ([]*istio.RouteDestination, *ConfigError, *ConfigError) {
 	if forwardTo == nil {
 		return nil, nil, nil
@@ -964,7 +1131,7 @@ func buildTCPDestination(
 	var invalidBackendErr *ConfigError
 	res := []*istio.RouteDestination{}
 	for i, fwd := range action {
-		dst, err := buildDestination(ctx, fwd, ns, enforceRefGrant)
+		dst, err := buildDestination(ctx, fwd, ns, enforceRefGrant, k)
 		if err != nil {
 			if isInvalidBackend(err) {
 				invalidBackendErr = err
@@ -1041,7 +1208,7 @@ func buildHTTPDestination(
 	var invalidBackendErr *ConfigError
 	res := []*istio.HTTPRouteDestination{}
 	for i, fwd := range action {
-		dst, err := buildDestination(ctx, fwd.BackendRef, ns, enforceRefGrant)
+		dst, err := buildDestination(ctx, fwd.BackendRef, ns, enforceRefGrant, gvk.HTTPRoute)
 		if err != nil {
 			if isInvalidBackend(err) {
 				invalidBackendErr = err
@@ -1083,12 +1250,90 @@ func buildHTTPDestination(
 	return res, invalidBackendErr, nil
 }
 
-func buildDestination(ctx configContext, to k8s.BackendRef, ns string, enforceRefGrant bool) (*istio.Destination, *ConfigError) {
+func grpcWeightSum(forwardTo []k8s.GRPCBackendRef) int {
+	sum := int32(0)
+	for _, w := range forwardTo {
+		sum += ptr.OrDefault(w.Weight, 1)
+	}
+	return int(sum)
+}
+
+func buildGRPCDestination(
+	ctx configContext,
+	forwardTo []k8s.GRPCBackendRef,
+	ns string,
+	enforceRefGrant bool,
+) ([]*istio.HTTPRouteDestination, *ConfigError, *ConfigError) {
+	if forwardTo == nil {
+		return nil, nil, nil
+	}
+	weights := []int{}
+	action := []k8s.GRPCBackendRef{}
+	for _, w := range forwardTo {
+		wt := int(ptr.OrDefault(w.Weight, 1))
+		if wt == 0 {
+			continue
+		}
+		action = append(action, w)
+		weights = append(weights, wt)
+	}
+	if len(weights) == 1 {
+		weights = []int{0}
+	}
+
+	var invalidBackendErr *ConfigError
+	res := []*istio.HTTPRouteDestination{}
+	for i, fwd := range action {
+		dst, err := buildDestination(ctx, fwd.BackendRef, ns, enforceRefGrant, gvk.GRPCRoute)
+		if err != nil {
+			if isInvalidBackend(err) {
+				invalidBackendErr = err
+				// keep going, we will gracefully drop invalid backends
+			} else {
+				return nil, nil, err
+			}
+		}
+		rd := &istio.HTTPRouteDestination{
+			Destination: dst,
+			Weight:      int32(weights[i]),
+		}
+		for _, filter := range fwd.Filters {
+			switch filter.Type {
+			case k8s.GRPCRouteFilterRequestHeaderModifier:
+				h := createHeadersFilter(filter.RequestHeaderModifier)
+				if h == nil {
+					continue
+				}
+				if rd.Headers == nil {
+					rd.Headers = &istio.Headers{}
+				}
+				rd.Headers.Request = h
+			case k8s.GRPCRouteFilterResponseHeaderModifier:
+				h := createHeadersFilter(filter.ResponseHeaderModifier)
+				if h == nil {
+					continue
+				}
+				if rd.Headers == nil {
+					rd.Headers = &istio.Headers{}
+				}
+				rd.Headers.Response = h
+			default:
+				return nil, nil, &ConfigError{Reason: InvalidFilter, Message: fmt.Sprintf("unsupported filter type %q", filter.Type)}
+			}
+		}
+		res = append(res, rd)
+	}
+	return res, invalidBackendErr, nil
+}
+
+// buildDestination converts a backendRef of a route of kind k. Cross namespace references must be allowed by a
+// ReferenceGrant from that kind, unless enforceRefGrant is unset.
+func buildDestination(ctx configContext, to k8s.BackendRef, ns string, enforceRefGrant bool, k config.GroupVersionKind) (*istio.Destination, *ConfigError) {
 	// check if the reference is allowed
 	if enforceRefGrant {
 		refs := ctx.AllowedReferences
 		if toNs := to.Namespace; toNs != nil && string(*toNs) != ns {
-			if !refs.BackendAllowed(gvk.HTTPRoute, to.Name, *toNs, ns) {
+			if !refs.BackendAllowed(k, to.Name, *toNs, ns) {
 				return &istio.Destination{}, &ConfigError{
 					Reason:  InvalidDestinationPermit,
 					Message: fmt.Sprintf("backendRef %v/%v not accessible to a route in namespace %q (missing a ReferenceGrant?)", to.Name, *toNs, ns),
@@ -1188,7 +1433,9 @@ func headerListToMap(hl []k8s.HTTPHeader) map[string]string {
 	return res
 }
 
-func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter, ns string, enforceRefGrant bool) (*istio.Destination, *ConfigError) {
+func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter, ns string,
+	enforceRefGrant bool, k config.GroupVersionKind,
+) (*istio.Destination, *ConfigError) {
 	if filter == nil {
 		return nil, nil
 	}
@@ -1196,7 +1443,7 @@ func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter,
 	return buildDestination(ctx, k8s.BackendRef{
 		BackendObjectReference: filter.BackendRef,
 		Weight:                 &weightOne,
-	}, ns, enforceRefGrant)
+	}, ns, enforceRefGrant, k)
 }
 
 func createRewriteFilter(filter *k8s.HTTPURLRewriteFilter) *istio.HTTPRewrite {
@@ -1373,6 +1620,79 @@ func createURIMatch(match k8s.HTTPRouteMatch) (*istio.StringMatch, *ConfigError)
 	}
 }
 
+// createGRPCURIMatch matches the path of the request, which is /<service>/<method> for gRPC.
+func createGRPCURIMatch(match k8s.GRPCRouteMatch) (*istio.StringMatch, *ConfigError) {
+	m := match.Method
+	if m == nil {
+		return nil, nil
+	}
+	tp := ptr.OrDefault(m.Type, k8s.GRPCMethodMatchExact)
+	if m.Method == nil && m.Service == nil {
+		return nil, &ConfigError{Reason: InvalidConfiguration, Message: "gRPC match must have method or service defined"}
+	}
+	switch tp {
+	case k8s.GRPCMethodMatchExact:
+		if m.Method == nil {
+			return &istio.StringMatch{
+				MatchType: &istio.StringMatch_Prefix{Prefix: fmt.Sprintf("/%s/", *m.Service)},
+			}, nil
+		}
+		if m.Service == nil {
+			return &istio.StringMatch{
+				MatchType: &istio.StringMatch_Regex{Regex: fmt.Sprintf("/[^/]+/%s", *m.Method)},
+			}, nil
+		}
+		return &istio.StringMatch{
+			MatchType: &istio.StringMatch_Exact{Exact: fmt.Sprintf("/%s/%s", *m.Service, *m.Method)},
+		}, nil
+	case k8s.GRPCMethodMatchRegularExpression:
+		if m.Method == nil {
+			return &istio.StringMatch{
+				MatchType: &istio.StringMatch_Regex{Regex: fmt.Sprintf("/%s/.+", *m.Service)},
+			}, nil
+		}
+		if m.Service == nil {
+			return &istio.StringMatch{
+				MatchType: &istio.StringMatch_Regex{Regex: fmt.Sprintf("/[^/]+/%s", *m.Method)},
+			}, nil
+		}
+		return &istio.StringMatch{
+			MatchType: &istio.StringMatch_Regex{Regex: fmt.Sprintf("/%s/%s", *m.Service, *m.Method)},
+		}, nil
+	default:
+		// Should never happen, unless a new field is added
+		return nil, &ConfigError{Reason: InvalidConfiguration, Message: fmt.Sprintf("unknown type: %q is not supported Method match type", tp)}
+	}
+}
+
+func createGRPCHeadersMatch(match k8s.GRPCRouteMatch) (map[string]*istio.StringMatch, *ConfigError) {
+	res := map[string]*istio.StringMatch{}
+	for _, header := range match.Headers {
+		tp := k8sbeta.HeaderMatchExact
+		if header.Type != nil {
+			tp = *header.Type
+		}
+		switch tp {
+		case k8sbeta.HeaderMatchExact:
+			res[string(header.Name)] = &istio.StringMatch{
+				MatchType: &istio.StringMatch_Exact{Exact: header.Value},
+			}
+		case k8sbeta.HeaderMatchRegularExpression:
+			res[string(header.Name)] = &istio.StringMatch{
+				MatchType: &istio.StringMatch_Regex{Regex: header.Value},
+			}
+		default:
+			// Should never happen, unless a new field is added
+			return nil, &ConfigError{Reason: InvalidConfiguration, Message: fmt.Sprintf("unknown type: %q is not supported HeaderMatch type", tp)}
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
 // getGatewayClass finds all gateway class that are owned by Istio
 // Response is ClassName -> Controller type
 func getGatewayClasses(r GatewayResources) map[string]k8s.GatewayController {
@@ -1603,6 +1923,7 @@ func convertGateways(r configContext) ([]config.Config, map[parentKey][]*parentI
 			// Mesh has no configurable AllowedKinds, so allow all supported
 			AllowedKinds: []k8s.RouteGroupKind{
 				{Group: (*k8s.Group)(ptr.Of(gvk.HTTPRoute.Group)), Kind: k8s.Kind(gvk.HTTPRoute.Kind)},
+				{Group: (*k8s.Group)(ptr.Of(gvk.GRPCRoute.Group)), Kind: k8s.Kind(gvk.GRPCRoute.Kind)},
 				{Group: (*k8s.Group)(ptr.Of(gvk.TCPRoute.Group)), Kind: k8s.Kind(gvk.TCPRoute.Kind)},
 				{Group: (*k8s.Group)(ptr.Of(gvk.TLSRoute.Group)), Kind: k8s.Kind(gvk.TLSRoute.Kind)},
 			},
@@ -2076,6 +2397,11 @@ func (kr GatewayResources) FuzzValidate() bool {
 			return false
 		}
 	}
+	for _, gr := range kr.GRPCRoute {
+		if gr.Spec == nil {
+			return false
+		}
+	}
 	for _, tr := range kr.TLSRoute {
 		if tr.Spec == nil {
 			return false
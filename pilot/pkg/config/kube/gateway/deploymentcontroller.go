@@ -22,7 +22,9 @@ import (
 	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	klabels "k8s.io/apimachinery/pkg/labels"
@@ -52,7 +54,8 @@ import (
 )
 
 // DeploymentController implements a controller that materializes a Gateway into an in cluster gateway proxy
-// to serve requests from. This is implemented with a Deployment and Service today.
+// to serve requests from. This is implemented with a Deployment and Service today, along with a
+// HorizontalPodAutoscaler and PodDisruptionBudget if the Gateway parameters ask for them.
 // The implementation makes a few non-obvious choices - namely using Server Side Apply from go templates
 // and not using controller-runtime.
 //
@@ -89,6 +92,9 @@ type DeploymentController struct {
 	services        kclient.Client[*corev1.Service]
 	serviceAccounts kclient.Client[*corev1.ServiceAccount]
 	namespaces      kclient.Client[*corev1.Namespace]
+	configMaps      kclient.Client[*corev1.ConfigMap]
+	hpas            kclient.Client[*autoscalingv2.HorizontalPodAutoscaler]
+	pdbs            kclient.Client[*policyv1.PodDisruptionBudget]
 	tagWatcher      revisions.TagWatcher
 	revision        string
 }
@@ -185,6 +191,24 @@ func NewDeploymentController(client kube.Client, clusterID cluster.ID, env *mode
 	dc.serviceAccounts.AddEventHandler(parentHandler)
 	dc.clients[gvr.ServiceAccount] = NewUntypedWrapper(dc.serviceAccounts)
 
+	dc.hpas = kclient.New[*autoscalingv2.HorizontalPodAutoscaler](client)
+	dc.hpas.AddEventHandler(parentHandler)
+	dc.clients[gvr.HorizontalPodAutoscaler] = NewUntypedWrapper(dc.hpas)
+
+	dc.pdbs = kclient.New[*policyv1.PodDisruptionBudget](client)
+	dc.pdbs.AddEventHandler(parentHandler)
+	dc.clients[gvr.PodDisruptionBudget] = NewUntypedWrapper(dc.pdbs)
+
+	// Parameters are not owned by the Gateway, so Gateways referencing a changed ConfigMap are requeued.
+	dc.configMaps = newParametersClient(client)
+	dc.configMaps.AddEventHandler(controllers.ObjectHandler(func(o controllers.Object) {
+		for _, gw := range dc.gateways.List(o.GetNamespace(), klabels.Everything()) {
+			if gw.Annotations[gatewayParametersRef] == o.GetName() {
+				dc.queue.AddObject(gw)
+			}
+		}
+	}))
+
 	dc.namespaces = kclient.New[*corev1.Namespace](client)
 	dc.namespaces.AddEventHandler(controllers.ObjectHandler(func(o controllers.Object) {
 		// TODO: make this more intelligent, checking if something we care about has changed
@@ -223,12 +247,16 @@ func (d *DeploymentController) Run(stop <-chan struct{}) {
 		d.deployments.HasSynced,
 		d.services.HasSynced,
 		d.serviceAccounts.HasSynced,
+		d.hpas.HasSynced,
+		d.pdbs.HasSynced,
+		d.configMaps.HasSynced,
 		d.gateways.HasSynced,
 		d.gatewayClasses.HasSynced,
 		d.tagWatcher.HasSynced,
 	)
 	d.queue.Run(stop)
-	controllers.ShutdownAll(d.namespaces, d.deployments, d.services, d.serviceAccounts, d.gateways, d.gatewayClasses)
+	controllers.ShutdownAll(d.namespaces, d.deployments, d.services, d.serviceAccounts, d.hpas, d.pdbs, d.configMaps,
+		d.gateways, d.gatewayClasses)
 }
 
 // Reconcile takes in the name of a Gateway and ensures the cluster is in the desired state
@@ -293,6 +321,16 @@ func (d *DeploymentController) configureIstioGateway(log *istiolog.Scope, gw gat
 	}
 	log.Info("reconciling")
 
+	params, err := d.parameters(gw)
+	if err != nil {
+		// Retrying does not help, the Gateway is reconciled again once the parameters ConfigMap changes.
+		log.Warnf("not deploying: %v", err)
+		if err := d.reportStatus(gw, []metav1.Condition{invalidParametersCondition(gw, err)}); err != nil {
+			return fmt.Errorf("update gateway status: %v", err)
+		}
+		return nil
+	}
+
 	defaultName := getDefaultName(gw.Name, &gw.Spec)
 	input := TemplateInput{
 		Gateway:        &gw,
@@ -302,6 +340,7 @@ func (d *DeploymentController) configureIstioGateway(log *istiolog.Scope, gw gat
 		ClusterID:      d.clusterID.String(),
 		KubeVersion122: kube.IsAtLeastVersion(d.client, 22),
 		Revision:       d.revision,
+		Parameters:     params,
 	}
 
 	if overwriteControllerVersion {
@@ -322,6 +361,17 @@ func (d *DeploymentController) configureIstioGateway(log *istiolog.Scope, gw gat
 			return fmt.Errorf("apply failed: %v", err)
 		}
 	}
+	if err := d.pruneParameterResources(input); err != nil {
+		return err
+	}
+
+	// The status of a Deployment we just created is reported once the informer sees it, which requeues the Gateway.
+	if deployment := d.deployments.Get(input.DeploymentName, gw.Namespace); deployment != nil {
+		hpa := d.hpas.Get(input.DeploymentName, gw.Namespace)
+		if err := d.reportStatus(gw, deploymentConditions(gw, params, deployment, hpa)); err != nil {
+			return fmt.Errorf("update gateway status: %v", err)
+		}
+	}
 
 	log.Info("gateway updated")
 	return nil
@@ -495,6 +545,7 @@ type TemplateInput struct {
 	ClusterID      string
 	KubeVersion122 bool
 	Revision       string
+	Parameters     GatewayParameters
 }
 
 func extractServicePorts(gw gateway.Gateway) []corev1.ServicePort {
@@ -16,14 +16,18 @@ package gateway
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"go.uber.org/atomic"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -62,6 +66,25 @@ func TestConfigureIstioGateway(t *testing.T) {
 	defaultIstio := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default-istio", Namespace: "default"}}
 	customSA := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "custom-sa", Namespace: "default"}}
 	defaultObjects := []runtime.Object{defaultIstio, customSA}
+	params := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "waypoint-params",
+			Namespace: "default",
+			Labels:    map[string]string{gatewayParametersLabel: "true"},
+		},
+		Data: map[string]string{
+			"autoscaling":         "minReplicas: 2\nmaxReplicas: 5",
+			"podDisruptionBudget": "minAvailable: 1",
+			"resources":           "requests:\n  cpu: 200m\n  memory: 256Mi",
+			"rollout":             "maxSurge: 1\nmaxUnavailable: 0\nminReadySeconds: 10\nprogressDeadlineSeconds: 300",
+			"topologySpreadConstraints": `- maxSkew: 1
+  topologyKey: topology.kubernetes.io/zone
+  whenUnsatisfiable: ScheduleAnyway
+  labelSelector:
+    matchLabels:
+      istio.io/gateway-name: namespace`,
+		},
+	}
 	store := model.NewFakeStore()
 	if _, err := store.Create(config.Config{
 		Meta: config.Meta{
@@ -194,6 +217,25 @@ func TestConfigureIstioGateway(t *testing.T) {
 			},
 			objects: defaultObjects,
 		},
+		{
+			name: "waypoint-parameters",
+			gw: v1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "namespace",
+					Namespace:   "default",
+					Annotations: map[string]string{gatewayParametersRef: "waypoint-params"},
+				},
+				Spec: v1beta1.GatewaySpec{
+					GatewayClassName: constants.WaypointGatewayClassName,
+					Listeners: []v1beta1.Listener{{
+						Name:     "mesh",
+						Port:     v1beta1.PortNumber(15008),
+						Protocol: "ALL",
+					}},
+				},
+			},
+			objects: append([]runtime.Object{params}, defaultObjects...),
+		},
 		{
 			name: "proxy-config-crd",
 			gw: v1beta1.Gateway{
@@ -214,15 +256,21 @@ func TestConfigureIstioGateway(t *testing.T) {
 			buf := &bytes.Buffer{}
 			client := kube.NewFakeClient(tt.objects...)
 			deployments := kclient.New[*appsv1.Deployment](client)
+			configMaps := newParametersClient(client)
+			hpas := kclient.New[*autoscalingv2.HorizontalPodAutoscaler](client)
+			pdbs := kclient.New[*policyv1.PodDisruptionBudget](client)
 			stop := test.NewStop(t)
 			client.RunAndWait(stop)
-			kube.WaitForCacheSync("test", stop, deployments.HasSynced)
+			kube.WaitForCacheSync("test", stop, deployments.HasSynced, configMaps.HasSynced, hpas.HasSynced, pdbs.HasSynced)
 			env := model.NewEnvironment()
 			env.PushContext().ProxyConfigs = tt.pcs
 			d := &DeploymentController{
 				client:       client,
 				env:          env,
 				deployments:  deployments,
+				configMaps:   configMaps,
+				hpas:         hpas,
+				pdbs:         pdbs,
 				clusterID:    cluster.ID(features.ClusterName),
 				injectConfig: testInjectionConfig(t),
 				patcher: func(gvr schema.GroupVersionResource, name string, namespace string, data []byte, subresources ...string) error {
@@ -246,6 +294,72 @@ func TestConfigureIstioGateway(t *testing.T) {
 	}
 }
 
+func TestConfigureIstioGatewayInvalidParameters(t *testing.T) {
+	test.SetForTest(t, &features.EnableAmbientControllers, true)
+	classInfos = getClassInfos()
+	invalid := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "invalid-params",
+			Namespace: "default",
+			Labels:    map[string]string{gatewayParametersLabel: "true"},
+		},
+		Data: map[string]string{"autoscaling": "minReplicas: 5\nmaxReplicas: 2"},
+	}
+	tests := []struct {
+		name    string
+		ref     string
+		message string
+	}{
+		{name: "missing", ref: "missing-params", message: "parameters ConfigMap default/missing-params"},
+		{name: "invalid", ref: "invalid-params", message: "autoscaling: invalid replicas bounds [5, 2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := kube.NewFakeClient(invalid)
+			configMaps := newParametersClient(client)
+			stop := test.NewStop(t)
+			client.RunAndWait(stop)
+			kube.WaitForCacheSync("test", stop, configMaps.HasSynced)
+			var writes []schema.GroupVersionResource
+			var status []byte
+			d := &DeploymentController{
+				client:     client,
+				configMaps: configMaps,
+				patcher: func(g schema.GroupVersionResource, name string, namespace string, data []byte, subresources ...string) error {
+					writes = append(writes, g)
+					status = data
+					return nil
+				},
+			}
+			gw := v1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "waypoint",
+					Namespace:   "default",
+					Generation:  2,
+					Annotations: map[string]string{gatewayParametersRef: tt.ref},
+				},
+				Spec: v1beta1.GatewaySpec{GatewayClassName: constants.WaypointGatewayClassName},
+			}
+			// The error is reported on the Gateway rather than retried.
+			assert.NoError(t, d.configureIstioGateway(istiolog.FindScope(istiolog.DefaultScopeName), gw))
+			assert.Equal(t, writes, []schema.GroupVersionResource{gvr.KubernetesGateway})
+			var patch struct {
+				Status v1beta1.GatewayStatus `json:"status"`
+			}
+			assert.NoError(t, json.Unmarshal(status, &patch))
+			assert.Equal(t, len(patch.Status.Conditions), 1)
+			c := patch.Status.Conditions[0]
+			assert.Equal(t, c.Type, GatewayDeploymentReady)
+			assert.Equal(t, c.Status, metav1.ConditionFalse)
+			assert.Equal(t, c.Reason, "InvalidParameters")
+			assert.Equal(t, c.ObservedGeneration, int64(2))
+			if !strings.Contains(c.Message, tt.message) {
+				t.Fatalf("message %q does not contain %q", c.Message, tt.message)
+			}
+		})
+	}
+}
+
 func TestVersionManagement(t *testing.T) {
 	log.SetOutputLevel(istiolog.DebugLevel)
 	writes := make(chan string, 10)
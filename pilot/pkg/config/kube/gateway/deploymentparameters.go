@@ -0,0 +1,332 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pilot/pkg/model/kstatus"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// gatewayParametersRef is an annotation on a Gateway naming a ConfigMap, in the namespace of the Gateway,
+	// holding the parameters of the deployment. gateway-api does not yet have a field referencing
+	// infrastructure parameters, so an annotation is used in the meantime.
+	gatewayParametersRef = "gateway.istio.io/parameters-ref"
+	// gatewayParametersLabel must be set on the ConfigMaps referenced by gatewayParametersRef. Only the ConfigMaps
+	// carrying it are watched, rather than every ConfigMap in the cluster.
+	gatewayParametersLabel = "gateway.istio.io/parameters"
+
+	// GatewayDeploymentReady is a condition reported on managed Gateways, set when their Deployment has ready replicas.
+	GatewayDeploymentReady = "gateway.istio.io/DeploymentReady"
+	// GatewayAutoscaling is a condition reported on managed Gateways with autoscaling parameters, set when the
+	// HorizontalPodAutoscaler is able to scale the Deployment.
+	GatewayAutoscaling = "gateway.istio.io/Autoscaling"
+)
+
+// deploymentConditionTypes are the condition types owned by the deployment controller.
+var deploymentConditionTypes = sets.New(GatewayDeploymentReady, GatewayAutoscaling)
+
+// GatewayParameters customizes the resources deployed for a Gateway. Each field is read from the key of the same
+// name in the parameters ConfigMap, holding a YAML document.
+type GatewayParameters struct {
+	// Autoscaling, if set, deploys a HorizontalPodAutoscaler for the Deployment.
+	Autoscaling *AutoscalingParameters `json:"autoscaling,omitempty"`
+	// PodDisruptionBudget, if set, deploys a PodDisruptionBudget for the pods of the Deployment.
+	PodDisruptionBudget *PodDisruptionBudgetParameters `json:"podDisruptionBudget,omitempty"`
+	// Resources, if set, replaces the resources of the proxy container.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// TopologySpreadConstraints are added to the pods of the Deployment.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// Rollout, if set, controls how the Deployment replaces its pods when it is updated.
+	Rollout *RolloutParameters `json:"rollout,omitempty"`
+}
+
+type AutoscalingParameters struct {
+	// MinReplicas defaults to 1.
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage defaults to 80.
+	TargetCPUUtilizationPercentage int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+}
+
+// RolloutParameters are the rollout settings of the Deployment.
+type RolloutParameters struct {
+	// MaxSurge and MaxUnavailable bound the pods created and unavailable during a rolling update.
+	MaxSurge       *intstr.IntOrString `json:"maxSurge,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// MinReadySeconds is how long a new pod must be ready before it counts as available.
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+	// ProgressDeadlineSeconds is how long a rollout may make no progress before it is reported stalled on the Gateway.
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+// RollingUpdate returns the rolling update strategy of the Deployment, or nil to keep the default one.
+func (r *RolloutParameters) RollingUpdate() *appsv1.RollingUpdateDeployment {
+	if r.MaxSurge == nil && r.MaxUnavailable == nil {
+		return nil
+	}
+	return &appsv1.RollingUpdateDeployment{MaxSurge: r.MaxSurge, MaxUnavailable: r.MaxUnavailable}
+}
+
+// PodDisruptionBudgetParameters sets exactly one of MinAvailable and MaxUnavailable.
+type PodDisruptionBudgetParameters struct {
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ParseGatewayParameters reads the parameters held by a ConfigMap.
+func ParseGatewayParameters(data map[string]string) (GatewayParameters, error) {
+	// Every key is a YAML document, so the ConfigMap is read as a single document with one field per key.
+	fields := map[string]json.RawMessage{}
+	for k, v := range data {
+		j, err := yaml.YAMLToJSON([]byte(v))
+		if err != nil {
+			return GatewayParameters{}, fmt.Errorf("%s: %v", k, err)
+		}
+		fields[k] = j
+	}
+	doc, err := json.Marshal(fields)
+	if err != nil {
+		return GatewayParameters{}, err
+	}
+	var p GatewayParameters
+	if err := yaml.UnmarshalStrict(doc, &p); err != nil {
+		return GatewayParameters{}, err
+	}
+	if a := p.Autoscaling; a != nil {
+		if a.MinReplicas == 0 {
+			a.MinReplicas = 1
+		}
+		if a.TargetCPUUtilizationPercentage == 0 {
+			a.TargetCPUUtilizationPercentage = 80
+		}
+		if a.MinReplicas < 0 || a.MaxReplicas < a.MinReplicas {
+			return GatewayParameters{}, fmt.Errorf("autoscaling: invalid replicas bounds [%d, %d]", a.MinReplicas, a.MaxReplicas)
+		}
+		if a.TargetCPUUtilizationPercentage < 0 {
+			return GatewayParameters{}, fmt.Errorf("autoscaling: invalid target CPU utilization %d", a.TargetCPUUtilizationPercentage)
+		}
+	}
+	if pdb := p.PodDisruptionBudget; pdb != nil && (pdb.MinAvailable == nil) == (pdb.MaxUnavailable == nil) {
+		return GatewayParameters{}, fmt.Errorf("podDisruptionBudget: exactly one of minAvailable and maxUnavailable must be set")
+	}
+	if r := p.Rollout; r != nil {
+		if r.MinReadySeconds < 0 || r.ProgressDeadlineSeconds < 0 {
+			return GatewayParameters{}, fmt.Errorf("rollout: durations must not be negative")
+		}
+		if r.ProgressDeadlineSeconds != 0 && r.ProgressDeadlineSeconds <= r.MinReadySeconds {
+			return GatewayParameters{}, fmt.Errorf("rollout: progressDeadlineSeconds must be greater than minReadySeconds")
+		}
+		if isZero(r.MaxSurge) && isZero(r.MaxUnavailable) {
+			return GatewayParameters{}, fmt.Errorf("rollout: maxSurge and maxUnavailable must not both be zero")
+		}
+	}
+	return p, nil
+}
+
+// isZero returns true if a rolling update bound is set to zero. Unset bounds default to 25%.
+func isZero(v *intstr.IntOrString) bool {
+	return v != nil && (v.String() == "0" || v.String() == "0%")
+}
+
+// newParametersClient returns a client of the ConfigMaps holding Gateway parameters, the ones with the
+// gatewayParametersLabel.
+func newParametersClient(client kube.Client) kclient.Client[*corev1.ConfigMap] {
+	return kclient.NewFiltered[*corev1.ConfigMap](client, kclient.Filter{LabelSelector: gatewayParametersLabel})
+}
+
+// parameters returns the parameters referenced by a Gateway.
+func (d *DeploymentController) parameters(gw gateway.Gateway) (GatewayParameters, error) {
+	name, f := gw.Annotations[gatewayParametersRef]
+	if !f {
+		return GatewayParameters{}, nil
+	}
+	cm := d.configMaps.Get(name, gw.Namespace)
+	if cm == nil {
+		return GatewayParameters{}, fmt.Errorf("parameters ConfigMap %s/%s with label %s not found", gw.Namespace, name, gatewayParametersLabel)
+	}
+	p, err := ParseGatewayParameters(cm.Data)
+	if err != nil {
+		return GatewayParameters{}, fmt.Errorf("invalid parameters ConfigMap %s/%s: %v", gw.Namespace, name, err)
+	}
+	return p, nil
+}
+
+// pruneParameterResources deletes the resources deployed for parameters that are no longer set.
+func (d *DeploymentController) pruneParameterResources(input TemplateInput) error {
+	if input.Parameters.Autoscaling == nil {
+		if hpa := d.hpas.Get(input.DeploymentName, input.Namespace); hpa != nil && isManagedByUs(hpa.Labels) {
+			if err := d.hpas.Delete(hpa.Name, hpa.Namespace); err != nil {
+				return fmt.Errorf("delete %v/%v/%v: %v", gvr.HorizontalPodAutoscaler, hpa.Namespace, hpa.Name, err)
+			}
+		}
+	}
+	if input.Parameters.PodDisruptionBudget == nil {
+		if pdb := d.pdbs.Get(input.DeploymentName, input.Namespace); pdb != nil && isManagedByUs(pdb.Labels) {
+			if err := d.pdbs.Delete(pdb.Name, pdb.Namespace); err != nil {
+				return fmt.Errorf("delete %v/%v/%v: %v", gvr.PodDisruptionBudget, pdb.Namespace, pdb.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deploymentConditions returns the conditions describing the state of the Deployment of a Gateway, and of its
+// HorizontalPodAutoscaler if autoscaling is enabled.
+func deploymentConditions(gw gateway.Gateway, params GatewayParameters, deployment *appsv1.Deployment,
+	hpa *autoscalingv2.HorizontalPodAutoscaler,
+) []metav1.Condition {
+	ready := metav1.Condition{
+		Type:               GatewayDeploymentReady,
+		Status:             kstatus.StatusTrue,
+		ObservedGeneration: gw.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Ready",
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	ready.Message = fmt.Sprintf("%d/%d replicas ready", deployment.Status.ReadyReplicas, desired)
+	var stalled *appsv1.DeploymentCondition
+	for i, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse {
+			stalled = &deployment.Status.Conditions[i]
+		}
+	}
+	switch {
+	case stalled != nil:
+		ready.Status = kstatus.StatusFalse
+		ready.Reason = stalled.Reason
+		ready.Message = stalled.Message
+	case deployment.Status.ReadyReplicas == 0:
+		ready.Status = kstatus.StatusFalse
+		ready.Reason = "NoReadyReplicas"
+	case deployment.Status.UpdatedReplicas < desired || deployment.Status.ObservedGeneration < deployment.Generation:
+		ready.Reason = "RolloutInProgress"
+	}
+	conditions := []metav1.Condition{ready}
+
+	a := params.Autoscaling
+	if a == nil {
+		return conditions
+	}
+	scaling := metav1.Condition{
+		Type:               GatewayAutoscaling,
+		Status:             kstatus.StatusTrue,
+		ObservedGeneration: gw.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Active",
+	}
+	if hpa == nil {
+		scaling.Status = metav1.ConditionUnknown
+		scaling.Reason = "Pending"
+		scaling.Message = "waiting for the HorizontalPodAutoscaler to be created"
+		return append(conditions, scaling)
+	}
+	scaling.Message = fmt.Sprintf("%d replicas, scaling between %d and %d", hpa.Status.CurrentReplicas, a.MinReplicas, a.MaxReplicas)
+	for _, c := range hpa.Status.Conditions {
+		switch {
+		case (c.Type == autoscalingv2.AbleToScale || c.Type == autoscalingv2.ScalingActive) && c.Status == corev1.ConditionFalse:
+			scaling.Status = kstatus.StatusFalse
+			scaling.Reason = c.Reason
+			scaling.Message = c.Message
+			return append(conditions, scaling)
+		case c.Type == autoscalingv2.ScalingLimited && c.Status == corev1.ConditionTrue:
+			scaling.Reason = c.Reason
+			scaling.Message = fmt.Sprintf("%s: %s", scaling.Message, c.Message)
+		}
+	}
+	return append(conditions, scaling)
+}
+
+// invalidParametersCondition reports that the resources of a Gateway are not deployed, as its parameters ConfigMap is
+// missing or invalid.
+func invalidParametersCondition(gw gateway.Gateway, err error) metav1.Condition {
+	return metav1.Condition{
+		Type:               GatewayDeploymentReady,
+		Status:             kstatus.StatusFalse,
+		ObservedGeneration: gw.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "InvalidParameters",
+		Message:            err.Error(),
+	}
+}
+
+// reportStatus applies the conditions owned by the deployment controller to the status of a Gateway. Conditions
+// owned by the Gateway controller are left untouched, as each condition is owned by the field manager applying it.
+// Nothing is written if the conditions are unchanged.
+func (d *DeploymentController) reportStatus(gw gateway.Gateway, conditions []metav1.Condition) error {
+	changed := false
+	for i, c := range conditions {
+		cur := kstatus.GetCondition(gw.Status.Conditions, c.Type)
+		if cur.Type != c.Type {
+			changed = true
+			continue
+		}
+		if cur.Status == c.Status {
+			// Retain LastTransitionTime if the status is not changed
+			conditions[i].LastTransitionTime = cur.LastTransitionTime
+		}
+		if cur.Status != c.Status || cur.Reason != c.Reason || cur.Message != c.Message || cur.ObservedGeneration != c.ObservedGeneration {
+			changed = true
+		}
+	}
+	kept := sets.New[string]()
+	for _, c := range conditions {
+		kept.Insert(c.Type)
+	}
+	for _, c := range gw.Status.Conditions {
+		if deploymentConditionTypes.Contains(c.Type) && !kept.Contains(c.Type) {
+			// A stale condition, removed as it is left out of the applied configuration.
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]any{
+		"apiVersion": gvr.KubernetesGateway.GroupVersion().String(),
+		"kind":       "Gateway",
+		"metadata":   map[string]any{"name": gw.Name, "namespace": gw.Namespace},
+		"status":     map[string]any{"conditions": conditions},
+	})
+	if err != nil {
+		return err
+	}
+	log.Debugf("applying status %v", string(patch))
+	return d.patcher(gvr.KubernetesGateway, gw.Name, gw.Namespace, patch, "status")
+}
+
+// isManagedByUs checks if a resource carries the ManagedGatewayLabel, like canManage.
+func isManagedByUs(labels map[string]string) bool {
+	_, managed := labels[constants.ManagedGatewayLabel]
+	return managed
+}
@@ -0,0 +1,374 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseGatewayParameters(t *testing.T) {
+	cpu := resource.MustParse("200m")
+	minAvailable := intstr.FromString("50%")
+	maxSurge := intstr.FromInt(1)
+	maxUnavailable := intstr.FromInt(0)
+	cases := []struct {
+		name    string
+		data    map[string]string
+		want    GatewayParameters
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			want: GatewayParameters{},
+		},
+		{
+			name: "defaults",
+			data: map[string]string{"autoscaling": "maxReplicas: 3"},
+			want: GatewayParameters{Autoscaling: &AutoscalingParameters{MinReplicas: 1, MaxReplicas: 3, TargetCPUUtilizationPercentage: 80}},
+		},
+		{
+			name: "all",
+			data: map[string]string{
+				"autoscaling":               "{minReplicas: 2, maxReplicas: 4, targetCPUUtilizationPercentage: 60}",
+				"podDisruptionBudget":       "minAvailable: 50%",
+				"resources":                 "requests:\n  cpu: 200m",
+				"topologySpreadConstraints": "- {maxSkew: 1, topologyKey: kubernetes.io/hostname, whenUnsatisfiable: DoNotSchedule}",
+				"rollout":                   "{maxSurge: 1, maxUnavailable: 0, minReadySeconds: 10}",
+			},
+			want: GatewayParameters{
+				Autoscaling:         &AutoscalingParameters{MinReplicas: 2, MaxReplicas: 4, TargetCPUUtilizationPercentage: 60},
+				PodDisruptionBudget: &PodDisruptionBudgetParameters{MinAvailable: &minAvailable},
+				Resources:           &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: cpu}},
+				TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+					MaxSkew:           1,
+					TopologyKey:       "kubernetes.io/hostname",
+					WhenUnsatisfiable: corev1.DoNotSchedule,
+				}},
+				Rollout: &RolloutParameters{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable, MinReadySeconds: 10},
+			},
+		},
+		{
+			name:    "unknown key",
+			data:    map[string]string{"replicas": "3"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    map[string]string{"autoscaling": "{maxReplicas: 3, minReplica: 2}"},
+			wantErr: true,
+		},
+		{
+			name:    "inverted bounds",
+			data:    map[string]string{"autoscaling": "{minReplicas: 3, maxReplicas: 2}"},
+			wantErr: true,
+		},
+		{
+			name:    "missing max",
+			data:    map[string]string{"autoscaling": "{minReplicas: 3}"},
+			wantErr: true,
+		},
+		{
+			name:    "both budgets",
+			data:    map[string]string{"podDisruptionBudget": "{minAvailable: 1, maxUnavailable: 1}"},
+			wantErr: true,
+		},
+		{
+			name:    "no budget",
+			data:    map[string]string{"podDisruptionBudget": "{}"},
+			wantErr: true,
+		},
+		{
+			name:    "blocked rollout",
+			data:    map[string]string{"rollout": "{maxSurge: 0, maxUnavailable: 0%}"},
+			wantErr: true,
+		},
+		{
+			name:    "rollout deadline before ready",
+			data:    map[string]string{"rollout": "{minReadySeconds: 60, progressDeadlineSeconds: 30}"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			data:    map[string]string{"resources": "requests: [cpu"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGatewayParameters(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Quantities keep a cached string, so compare the serialized parameters.
+			assert.Equal(t, toJSON(t, got), toJSON(t, tt.want))
+		})
+	}
+}
+
+func toJSON(t test.Failer, v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDeploymentConditions(t *testing.T) {
+	gw := v1beta1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default", Generation: 3}}
+	autoscaling := GatewayParameters{Autoscaling: &AutoscalingParameters{MinReplicas: 2, MaxReplicas: 5}}
+	deployment := func(ready, updated int32, conditions ...appsv1.DeploymentCondition) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Generation: 1},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.Of(int32(2))},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: 1,
+				ReadyReplicas:      ready,
+				UpdatedReplicas:    updated,
+				Conditions:         conditions,
+			},
+		}
+	}
+	hpa := func(current int32, conditions ...autoscalingv2.HorizontalPodAutoscalerCondition) *autoscalingv2.HorizontalPodAutoscaler {
+		return &autoscalingv2.HorizontalPodAutoscaler{Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: current,
+			Conditions:      conditions,
+		}}
+	}
+	type cond struct {
+		Type, Status, Reason, Message string
+	}
+	cases := []struct {
+		name       string
+		params     GatewayParameters
+		deployment *appsv1.Deployment
+		hpa        *autoscalingv2.HorizontalPodAutoscaler
+		want       []cond
+	}{
+		{
+			name:       "ready",
+			deployment: deployment(2, 2),
+			want:       []cond{{GatewayDeploymentReady, "True", "Ready", "2/2 replicas ready"}},
+		},
+		{
+			name:       "rolling out",
+			deployment: deployment(2, 1),
+			want:       []cond{{GatewayDeploymentReady, "True", "RolloutInProgress", "2/2 replicas ready"}},
+		},
+		{
+			name:       "not ready",
+			deployment: deployment(0, 2),
+			want:       []cond{{GatewayDeploymentReady, "False", "NoReadyReplicas", "0/2 replicas ready"}},
+		},
+		{
+			name: "stalled",
+			deployment: deployment(1, 1, appsv1.DeploymentCondition{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  corev1.ConditionFalse,
+				Reason:  "ProgressDeadlineExceeded",
+				Message: `ReplicaSet "gw-123" has timed out progressing.`,
+			}),
+			want: []cond{{GatewayDeploymentReady, "False", "ProgressDeadlineExceeded", `ReplicaSet "gw-123" has timed out progressing.`}},
+		},
+		{
+			name:       "autoscaler pending",
+			params:     autoscaling,
+			deployment: deployment(2, 2),
+			want: []cond{
+				{GatewayDeploymentReady, "True", "Ready", "2/2 replicas ready"},
+				{GatewayAutoscaling, "Unknown", "Pending", "waiting for the HorizontalPodAutoscaler to be created"},
+			},
+		},
+		{
+			name:       "autoscaler active",
+			params:     autoscaling,
+			deployment: deployment(2, 2),
+			hpa: hpa(2, autoscalingv2.HorizontalPodAutoscalerCondition{
+				Type:   autoscalingv2.ScalingActive,
+				Status: corev1.ConditionTrue,
+				Reason: "ValidMetricFound",
+			}),
+			want: []cond{
+				{GatewayDeploymentReady, "True", "Ready", "2/2 replicas ready"},
+				{GatewayAutoscaling, "True", "Active", "2 replicas, scaling between 2 and 5"},
+			},
+		},
+		{
+			name:       "autoscaler limited",
+			params:     autoscaling,
+			deployment: deployment(2, 2),
+			hpa: hpa(5, autoscalingv2.HorizontalPodAutoscalerCondition{
+				Type:    autoscalingv2.ScalingLimited,
+				Status:  corev1.ConditionTrue,
+				Reason:  "TooManyReplicas",
+				Message: "the desired replica count is more than the maximum replica count",
+			}),
+			want: []cond{
+				{GatewayDeploymentReady, "True", "Ready", "2/2 replicas ready"},
+				{
+					GatewayAutoscaling, "True", "TooManyReplicas",
+					"5 replicas, scaling between 2 and 5: the desired replica count is more than the maximum replica count",
+				},
+			},
+		},
+		{
+			name:       "autoscaler failing",
+			params:     autoscaling,
+			deployment: deployment(2, 2),
+			hpa: hpa(2, autoscalingv2.HorizontalPodAutoscalerCondition{
+				Type:    autoscalingv2.ScalingActive,
+				Status:  corev1.ConditionFalse,
+				Reason:  "FailedGetResourceMetric",
+				Message: "missing request for cpu",
+			}),
+			want: []cond{
+				{GatewayDeploymentReady, "True", "Ready", "2/2 replicas ready"},
+				{GatewayAutoscaling, "False", "FailedGetResourceMetric", "missing request for cpu"},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []cond
+			for _, c := range deploymentConditions(gw, tt.params, tt.deployment, tt.hpa) {
+				if c.ObservedGeneration != gw.Generation {
+					t.Fatalf("unexpected observed generation %d", c.ObservedGeneration)
+				}
+				got = append(got, cond{c.Type, string(c.Status), c.Reason, c.Message})
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	var writes []string
+	d := &DeploymentController{
+		patcher: func(g schema.GroupVersionResource, name string, namespace string, data []byte, subresources ...string) error {
+			if g != gvr.KubernetesGateway || len(subresources) != 1 || subresources[0] != "status" {
+				t.Fatalf("unexpected patch of %v %v", g, subresources)
+			}
+			writes = append(writes, string(data))
+			return nil
+		},
+	}
+	then := metav1.Unix(1000, 0)
+	ready := metav1.Condition{
+		Type:               GatewayDeploymentReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: 1,
+		LastTransitionTime: then,
+		Reason:             "Ready",
+		Message:            "1/1 replicas ready",
+	}
+	accepted := metav1.Condition{Type: "Accepted", Status: metav1.ConditionTrue, Reason: "Accepted"}
+	gw := v1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default", Generation: 1},
+		Status:     v1beta1.GatewayStatus{Conditions: []metav1.Condition{accepted, ready}},
+	}
+	now := func(c metav1.Condition) metav1.Condition {
+		c.LastTransitionTime = metav1.Now()
+		return c
+	}
+
+	// Unchanged conditions are not written again.
+	assert.NoError(t, d.reportStatus(gw, []metav1.Condition{now(ready)}))
+	assert.Equal(t, len(writes), 0)
+
+	// A new message keeps the transition time.
+	rollingOut := ready
+	rollingOut.Reason, rollingOut.Message = "RolloutInProgress", "1/2 replicas ready"
+	assert.NoError(t, d.reportStatus(gw, []metav1.Condition{now(rollingOut)}))
+	assert.Equal(t, len(writes), 1)
+	var patch struct {
+		Status v1beta1.GatewayStatus `json:"status"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(writes[0]), &patch))
+	assert.Equal(t, len(patch.Status.Conditions), 1)
+	assert.Equal(t, patch.Status.Conditions[0].Reason, "RolloutInProgress")
+	assert.Equal(t, patch.Status.Conditions[0].LastTransitionTime.Unix(), then.Unix())
+
+	// A new status moves the transition time.
+	notReady := ready
+	notReady.Status, notReady.Reason = metav1.ConditionFalse, "NoReadyReplicas"
+	assert.NoError(t, d.reportStatus(gw, []metav1.Condition{now(notReady)}))
+	assert.NoError(t, json.Unmarshal([]byte(writes[1]), &patch))
+	if patch.Status.Conditions[0].LastTransitionTime.Unix() == then.Unix() {
+		t.Fatalf("expected the transition time to change")
+	}
+
+	// A condition that is no longer reported is removed by applying the remaining conditions.
+	withScaling := gw.DeepCopy()
+	withScaling.Status.Conditions = append(withScaling.Status.Conditions, metav1.Condition{Type: GatewayAutoscaling, Status: metav1.ConditionTrue})
+	assert.NoError(t, d.reportStatus(*withScaling, []metav1.Condition{now(ready)}))
+	assert.Equal(t, len(writes), 3)
+	assert.NoError(t, json.Unmarshal([]byte(writes[2]), &patch))
+	assert.Equal(t, len(patch.Status.Conditions), 1)
+}
+
+func TestPruneParameterResources(t *testing.T) {
+	managed := map[string]string{constants.ManagedGatewayLabel: "istio.io-mesh-controller"}
+	client := kube.NewFakeClient(
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default", Labels: managed}},
+		&policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"}},
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default", Labels: managed}},
+	)
+	d := &DeploymentController{
+		hpas: kclient.New[*autoscalingv2.HorizontalPodAutoscaler](client),
+		pdbs: kclient.New[*policyv1.PodDisruptionBudget](client),
+	}
+	stop := test.NewStop(t)
+	client.RunAndWait(stop)
+	kube.WaitForCacheSync("test", stop, d.hpas.HasSynced, d.pdbs.HasSynced)
+
+	// Resources still configured are kept.
+	assert.NoError(t, d.pruneParameterResources(TemplateInput{
+		Gateway:        &v1beta1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default"}},
+		DeploymentName: "kept",
+		Parameters:     GatewayParameters{Autoscaling: &AutoscalingParameters{MinReplicas: 1, MaxReplicas: 2}},
+	}))
+	// Managed resources no longer configured are deleted; unmanaged ones are left alone.
+	assert.NoError(t, d.pruneParameterResources(TemplateInput{
+		Gateway:        &v1beta1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"}},
+		DeploymentName: "gw",
+	}))
+	assert.EventuallyEqual(t, func() bool { return d.hpas.Get("gw", "default") == nil }, true)
+	assert.Equal(t, d.hpas.Get("kept", "default") != nil, true)
+	assert.Equal(t, d.pdbs.Get("gw", "default") != nil, true)
+}
@@ -39,6 +39,7 @@ type GatewayResources struct {
 	GatewayClass   []config.Config
 	Gateway        []config.Config
 	HTTPRoute      []config.Config
+	GRPCRoute      []config.Config
 	TCPRoute       []config.Config
 	TLSRoute       []config.Config
 	ReferenceGrant []config.Config
@@ -395,6 +395,10 @@ type WatchedResource struct {
 	// NonceAcked is the last acked message.
 	NonceAcked string
 
+	// NackError is the error the proxy reported for the last response it rejected. It is cleared once a later
+	// response is acked.
+	NackError string
+
 	// AlwaysRespond, if true, will ensure that even when a request would otherwise be treated as an
 	// ACK, it will be responded to. This typically happens when a proxy reconnects to another instance of
 	// Istiod. In that case, Envoy expects us to respond to EDS/RDS/SDS requests to finish warming of
@@ -0,0 +1,245 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	networking "istio.io/api/networking/v1alpha3"
+	authpb "istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/topology"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// anySource is the principal node used for authorization rules without a source restriction.
+const anySource = "*"
+
+// Topology returns a static graph of who can talk to whom, derived from the Sidecar scopes, VirtualServices and
+// AuthorizationPolicies in the push context. It does not reflect observed traffic.
+func (ps *PushContext) Topology() *topology.Graph {
+	t := &topologyBuilder{ps: ps, g: topology.NewGraph(), hostNamespaces: map[string]string{}}
+	namespaces := sets.New[string]()
+	for _, svc := range ps.GetAllServices() {
+		t.hostNamespaces[string(svc.Hostname)] = svc.Attributes.Namespace
+		t.service(string(svc.Hostname))
+		namespaces.Insert(svc.Attributes.Namespace)
+	}
+	for ns := range ps.sidecarIndex.sidecarsByNamespace {
+		namespaces.Insert(ns)
+	}
+	if ps.AuthzPolicies != nil {
+		for ns := range ps.AuthzPolicies.NamespaceToPolicies {
+			namespaces.Insert(ns)
+		}
+	}
+	nsList := sets.SortedList(namespaces)
+	for _, ns := range nsList {
+		t.addVisibility(ns)
+	}
+	t.addRoutes(nsList)
+	t.addAuthorization()
+	t.g.Sort()
+	return t.g
+}
+
+type topologyBuilder struct {
+	ps *PushContext
+	g  *topology.Graph
+	// hostNamespaces maps service hostnames to the namespace of the service.
+	hostNamespaces map[string]string
+}
+
+// service adds a node for the service with the given hostname and returns its ID. Hosts which are not in the
+// registry are added without a namespace.
+func (t *topologyBuilder) service(hostname string) string {
+	id := topology.ServiceID(hostname)
+	t.g.AddNode(topology.Node{ID: id, Kind: topology.Service, Name: hostname, Namespace: t.hostNamespaces[hostname]})
+	return id
+}
+
+// workloads adds a node for a group of workloads in the namespace, optionally narrowed by a selector, and returns
+// its ID. name distinguishes several selectors in the same namespace.
+func (t *topologyBuilder) workloads(namespace, name string, selector map[string]string) string {
+	id := topology.WorkloadsID(namespace, name)
+	label := anySource
+	if len(selector) > 0 {
+		label = labels.Instance(selector).String()
+	}
+	t.g.AddNode(topology.Node{ID: id, Kind: topology.Workloads, Name: label, Namespace: namespace})
+	return id
+}
+
+func (t *topologyBuilder) principal(name string) string {
+	id := topology.PrincipalID(name)
+	t.g.AddNode(topology.Node{ID: id, Kind: topology.Principal, Name: name})
+	return id
+}
+
+// addVisibility adds edges from the workloads in the namespace to every service their Sidecar scope imports.
+func (t *topologyBuilder) addVisibility(ns string) {
+	hasNamespaceDefault := false
+	for _, sc := range t.ps.sidecarIndex.sidecarsByNamespace[ns] {
+		if sc.Sidecar == nil || sc.Sidecar.GetWorkloadSelector() == nil {
+			hasNamespaceDefault = true
+			t.addScope(t.workloads(ns, "", nil), sc, resourceName(gvk.Sidecar.Kind, ns, sc.Name))
+			continue
+		}
+		selector := sc.Sidecar.GetWorkloadSelector().GetLabels()
+		t.addScope(t.workloads(ns, gvk.Sidecar.Kind+"/"+sc.Name, selector), sc, resourceName(gvk.Sidecar.Kind, ns, sc.Name))
+	}
+	if hasNamespaceDefault {
+		return
+	}
+	// Workloads not selected by any Sidecar in their namespace get the root namespace Sidecar or the mesh default.
+	sc := t.ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: ns}, nil)
+	resource := ""
+	if root := t.ps.sidecarIndex.meshRootSidecarConfig; root != nil {
+		resource = resourceName(gvk.Sidecar.Kind, root.Namespace, root.Name)
+	}
+	t.addScope(t.workloads(ns, "", nil), sc, resource)
+}
+
+func (t *topologyBuilder) addScope(from string, sc *SidecarScope, resource string) {
+	if sc == nil {
+		return
+	}
+	if sc.Sidecar == nil {
+		// A scope without a Sidecar resource is the mesh wide default.
+		resource = ""
+	}
+	for _, svc := range sc.Services() {
+		t.g.AddEdge(topology.Edge{From: from, To: t.service(string(svc.Hostname)), Kind: topology.Visibility, Resource: resource})
+	}
+}
+
+// addRoutes adds edges from the hosts of each mesh VirtualService to its destinations.
+func (t *topologyBuilder) addRoutes(namespaces []string) {
+	seen := sets.New[string]()
+	for _, ns := range namespaces {
+		for _, vs := range t.ps.VirtualServicesForGateway(ns, constants.IstioMeshGateway) {
+			resource := resourceName(gvk.VirtualService.Kind, vs.Namespace, vs.Name)
+			if seen.InsertContains(resource) {
+				continue
+			}
+			spec, ok := vs.Spec.(*networking.VirtualService)
+			if !ok {
+				continue
+			}
+			destinations := slices.Sort(maps.Keys(virtualServiceDestinations(spec)))
+			for _, h := range spec.Hosts {
+				from := t.service(h)
+				for _, d := range destinations {
+					t.g.AddEdge(topology.Edge{From: from, To: t.service(d), Kind: topology.Route, Resource: resource})
+				}
+			}
+		}
+	}
+}
+
+// addAuthorization adds edges from the sources named in ALLOW and DENY policies to the workloads they select.
+// CUSTOM and AUDIT policies do not change who can talk to whom and are skipped.
+func (t *topologyBuilder) addAuthorization() {
+	policies := t.ps.AuthzPolicies
+	if policies == nil {
+		return
+	}
+	for _, ns := range slices.Sort(maps.Keys(policies.NamespaceToPolicies)) {
+		for _, p := range policies.NamespaceToPolicies[ns] {
+			var kind topology.EdgeKind
+			switch p.Spec.GetAction() {
+			case authpb.AuthorizationPolicy_ALLOW:
+				kind = topology.Allow
+			case authpb.AuthorizationPolicy_DENY:
+				kind = topology.Deny
+			default:
+				continue
+			}
+			resource := resourceName(gvk.AuthorizationPolicy.Kind, p.Namespace, p.Name)
+			target := t.policyTarget(p)
+			for _, rule := range p.Spec.GetRules() {
+				for _, src := range t.ruleSources(rule) {
+					t.g.AddEdge(topology.Edge{From: src, To: target, Kind: kind, Resource: resource})
+				}
+			}
+		}
+	}
+}
+
+// policyTarget returns the node for the workloads a policy applies to.
+func (t *topologyBuilder) policyTarget(p AuthorizationPolicy) string {
+	selector := p.Spec.GetSelector().GetMatchLabels()
+	ns := p.Namespace
+	if ns == t.ps.AuthzPolicies.RootNamespace {
+		// Root namespace policies apply to the whole mesh, which is not a namespace of its own.
+		ns = ""
+	}
+	name := ""
+	if len(selector) > 0 {
+		name = gvk.AuthorizationPolicy.Kind + "/" + p.Name
+	}
+	if ns == "" {
+		id := topology.WorkloadsID(anySource, name)
+		label := "mesh"
+		if len(selector) > 0 {
+			label += " " + labels.Instance(selector).String()
+		}
+		t.g.AddNode(topology.Node{ID: id, Kind: topology.Workloads, Name: label})
+		return id
+	}
+	return t.workloads(ns, name, selector)
+}
+
+// ruleSources returns the nodes for the sources a rule matches.
+func (t *topologyBuilder) ruleSources(rule *authpb.Rule) []string {
+	if len(rule.GetFrom()) == 0 {
+		return []string{t.principal(anySource)}
+	}
+	var out []string
+	for _, from := range rule.GetFrom() {
+		src := from.GetSource()
+		before := len(out)
+		for _, ns := range src.GetNamespaces() {
+			if ns == anySource {
+				out = append(out, t.principal(anySource))
+				continue
+			}
+			out = append(out, t.workloads(ns, "", nil))
+		}
+		for _, p := range src.GetPrincipals() {
+			out = append(out, t.principal(p))
+		}
+		for _, p := range src.GetRequestPrincipals() {
+			out = append(out, t.principal("jwt:"+p))
+		}
+		for _, b := range src.GetIpBlocks() {
+			out = append(out, t.principal("ip:"+b))
+		}
+		for _, b := range src.GetRemoteIpBlocks() {
+			out = append(out, t.principal("ip:"+b))
+		}
+		if len(out) == before {
+			// Only negative matches (notNamespaces, notPrincipals...), which can only be shown as any source.
+			out = append(out, t.principal(anySource))
+		}
+	}
+	return out
+}
+
+func resourceName(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	securityBeta "istio.io/api/security/v1beta1"
+	selectorpb "istio.io/api/type/v1beta1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/topology"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestTopology(t *testing.T) {
+	env := NewEnvironment()
+	configStore := NewFakeStore()
+	_, _ = configStore.Create(config.Config{
+		Meta: config.Meta{Name: "a", Namespace: "test1", GroupVersionKind: gvk.VirtualService},
+		Spec: &networking.VirtualService{
+			Hosts: []string{"a.test1.svc.cluster.local"},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "b.test1.svc.cluster.local"},
+				}},
+			}},
+		},
+	})
+	_, _ = configStore.Create(config.Config{
+		Meta: config.Meta{Name: "allow-test2", Namespace: "test1", GroupVersionKind: gvk.AuthorizationPolicy},
+		Spec: &securityBeta.AuthorizationPolicy{
+			Selector: &selectorpb.WorkloadSelector{MatchLabels: map[string]string{"app": "b"}},
+			Rules: []*securityBeta.Rule{{
+				From: []*securityBeta.Rule_From{{Source: &securityBeta.Source{Namespaces: []string{"test2"}}}},
+			}},
+		},
+	})
+	_, _ = configStore.Create(config.Config{
+		Meta: config.Meta{Name: "deny-all", Namespace: "test2", GroupVersionKind: gvk.AuthorizationPolicy},
+		Spec: &securityBeta.AuthorizationPolicy{
+			Action: securityBeta.AuthorizationPolicy_DENY,
+			Rules:  []*securityBeta.Rule{{}},
+		},
+	})
+	env.ConfigStore = configStore
+	env.ServiceDiscovery = &localServiceDiscovery{
+		services: []*Service{
+			{Hostname: "a.test1.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "test1"}},
+			{Hostname: "b.test1.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "test1"}},
+			{Hostname: "c.test2.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "test2"}},
+		},
+	}
+	env.Watcher = mesh.NewFixedWatcher(mesh.DefaultMeshConfig())
+	env.Init()
+
+	ps := NewPushContext()
+	if err := ps.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	g := ps.Topology()
+
+	edges := sets.New(g.Edges...)
+	for _, want := range []topology.Edge{
+		{
+			From: topology.WorkloadsID("test2", ""),
+			To:   topology.ServiceID("a.test1.svc.cluster.local"),
+			Kind: topology.Visibility,
+		},
+		{
+			From:     topology.ServiceID("a.test1.svc.cluster.local"),
+			To:       topology.ServiceID("b.test1.svc.cluster.local"),
+			Kind:     topology.Route,
+			Resource: "VirtualService/test1/a",
+		},
+		{
+			From:     topology.WorkloadsID("test2", ""),
+			To:       topology.WorkloadsID("test1", "AuthorizationPolicy/allow-test2"),
+			Kind:     topology.Allow,
+			Resource: "AuthorizationPolicy/test1/allow-test2",
+		},
+		{
+			From:     topology.PrincipalID("*"),
+			To:       topology.WorkloadsID("test2", ""),
+			Kind:     topology.Deny,
+			Resource: "AuthorizationPolicy/test2/deny-all",
+		},
+	} {
+		if !edges.Contains(want) {
+			t.Errorf("missing edge %+v in %+v", want, g.Edges)
+		}
+	}
+
+	// Only the allow policy's edge remains when filtering on allow edges into test1.
+	filtered := g.Filter([]string{"test1"}, []topology.EdgeKind{topology.Allow})
+	if len(filtered.Edges) != 1 {
+		t.Errorf("expected a single allow edge, got %+v", filtered.Edges)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/resource"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/pkg/workloadapi"
+	"istio.io/istio/pkg/workloadapi/security"
+)
+
+// ambientConfigKinds are the Istio config kinds read by the ambient index.
+var ambientConfigKinds = []config.GroupVersionKind{gvk.AuthorizationPolicy, gvk.PeerAuthentication, gvk.WorkloadEntry}
+
+// AmbientSnapshot is the complete state of the ambient index of a cluster: the objects it is built from, and the
+// objects derived from them. A snapshot can be replayed with NewFakeAmbientController to rebuild the same index.
+type AmbientSnapshot struct {
+	Cluster cluster.ID
+	// Network is the default network of the cluster, read from the system namespace.
+	Network       network.ID
+	RootNamespace string
+	Inputs        AmbientInputs
+	Outputs       AmbientOutputs
+}
+
+// AmbientInputs are the objects the ambient index is built from.
+type AmbientInputs struct {
+	// Pods only hold the fields read by the index, see ambientPod.
+	Pods     []*v1.Pod
+	Services []*v1.Service
+	// Configs holds the WorkloadEntries, AuthorizationPolicies and PeerAuthentications.
+	Configs []config.Config
+}
+
+// AmbientOutputs are the objects derived by the ambient index, as sent to ztunnel.
+type AmbientOutputs struct {
+	Workloads      []*workloadapi.Workload
+	Services       []*workloadapi.Service
+	Authorizations []*security.Authorization
+}
+
+// AmbientSnapshot returns the state of the ambient index, or nil if it is disabled. Inputs and outputs are read one
+// after the other, so a snapshot taken while the index is changing may be inconsistent; taking another is enough.
+func (c *Controller) AmbientSnapshot() *AmbientSnapshot {
+	if c.ambientIndex == nil {
+		return nil
+	}
+	return &AmbientSnapshot{
+		Cluster:       c.Cluster(),
+		Network:       c.networkFromSystemNamespace(),
+		RootNamespace: c.meshWatcher.Mesh().GetRootNamespace(),
+		Inputs:        c.ambientInputs(),
+		Outputs:       c.ambientOutputs(),
+	}
+}
+
+func (c *Controller) ambientInputs() AmbientInputs {
+	in := AmbientInputs{
+		Pods:     slices.Map(c.podsClient.List(metav1.NamespaceAll, klabels.Everything()), ambientPod),
+		Services: slices.Map(c.services.List(metav1.NamespaceAll, klabels.Everything()), ambientService),
+	}
+	for _, k := range ambientConfigKinds {
+		in.Configs = append(in.Configs, c.configController.List(k, metav1.NamespaceAll)...)
+	}
+	in.sort()
+	return in
+}
+
+// ambientPod returns a copy of a pod holding only the fields read by the ambient index: its identity and labels, the
+// redirection annotation, node, service account, IPs and readiness. Snapshots are meant to be shared in bug reports,
+// so the rest of the pod, such as the environment of its containers, is left out.
+func ambientPod(pod *v1.Pod) *v1.Pod {
+	res := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			GenerateName:    pod.GenerateName,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			Labels:          pod.Labels,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: v1.PodSpec{
+			NodeName:           pod.Spec.NodeName,
+			ServiceAccountName: pod.Spec.ServiceAccountName,
+			HostNetwork:        pod.Spec.HostNetwork,
+		},
+		Status: v1.PodStatus{
+			Phase:  pod.Status.Phase,
+			PodIP:  pod.Status.PodIP,
+			PodIPs: pod.Status.PodIPs,
+		},
+	}
+	if v, f := pod.Annotations[constants.AmbientRedirection]; f {
+		res.Annotations = map[string]string{constants.AmbientRedirection: v}
+	}
+	if c := GetPodReadyCondition(pod.Status); c != nil {
+		res.Status.Conditions = []v1.PodCondition{{Type: c.Type, Status: c.Status}}
+	}
+	return res
+}
+
+// ambientService returns a copy of a service without its managed fields and last applied configuration.
+func ambientService(svc *v1.Service) *v1.Service {
+	svc = svc.DeepCopy()
+	svc.ManagedFields = nil
+	delete(svc.Annotations, v1.LastAppliedConfigAnnotation)
+	return svc
+}
+
+// Filter returns the part of a snapshot in a namespace and on a node, each ignored if empty. Services and configs
+// are not bound to a node, and configs of the root namespace apply to every namespace, so they are kept. A
+// filtered snapshot is smaller, but replaying it only derives the same workloads if they do not depend on objects
+// left out, like Services selecting pods in other namespaces.
+func (s *AmbientSnapshot) Filter(namespace, node string) *AmbientSnapshot {
+	inNamespace := func(ns string) bool {
+		return namespace == "" || ns == namespace
+	}
+	inScope := func(ns string) bool {
+		return inNamespace(ns) || ns == s.RootNamespace
+	}
+	return &AmbientSnapshot{
+		Cluster:       s.Cluster,
+		Network:       s.Network,
+		RootNamespace: s.RootNamespace,
+		Inputs: AmbientInputs{
+			Pods: slices.Filter(s.Inputs.Pods, func(p *v1.Pod) bool {
+				return inNamespace(p.Namespace) && (node == "" || p.Spec.NodeName == node)
+			}),
+			Services: slices.Filter(s.Inputs.Services, func(svc *v1.Service) bool {
+				return inNamespace(svc.Namespace)
+			}),
+			Configs: slices.Filter(s.Inputs.Configs, func(c config.Config) bool {
+				return inScope(c.Namespace)
+			}),
+		},
+		Outputs: AmbientOutputs{
+			Workloads: slices.Filter(s.Outputs.Workloads, func(w *workloadapi.Workload) bool {
+				return inNamespace(w.Namespace) && (node == "" || w.Node == node)
+			}),
+			Services: slices.Filter(s.Outputs.Services, func(svc *workloadapi.Service) bool {
+				return inNamespace(svc.Namespace)
+			}),
+			Authorizations: slices.Filter(s.Outputs.Authorizations, func(a *security.Authorization) bool {
+				return inScope(a.Namespace)
+			}),
+		},
+	}
+}
+
+func (c *Controller) ambientOutputs() AmbientOutputs {
+	out := AmbientOutputs{}
+	for _, addr := range c.ambientIndex.All() {
+		switch a := addr.Type.(type) {
+		case *workloadapi.Address_Workload:
+			out.Workloads = append(out.Workloads, a.Workload)
+		case *workloadapi.Address_Service:
+			out.Services = append(out.Services, a.Service)
+		}
+	}
+	out.Authorizations = c.Policies(nil)
+	out.sort()
+	return out
+}
+
+func (in *AmbientInputs) sort() {
+	sort.Slice(in.Pods, func(i, j int) bool {
+		return in.Pods[i].Namespace+"/"+in.Pods[i].Name < in.Pods[j].Namespace+"/"+in.Pods[j].Name
+	})
+	sort.Slice(in.Services, func(i, j int) bool {
+		return in.Services[i].Namespace+"/"+in.Services[i].Name < in.Services[j].Namespace+"/"+in.Services[j].Name
+	})
+	sort.Slice(in.Configs, func(i, j int) bool {
+		return in.Configs[i].Key() < in.Configs[j].Key()
+	})
+}
+
+func (out *AmbientOutputs) sort() {
+	sort.Slice(out.Workloads, func(i, j int) bool {
+		return out.Workloads[i].Uid < out.Workloads[j].Uid
+	})
+	sort.Slice(out.Services, func(i, j int) bool {
+		return out.Services[i].Namespace+"/"+out.Services[i].Hostname < out.Services[j].Namespace+"/"+out.Services[j].Hostname
+	})
+	sort.Slice(out.Authorizations, func(i, j int) bool {
+		return out.Authorizations[i].Namespace+"/"+out.Authorizations[i].Name < out.Authorizations[j].Namespace+"/"+out.Authorizations[j].Name
+	})
+}
+
+// ambientSnapshotJSON is the serialized form of an AmbientSnapshot. Inputs are Kubernetes objects, so that they can
+// be read back like a directory of YAML, and outputs use the proto JSON mapping.
+type ambientSnapshotJSON struct {
+	Cluster        cluster.ID        `json:"cluster"`
+	Network        network.ID        `json:"network,omitempty"`
+	RootNamespace  string            `json:"rootNamespace,omitempty"`
+	Inputs         []json.RawMessage `json:"inputs"`
+	Workloads      []json.RawMessage `json:"workloads"`
+	Services       []json.RawMessage `json:"services"`
+	Authorizations []json.RawMessage `json:"authorizations"`
+}
+
+func (s *AmbientSnapshot) MarshalJSON() ([]byte, error) {
+	js := ambientSnapshotJSON{
+		Cluster:       s.Cluster,
+		Network:       s.Network,
+		RootNamespace: s.RootNamespace,
+	}
+	for _, p := range s.Inputs.Pods {
+		p = p.DeepCopy()
+		p.SetGroupVersionKind(gvk.Pod.Kubernetes())
+		b, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		js.Inputs = append(js.Inputs, b)
+	}
+	for _, svc := range s.Inputs.Services {
+		svc = svc.DeepCopy()
+		svc.SetGroupVersionKind(gvk.Service.Kubernetes())
+		b, err := json.Marshal(svc)
+		if err != nil {
+			return nil, err
+		}
+		js.Inputs = append(js.Inputs, b)
+	}
+	for _, cfg := range s.Inputs.Configs {
+		obj, err := crd.ConvertConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", cfg.Key(), err)
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		js.Inputs = append(js.Inputs, b)
+	}
+	for _, w := range s.Outputs.Workloads {
+		b, err := protomarshal.Marshal(w)
+		if err != nil {
+			return nil, err
+		}
+		js.Workloads = append(js.Workloads, b)
+	}
+	for _, svc := range s.Outputs.Services {
+		b, err := protomarshal.Marshal(svc)
+		if err != nil {
+			return nil, err
+		}
+		js.Services = append(js.Services, b)
+	}
+	for _, a := range s.Outputs.Authorizations {
+		b, err := protomarshal.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		js.Authorizations = append(js.Authorizations, b)
+	}
+	return json.Marshal(js)
+}
+
+func (s *AmbientSnapshot) UnmarshalJSON(data []byte) error {
+	js := ambientSnapshotJSON{}
+	if err := json.Unmarshal(data, &js); err != nil {
+		return err
+	}
+	*s = AmbientSnapshot{
+		Cluster:       js.Cluster,
+		Network:       js.Network,
+		RootNamespace: js.RootNamespace,
+	}
+	for _, raw := range js.Inputs {
+		if err := s.Inputs.add(raw); err != nil {
+			return err
+		}
+	}
+	for _, raw := range js.Workloads {
+		w := &workloadapi.Workload{}
+		if err := protomarshal.Unmarshal(raw, w); err != nil {
+			return fmt.Errorf("workload: %v", err)
+		}
+		s.Outputs.Workloads = append(s.Outputs.Workloads, w)
+	}
+	for _, raw := range js.Services {
+		svc := &workloadapi.Service{}
+		if err := protomarshal.Unmarshal(raw, svc); err != nil {
+			return fmt.Errorf("service: %v", err)
+		}
+		s.Outputs.Services = append(s.Outputs.Services, svc)
+	}
+	for _, raw := range js.Authorizations {
+		a := &security.Authorization{}
+		if err := protomarshal.Unmarshal(raw, a); err != nil {
+			return fmt.Errorf("authorization: %v", err)
+		}
+		s.Outputs.Authorizations = append(s.Outputs.Authorizations, a)
+	}
+	s.Inputs.sort()
+	s.Outputs.sort()
+	return nil
+}
+
+// ParseAmbientSnapshot reads a single snapshot.
+func ParseAmbientSnapshot(data []byte) (*AmbientSnapshot, error) {
+	s := &AmbientSnapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseAmbientSnapshots reads the snapshots of every cluster, as served by the /debug/ambientz endpoint of istiod.
+func ParseAmbientSnapshots(data []byte) ([]*AmbientSnapshot, error) {
+	var res []*AmbientSnapshot
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ParseAmbientInputs reads the inputs of the ambient index from a stream of YAML or JSON Kubernetes objects, such as
+// the output of kubectl get -o yaml. Lists are flattened, and objects not read by the index are skipped.
+func ParseAmbientInputs(r io.Reader) (AmbientInputs, error) {
+	in := AmbientInputs{}
+	decoder := kubeyaml.NewYAMLOrJSONDecoder(r, 512*1024)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return AmbientInputs{}, err
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := in.add(raw); err != nil {
+			return AmbientInputs{}, err
+		}
+	}
+	in.sort()
+	return in, nil
+}
+
+// ReadAmbientInputs reads the inputs of the ambient index from every YAML or JSON file of a directory.
+func ReadAmbientInputs(dir string) (AmbientInputs, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return AmbientInputs{}, err
+	}
+	res := AmbientInputs{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return AmbientInputs{}, err
+		}
+		in, err := ParseAmbientInputs(f)
+		_ = f.Close()
+		if err != nil {
+			return AmbientInputs{}, fmt.Errorf("%v: %v", e.Name(), err)
+		}
+		res.Pods = append(res.Pods, in.Pods...)
+		res.Services = append(res.Services, in.Services...)
+		res.Configs = append(res.Configs, in.Configs...)
+	}
+	res.sort()
+	return res, nil
+}
+
+// add decodes a single Kubernetes object and appends it to the inputs if it is read by the index.
+func (in *AmbientInputs) add(raw []byte) error {
+	meta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return err
+	}
+	switch k := meta.GroupVersionKind(); {
+	case meta.APIVersion == "v1" && meta.Kind == "List":
+		list := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if err := in.add(item); err != nil {
+				return err
+			}
+		}
+	case k == gvk.Pod.Kubernetes():
+		pod := &v1.Pod{}
+		if err := json.Unmarshal(raw, pod); err != nil {
+			return fmt.Errorf("pod: %v", err)
+		}
+		in.Pods = append(in.Pods, pod)
+	case k == gvk.Service.Kubernetes():
+		svc := &v1.Service{}
+		if err := json.Unmarshal(raw, svc); err != nil {
+			return fmt.Errorf("service: %v", err)
+		}
+		in.Services = append(in.Services, svc)
+	default:
+		s, f := collections.PilotGatewayAPI().FindByGroupVersionAliasesKind(resource.FromKubernetesGVK(&k))
+		if !f || !slices.Contains(ambientConfigKinds, s.GroupVersionKind()) {
+			return nil
+		}
+		obj := &crd.IstioKind{}
+		if err := json.Unmarshal(raw, obj); err != nil {
+			return fmt.Errorf("%v: %v", meta.Kind, err)
+		}
+		cfg, err := crd.ConvertObject(s, obj, "")
+		if err != nil {
+			return fmt.Errorf("%v %v/%v: %v", meta.Kind, obj.Namespace, obj.Name, err)
+		}
+		in.Configs = append(in.Configs, *cfg)
+	}
+	return nil
+}
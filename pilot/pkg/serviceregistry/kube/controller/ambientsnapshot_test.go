@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/workloadapi"
+)
+
+func TestParseAmbientInputs(t *testing.T) {
+	in, err := ParseAmbientInputs(strings.NewReader(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod
+    namespace: ns1
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: svc
+    namespace: ns1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: skipped
+  namespace: ns1
+---
+apiVersion: networking.istio.io/v1beta1
+kind: WorkloadEntry
+metadata:
+  name: we
+  namespace: ns1
+spec:
+  address: 1.2.3.4
+---
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+metadata:
+  name: skipped
+  namespace: ns1
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, len(in.Pods), 1)
+	assert.Equal(t, len(in.Services), 1)
+	assert.Equal(t, len(in.Configs), 1)
+	assert.Equal(t, in.Configs[0].GroupVersionKind, gvk.WorkloadEntry)
+
+	_, err = ParseAmbientInputs(strings.NewReader(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name:
+  - invalid
+`))
+	assert.Error(t, err)
+}
+
+func TestAmbientSnapshotReplay(t *testing.T) {
+	inputs, err := ReadAmbientInputs(filepath.Join("testdata", "ambient-snapshot"))
+	assert.NoError(t, err)
+	c := NewFakeAmbientController(t, &AmbientSnapshot{
+		Cluster:       "cluster0",
+		Network:       "testnetwork",
+		RootNamespace: "istio-system",
+		Inputs:        inputs,
+	})
+
+	uids := func() []string {
+		return slices.Map(c.AmbientOutputs().Workloads, (*workloadapi.Workload).GetUid)
+	}
+	assert.EventuallyEqual(t, uids, []string{
+		"cluster0//Pod/ns1/name1",
+		"cluster0//Pod/ns1/name2",
+		"cluster0/networking.istio.io/WorkloadEntry/ns1/vm1",
+	}, retry.Timeout(time.Second*10))
+	retry.UntilSuccessOrFail(t, func() error {
+		out := c.AmbientOutputs()
+		if len(out.Services) != 1 || len(out.Authorizations) != 1 || len(out.Workloads[0].VirtualIps) != 1 {
+			return fmt.Errorf("incomplete outputs: %v", out)
+		}
+		return nil
+	}, retry.Timeout(time.Second*10))
+
+	snapshot := c.AmbientSnapshot()
+	name1 := snapshot.Outputs.Workloads[0]
+	assert.Equal(t, name1.AuthorizationPolicies, []string{"ns1/allow-a"})
+	assert.Equal(t, name1.Status, workloadapi.WorkloadStatus_HEALTHY)
+	assert.Equal(t, snapshot.Outputs.Workloads[1].Status, workloadapi.WorkloadStatus_UNHEALTHY)
+	assert.Equal(t, snapshot.Outputs.Services[0].Hostname, "svc1.ns1.svc.company.com")
+	assert.Equal(t, snapshot.Outputs.Authorizations[0].Name, "allow-a")
+
+	// Only the fields read by the index are dumped.
+	pod := snapshot.Inputs.Pods[0]
+	assert.Equal(t, len(pod.Spec.Containers), 0)
+	assert.Equal(t, pod.Spec.NodeName, "node1")
+	assert.Equal(t, pod.Spec.ServiceAccountName, "sa1")
+	assert.Equal(t, pod.Labels, map[string]string{"app": "a"})
+
+	filtered := snapshot.Filter("ns1", "node1")
+	assert.Equal(t, len(filtered.Inputs.Pods), 2)
+	assert.Equal(t, len(filtered.Outputs.Workloads), 2)
+	assert.Equal(t, len(filtered.Outputs.Services), 1)
+	filtered = snapshot.Filter("ns2", "")
+	assert.Equal(t, len(filtered.Inputs.Pods), 0)
+	assert.Equal(t, len(filtered.Outputs.Workloads), 0)
+	assert.Equal(t, len(filtered.Outputs.Authorizations), 0)
+
+	// A dump is replayed into a new index, which must derive the same objects.
+	b, err := json.Marshal([]*AmbientSnapshot{snapshot})
+	assert.NoError(t, err)
+	dump, err := ParseAmbientSnapshots(b)
+	assert.NoError(t, err)
+	assert.Equal(t, len(dump), 1)
+	replayed := dump[0]
+	assert.Equal(t, replayed.Cluster, snapshot.Cluster)
+	assert.Equal(t, replayed.Network, snapshot.Network)
+	assert.Equal(t, len(replayed.Inputs.Pods), 2)
+	assert.Equal(t, len(replayed.Inputs.Services), 1)
+	assert.Equal(t, len(replayed.Inputs.Configs), 2)
+	assert.Equal(t, replayed.Outputs, snapshot.Outputs)
+
+	NewFakeAmbientController(t, replayed).AssertAmbientOutputs(t, snapshot.Outputs)
+}
@@ -15,22 +15,30 @@
 package controller
 
 import (
+	"fmt"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
 	kubelib "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/kclient/clienttest"
 	filter "istio.io/istio/pkg/kube/namespace"
 	"istio.io/istio/pkg/queue"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 const (
@@ -126,3 +134,66 @@ func NewFakeControllerWithOptions(t test.Failer, opts FakeControllerOptions) (*F
 
 	return &FakeController{c}, fx
 }
+
+// NewFakeAmbientController builds a controller with the ambient index enabled, and replays the inputs of a snapshot
+// into it. A snapshot is read with ParseAmbientSnapshots from a dump of /debug/ambientz, or built from the inputs
+// returned by ReadAmbientInputs for a directory of Kubernetes YAML. Inputs are applied in a fixed order: configs,
+// then Services, then Pods, each kind being visible to the controller before the next is applied.
+func NewFakeAmbientController(t test.Failer, snapshot *AmbientSnapshot) *FakeController {
+	test.SetForTest(t, &features.EnableAmbientControllers, true)
+	store := memory.NewSyncController(memory.MakeSkipValidation(collections.PilotGatewayAPI()))
+	c, _ := NewFakeControllerWithOptions(t, FakeControllerOptions{
+		ConfigController: store,
+		ConfigCluster:    true,
+		MeshWatcher:      mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: snapshot.RootNamespace}),
+		ClusterID:        snapshot.Cluster,
+	})
+	c.network = snapshot.Network
+	store.RegisterEventHandler(gvk.AuthorizationPolicy, c.AuthorizationPolicyHandler)
+	store.RegisterEventHandler(gvk.PeerAuthentication, c.PeerAuthenticationHandler)
+	go store.Run(c.stop)
+
+	for _, cfg := range snapshot.Inputs.Configs {
+		cfg = cfg.DeepCopy()
+		cfg.ResourceVersion = ""
+		if _, err := store.Create(cfg); err != nil {
+			t.Fatalf("failed to create %v: %v", cfg.Key(), err)
+		}
+	}
+	services := clienttest.Wrap(t, c.services)
+	for _, svc := range snapshot.Inputs.Services {
+		svc = svc.DeepCopy()
+		svc.ResourceVersion = ""
+		services.Create(svc)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if got, want := len(services.List(metav1.NamespaceAll, klabels.Everything())), len(snapshot.Inputs.Services); got != want {
+			return fmt.Errorf("expected %d services, got %d", want, got)
+		}
+		return nil
+	}, retry.Timeout(time.Second*10))
+	pods := clienttest.Wrap(t, c.podsClient)
+	for _, pod := range snapshot.Inputs.Pods {
+		pod = pod.DeepCopy()
+		pod.ResourceVersion = ""
+		pods.Create(pod)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if got, want := len(pods.List(metav1.NamespaceAll, klabels.Everything())), len(snapshot.Inputs.Pods); got != want {
+			return fmt.Errorf("expected %d pods, got %d", want, got)
+		}
+		return nil
+	}, retry.Timeout(time.Second*10))
+	return c
+}
+
+// AmbientOutputs returns the objects currently derived by the ambient index, in the order of a snapshot.
+func (fc *FakeController) AmbientOutputs() AmbientOutputs {
+	return fc.ambientOutputs()
+}
+
+// AssertAmbientOutputs waits until the ambient index derives the expected objects.
+func (fc *FakeController) AssertAmbientOutputs(t test.Failer, expected AmbientOutputs) {
+	t.Helper()
+	assert.EventuallyEqual(t, fc.AmbientOutputs, expected, retry.Timeout(time.Second*10))
+}
@@ -17,27 +17,24 @@ package simulation
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"regexp"
 	"strings"
 	"testing"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/yl2chen/cidranger"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/xds"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/test/xdstest"
-	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/envoy/match"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/util/sets"
@@ -69,11 +66,11 @@ var httpProtocols = sets.New(string(HTTP), string(HTTP2))
 
 var (
 	ErrNoListener          = errors.New("no listener matched")
-	ErrNoFilterChain       = errors.New("no filter chains matched")
+	ErrNoFilterChain       = match.ErrNoFilterChain
 	ErrNoRoute             = errors.New("no route matched")
 	ErrTLSRedirect         = errors.New("tls required, sending 301")
 	ErrNoVirtualHost       = errors.New("no virtual host matched")
-	ErrMultipleFilterChain = errors.New("multiple filter chains matched")
+	ErrMultipleFilterChain = match.ErrMultipleFilterChain
 	// ErrProtocolError happens when sending TLS/TCP request to HCM, for example
 	ErrProtocolError = errors.New("protocol error")
 	ErrTLSError      = errors.New("invalid TLS")
@@ -263,17 +260,6 @@ func (sim *Simulation) RunExpectations(es []Expect) {
 	}
 }
 
-func hasFilterOnPort(l *listener.Listener, filter string, port int) bool {
-	got, f := xdstest.ExtractListenerFilters(l)[filter]
-	if !f {
-		return false
-	}
-	if got.FilterDisabled == nil {
-		return true
-	}
-	return !xdstest.EvaluateListenerFilterPredicates(got.FilterDisabled, port)
-}
-
 func (sim *Simulation) Run(input Call) (result Result) {
 	result = Result{t: sim.t}
 	input = input.FillDefaults()
@@ -283,14 +269,19 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 
 	// First we will match a listener
-	l := matchListener(sim.Listeners, input)
+	var l *listener.Listener
+	if input.CallMode == CallModeInbound {
+		l = xdstest.ExtractListener(model.VirtualInboundListenerName, sim.Listeners)
+	} else {
+		l = match.Listener(sim.Listeners, input.Address, input.Port)
+	}
 	if l == nil {
 		result.Error = ErrNoListener
 		return
 	}
 	result.ListenerMatched = l.Name
 
-	hasTLSInspector := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
+	hasTLSInspector := match.HasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
 	if !hasTLSInspector {
 		// Without tls inspector, Envoy would not read the ALPN in the TLS handshake
 		// HTTP inspector still may set it though
@@ -298,13 +289,30 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 
 	// Apply listener filters
-	if hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) {
+	if match.HasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) {
 		if alpn := protocolToAlpn(input.Protocol); alpn != "" && input.TLS == Plaintext {
 			input.Alpn = alpn
 		}
 	}
 
-	fc, err := sim.matchFilterChain(l.FilterChains, l.DefaultFilterChain, input, hasTLSInspector)
+	// Without tls inspector, transport protocol will always be raw buffer
+	transport := xdsfilters.RawBufferTransportProtocol
+	if hasTLSInspector && (input.TLS == TLS || input.TLS == MTLS) {
+		transport = xdsfilters.TLSTransportProtocol
+	}
+	conn := match.Connection{
+		Address:           input.Address,
+		Port:              input.Port,
+		SNI:               input.Sni,
+		TransportProtocol: transport,
+		ALPN:              input.Alpn,
+	}
+	fc, err := match.FilterChain(l.FilterChains, l.DefaultFilterChain, conn)
+	if err == ErrMultipleFilterChain {
+		for _, c := range match.FilterChains(l.FilterChains, conn) {
+			log.Warnf("Matched chain %v", c.Name)
+		}
+	}
 	if err != nil {
 		result.Error = err
 		return
@@ -360,7 +368,7 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		if len(input.Headers["Host"]) > 0 {
 			hostHeader = input.Headers["Host"][0]
 		}
-		vh := sim.matchVirtualHost(rc, hostHeader)
+		vh := match.VirtualHost(rc, hostHeader)
 		if vh == nil {
 			result.Error = ErrNoVirtualHost
 			return
@@ -444,182 +452,6 @@ func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Rout
 	return nil
 }
 
-func (sim *Simulation) matchVirtualHost(rc *route.RouteConfiguration, host string) *route.VirtualHost {
-	if rc.GetIgnorePortInHostMatching() {
-		if h, _, err := net.SplitHostPort(host); err == nil {
-			host = h
-		}
-	}
-	// Exact match
-	for _, vh := range rc.VirtualHosts {
-		for _, d := range vh.Domains {
-			if d == host {
-				return vh
-			}
-		}
-	}
-	// prefix match
-	var bestMatch *route.VirtualHost
-	longest := 0
-	for _, vh := range rc.VirtualHosts {
-		for _, d := range vh.Domains {
-			if d[0] != '*' {
-				continue
-			}
-			if len(host) >= len(d) && strings.HasSuffix(host, d[1:]) && len(d) > longest {
-				bestMatch = vh
-				longest = len(d)
-			}
-		}
-	}
-	if bestMatch != nil {
-		return bestMatch
-	}
-	// Suffix match
-	longest = 0
-	for _, vh := range rc.VirtualHosts {
-		for _, d := range vh.Domains {
-			if d[len(d)-1] != '*' {
-				continue
-			}
-			if len(host) >= len(d) && strings.HasPrefix(host, d[:len(d)-1]) && len(d) > longest {
-				bestMatch = vh
-				longest = len(d)
-			}
-		}
-	}
-	if bestMatch != nil {
-		return bestMatch
-	}
-	// wildcard match
-	for _, vh := range rc.VirtualHosts {
-		for _, d := range vh.Domains {
-			if d == "*" {
-				return vh
-			}
-		}
-	}
-	return nil
-}
-
-// Follow the 8 step Sieve as in
-// https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/listener/v3/listener_components.proto.html#config-listener-v3-filterchainmatch
-// The implementation may initially be confusing because of a property of the
-// Envoy algorithm - at each level we will filter out all FilterChains that do
-// not match. This means an empty match (`{}`) may not match if another chain
-// matches one criteria but not another.
-func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultChain *listener.FilterChain,
-	input Call, hasTLSInspector bool,
-) (*listener.FilterChain, error) {
-	chains = filter("DestinationPort", chains, func(fc *listener.FilterChainMatch) bool {
-		return fc.GetDestinationPort() == nil
-	}, func(fc *listener.FilterChainMatch) bool {
-		return int(fc.GetDestinationPort().GetValue()) == input.Port
-	})
-	chains = filter("PrefixRanges", chains, func(fc *listener.FilterChainMatch) bool {
-		return fc.GetPrefixRanges() == nil
-	}, func(fc *listener.FilterChainMatch) bool {
-		ranger := cidranger.NewPCTrieRanger()
-		for _, a := range fc.GetPrefixRanges() {
-			s := fmt.Sprintf("%s/%d", a.AddressPrefix, a.GetPrefixLen().GetValue())
-			_, cidr, err := net.ParseCIDR(s)
-			if err != nil {
-				sim.t.Fatalf("failed to parse cidr %v: %v", s, err)
-			}
-			if err := ranger.Insert(cidranger.NewBasicRangerEntry(*cidr)); err != nil {
-				sim.t.Fatalf("failed to insert cidr %v: %v", cidr, err)
-			}
-		}
-		f, err := ranger.Contains(net.ParseIP(input.Address))
-		if err != nil {
-			sim.t.Fatalf("cidr containers %v failed: %v", input.Address, err)
-		}
-		return f
-	})
-	chains = filter("ServerNames", chains, func(fc *listener.FilterChainMatch) bool {
-		return fc.GetServerNames() == nil
-	}, func(fc *listener.FilterChainMatch) bool {
-		sni := host.Name(input.Sni)
-		for _, s := range fc.GetServerNames() {
-			if sni.SubsetOf(host.Name(s)) {
-				return true
-			}
-		}
-		return false
-	})
-	chains = filter("TransportProtocol", chains, func(fc *listener.FilterChainMatch) bool {
-		return fc.GetTransportProtocol() == ""
-	}, func(fc *listener.FilterChainMatch) bool {
-		if !hasTLSInspector {
-			// Without tls inspector, transport protocol will always be raw buffer
-			return fc.GetTransportProtocol() == xdsfilters.RawBufferTransportProtocol
-		}
-		switch fc.GetTransportProtocol() {
-		case xdsfilters.TLSTransportProtocol:
-			return input.TLS == TLS || input.TLS == MTLS
-		case xdsfilters.RawBufferTransportProtocol:
-			return input.TLS == Plaintext
-		}
-		return false
-	})
-	chains = filter("ApplicationProtocols", chains, func(fc *listener.FilterChainMatch) bool {
-		return fc.GetApplicationProtocols() == nil
-	}, func(fc *listener.FilterChainMatch) bool {
-		return sets.New(fc.GetApplicationProtocols()...).Contains(input.Alpn)
-	})
-	// We do not implement the "source" based filters as we do not use them
-	if len(chains) > 1 {
-		for _, c := range chains {
-			log.Warnf("Matched chain %v", c.Name)
-		}
-		return nil, ErrMultipleFilterChain
-	}
-	if len(chains) == 0 {
-		if defaultChain != nil {
-			return defaultChain, nil
-		}
-		return nil, ErrNoFilterChain
-	}
-	return chains[0], nil
-}
-
-func filter(desc string, chains []*listener.FilterChain,
-	empty func(fc *listener.FilterChainMatch) bool,
-	match func(fc *listener.FilterChainMatch) bool,
-) []*listener.FilterChain {
-	res := []*listener.FilterChain{}
-	anySet := false
-	for _, c := range chains {
-		if !empty(c.GetFilterChainMatch()) {
-			anySet = true
-			break
-		}
-	}
-	if !anySet {
-		log.Debugf("%v: none set, skipping", desc)
-		return chains
-	}
-	for i, c := range chains {
-		if match(c.GetFilterChainMatch()) {
-			log.Debugf("%v: matched chain %v/%v", desc, i, c.GetName())
-			res = append(res, c)
-		}
-	}
-	// Return all matching filter chains
-	if len(res) > 0 {
-		return res
-	}
-	// Unless there were no matches - in which case we return all filter chains that did not have a
-	// match set
-	for i, c := range chains {
-		if empty(c.GetFilterChainMatch()) {
-			log.Debugf("%v: no matches, found empty chain match %v/%v", desc, i, c.GetName())
-			res = append(res, c)
-		}
-	}
-	return res
-}
-
 func protocolToMTLSAlpn(s Protocol) string {
 	switch s {
 	case HTTP:
@@ -652,40 +484,3 @@ func protocolToAlpn(s Protocol) string {
 		return ""
 	}
 }
-
-func matchListener(listeners []*listener.Listener, input Call) *listener.Listener {
-	if input.CallMode == CallModeInbound {
-		return xdstest.ExtractListener(model.VirtualInboundListenerName, listeners)
-	}
-	// First find exact match for the IP/Port, then fallback to wildcard IP/Port
-	// There is no wildcard port
-	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), input.Address, input.Port) {
-			return l
-		}
-	}
-	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), "0.0.0.0", input.Port) {
-			return l
-		}
-	}
-
-	// Fallback to the outbound listener
-	// TODO - support inbound
-	for _, l := range listeners {
-		if l.Name == model.VirtualOutboundListenerName {
-			return l
-		}
-	}
-	return nil
-}
-
-func matchAddress(a *core.Address, address string, port int) bool {
-	if a.GetSocketAddress().GetAddress() != address {
-		return false
-	}
-	if int(a.GetSocketAddress().GetPortValue()) != port {
-		return false
-	}
-	return true
-}
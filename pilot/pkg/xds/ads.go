@@ -362,6 +362,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 		errCode := codes.Code(request.ErrorDetail.Code)
 		log.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.conID, errCode.String(), request.ErrorDetail.GetMessage())
 		incrementXDSRejects(request.TypeUrl, con.proxy.ID, errCode.String())
+		con.recordNack(request.TypeUrl, request.ErrorDetail.GetMessage())
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, request)
 		}
@@ -427,6 +428,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 	con.proxy.Lock()
 	previousResources := con.proxy.WatchedResources[request.TypeUrl].ResourceNames
 	con.proxy.WatchedResources[request.TypeUrl].NonceAcked = request.ResponseNonce
+	con.proxy.WatchedResources[request.TypeUrl].NackError = ""
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = request.ResourceNames
 	alwaysRespond := previousInfo.AlwaysRespond
 	previousInfo.AlwaysRespond = false
@@ -678,7 +680,7 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 		}
 		for conf := range request.ConfigsUpdated {
 			switch conf.Kind {
-			case kind.ServiceEntry, kind.DestinationRule, kind.VirtualService, kind.Sidecar, kind.HTTPRoute, kind.TCPRoute:
+			case kind.ServiceEntry, kind.DestinationRule, kind.VirtualService, kind.Sidecar, kind.HTTPRoute, kind.GRPCRoute, kind.TCPRoute:
 				sidecar = true
 			case kind.Gateway, kind.KubernetesGateway, kind.GatewayClass, kind.ReferenceGrant:
 				gateway = true
@@ -925,6 +927,28 @@ func (conn *Connection) NonceAcked(typeUrl string) string {
 	return ""
 }
 
+// NackError returns the error reported by the proxy for the last rejected response of the type, if it has
+// not acked a later response since.
+// nolint
+func (conn *Connection) NackError(typeUrl string) string {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	if conn.proxy.WatchedResources != nil && conn.proxy.WatchedResources[typeUrl] != nil {
+		return conn.proxy.WatchedResources[typeUrl].NackError
+	}
+	return ""
+}
+
+// recordNack stores the rejection details on the watched resource so they can be surfaced by the debug endpoints.
+// nolint
+func (conn *Connection) recordNack(typeUrl, message string) {
+	conn.proxy.Lock()
+	defer conn.proxy.Unlock()
+	if wr := conn.proxy.WatchedResources[typeUrl]; wr != nil {
+		wr.NackError = message
+	}
+}
+
 // nolint
 func (conn *Connection) NonceSent(typeUrl string) string {
 	conn.proxy.RLock()
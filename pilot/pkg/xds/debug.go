@@ -40,13 +40,17 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/resource"
 	"istio.io/istio/pkg/config/xds"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/topology"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -130,6 +134,8 @@ type SyncStatus struct {
 	EndpointAcked        string         `json:"endpoint_acked,omitempty"`
 	ExtensionConfigSent  string         `json:"extensionconfig_sent,omitempty"`
 	ExtensionConfigAcked string         `json:"extensionconfig_acked,omitempty"`
+	// Nacks holds the error message of the last rejected response, keyed by the short xDS type (CDS, LDS...).
+	Nacks map[string]string `json:"nacks,omitempty"`
 }
 
 // SyncedVersions shows what resourceVersion of a given resource has been acked by Envoy.
@@ -196,6 +202,8 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 
 	s.addDebugHandler(mux, internalMux, "/debug/authorizationz", "Internal authorization policies", s.authorizationz)
 	s.addDebugHandler(mux, internalMux, "/debug/telemetryz", "Debug Telemetry configuration", s.telemetryz)
+	s.addDebugHandler(mux, internalMux, "/debug/topologyz",
+		"Static service graph derived from Sidecar, VirtualService and AuthorizationPolicy config", s.topologyz)
 	s.addDebugHandler(mux, internalMux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, internalMux, "/debug/push_status", "Last PushContext Details", s.pushStatusHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/pushcontext", "Debug support for current push context", s.pushContextHandler)
@@ -206,6 +214,7 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/clusterz", "List remote clusters where istiod reads endpoints", s.clusterz)
 	s.addDebugHandler(mux, internalMux, "/debug/networkz", "List cross-network gateways", s.networkz)
 	s.addDebugHandler(mux, internalMux, "/debug/mcsz", "List information about Kubernetes MCS services", s.mcsz)
+	s.addDebugHandler(mux, internalMux, "/debug/ambientz", "Dump the inputs and outputs of the ambient index of each cluster", s.ambientz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/list", "List all supported debug commands in json", s.list)
 }
@@ -285,12 +294,27 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, req *http.Request) {
 				EndpointAcked:        con.NonceAcked(v3.EndpointType),
 				ExtensionConfigSent:  con.NonceSent(v3.ExtensionConfigurationType),
 				ExtensionConfigAcked: con.NonceAcked(v3.ExtensionConfigurationType),
+				Nacks:                connectionNacks(con),
 			})
 		}
 	}
 	writeJSON(w, syncz, req)
 }
 
+// connectionNacks returns the NACK error messages the connection still has outstanding, keyed by short type.
+func connectionNacks(con *Connection) map[string]string {
+	var nacks map[string]string
+	for _, typeURL := range []string{v3.ClusterType, v3.ListenerType, v3.RouteType, v3.EndpointType, v3.ExtensionConfigurationType} {
+		if msg := con.NackError(typeURL); msg != "" {
+			if nacks == nil {
+				nacks = map[string]string{}
+			}
+			nacks[v3.GetShortType(typeURL)] = msg
+		}
+	}
+	return nacks
+}
+
 // registryz providees debug support for registry - adding and listing model items.
 // Can be combined with the push debug interface to reproduce changes.
 func (s *DiscoveryServer) registryz(w http.ResponseWriter, req *http.Request) {
@@ -524,6 +548,33 @@ func (s *DiscoveryServer) telemetryz(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, info, req)
 }
 
+// topologyz dumps the static service graph of the mesh. The graph can be narrowed with a comma separated list of
+// namespaces and edge kinds, and rendered as json (default), dot or mermaid.
+func (s *DiscoveryServer) topologyz(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	var namespaces []string
+	if ns := q.Get("namespace"); ns != "" {
+		namespaces = strings.Split(ns, ",")
+	}
+	var kinds []topology.EdgeKind
+	if k := q.Get("kind"); k != "" {
+		for _, kind := range strings.Split(k, ",") {
+			kinds = append(kinds, topology.EdgeKind(kind))
+		}
+	}
+	g := s.globalPushContext().Topology().Filter(namespaces, kinds)
+	format := topology.Format(q.Get("format"))
+	if format == "" || format == topology.JSON {
+		writeJSON(w, g, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	if err := topology.Write(w, g, format); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+	}
+}
+
 // connectionsHandler implements interface for displaying current connections.
 // It is mapped to /debug/connections.
 func (s *DiscoveryServer) connectionsHandler(w http.ResponseWriter, req *http.Request) {
@@ -1110,6 +1161,42 @@ func sortMCSServices(svcs []model.MCSServiceInfo) []model.MCSServiceInfo {
 	return svcs
 }
 
+// ambientz dumps the ambient index of each Kubernetes cluster, or of the one selected by the cluster query parameter.
+// The namespace and node query parameters restrict the dump to the objects in a namespace and on a node.
+// A dump can be attached to a bug report, and replayed in tests with kubecontroller.NewFakeAmbientController.
+func (s *DiscoveryServer) ambientz(w http.ResponseWriter, req *http.Request) {
+	ag, ok := s.Env.ServiceDiscovery.(*aggregate.Controller)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("service registries are not available\n"))
+		return
+	}
+	clusterID := cluster.ID(req.URL.Query().Get("cluster"))
+	namespace, node := req.URL.Query().Get("namespace"), req.URL.Query().Get("node")
+	snapshots := []*kubecontroller.AmbientSnapshot{}
+	for _, r := range ag.GetRegistries() {
+		if clusterID != "" && r.Cluster() != clusterID {
+			continue
+		}
+		kr, ok := r.(interface {
+			AmbientSnapshot() *kubecontroller.AmbientSnapshot
+		})
+		if !ok {
+			continue
+		}
+		if snapshot := kr.AmbientSnapshot(); snapshot != nil {
+			if namespace != "" || node != "" {
+				snapshot = snapshot.Filter(namespace, node)
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Cluster < snapshots[j].Cluster
+	})
+	writeJSON(w, snapshots, req)
+}
+
 func (s *DiscoveryServer) clusterz(w http.ResponseWriter, req *http.Request) {
 	if s.ListRemoteClusters == nil {
 		w.WriteHeader(http.StatusBadRequest)
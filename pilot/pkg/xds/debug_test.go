@@ -64,6 +64,16 @@ func TestSyncz(t *testing.T) {
 		})
 		node, _ := model.ParseServiceNodeWithMetadata(ads.ID, &model.NodeMetadata{})
 		verifySyncStatus(t, s.Discovery, node.ID, true, false)
+		for _, ss := range getSyncStatus(t, s.Discovery) {
+			if ss.ProxyID != node.ID {
+				continue
+			}
+			for _, typ := range []string{"CDS", "LDS", "EDS", "RDS"} {
+				if got := ss.Nacks[typ]; got != "Test request NACK" {
+					t.Errorf("wanted %s NACK message recorded, got %q", typ, got)
+				}
+			}
+		}
 	})
 	t.Run("sync ecds", func(t *testing.T) {
 		s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
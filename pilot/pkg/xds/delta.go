@@ -325,6 +325,7 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 		errCode := codes.Code(request.ErrorDetail.Code)
 		deltaLog.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.conID, errCode.String(), request.ErrorDetail.GetMessage())
 		incrementXDSRejects(request.TypeUrl, con.proxy.ID, errCode.String())
+		con.recordNack(request.TypeUrl, request.ErrorDetail.GetMessage())
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, deltaToSotwRequest(request))
 		}
@@ -390,6 +391,7 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 	previousResources := con.proxy.WatchedResources[request.TypeUrl].ResourceNames
 	deltaResources, _ := deltaWatchedResources(previousResources, request)
 	con.proxy.WatchedResources[request.TypeUrl].NonceAcked = request.ResponseNonce
+	con.proxy.WatchedResources[request.TypeUrl].NackError = ""
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = deltaResources
 	alwaysRespond := previousInfo.AlwaysRespond
 	previousInfo.AlwaysRespond = false
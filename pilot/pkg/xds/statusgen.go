@@ -17,6 +17,7 @@ package xds
 import (
 	"fmt"
 
+	admin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	status "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
@@ -133,6 +134,9 @@ func (sg *StatusGen) debugSyncz() model.Resources {
 				pxc := &status.ClientConfig_GenericXdsConfig{}
 				if watchedResource, ok := con.proxy.WatchedResources[stype]; ok {
 					pxc.ConfigStatus = debugSyncStatus(watchedResource)
+					if watchedResource.NackError != "" {
+						pxc.ErrorState = &admin.UpdateFailureState{Details: watchedResource.NackError}
+					}
 				} else if isZtunnel(con) {
 					pxc.ConfigStatus = status.ConfigStatus_UNKNOWN
 				} else {
@@ -236,6 +236,10 @@ var (
 	// InvalidGatewayCredential defines a diag.MessageType for message "InvalidGatewayCredential".
 	// Description: The credential provided for the Gateway resource is invalid
 	InvalidGatewayCredential = diag.NewMessageType(diag.Error, "IST0161", "The credential referenced by the Gateway %s in namespace %s is invalid, which can cause the traffic not to work as expected.")
+
+	// UpgradeBehaviorChange defines a diag.MessageType for message "UpgradeBehaviorChange".
+	// Description: The behavior of the configuration changes when upgrading to the target Istio version
+	UpgradeBehaviorChange = diag.NewMessageType(diag.Warning, "IST0162", "The behavior of %s changes when upgrading to Istio %s: %s")
 )
 
 // All returns a list of all known message types.
@@ -298,6 +302,7 @@ func All() []*diag.MessageType {
 		ConflictingTelemetryWorkloadSelectors,
 		MultipleTelemetriesWithoutWorkloadSelectors,
 		InvalidGatewayCredential,
+		UpgradeBehaviorChange,
 	}
 }
 
@@ -860,3 +865,14 @@ func NewInvalidGatewayCredential(r *resource.Instance, gatewayName string, gatew
 		gatewayNamespace,
 	)
 }
+
+// NewUpgradeBehaviorChange returns a new diag.Message based on UpgradeBehaviorChange.
+func NewUpgradeBehaviorChange(r *resource.Instance, subject string, targetVersion string, detail string) diag.Message {
+	return diag.NewMessage(
+		UpgradeBehaviorChange,
+		r,
+		subject,
+		targetVersion,
+		detail,
+	)
+}
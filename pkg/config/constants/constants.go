@@ -175,4 +175,11 @@ const (
 	AmbientRedirectionEnabled = "enabled"
 	// AmbientRedirectionDisabled is an opt-out, configured by user.
 	AmbientRedirectionDisabled = "disabled"
+	// AmbientRedirectionStatus records, as JSON, the outcome of the last attempt of the CNI node agent to configure
+	// redirection for a pod: the redirection mode, the ztunnel pod, the error if it failed, and when it happened.
+	AmbientRedirectionStatus = "ambient.istio.io/redirection-status"
+
+	// SidecarInterceptionBackend selects how istio-cni redirects the traffic of a sidecar pod to its proxy:
+	// "iptables" or "nftables". It overrides the backend configured for the node.
+	SidecarInterceptionBackend = "sidecar.istio.io/interceptionBackend"
 )
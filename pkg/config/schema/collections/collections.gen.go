@@ -11,10 +11,12 @@ import (
 
 	k8sioapiadmissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	k8sioapiappsv1 "k8s.io/api/apps/v1"
+	k8sioapiautoscalingv2 "k8s.io/api/autoscaling/v2"
 	k8sioapicertificatesv1 "k8s.io/api/certificates/v1"
 	k8sioapicorev1 "k8s.io/api/core/v1"
 	k8sioapidiscoveryv1 "k8s.io/api/discovery/v1"
 	k8sioapinetworkingv1 "k8s.io/api/networking/v1"
+	k8sioapipolicyv1 "k8s.io/api/policy/v1"
 	k8sioapiextensionsapiserverpkgapisapiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	sigsk8siogatewayapiapisv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	sigsk8siogatewayapiapisv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
@@ -242,6 +244,21 @@ var (
 		ValidateProto: validation.ValidateHTTPRoute,
 	}.MustBuild()
 
+	HorizontalPodAutoscaler = resource.Builder{
+		Identifier:    "HorizontalPodAutoscaler",
+		Group:         "autoscaling",
+		Kind:          "HorizontalPodAutoscaler",
+		Plural:        "horizontalpodautoscalers",
+		Version:       "v2",
+		Proto:         "k8s.io.api.autoscaling.v2.HorizontalPodAutoscalerSpec",
+		ReflectType:   reflect.TypeOf(&k8sioapiautoscalingv2.HorizontalPodAutoscalerSpec{}).Elem(),
+		ProtoPackage:  "k8s.io/api/autoscaling/v2",
+		ClusterScoped: false,
+		Synthetic:     false,
+		Builtin:       true,
+		ValidateProto: validation.EmptyValidate,
+	}.MustBuild()
+
 	Ingress = resource.Builder{
 		Identifier: "Ingress",
 		Group:      "networking.k8s.io",
@@ -395,6 +412,21 @@ var (
 		ValidateProto: validation.EmptyValidate,
 	}.MustBuild()
 
+	PodDisruptionBudget = resource.Builder{
+		Identifier:    "PodDisruptionBudget",
+		Group:         "policy",
+		Kind:          "PodDisruptionBudget",
+		Plural:        "poddisruptionbudgets",
+		Version:       "v1",
+		Proto:         "k8s.io.api.policy.v1.PodDisruptionBudgetSpec",
+		ReflectType:   reflect.TypeOf(&k8sioapipolicyv1.PodDisruptionBudgetSpec{}).Elem(),
+		ProtoPackage:  "k8s.io/api/policy/v1",
+		ClusterScoped: false,
+		Synthetic:     false,
+		Builtin:       true,
+		ValidateProto: validation.EmptyValidate,
+	}.MustBuild()
+
 	ProxyConfig = resource.Builder{
 		Identifier: "ProxyConfig",
 		Group:      "networking.istio.io",
@@ -686,6 +718,7 @@ var (
 		MustAdd(Gateway).
 		MustAdd(GatewayClass).
 		MustAdd(HTTPRoute).
+		MustAdd(HorizontalPodAutoscaler).
 		MustAdd(Ingress).
 		MustAdd(IngressClass).
 		MustAdd(KubernetesGateway).
@@ -696,6 +729,7 @@ var (
 		MustAdd(Node).
 		MustAdd(PeerAuthentication).
 		MustAdd(Pod).
+		MustAdd(PodDisruptionBudget).
 		MustAdd(ProxyConfig).
 		MustAdd(ReferenceGrant).
 		MustAdd(RequestAuthentication).
@@ -726,6 +760,7 @@ var (
 		MustAdd(GRPCRoute).
 		MustAdd(GatewayClass).
 		MustAdd(HTTPRoute).
+		MustAdd(HorizontalPodAutoscaler).
 		MustAdd(Ingress).
 		MustAdd(IngressClass).
 		MustAdd(KubernetesGateway).
@@ -733,6 +768,7 @@ var (
 		MustAdd(Namespace).
 		MustAdd(Node).
 		MustAdd(Pod).
+		MustAdd(PodDisruptionBudget).
 		MustAdd(ReferenceGrant).
 		MustAdd(Secret).
 		MustAdd(Service).
@@ -24,6 +24,7 @@ var (
 	Gateway                        = config.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"}
 	GatewayClass                   = config.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "GatewayClass"}
 	HTTPRoute                      = config.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "HTTPRoute"}
+	HorizontalPodAutoscaler        = config.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}
 	Ingress                        = config.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
 	IngressClass                   = config.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "IngressClass"}
 	KubernetesGateway              = config.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "Gateway"}
@@ -34,6 +35,7 @@ var (
 	Node                           = config.GroupVersionKind{Group: "", Version: "v1", Kind: "Node"}
 	PeerAuthentication             = config.GroupVersionKind{Group: "security.istio.io", Version: "v1beta1", Kind: "PeerAuthentication"}
 	Pod                            = config.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}
+	PodDisruptionBudget            = config.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}
 	ProxyConfig                    = config.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "ProxyConfig"}
 	ReferenceGrant                 = config.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "ReferenceGrant"}
 	RequestAuthentication          = config.GroupVersionKind{Group: "security.istio.io", Version: "v1beta1", Kind: "RequestAuthentication"}
@@ -82,6 +84,8 @@ func ToGVR(g config.GroupVersionKind) (schema.GroupVersionResource, bool) {
 		return gvr.GatewayClass, true
 	case HTTPRoute:
 		return gvr.HTTPRoute, true
+	case HorizontalPodAutoscaler:
+		return gvr.HorizontalPodAutoscaler, true
 	case Ingress:
 		return gvr.Ingress, true
 	case IngressClass:
@@ -102,6 +106,8 @@ func ToGVR(g config.GroupVersionKind) (schema.GroupVersionResource, bool) {
 		return gvr.PeerAuthentication, true
 	case Pod:
 		return gvr.Pod, true
+	case PodDisruptionBudget:
+		return gvr.PodDisruptionBudget, true
 	case ProxyConfig:
 		return gvr.ProxyConfig, true
 	case ReferenceGrant:
@@ -180,6 +186,8 @@ func FromGVR(g schema.GroupVersionResource) (config.GroupVersionKind, bool) {
 		return GatewayClass, true
 	case gvr.HTTPRoute:
 		return HTTPRoute, true
+	case gvr.HorizontalPodAutoscaler:
+		return HorizontalPodAutoscaler, true
 	case gvr.Ingress:
 		return Ingress, true
 	case gvr.IngressClass:
@@ -200,6 +208,8 @@ func FromGVR(g schema.GroupVersionResource) (config.GroupVersionKind, bool) {
 		return PeerAuthentication, true
 	case gvr.Pod:
 		return Pod, true
+	case gvr.PodDisruptionBudget:
+		return PodDisruptionBudget, true
 	case gvr.ProxyConfig:
 		return ProxyConfig, true
 	case gvr.ReferenceGrant:
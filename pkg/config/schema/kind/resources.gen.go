@@ -23,6 +23,7 @@ const (
 	Gateway
 	GatewayClass
 	HTTPRoute
+	HorizontalPodAutoscaler
 	Ingress
 	IngressClass
 	KubernetesGateway
@@ -33,6 +34,7 @@ const (
 	Node
 	PeerAuthentication
 	Pod
+	PodDisruptionBudget
 	ProxyConfig
 	ReferenceGrant
 	RequestAuthentication
@@ -82,6 +84,8 @@ func (k Kind) String() string {
 		return "GatewayClass"
 	case HTTPRoute:
 		return "HTTPRoute"
+	case HorizontalPodAutoscaler:
+		return "HorizontalPodAutoscaler"
 	case Ingress:
 		return "Ingress"
 	case IngressClass:
@@ -102,6 +106,8 @@ func (k Kind) String() string {
 		return "PeerAuthentication"
 	case Pod:
 		return "Pod"
+	case PodDisruptionBudget:
+		return "PodDisruptionBudget"
 	case ProxyConfig:
 		return "ProxyConfig"
 	case ReferenceGrant:
@@ -169,6 +175,8 @@ func MustFromGVK(g config.GroupVersionKind) Kind {
 		return GatewayClass
 	case gvk.HTTPRoute:
 		return HTTPRoute
+	case gvk.HorizontalPodAutoscaler:
+		return HorizontalPodAutoscaler
 	case gvk.Ingress:
 		return Ingress
 	case gvk.IngressClass:
@@ -189,6 +197,8 @@ func MustFromGVK(g config.GroupVersionKind) Kind {
 		return PeerAuthentication
 	case gvk.Pod:
 		return Pod
+	case gvk.PodDisruptionBudget:
+		return PodDisruptionBudget
 	case gvk.ProxyConfig:
 		return ProxyConfig
 	case gvk.ReferenceGrant:
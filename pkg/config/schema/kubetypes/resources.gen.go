@@ -8,10 +8,12 @@ import (
 
 	k8sioapiadmissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	k8sioapiappsv1 "k8s.io/api/apps/v1"
+	k8sioapiautoscalingv2 "k8s.io/api/autoscaling/v2"
 	k8sioapicertificatesv1 "k8s.io/api/certificates/v1"
 	k8sioapicorev1 "k8s.io/api/core/v1"
 	k8sioapidiscoveryv1 "k8s.io/api/discovery/v1"
 	k8sioapinetworkingv1 "k8s.io/api/networking/v1"
+	k8sioapipolicyv1 "k8s.io/api/policy/v1"
 	k8sioapiextensionsapiserverpkgapisapiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	sigsk8siogatewayapiapisv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -69,6 +71,8 @@ func GetGVK[T runtime.Object]() config.GroupVersionKind {
 		return gvk.GatewayClass
 	case *sigsk8siogatewayapiapisv1beta1.HTTPRoute:
 		return gvk.HTTPRoute
+	case *k8sioapiautoscalingv2.HorizontalPodAutoscaler:
+		return gvk.HorizontalPodAutoscaler
 	case *k8sioapinetworkingv1.Ingress:
 		return gvk.Ingress
 	case *k8sioapinetworkingv1.IngressClass:
@@ -91,6 +95,8 @@ func GetGVK[T runtime.Object]() config.GroupVersionKind {
 		return gvk.PeerAuthentication
 	case *k8sioapicorev1.Pod:
 		return gvk.Pod
+	case *k8sioapipolicyv1.PodDisruptionBudget:
+		return gvk.PodDisruptionBudget
 	case *istioioapinetworkingv1beta1.ProxyConfig:
 		return gvk.ProxyConfig
 	case *apiistioioapinetworkingv1beta1.ProxyConfig:
@@ -0,0 +1,291 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package match selects the listener, filter chain, virtual host and route Envoy uses for a connection or request,
+// following Envoy's matching rules. It is shared by the simulation tests of the generated config and by
+// istioctl, which explains requests against the config dump of a proxy.
+package match
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// virtualOutboundListenerName is the name of the sidecar listener receiving the outbound traffic that does not match
+// a listener bound to its destination.
+const virtualOutboundListenerName = "virtualOutbound"
+
+var (
+	ErrNoFilterChain       = errors.New("no filter chains matched")
+	ErrMultipleFilterChain = errors.New("multiple filter chains matched")
+)
+
+// Connection is what Envoy knows of a downstream connection when it selects a filter chain.
+type Connection struct {
+	// Address is the destination IP.
+	Address string
+	Port    int
+	// SNI is the server name read by the TLS inspector.
+	SNI string
+	// TransportProtocol is "tls" if the TLS inspector detected TLS, "raw_buffer" otherwise.
+	TransportProtocol string
+	// ALPN is the application protocol read by the TLS or HTTP inspector.
+	ALPN string
+}
+
+// Listener returns the listener receiving connections to address and port: a listener bound to them, then a
+// listener bound to the wildcard address on the port, and finally the sidecar virtual outbound listener. address
+// may be empty to only consider wildcard listeners.
+func Listener(listeners []*listener.Listener, address string, port int) *listener.Listener {
+	// There is no wildcard port
+	if address != "" {
+		for _, l := range listeners {
+			if matchAddress(l.GetAddress(), address, port) {
+				return l
+			}
+		}
+	}
+	for _, l := range listeners {
+		if matchAddress(l.GetAddress(), "0.0.0.0", port) || matchAddress(l.GetAddress(), "::", port) {
+			return l
+		}
+	}
+	for _, l := range listeners {
+		if l.GetName() == virtualOutboundListenerName {
+			return l
+		}
+	}
+	return nil
+}
+
+func matchAddress(a *core.Address, address string, port int) bool {
+	return a.GetSocketAddress().GetAddress() == address && int(a.GetSocketAddress().GetPortValue()) == port
+}
+
+// HasFilterOnPort reports whether the listener filter runs for connections to port.
+func HasFilterOnPort(l *listener.Listener, filter string, port int) bool {
+	for _, lf := range l.GetListenerFilters() {
+		if lf.GetName() != filter {
+			continue
+		}
+		if lf.GetFilterDisabled() == nil {
+			return true
+		}
+		return !evaluateListenerFilterPredicate(lf.GetFilterDisabled(), port)
+	}
+	return false
+}
+
+func evaluateListenerFilterPredicate(predicate *listener.ListenerFilterChainMatchPredicate, port int) bool {
+	if predicate == nil {
+		return true
+	}
+	switch r := predicate.Rule.(type) {
+	case *listener.ListenerFilterChainMatchPredicate_NotMatch:
+		return !evaluateListenerFilterPredicate(r.NotMatch, port)
+	case *listener.ListenerFilterChainMatchPredicate_OrMatch:
+		for _, r := range r.OrMatch.GetRules() {
+			if evaluateListenerFilterPredicate(r, port) {
+				return true
+			}
+		}
+		return false
+	case *listener.ListenerFilterChainMatchPredicate_AndMatch:
+		for _, r := range r.AndMatch.GetRules() {
+			if !evaluateListenerFilterPredicate(r, port) {
+				return false
+			}
+		}
+		return true
+	case *listener.ListenerFilterChainMatchPredicate_DestinationPortRange:
+		return int32(port) >= r.DestinationPortRange.GetStart() && int32(port) < r.DestinationPortRange.GetEnd()
+	case *listener.ListenerFilterChainMatchPredicate_AnyMatch:
+		return r.AnyMatch
+	default:
+		return false
+	}
+}
+
+// FilterChain returns the filter chain selected for the connection, or the default filter chain if none matches.
+func FilterChain(chains []*listener.FilterChain, defaultChain *listener.FilterChain, c Connection) (*listener.FilterChain, error) {
+	chains = FilterChains(chains, c)
+	if len(chains) > 1 {
+		return nil, ErrMultipleFilterChain
+	}
+	if len(chains) == 0 {
+		if defaultChain != nil {
+			return defaultChain, nil
+		}
+		return nil, ErrNoFilterChain
+	}
+	return chains[0], nil
+}
+
+// FilterChains returns the filter chains matching the connection. A valid listener has at most one.
+//
+// Follow the 8 step Sieve as in
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/listener/v3/listener_components.proto.html#config-listener-v3-filterchainmatch
+// The implementation may initially be confusing because of a property of the
+// Envoy algorithm - at each level we will filter out all FilterChains that do
+// not match. This means an empty match (`{}`) may not match if another chain
+// matches one criteria but not another.
+func FilterChains(chains []*listener.FilterChain, c Connection) []*listener.FilterChain {
+	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetDestinationPort() == nil
+	}, func(fc *listener.FilterChainMatch) bool {
+		return int(fc.GetDestinationPort().GetValue()) == c.Port
+	})
+	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetPrefixRanges() == nil
+	}, func(fc *listener.FilterChainMatch) bool {
+		addr, err := netip.ParseAddr(c.Address)
+		if err != nil {
+			return false
+		}
+		for _, r := range fc.GetPrefixRanges() {
+			prefix, err := netip.ParsePrefix(fmt.Sprintf("%s/%d", r.GetAddressPrefix(), r.GetPrefixLen().GetValue()))
+			if err == nil && prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	})
+	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetServerNames() == nil
+	}, func(fc *listener.FilterChainMatch) bool {
+		sni := host.Name(c.SNI)
+		for _, s := range fc.GetServerNames() {
+			if sni.SubsetOf(host.Name(s)) {
+				return true
+			}
+		}
+		return false
+	})
+	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetTransportProtocol() == ""
+	}, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetTransportProtocol() == c.TransportProtocol
+	})
+	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
+		return fc.GetApplicationProtocols() == nil
+	}, func(fc *listener.FilterChainMatch) bool {
+		return sets.New(fc.GetApplicationProtocols()...).Contains(c.ALPN)
+	})
+	// Source based matches are not used by Istio, so they are not implemented.
+	return chains
+}
+
+func filter(chains []*listener.FilterChain,
+	empty func(fc *listener.FilterChainMatch) bool,
+	match func(fc *listener.FilterChainMatch) bool,
+) []*listener.FilterChain {
+	res := []*listener.FilterChain{}
+	anySet := false
+	for _, c := range chains {
+		if !empty(c.GetFilterChainMatch()) {
+			anySet = true
+			break
+		}
+	}
+	if !anySet {
+		return chains
+	}
+	for _, c := range chains {
+		if match(c.GetFilterChainMatch()) {
+			res = append(res, c)
+		}
+	}
+	// Return all matching filter chains
+	if len(res) > 0 {
+		return res
+	}
+	// Unless there were no matches - in which case we return all filter chains that did not have a
+	// match set
+	for _, c := range chains {
+		if empty(c.GetFilterChainMatch()) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// VirtualHost returns the virtual host of the route configuration serving the host header: an exact domain match,
+// then the longest suffix and prefix wildcard domains, then the "*" domain.
+func VirtualHost(rc *route.RouteConfiguration, hostHeader string) *route.VirtualHost {
+	if rc.GetIgnorePortInHostMatching() {
+		if h, _, err := net.SplitHostPort(hostHeader); err == nil {
+			hostHeader = h
+		}
+	}
+	// Exact match
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.GetDomains() {
+			if d == hostHeader {
+				return vh
+			}
+		}
+	}
+	// Suffix match, such as *.example.com
+	var bestMatch *route.VirtualHost
+	longest := 0
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.GetDomains() {
+			if d == "" || d == "*" || d[0] != '*' {
+				continue
+			}
+			if len(hostHeader) >= len(d) && strings.HasSuffix(hostHeader, d[1:]) && len(d) > longest {
+				bestMatch = vh
+				longest = len(d)
+			}
+		}
+	}
+	if bestMatch != nil {
+		return bestMatch
+	}
+	// Prefix match, such as example.*
+	longest = 0
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.GetDomains() {
+			if d == "" || d[len(d)-1] != '*' {
+				continue
+			}
+			if len(hostHeader) >= len(d) && strings.HasPrefix(hostHeader, d[:len(d)-1]) && len(d) > longest {
+				bestMatch = vh
+				longest = len(d)
+			}
+		}
+	}
+	if bestMatch != nil {
+		return bestMatch
+	}
+	// wildcard match
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.GetDomains() {
+			if d == "*" {
+				return vh
+			}
+		}
+	}
+	return nil
+}
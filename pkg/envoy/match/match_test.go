@@ -0,0 +1,172 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package match
+
+import (
+	"net/http"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func socketListener(name, address string, port uint32) *listener.Listener {
+	return &listener.Listener{
+		Name: name,
+		Address: &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{
+			Address:       address,
+			PortSpecifier: &core.SocketAddress_PortValue{PortValue: port},
+		}}},
+	}
+}
+
+func TestListener(t *testing.T) {
+	listeners := []*listener.Listener{
+		socketListener("10.0.0.1_80", "10.0.0.1", 80),
+		socketListener("0.0.0.0_80", "0.0.0.0", 80),
+		socketListener("::_8080", "::", 8080),
+		{Name: virtualOutboundListenerName},
+	}
+	cases := []struct {
+		address string
+		port    int
+		want    string
+	}{
+		{"10.0.0.1", 80, "10.0.0.1_80"},
+		{"10.0.0.2", 80, "0.0.0.0_80"},
+		{"", 80, "0.0.0.0_80"},
+		{"10.0.0.1", 8080, "::_8080"},
+		{"10.0.0.1", 443, virtualOutboundListenerName},
+	}
+	for _, tt := range cases {
+		assert.Equal(t, Listener(listeners, tt.address, tt.port).GetName(), tt.want)
+	}
+}
+
+func TestFilterChain(t *testing.T) {
+	chains := []*listener.FilterChain{
+		{Name: "tls", FilterChainMatch: &listener.FilterChainMatch{TransportProtocol: "tls", ServerNames: []string{"*.example.com"}}},
+		{Name: "http", FilterChainMatch: &listener.FilterChainMatch{ApplicationProtocols: []string{"http/1.1", "h2c"}}},
+		{Name: "tcp", FilterChainMatch: &listener.FilterChainMatch{}},
+	}
+	fallback := &listener.FilterChain{Name: "default"}
+	cases := []struct {
+		name string
+		conn Connection
+		want string
+		err  error
+	}{
+		{"sni", Connection{TransportProtocol: "tls", SNI: "a.example.com"}, "tls", nil},
+		{"unknown sni", Connection{TransportProtocol: "tls", SNI: "a.other.com"}, "tcp", nil},
+		{"http", Connection{TransportProtocol: "raw_buffer", ALPN: "h2c"}, "http", nil},
+		{"tcp", Connection{TransportProtocol: "raw_buffer"}, "tcp", nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, err := FilterChain(chains, fallback, tt.conn)
+			assert.Equal(t, err, tt.err)
+			assert.Equal(t, fc.GetName(), tt.want)
+		})
+	}
+
+	ambiguous := []*listener.FilterChain{{Name: "a"}, {Name: "b"}}
+	_, err := FilterChain(ambiguous, nil, Connection{})
+	assert.Equal(t, err, ErrMultipleFilterChain)
+	assert.Equal(t, len(FilterChains(ambiguous, Connection{})), 2)
+
+	ports := []*listener.FilterChain{{Name: "8080", FilterChainMatch: &listener.FilterChainMatch{
+		DestinationPort: wrapperspb.UInt32(8080),
+	}}}
+	fc, err := FilterChain(ports, fallback, Connection{Port: 80})
+	assert.NoError(t, err)
+	assert.Equal(t, fc.GetName(), "default")
+	_, err = FilterChain(ports, nil, Connection{Port: 80})
+	assert.Equal(t, err, ErrNoFilterChain)
+}
+
+func TestVirtualHost(t *testing.T) {
+	rc := &route.RouteConfiguration{
+		IgnorePortInHostMatching: true,
+		VirtualHosts: []*route.VirtualHost{
+			{Name: "exact", Domains: []string{"reviews.default.svc.cluster.local"}},
+			{Name: "suffix", Domains: []string{"*.default.svc.cluster.local"}},
+			{Name: "prefix", Domains: []string{"reviews.*"}},
+			{Name: "wildcard", Domains: []string{"*"}},
+		},
+	}
+	for host, want := range map[string]string{
+		"reviews.default.svc.cluster.local:9080": "exact",
+		"ratings.default.svc.cluster.local":      "suffix",
+		"reviews.other":                          "prefix",
+		"example.com":                            "wildcard",
+	} {
+		assert.Equal(t, VirtualHost(rc, host).GetName(), want)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	vh := &route.VirtualHost{Routes: []*route.Route{
+		{Name: "regex", Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_SafeRegex{
+			SafeRegex: &matcher.RegexMatcher{Regex: "/api/v[0-9]+"},
+		}}},
+		{Name: "separated", Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_PathSeparatedPrefix{
+			PathSeparatedPrefix: "/static",
+		}}},
+		{Name: "header", Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+			Headers: []*route.HeaderMatcher{{
+				Name: "end-user",
+				HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+					StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: "jason"}},
+				},
+			}},
+		}},
+		{Name: "query", Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+			QueryParameters: []*route.QueryParameterMatcher{{
+				Name:                         "debug",
+				QueryParameterMatchSpecifier: &route.QueryParameterMatcher_PresentMatch{PresentMatch: true},
+			}},
+		}},
+		{Name: "default", Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}},
+	}}
+	cases := []struct {
+		name    string
+		path    string
+		headers http.Header
+		want    string
+	}{
+		{"regex", "/api/v2", nil, "regex"},
+		// Regular expressions match the whole path.
+		{"regex prefix", "/api/v2/users", nil, "default"},
+		{"separated prefix", "/static/app.js", nil, "separated"},
+		{"separated prefix exact", "/static", nil, "separated"},
+		{"separated prefix partial segment", "/statics", nil, "default"},
+		{"header", "/", http.Header{"End-User": {"jason"}}, "header"},
+		{"other header value", "/", http.Header{"End-User": {"mary"}}, "default"},
+		{"query", "/?debug=1", nil, "query"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Route(vh, Request{Path: tt.path, Method: http.MethodGet, Headers: tt.headers})
+			assert.Equal(t, r.GetName(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package match
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+)
+
+// Request is what route matching sees of an HTTP request.
+type Request struct {
+	Host string
+	// Path is the request path, including the query string.
+	Path    string
+	Method  string
+	Headers http.Header
+	// TLS is set for https requests.
+	TLS bool
+}
+
+// Route returns the first route in the virtual host matching the path, headers and query parameters of req.
+func Route(vh *route.VirtualHost, req Request) *route.Route {
+	path, rawQuery, _ := strings.Cut(req.Path, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	for _, r := range vh.GetRoutes() {
+		m := r.GetMatch()
+		if !matchPath(m, path) {
+			continue
+		}
+		if !matchHeaders(m.GetHeaders(), req) {
+			continue
+		}
+		if !matchQueryParameters(m.GetQueryParameters(), query) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+func matchPath(m *route.RouteMatch, path string) bool {
+	caseSensitive := m.GetCaseSensitive() == nil || m.GetCaseSensitive().GetValue()
+	compare := path
+	if !caseSensitive {
+		compare = strings.ToLower(path)
+	}
+	lower := func(s string) string {
+		if caseSensitive {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	switch pt := m.GetPathSpecifier().(type) {
+	case *route.RouteMatch_Prefix:
+		return strings.HasPrefix(compare, lower(pt.Prefix))
+	case *route.RouteMatch_Path:
+		return compare == lower(pt.Path)
+	case *route.RouteMatch_PathSeparatedPrefix:
+		prefix := lower(pt.PathSeparatedPrefix)
+		return compare == prefix || strings.HasPrefix(compare, prefix+"/")
+	case *route.RouteMatch_SafeRegex:
+		return matchRegex(pt.SafeRegex.GetRegex(), path)
+	default:
+		// Connect and URI template matchers are not generated by Istio.
+		return false
+	}
+}
+
+func matchHeaders(headers []*route.HeaderMatcher, req Request) bool {
+	for _, h := range headers {
+		value, present := headerValue(h.GetName(), req)
+		if !present && h.GetTreatMissingHeaderAsEmpty() {
+			value, present = "", true
+		}
+		if matchHeader(h, value, present) == h.GetInvertMatch() {
+			return false
+		}
+	}
+	return true
+}
+
+func matchHeader(h *route.HeaderMatcher, value string, present bool) bool {
+	switch hm := h.GetHeaderMatchSpecifier().(type) {
+	case *route.HeaderMatcher_PresentMatch:
+		return present == hm.PresentMatch
+	case nil:
+		return present
+	}
+	if !present {
+		return false
+	}
+	switch hm := h.GetHeaderMatchSpecifier().(type) {
+	case *route.HeaderMatcher_ExactMatch:
+		return value == hm.ExactMatch
+	case *route.HeaderMatcher_PrefixMatch:
+		return strings.HasPrefix(value, hm.PrefixMatch)
+	case *route.HeaderMatcher_SuffixMatch:
+		return strings.HasSuffix(value, hm.SuffixMatch)
+	case *route.HeaderMatcher_ContainsMatch:
+		return strings.Contains(value, hm.ContainsMatch)
+	case *route.HeaderMatcher_SafeRegexMatch:
+		return matchRegex(hm.SafeRegexMatch.GetRegex(), value)
+	case *route.HeaderMatcher_RangeMatch:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && v >= hm.RangeMatch.GetStart() && v < hm.RangeMatch.GetEnd()
+	case *route.HeaderMatcher_StringMatch:
+		return matchString(hm.StringMatch, value)
+	default:
+		return false
+	}
+}
+
+// headerValue returns the value of a request header, including the HTTP/2 pseudo headers.
+func headerValue(name string, req Request) (string, bool) {
+	switch name {
+	case ":authority", "host":
+		return req.Host, req.Host != ""
+	case ":method":
+		return req.Method, true
+	case ":path":
+		return req.Path, true
+	case ":scheme":
+		if req.TLS {
+			return "https", true
+		}
+		return "http", true
+	}
+	values := req.Headers.Values(name)
+	if len(values) == 0 {
+		return "", false
+	}
+	// Envoy matches repeated headers against their values joined with a comma.
+	return strings.Join(values, ","), true
+}
+
+func matchQueryParameters(params []*route.QueryParameterMatcher, query url.Values) bool {
+	for _, p := range params {
+		values, present := query[p.GetName()]
+		switch qm := p.GetQueryParameterMatchSpecifier().(type) {
+		case *route.QueryParameterMatcher_PresentMatch:
+			if present != qm.PresentMatch {
+				return false
+			}
+		case *route.QueryParameterMatcher_StringMatch:
+			if !present || !matchString(qm.StringMatch, values[0]) {
+				return false
+			}
+		default:
+			if !present {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchString(m *matcher.StringMatcher, value string) bool {
+	if m.GetIgnoreCase() {
+		value = strings.ToLower(value)
+	}
+	lower := func(s string) string {
+		if m.GetIgnoreCase() {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch sm := m.GetMatchPattern().(type) {
+	case *matcher.StringMatcher_Exact:
+		return value == lower(sm.Exact)
+	case *matcher.StringMatcher_Prefix:
+		return strings.HasPrefix(value, lower(sm.Prefix))
+	case *matcher.StringMatcher_Suffix:
+		return strings.HasSuffix(value, lower(sm.Suffix))
+	case *matcher.StringMatcher_Contains:
+		return strings.Contains(value, lower(sm.Contains))
+	case *matcher.StringMatcher_SafeRegex:
+		return matchRegex(sm.SafeRegex.GetRegex(), value)
+	default:
+		return false
+	}
+}
+
+// matchRegex reports whether the whole value matches the RE2 expression, as Envoy's safe regex matchers do.
+func matchRegex(expr, value string) bool {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(value)
+}
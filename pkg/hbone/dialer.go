@@ -87,7 +87,7 @@ func (d *dialer) DialContext(ctx context.Context, network, address string) (net.
 	}
 	// TODO: use context
 	c, s := net.Pipe()
-	err := d.proxyTo(s, d.cfg, address)
+	err := proxyTo(d.transport, s, d.cfg, address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -98,8 +98,13 @@ func (d dialer) Dial(network, address string) (c net.Conn, err error) {
 	return d.DialContext(context.Background(), network, address)
 }
 
-func (d *dialer) proxyTo(conn io.ReadWriteCloser, req Config, address string) error {
+// proxyTo sends a CONNECT request for `address` with rt, and copies data between the stream and conn until either
+// is closed. done, if set, is called once the stream is over, or if it could not be established.
+func proxyTo(rt http.RoundTripper, conn io.ReadWriteCloser, req Config, address string, done func()) error {
 	t0 := time.Now()
+	if done == nil {
+		done = func() {}
+	}
 
 	url := "http://" + req.ProxyAddress
 	if req.TLS != nil {
@@ -111,6 +116,7 @@ func (d *dialer) proxyTo(conn io.ReadWriteCloser, req Config, address string) er
 	pr, pw := io.Pipe()
 	r, err := http.NewRequest(http.MethodConnect, url, pr)
 	if err != nil {
+		done()
 		return fmt.Errorf("new request: %v", err)
 	}
 	r.Host = address
@@ -118,8 +124,10 @@ func (d *dialer) proxyTo(conn io.ReadWriteCloser, req Config, address string) er
 	// Initiate CONNECT.
 	log.Infof("initiate CONNECT to %v via %v", r.Host, url)
 
-	resp, err := d.transport.RoundTrip(r)
+	resp, err := rt.RoundTrip(r)
 	if err != nil {
+		_ = pw.Close()
+		done()
 		return fmt.Errorf("round trip: %v", err)
 	}
 	var remoteID string
@@ -130,10 +138,14 @@ func (d *dialer) proxyTo(conn io.ReadWriteCloser, req Config, address string) er
 		}
 	}
 	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		_ = pw.Close()
+		done()
 		return fmt.Errorf("round trip failed: %v", resp.Status)
 	}
 	log.WithLabels("host", r.Host, "remote", remoteID).Info("CONNECT established")
 	go func() {
+		defer done()
 		defer conn.Close()
 		defer resp.Body.Close()
 
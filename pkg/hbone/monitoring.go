@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbone
+
+import (
+	"go.uber.org/atomic"
+
+	"istio.io/istio/pkg/monitoring"
+)
+
+var (
+	resultTag     = monitoring.MustCreateLabel("result")
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	connectionTag    = monitoring.MustCreateLabel("connection")
+	connectionNew    = "new"
+	connectionReused = "reused"
+
+	// openConns and streamsInUse hold the values of the gauges, shared by every pool.
+	openConns    = atomic.NewInt64(0)
+	streamsInUse = atomic.NewInt64(0)
+
+	poolConnections = monitoring.NewSum(
+		"hbone_pool_connections_total",
+		"Total number of connections opened to HBONE proxies by pooled dialers.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	openConnections = monitoring.NewGauge(
+		"hbone_pool_open_connections",
+		"Number of pooled connections to HBONE proxies currently open.",
+	)
+
+	poolStreams = monitoring.NewSum(
+		"hbone_pool_streams_total",
+		"Total number of CONNECT streams opened by pooled dialers, by whether they opened a new connection.",
+		monitoring.WithLabels(connectionTag),
+	)
+
+	activeStreams = monitoring.NewGauge(
+		"hbone_pool_active_streams",
+		"Number of CONNECT streams currently open over pooled connections.",
+	)
+
+	poolIdleCloses = monitoring.NewSum(
+		"hbone_pool_idle_closes_total",
+		"Total number of pooled connections closed after their idle timeout.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(poolConnections, openConnections, poolStreams, activeStreams, poolIdleCloses)
+}
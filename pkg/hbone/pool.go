@@ -0,0 +1,321 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbone
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// DefaultPoolIdleTimeout is the time a pooled connection is kept open without any stream, unless configured.
+const DefaultPoolIdleTimeout = 90 * time.Second
+
+var errPoolClosed = errors.New("hbone: connection pool closed")
+
+// PoolConfig configures the connections shared by the dialers of a Pool.
+type PoolConfig struct {
+	// MaxStreamsPerConn limits the number of CONNECT streams multiplexed over a single connection. Once every
+	// connection reaches the limit, a new one is opened. When 0, only the limit advertised by the server applies.
+	MaxStreamsPerConn int
+	// IdleTimeout is the time a connection without streams is kept open. Defaults to DefaultPoolIdleTimeout.
+	IdleTimeout time.Duration
+}
+
+// Pool multiplexes the CONNECT streams of its dialers over shared HTTP/2 connections. Connections are shared by
+// the dialers with the same proxy address and TLS identity: the client certificate and the server name.
+type Pool struct {
+	cfg       PoolConfig
+	transport *http2.Transport
+
+	mu     sync.Mutex
+	conns  map[poolKey][]*pooledConn
+	closed bool
+}
+
+type poolKey struct {
+	proxyAddress string
+	identity     string
+}
+
+type pooledConn struct {
+	key poolKey
+	cc  *http2.ClientConn
+	// streams is the number of streams using the connection, guarded by the pool lock.
+	streams int
+	idle    *time.Timer
+}
+
+// NewPool creates a connection pool. Close should be called once the dialers of the pool are no longer used.
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultPoolIdleTimeout
+	}
+	return &Pool{
+		cfg: cfg,
+		transport: &http2.Transport{
+			// Connections are opened by the pool, which also handles plaintext proxies (h2c).
+			AllowHTTP: true,
+		},
+		conns: map[poolKey][]*pooledConn{},
+	}
+}
+
+// NewDialer creates a Dialer that proxies connections over HBONE to the configured proxy, reusing the connections
+// of the pool.
+func (p *Pool) NewDialer(cfg Config) Dialer {
+	return &pooledDialer{
+		pool: p,
+		cfg:  cfg,
+		key:  poolKey{proxyAddress: cfg.ProxyAddress, identity: tlsIdentity(cfg.TLS)},
+	}
+}
+
+// Close closes every pooled connection. Streams in progress are interrupted, and further dials fail.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			p.closeLocked(pc)
+		}
+	}
+	p.conns = map[poolKey][]*pooledConn{}
+	return nil
+}
+
+// acquire returns a connection with room for one more stream, opening one if needed. The stream must be released
+// once it is over.
+func (p *Pool) acquire(ctx context.Context, d *pooledDialer) (*pooledConn, error) {
+	if pc, err := p.reserve(d.key); pc != nil || err != nil {
+		if pc != nil {
+			poolStreams.With(connectionTag.Value(connectionReused)).Increment()
+		}
+		return pc, err
+	}
+	// Concurrent dials may each open a connection. They are all pooled, and the extra ones expire once idle.
+	conn, err := dialProxy(ctx, d.cfg)
+	if err != nil {
+		poolConnections.With(resultTag.Value(resultFailure)).Increment()
+		return nil, err
+	}
+	cc, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		poolConnections.With(resultTag.Value(resultFailure)).Increment()
+		return nil, fmt.Errorf("http2 client connection: %v", err)
+	}
+	poolConnections.With(resultTag.Value(resultSuccess)).Increment()
+	pc := &pooledConn{key: d.key, cc: cc}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		_ = cc.Close()
+		return nil, errPoolClosed
+	}
+	if !cc.ReserveNewRequest() {
+		_ = cc.Close()
+		return nil, fmt.Errorf("new connection to %v cannot take requests", d.cfg.ProxyAddress)
+	}
+	pc.streams++
+	p.conns[d.key] = append(p.conns[d.key], pc)
+	openConnections.Record(float64(openConns.Inc()))
+	activeStreams.Record(float64(streamsInUse.Inc()))
+	poolStreams.With(connectionTag.Value(connectionNew)).Increment()
+	return pc, nil
+}
+
+// reserve returns a pooled connection with room for one more stream, or nil if there is none.
+func (p *Pool) reserve(key poolKey) (*pooledConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errPoolClosed
+	}
+	conns := p.conns[key][:0]
+	var found *pooledConn
+	for _, pc := range p.conns[key] {
+		if pc.cc.State().Closed {
+			// The connection was closed by the server or failed; its streams, if any, are already over.
+			p.closeLocked(pc)
+			continue
+		}
+		conns = append(conns, pc)
+		if found != nil || (p.cfg.MaxStreamsPerConn > 0 && pc.streams >= p.cfg.MaxStreamsPerConn) {
+			continue
+		}
+		// Reserving a stream also checks the limit of the server, and whether the connection is going away.
+		if pc.cc.ReserveNewRequest() {
+			found = pc
+		}
+	}
+	if len(conns) == 0 {
+		delete(p.conns, key)
+	} else {
+		p.conns[key] = conns
+	}
+	if found != nil {
+		found.streams++
+		activeStreams.Record(float64(streamsInUse.Inc()))
+		if found.idle != nil {
+			found.idle.Stop()
+			found.idle = nil
+		}
+	}
+	return found, nil
+}
+
+// release is called once a stream is over. Connections without streams are closed after the idle timeout.
+func (p *Pool) release(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc.streams--
+	activeStreams.Record(float64(streamsInUse.Dec()))
+	if pc.streams > 0 || p.closed {
+		return
+	}
+	if pc.idle != nil {
+		pc.idle.Stop()
+	}
+	pc.idle = time.AfterFunc(p.cfg.IdleTimeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if pc.streams > 0 {
+			return
+		}
+		conns := p.conns[pc.key]
+		for i, c := range conns {
+			if c == pc {
+				p.conns[pc.key] = append(conns[:i:i], conns[i+1:]...)
+				if len(p.conns[pc.key]) == 0 {
+					delete(p.conns, pc.key)
+				}
+				p.closeLocked(pc)
+				poolIdleCloses.Increment()
+				return
+			}
+		}
+	})
+}
+
+// closeLocked closes a connection that is being removed from the pool.
+func (p *Pool) closeLocked(pc *pooledConn) {
+	if pc.idle != nil {
+		pc.idle.Stop()
+		pc.idle = nil
+	}
+	_ = pc.cc.Close()
+	openConnections.Record(float64(openConns.Dec()))
+}
+
+type pooledDialer struct {
+	pool *Pool
+	cfg  Config
+	key  poolKey
+}
+
+// DialContext connects to `address` via the HBONE proxy, over a pooled connection.
+func (d *pooledDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return net.Dial(network, address)
+	}
+	pc, err := d.pool.acquire(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	c, s := net.Pipe()
+	var once sync.Once
+	err = proxyTo(pc.cc, s, d.cfg, address, func() {
+		once.Do(func() { d.pool.release(pc) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *pooledDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// dialProxy opens a connection to the proxy, negotiating HTTP/2 over TLS if configured.
+func dialProxy(ctx context.Context, cfg Config) (net.Conn, error) {
+	d := &net.Dialer{}
+	if cfg.Timeout != nil {
+		d.Timeout = *cfg.Timeout
+	}
+	if cfg.TLS == nil {
+		return d.DialContext(ctx, "tcp", cfg.ProxyAddress)
+	}
+	tlsCfg := cfg.TLS.Clone()
+	tlsCfg.NextProtos = []string{http2.NextProtoTLS}
+	if tlsCfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(cfg.ProxyAddress)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ServerName = host
+	}
+	conn, err := (&tls.Dialer{NetDialer: d, Config: tlsCfg}).DialContext(ctx, "tcp", cfg.ProxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if proto := conn.(*tls.Conn).ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %v negotiated protocol %q, expected %q", cfg.ProxyAddress, proto, http2.NextProtoTLS)
+	}
+	return conn, nil
+}
+
+// tlsIdentity identifies the connections a TLS configuration would open: the identities of its client
+// certificate, and the server name and roots it verifies. Configurations providing certificates through callbacks cannot
+// be compared, so each one gets its own connections.
+func tlsIdentity(cfg *tls.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	if cfg.GetClientCertificate != nil || cfg.VerifyPeerCertificate != nil || cfg.VerifyConnection != nil {
+		return fmt.Sprintf("%p", cfg)
+	}
+	var ids []string
+	if len(cfg.Certificates) > 0 && len(cfg.Certificates[0].Certificate) > 0 {
+		leaf := cfg.Certificates[0].Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(cfg.Certificates[0].Certificate[0]); err != nil {
+				return fmt.Sprintf("%p", cfg)
+			}
+		}
+		ids, _ = util.ExtractIDs(leaf.Extensions)
+		if len(ids) == 0 {
+			ids = []string{leaf.Subject.String()}
+		}
+		sort.Strings(ids)
+	}
+	return fmt.Sprintf("tls;server=%s;roots=%p;insecure=%v;client=%s",
+		cfg.ServerName, cfg.RootCAs, cfg.InsecureSkipVerify, strings.Join(ids, ","))
+}
@@ -0,0 +1,190 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbone
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+// countingListener counts the connections accepted by a listener.
+type countingListener struct {
+	net.Listener
+	accepted *atomic.Int32
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Inc()
+	}
+	return c, err
+}
+
+// newCountingHBONEServer starts an HBONE server, and returns its address along with the number of connections it
+// accepted.
+func newCountingHBONEServer(t *testing.T) (string, *atomic.Int32) {
+	s := NewServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := atomic.NewInt32(0)
+	go func() {
+		_ = s.Serve(countingListener{Listener: l, accepted: accepted})
+	}()
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return l.Addr().String(), accepted
+}
+
+// newEchoServer starts a TCP server writing back what it reads.
+func newEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	return l.Addr().String()
+}
+
+// echo sends a message over a new connection, and returns the connection once the message is echoed back.
+func echo(t *testing.T, d Dialer, address string) net.Conn {
+	t.Helper()
+	c, err := d.Dial("tcp", address)
+	assert.NoError(t, err)
+	go func() {
+		_, _ = c.Write([]byte("hello"))
+	}()
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(c, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, string(buf), "hello")
+	return c
+}
+
+func TestPoolMultiplexesStreams(t *testing.T) {
+	proxy, accepted := newCountingHBONEServer(t)
+	upstream := newEchoServer(t)
+	pool := NewPool(PoolConfig{})
+	t.Cleanup(func() { _ = pool.Close() })
+	d := pool.NewDialer(Config{ProxyAddress: proxy})
+
+	// Concurrent streams share a connection.
+	c1 := echo(t, d, upstream)
+	c2 := echo(t, d, upstream)
+	assert.Equal(t, accepted.Load(), int32(1))
+	_ = c1.Close()
+	_ = c2.Close()
+
+	// So do later ones, even from another dialer.
+	c3 := echo(t, pool.NewDialer(Config{ProxyAddress: proxy}), upstream)
+	_ = c3.Close()
+	assert.Equal(t, accepted.Load(), int32(1))
+}
+
+func TestPoolMaxStreams(t *testing.T) {
+	proxy, accepted := newCountingHBONEServer(t)
+	upstream := newEchoServer(t)
+	pool := NewPool(PoolConfig{MaxStreamsPerConn: 2})
+	t.Cleanup(func() { _ = pool.Close() })
+	d := pool.NewDialer(Config{ProxyAddress: proxy})
+
+	c1 := echo(t, d, upstream)
+	c2 := echo(t, d, upstream)
+	c3 := echo(t, d, upstream)
+	assert.Equal(t, accepted.Load(), int32(2))
+
+	// Once a stream is over, its connection has room for another one.
+	_ = c1.Close()
+	assert.EventuallyEqual(t, func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.conns[d.(*pooledDialer).key][0].streams
+	}, 1)
+	c4 := echo(t, d, upstream)
+	assert.Equal(t, accepted.Load(), int32(2))
+	for _, c := range []net.Conn{c2, c3, c4} {
+		_ = c.Close()
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	proxy, accepted := newCountingHBONEServer(t)
+	upstream := newEchoServer(t)
+	pool := NewPool(PoolConfig{IdleTimeout: 50 * time.Millisecond})
+	t.Cleanup(func() { _ = pool.Close() })
+	d := pool.NewDialer(Config{ProxyAddress: proxy})
+
+	_ = echo(t, d, upstream).Close()
+	assert.EventuallyEqual(t, func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.conns)
+	}, 0)
+	_ = echo(t, d, upstream).Close()
+	assert.Equal(t, accepted.Load(), int32(2))
+}
+
+func TestPoolClose(t *testing.T) {
+	proxy, _ := newCountingHBONEServer(t)
+	pool := NewPool(PoolConfig{})
+	d := pool.NewDialer(Config{ProxyAddress: proxy})
+	assert.NoError(t, pool.Close())
+	_, err := d.Dial("tcp", newEchoServer(t))
+	assert.Error(t, err)
+}
+
+func TestTLSIdentity(t *testing.T) {
+	cfg := &tls.Config{ServerName: "ztunnel.istio-system", MinVersion: tls.VersionTLS12}
+	assert.Equal(t, tlsIdentity(nil), "")
+	assert.Equal(t, tlsIdentity(cfg), tlsIdentity(cfg.Clone()))
+
+	other := cfg.Clone()
+	other.ServerName = "waypoint.default"
+	if tlsIdentity(cfg) == tlsIdentity(other) {
+		t.Fatal("expected configurations with different server names to have different identities")
+	}
+
+	callback := cfg.Clone()
+	callback.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return nil, nil
+	}
+	if tlsIdentity(callback) == tlsIdentity(callback.Clone()) {
+		t.Fatal("expected configurations with callbacks to have their own identity")
+	}
+}
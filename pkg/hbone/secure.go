@@ -0,0 +1,160 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbone
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	proxyproto "github.com/pires/go-proxyproto"
+	"golang.org/x/net/http2"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// PeerIdentityTLV is the type of the PROXY protocol v2 TLV carrying the identity of the peer, when
+// ServerOptions.ProxyProtocol is set.
+const PeerIdentityTLV proxyproto.PP2Type = 0xD0
+
+// Peer describes the client of a CONNECT request.
+type Peer struct {
+	// Identity is the SPIFFE identity of the client certificate. It is empty for plaintext servers.
+	Identity string
+	// Address is the address of the client.
+	Address string
+}
+
+// Authorizer decides whether the peer may connect to address. The request is denied with the returned error.
+type Authorizer func(peer Peer, address string) error
+
+// ServerOptions configures an HBONE server.
+type ServerOptions struct {
+	// SecretManager provides the workload certificate of the server, and the root certificate the client
+	// certificates are verified against. Both are fetched for each handshake, so rotations are picked up.
+	SecretManager security.SecretManager
+	// Authorizer, if set, is called before connecting to the requested address.
+	Authorizer Authorizer
+	// ProxyProtocol sends a PROXY protocol v2 header to the upstream, with the address of the client and its
+	// identity in a PeerIdentityTLV.
+	ProxyProtocol bool
+	// DialTimeout is the timeout to connect to the upstream. Defaults to 10s.
+	DialTimeout time.Duration
+}
+
+// NewSecureServer creates an HBONE server terminating mTLS with the workload certificates of opts.SecretManager. The
+// server only accepts clients with a certificate issued by the mesh CA, which carries their identity. It is meant
+// to be served with ServeTLS, without any certificate file:
+//
+//	s, _ := hbone.NewSecureServer(opts)
+//	l, _ := net.Listen("tcp", "0.0.0.0:15008")
+//	s.ServeTLS(l, "", "")
+func NewSecureServer(opts ServerOptions) (*http.Server, error) {
+	if opts.SecretManager == nil {
+		return nil, fmt.Errorf("a secret manager is required to terminate mTLS")
+	}
+	hs := &http.Server{
+		Handler:   connectHandler(opts),
+		TLSConfig: serverTLSConfig(opts.SecretManager),
+	}
+	if err := http2.ConfigureServer(hs, &http2.Server{}); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+func serverTLSConfig(sm security.SecretManager) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// GetConfigForClient takes precedence for the handshakes, GetCertificate is set for ServeTLS to accept the
+		// configuration without certificate files.
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return workloadCertificate(sm)
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, err := workloadCertificate(sm)
+			if err != nil {
+				return nil, err
+			}
+			root, err := sm.GenerateSecret(security.RootCertReqResourceName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the root certificate: %v", err)
+			}
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(root.RootCert) {
+				return nil, fmt.Errorf("invalid root certificate")
+			}
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    roots,
+				NextProtos:   []string{http2.NextProtoTLS},
+				VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+					if len(chains) == 0 || len(chains[0]) == 0 {
+						return fmt.Errorf("no verified client certificate")
+					}
+					if ids, _ := util.ExtractIDs(chains[0][0].Extensions); len(ids) == 0 {
+						return fmt.Errorf("client certificate has no identity")
+					}
+					return nil
+				},
+			}, nil
+		},
+	}
+}
+
+// workloadCertificate returns the current workload certificate of the secret manager.
+func workloadCertificate(sm security.SecretManager) (*tls.Certificate, error) {
+	key, err := sm.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the workload certificate: %v", err)
+	}
+	cert, err := tls.X509KeyPair(key.CertificateChain, key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workload certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+func peerFromRequest(r *http.Request) Peer {
+	peer := Peer{Address: r.RemoteAddr}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if ids, _ := util.ExtractIDs(r.TLS.PeerCertificates[0].Extensions); len(ids) > 0 {
+			peer.Identity = ids[0]
+		}
+	}
+	return peer
+}
+
+// writeProxyHeader sends a PROXY protocol v2 header with the address and identity of the peer.
+func writeProxyHeader(dst net.Conn, peer Peer) error {
+	src, err := net.ResolveTCPAddr("tcp", peer.Address)
+	if err != nil {
+		return fmt.Errorf("invalid peer address %q: %v", peer.Address, err)
+	}
+	header := proxyproto.HeaderProxyFromAddrs(2, src, dst.RemoteAddr())
+	if peer.Identity != "" {
+		if err := header.SetTLVs([]proxyproto.TLV{{Type: PeerIdentityTLV, Value: []byte(peer.Identity)}}); err != nil {
+			return err
+		}
+	}
+	_, err = header.WriteTo(dst)
+	return err
+}
@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbone
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	proxyproto "github.com/pires/go-proxyproto"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+var certDir = filepath.Join(env.IstioSrc, "tests/testdata/certs/default")
+
+const clientIdentity = "spiffe://cluster.local/ns/default/sa/default"
+
+type fileSecretManager struct {
+	t *testing.T
+}
+
+func (f fileSecretManager) GenerateSecret(resourceName string) (*security.SecretItem, error) {
+	read := func(name string) []byte {
+		b, err := os.ReadFile(filepath.Join(certDir, name))
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		return b
+	}
+	if resourceName == security.RootCertReqResourceName {
+		return &security.SecretItem{ResourceName: resourceName, RootCert: read("root-cert.pem")}, nil
+	}
+	return &security.SecretItem{
+		ResourceName:     resourceName,
+		CertificateChain: read("cert-chain.pem"),
+		PrivateKey:       read("key.pem"),
+	}, nil
+}
+
+func newSecureHBONEServer(t *testing.T, opts ServerOptions) string {
+	opts.SecretManager = fileSecretManager{t}
+	s, err := NewSecureServer(opts)
+	assert.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = s.ServeTLS(l, "", "")
+	}()
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return l.Addr().String()
+}
+
+// newProxyProtocolServer starts a TCP server reading a PROXY protocol header, and writing back the identity it
+// carries.
+func newProxyProtocolServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			header, err := proxyproto.Read(bufio.NewReader(c))
+			if err != nil {
+				_, _ = fmt.Fprintf(c, "error: %v\n", err)
+				_ = c.Close()
+				continue
+			}
+			tlvs, _ := header.TLVs()
+			identity := "none"
+			for _, tlv := range tlvs {
+				if tlv.Type == PeerIdentityTLV {
+					identity = string(tlv.Value)
+				}
+			}
+			_, _ = fmt.Fprintf(c, "%s\n", identity)
+			_ = c.Close()
+		}
+	}()
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	return l.Addr().String()
+}
+
+func clientTLS(t *testing.T, withCert bool) *tls.Config {
+	// nolint: gosec
+	// The test certificate has no SAN matching the server address.
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	if withCert {
+		cert, err := tls.LoadX509KeyPair(filepath.Join(certDir, "cert-chain.pem"), filepath.Join(certDir, "key.pem"))
+		assert.NoError(t, err)
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg
+}
+
+func TestSecureServer(t *testing.T) {
+	upstream := newProxyProtocolServer(t)
+	denied := newTCPServer(t, "hello")
+	var authorized []Peer
+	proxy := newSecureHBONEServer(t, ServerOptions{
+		ProxyProtocol: true,
+		Authorizer: func(peer Peer, address string) error {
+			if address == denied {
+				return fmt.Errorf("%v may not connect to %v", peer.Identity, address)
+			}
+			authorized = append(authorized, peer)
+			return nil
+		},
+	})
+
+	d := NewDialer(Config{ProxyAddress: proxy, TLS: clientTLS(t, true)})
+	c, err := d.Dial("tcp", upstream)
+	assert.NoError(t, err)
+	defer c.Close()
+	line, err := bufio.NewReader(c).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(line), clientIdentity)
+	assert.Equal(t, len(authorized), 1)
+	assert.Equal(t, authorized[0].Identity, clientIdentity)
+
+	_, err = d.Dial("tcp", denied)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected the CONNECT request to be denied, got %v", err)
+	}
+}
+
+func TestSecureServerRequiresClientCertificate(t *testing.T) {
+	proxy := newSecureHBONEServer(t, ServerOptions{})
+	d := NewDialer(Config{ProxyAddress: proxy, TLS: clientTLS(t, false)})
+	_, err := d.Dial("tcp", newTCPServer(t, "hello"))
+	assert.Error(t, err)
+}
+
+func TestSecureServerServeTLSConfig(t *testing.T) {
+	s, err := NewSecureServer(ServerOptions{SecretManager: fileSecretManager{t}})
+	assert.NoError(t, err)
+	// ServeTLS without certificate files requires Certificates or GetCertificate on every supported Go version, it
+	// does not consider GetConfigForClient.
+	assert.Equal(t, s.TLSConfig.GetCertificate != nil, true)
+	cert, err := s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(cert.Certificate) > 0, true)
+}
+
+func TestNewSecureServerRequiresSecretManager(t *testing.T) {
+	_, err := NewSecureServer(ServerOptions{})
+	assert.Error(t, err)
+}
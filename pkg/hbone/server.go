@@ -26,6 +26,8 @@ import (
 	"istio.io/istio/pkg/h2c"
 )
 
+// NewServer creates a plaintext (h2c) HBONE server, accepting any CONNECT request. See NewSecureServer for a server
+// terminating mTLS.
 func NewServer() *http.Server {
 	// Need to set this to allow timeout on the read header
 	h1 := &http.Transport{
@@ -35,9 +37,16 @@ func NewServer() *http.Server {
 	h2.ReadIdleTimeout = 10 * time.Minute // TODO: much larger to support long-lived connections
 	h2.AllowHTTP = true
 	h2Server := &http2.Server{}
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	hs := &http.Server{
+		Handler: h2c.NewHandler(connectHandler(ServerOptions{}), h2Server),
+	}
+	return hs
+}
+
+func connectHandler(opts ServerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodConnect {
-			if handleConnect(w, r) {
+			if handleConnect(w, r, opts) {
 				return
 			}
 		} else {
@@ -45,18 +54,24 @@ func NewServer() *http.Server {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
-	hs := &http.Server{
-		Handler: h2c.NewHandler(handler, h2Server),
-	}
-	return hs
 }
 
-func handleConnect(w http.ResponseWriter, r *http.Request) bool {
+func handleConnect(w http.ResponseWriter, r *http.Request, opts ServerOptions) bool {
 	t0 := time.Now()
-	log.WithLabels("host", r.Host, "source", r.RemoteAddr).Info("Received CONNECT")
-	// Send headers back immediately so we can start getting the body
-	w.(http.Flusher).Flush()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	peer := peerFromRequest(r)
+	log.WithLabels("host", r.Host, "source", r.RemoteAddr, "identity", peer.Identity).Info("Received CONNECT")
+	if opts.Authorizer != nil {
+		if err := opts.Authorizer(peer, r.Host); err != nil {
+			log.WithLabels("host", r.Host, "identity", peer.Identity).Infof("CONNECT denied: %v", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return true
+		}
+	}
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), dialTimeout)
 	defer cancel()
 
 	dst, err := (&net.Dialer{}).DialContext(ctx, "tcp", r.Host)
@@ -65,8 +80,18 @@ func handleConnect(w http.ResponseWriter, r *http.Request) bool {
 		log.Errorf("failed to dial upstream: %v", err)
 		return true
 	}
+	defer dst.Close()
+	if opts.ProxyProtocol {
+		if err := writeProxyHeader(dst, peer); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			log.Errorf("failed to write PROXY protocol header upstream: %v", err)
+			return true
+		}
+	}
 	log.Infof("Connected to %v", r.Host)
 	w.WriteHeader(http.StatusOK)
+	// Send headers back immediately so we can start getting the body
+	w.(http.Flusher).Flush()
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package topology holds a static service graph of the mesh, derived from configuration rather than observed
+// traffic, and renders it in several formats.
+package topology
+
+import (
+	"sort"
+
+	"istio.io/istio/pkg/util/sets"
+)
+
+// NodeKind is the type of a graph node.
+type NodeKind string
+
+const (
+	// Service is a service in the registry, identified by hostname.
+	Service NodeKind = "service"
+	// Workloads is a group of workloads sharing a Sidecar scope or an authorization policy selector.
+	Workloads NodeKind = "workloads"
+	// Principal is a peer identity or namespace referenced by an authorization policy.
+	Principal NodeKind = "principal"
+)
+
+// EdgeKind is the reason an edge exists.
+type EdgeKind string
+
+const (
+	// Visibility edges connect workloads to the services their Sidecar scope can reach.
+	Visibility EdgeKind = "visibility"
+	// Route edges connect a service to the destinations a VirtualService routes it to.
+	Route EdgeKind = "route"
+	// Allow edges connect a source to the workloads an ALLOW authorization policy admits it to.
+	Allow EdgeKind = "allow"
+	// Deny edges connect a source to the workloads a DENY authorization policy blocks it from.
+	Deny EdgeKind = "deny"
+)
+
+// Node is a vertex of the graph.
+type Node struct {
+	ID        string   `json:"id"`
+	Kind      NodeKind `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+}
+
+// Edge is a directed edge of the graph.
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+	// Resource is the Istio resource the edge was derived from, as Kind/namespace/name.
+	Resource string `json:"resource,omitempty"`
+}
+
+// Graph is a static service graph.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+
+	nodeIDs sets.String
+	edgeIDs sets.Set[Edge]
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{Nodes: []Node{}, Edges: []Edge{}, nodeIDs: sets.New[string](), edgeIDs: sets.New[Edge]()}
+}
+
+// ServiceID returns the node ID of a service.
+func ServiceID(hostname string) string {
+	return string(Service) + ":" + hostname
+}
+
+// WorkloadsID returns the node ID of a workload group in a namespace. An empty name means all workloads in the
+// namespace.
+func WorkloadsID(namespace, name string) string {
+	if name == "" {
+		return string(Workloads) + ":" + namespace
+	}
+	return string(Workloads) + ":" + namespace + "/" + name
+}
+
+// PrincipalID returns the node ID of an authorization policy source.
+func PrincipalID(name string) string {
+	return string(Principal) + ":" + name
+}
+
+// AddNode adds n to the graph, unless a node with the same ID already exists.
+func (g *Graph) AddNode(n Node) {
+	g.init()
+	if g.nodeIDs.InsertContains(n.ID) {
+		return
+	}
+	g.Nodes = append(g.Nodes, n)
+}
+
+// AddEdge adds e to the graph, unless an identical edge already exists.
+func (g *Graph) AddEdge(e Edge) {
+	g.init()
+	if g.edgeIDs.InsertContains(e) {
+		return
+	}
+	g.Edges = append(g.Edges, e)
+}
+
+func (g *Graph) init() {
+	if g.nodeIDs != nil {
+		return
+	}
+	g.nodeIDs = sets.New[string]()
+	g.edgeIDs = sets.New[Edge]()
+	for _, n := range g.Nodes {
+		g.nodeIDs.Insert(n.ID)
+	}
+	for _, e := range g.Edges {
+		g.edgeIDs.Insert(e)
+	}
+}
+
+// Filter returns the subgraph of edges with at least one end in the given namespaces and one of the given kinds.
+// Empty namespaces or kinds match everything. Nodes without a namespace, such as external services or any-source
+// principals, never match a namespace on their own.
+func (g *Graph) Filter(namespaces []string, kinds []EdgeKind) *Graph {
+	nsSet := sets.New(namespaces...)
+	kindSet := sets.New(kinds...)
+	nodes := make(map[string]Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	inScope := func(id string) bool {
+		return len(nsSet) == 0 || nsSet.Contains(nodes[id].Namespace)
+	}
+	out := NewGraph()
+	for _, e := range g.Edges {
+		if len(kindSet) > 0 && !kindSet.Contains(e.Kind) {
+			continue
+		}
+		if !inScope(e.From) && !inScope(e.To) {
+			continue
+		}
+		out.AddNode(nodes[e.From])
+		out.AddNode(nodes[e.To])
+		out.AddEdge(e)
+	}
+	// Keep isolated nodes so that a namespace with no edges still shows up.
+	for _, n := range g.Nodes {
+		if len(nsSet) > 0 && nsSet.Contains(n.Namespace) {
+			out.AddNode(n)
+		}
+	}
+	out.Sort()
+	return out
+}
+
+// Sort orders nodes and edges so that rendered output is stable.
+func (g *Graph) Sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Resource < b.Resource
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func testGraph() *Graph {
+	g := NewGraph()
+	g.AddNode(Node{ID: WorkloadsID("default", ""), Kind: Workloads, Name: "*", Namespace: "default"})
+	g.AddNode(Node{ID: ServiceID("reviews.default.svc.cluster.local"), Kind: Service, Name: "reviews.default.svc.cluster.local", Namespace: "default"})
+	g.AddNode(Node{ID: ServiceID("ratings.other.svc.cluster.local"), Kind: Service, Name: "ratings.other.svc.cluster.local", Namespace: "other"})
+	g.AddNode(Node{ID: WorkloadsID("other", ""), Kind: Workloads, Name: "*", Namespace: "other"})
+	g.AddNode(Node{ID: PrincipalID("ns/default"), Kind: Principal, Name: "ns/default"})
+	g.AddNode(Node{ID: WorkloadsID("isolated", ""), Kind: Workloads, Name: "*", Namespace: "isolated"})
+	// Duplicates are ignored.
+	g.AddNode(Node{ID: WorkloadsID("default", ""), Kind: Workloads, Name: "dup", Namespace: "default"})
+
+	g.AddEdge(Edge{From: WorkloadsID("default", ""), To: ServiceID("reviews.default.svc.cluster.local"), Kind: Visibility})
+	g.AddEdge(Edge{
+		From: ServiceID("reviews.default.svc.cluster.local"), To: ServiceID("ratings.other.svc.cluster.local"),
+		Kind: Route, Resource: "VirtualService/default/reviews",
+	})
+	g.AddEdge(Edge{From: PrincipalID("ns/default"), To: WorkloadsID("other", ""), Kind: Deny, Resource: "AuthorizationPolicy/other/deny"})
+	g.AddEdge(Edge{From: WorkloadsID("default", ""), To: ServiceID("reviews.default.svc.cluster.local"), Kind: Visibility})
+	g.Sort()
+	return g
+}
+
+func TestGraph(t *testing.T) {
+	g := testGraph()
+	assert.Equal(t, len(g.Nodes), 6)
+	assert.Equal(t, len(g.Edges), 3)
+
+	// A graph round tripped through JSON still deduplicates.
+	b, err := json.Marshal(g)
+	assert.NoError(t, err)
+	parsed := &Graph{}
+	assert.NoError(t, json.Unmarshal(b, parsed))
+	parsed.AddEdge(g.Edges[0])
+	assert.Equal(t, len(parsed.Edges), 3)
+}
+
+func TestFilter(t *testing.T) {
+	g := testGraph()
+	cases := []struct {
+		name       string
+		namespaces []string
+		kinds      []EdgeKind
+		wantNodes  []string
+		wantEdges  int
+	}{
+		{
+			name:      "all",
+			wantNodes: []string{"principal:ns/default", "service:ratings.other.svc.cluster.local", "service:reviews.default.svc.cluster.local", "workloads:default", "workloads:other"},
+			wantEdges: 3,
+		},
+		{
+			name:       "namespace",
+			namespaces: []string{"other"},
+			wantNodes:  []string{"principal:ns/default", "service:ratings.other.svc.cluster.local", "service:reviews.default.svc.cluster.local", "workloads:other"},
+			wantEdges:  2,
+		},
+		{
+			name:       "isolated namespace",
+			namespaces: []string{"isolated"},
+			wantNodes:  []string{"workloads:isolated"},
+			wantEdges:  0,
+		},
+		{
+			name:      "kind",
+			kinds:     []EdgeKind{Route},
+			wantNodes: []string{"service:ratings.other.svc.cluster.local", "service:reviews.default.svc.cluster.local"},
+			wantEdges: 1,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.Filter(tt.namespaces, tt.kinds)
+			var ids []string
+			for _, n := range got.Nodes {
+				ids = append(ids, n.ID)
+			}
+			assert.Equal(t, ids, tt.wantNodes)
+			assert.Equal(t, len(got.Edges), tt.wantEdges)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g := testGraph().Filter(nil, []EdgeKind{Route, Deny})
+	cases := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: DOT,
+			want: `digraph mesh {
+  rankdir=LR;
+  "principal:ns/default" [label="ns/default", shape=diamond];
+  subgraph cluster_1 {
+    label="other";
+    "service:ratings.other.svc.cluster.local" [label="ratings.other.svc.cluster.local", shape=ellipse];
+    "workloads:other" [label="*", shape=box];
+  }
+  subgraph cluster_2 {
+    label="default";
+    "service:reviews.default.svc.cluster.local" [label="reviews.default.svc.cluster.local", shape=ellipse];
+  }
+  "principal:ns/default" -> "workloads:other" [label="deny\nAuthorizationPolicy/other/deny", color="red", style=bold];
+  "service:reviews.default.svc.cluster.local" -> "service:ratings.other.svc.cluster.local" [label="route\nVirtualService/default/reviews", color="blue"];
+}
+`,
+		},
+		{
+			format: Mermaid,
+			want: `flowchart LR
+  n0{"ns/default"}
+  subgraph ns1 ["other"]
+    n1("ratings.other.svc.cluster.local")
+    n3["*"]
+  end
+  subgraph ns2 ["default"]
+    n2("reviews.default.svc.cluster.local")
+  end
+  n0 --x|"deny AuthorizationPolicy/other/deny"| n3
+  n2 -->|"route VirtualService/default/reviews"| n1
+`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, Write(&out, g, tt.format))
+			assert.Equal(t, out.String(), tt.want)
+		})
+	}
+	assert.Error(t, Write(&bytes.Buffer{}, g, "svg"))
+}
@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is an output format for a Graph.
+type Format string
+
+const (
+	JSON    Format = "json"
+	DOT     Format = "dot"
+	Mermaid Format = "mermaid"
+)
+
+// Formats lists the supported output formats.
+var Formats = []Format{JSON, DOT, Mermaid}
+
+// edgeStyles are the DOT attributes used for each edge kind.
+var edgeStyles = map[EdgeKind]string{
+	Visibility: `style=dotted, color="gray40"`,
+	Route:      `color="blue"`,
+	Allow:      `color="darkgreen"`,
+	Deny:       `color="red", style=bold`,
+}
+
+// nodeShapes are the DOT shapes used for each node kind.
+var nodeShapes = map[NodeKind]string{
+	Service:   "ellipse",
+	Workloads: "box",
+	Principal: "diamond",
+}
+
+// Write renders g to w in the given format.
+func Write(w io.Writer, g *Graph, format Format) error {
+	switch format {
+	case JSON:
+		b, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case DOT:
+		return WriteDOT(w, g)
+	case Mermaid:
+		return WriteMermaid(w, g)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// WriteDOT renders g in Graphviz DOT format, with one cluster per namespace.
+func WriteDOT(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph mesh {\n  rankdir=LR;\n")
+	byNamespace := map[string][]Node{}
+	var namespaces []string
+	for _, n := range g.Nodes {
+		if _, f := byNamespace[n.Namespace]; !f {
+			namespaces = append(namespaces, n.Namespace)
+		}
+		byNamespace[n.Namespace] = append(byNamespace[n.Namespace], n)
+	}
+	for i, ns := range namespaces {
+		indent := "  "
+		if ns != "" {
+			fmt.Fprintf(&sb, "  subgraph cluster_%d {\n    label=%s;\n", i, strconv.Quote(ns))
+			indent = "    "
+		}
+		for _, n := range byNamespace[ns] {
+			fmt.Fprintf(&sb, "%s%s [label=%s, shape=%s];\n", indent, strconv.Quote(n.ID), strconv.Quote(n.Name), nodeShapes[n.Kind])
+		}
+		if ns != "" {
+			sb.WriteString("  }\n")
+		}
+	}
+	for _, e := range g.Edges {
+		label := string(e.Kind)
+		if e.Resource != "" {
+			label += "\n" + e.Resource
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s, %s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(label), edgeStyles[e.Kind])
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMermaid renders g as a Mermaid flowchart, with one subgraph per namespace.
+func WriteMermaid(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	ids := make(map[string]string, len(g.Nodes))
+	byNamespace := map[string][]Node{}
+	var namespaces []string
+	for i, n := range g.Nodes {
+		// Mermaid IDs must be plain identifiers, so nodes are numbered and labeled with their name.
+		ids[n.ID] = "n" + strconv.Itoa(i)
+		if _, f := byNamespace[n.Namespace]; !f {
+			namespaces = append(namespaces, n.Namespace)
+		}
+		byNamespace[n.Namespace] = append(byNamespace[n.Namespace], n)
+	}
+	for i, ns := range namespaces {
+		indent := "  "
+		if ns != "" {
+			fmt.Fprintf(&sb, "  subgraph ns%d [%s]\n", i, mermaidText(ns))
+			indent = "    "
+		}
+		for _, n := range byNamespace[ns] {
+			open, closing := mermaidShape(n.Kind)
+			fmt.Fprintf(&sb, "%s%s%s%s%s\n", indent, ids[n.ID], open, mermaidText(n.Name), closing)
+		}
+		if ns != "" {
+			sb.WriteString("  end\n")
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		switch e.Kind {
+		case Visibility:
+			arrow = "-.->"
+		case Deny:
+			arrow = "--x"
+		}
+		label := string(e.Kind)
+		if e.Resource != "" {
+			label += " " + e.Resource
+		}
+		fmt.Fprintf(&sb, "  %s %s|%s| %s\n", ids[e.From], arrow, mermaidText(label), ids[e.To])
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func mermaidShape(k NodeKind) (string, string) {
+	switch k {
+	case Workloads:
+		return "[", "]"
+	case Principal:
+		return "{", "}"
+	default:
+		return "(", ")"
+	}
+}
+
+// mermaidText quotes s so that characters like '/' and '*' are not interpreted by Mermaid.
+func mermaidText(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
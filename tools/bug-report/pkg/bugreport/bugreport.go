@@ -84,6 +84,7 @@ e.g.
 		},
 	}
 	rootCmd.AddCommand(version.CobraCommand())
+	rootCmd.AddCommand(diffCmd())
 	addFlags(rootCmd, gConfig)
 
 	return rootCmd
@@ -0,0 +1,84 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/tools/bug-report/pkg/diff"
+)
+
+const (
+	diffOutputText = "text"
+	diffOutputJSON = "json"
+)
+
+// diffCmd returns a cobra command which compares two bug-report archives.
+func diffCmd() *cobra.Command {
+	outputFormat := diffOutputText
+	cmd := &cobra.Command{
+		Use:   "diff <before> <after>",
+		Short: "Compare two bug-report archives.",
+		Long: `diff compares two archives created by bug-report, e.g. when bug-report is run periodically to capture an
+intermittent incident. Each archive may be the .tar.gz file or the directory it was extracted to.
+
+The report lists Istio resources which were added, removed or modified, proxies whose config dump changed
+(ignoring versions and timestamps), error log signatures which only appear in the later archive, and containers
+whose restart count increased.`,
+		Example: `  # Compare two archives captured an hour apart
+  bug-report diff 0900/bug-report.tar.gz 1000/bug-report.tar.gz
+
+  # Ignore known noisy errors and print the result as JSON
+  bug-report diff before/ after/ --ignore-errs "*connection reset*" -o json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputFormat != diffOutputText && outputFormat != diffOutputJSON {
+				return fmt.Errorf("unknown output format %q, must be one of %s or %s", outputFormat, diffOutputText, diffOutputJSON)
+			}
+			// The diff is only affected by the error filters, but they may come from the -f config file.
+			config, err := parseConfig()
+			if err != nil {
+				return err
+			}
+			before, err := diff.Load(args[0])
+			if err != nil {
+				return fmt.Errorf("could not read %s: %v", args[0], err)
+			}
+			after, err := diff.Load(args[1])
+			if err != nil {
+				return fmt.Errorf("could not read %s: %v", args[1], err)
+			}
+			report, err := diff.Compare(before, after, config)
+			if err != nil {
+				return err
+			}
+			if outputFormat == diffOutputJSON {
+				b, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(b))
+				return nil
+			}
+			fmt.Fprint(cmd.OutOrStdout(), report.String())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", diffOutputText, "Output format: one of text|json")
+	return cmd
+}
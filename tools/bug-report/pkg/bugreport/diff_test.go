@@ -0,0 +1,52 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestDiffConfigFile(t *testing.T) {
+	writeArchive := func(log string) string {
+		dir := t.TempDir()
+		p := filepath.Join(dir, "bug-report", "istio", "istio-system", "istiod-abc", "discovery.log")
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(log), 0o644))
+		return dir
+	}
+	before := writeArchive("")
+	after := writeArchive("2023-05-10T18:43:55.356647Z\terror\tads\tpush failed for 10.0.0.2:15010\n" +
+		"2023-05-10T18:43:56.356647Z\terror\tcache\tsecret fetch timed out after 30s\n")
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("ignoredErrors:\n- \"*timed out*\"\n"), 0o644))
+
+	cmd := Cmd(nil, nil)
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"diff", before, after, "-f", configFile})
+	assert.NoError(t, cmd.Execute())
+	if !strings.Contains(out.String(), "push failed") {
+		t.Fatalf("expected the push error in the diff, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "timed out") {
+		t.Fatalf("expected the error ignored by the config file to be filtered, got:\n%s", out.String())
+	}
+}
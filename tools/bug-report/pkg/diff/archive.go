@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	crsFile          = "crs"
+	k8sResourcesFile = "k8s-resources"
+	configDumpFile   = "config_dump?include_eds"
+	ztunnelDumpFile  = "config_dump"
+)
+
+// topLevelDirs are the directories at the root of a bug-report archive, see the archive package.
+var topLevelDirs = []string{"cluster", "proxies", "istio", "operator", "analyze"}
+
+// Archive holds the contents of a bug-report archive, keyed by slash separated path relative to the archive root.
+type Archive struct {
+	files map[string]string
+}
+
+// logFile is a container log stored in an archive.
+type logFile struct {
+	namespace, pod, container, text string
+}
+
+// Load reads the bug-report archive at path, which is either a .tar.gz file created by bug-report or a directory
+// it was extracted to.
+func Load(path string) (*Archive, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return loadDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadTarGz(f)
+}
+
+// NewArchive returns an Archive holding files, keyed by path relative to the archive root.
+func NewArchive(files map[string]string) *Archive {
+	a := &Archive{files: make(map[string]string, len(files))}
+	for name, text := range files {
+		a.add(name, text)
+	}
+	return a
+}
+
+func loadDir(dir string) (*Archive, error) {
+	a := &Archive{files: make(map[string]string)}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		a.add(rel, string(b))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func loadTarGz(r io.Reader) (*Archive, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	a := &Archive{files: make(map[string]string)}
+	tr := tar.NewReader(gzr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return a, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		a.add(h.Name, string(b))
+	}
+}
+
+// add stores text under name, stripping any leading directories above the archive root.
+func (a *Archive) add(name, text string) {
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	for i, p := range parts {
+		for _, d := range topLevelDirs {
+			if p == d {
+				a.files[strings.Join(parts[i:], "/")] = text
+				return
+			}
+		}
+	}
+	a.files[strings.Join(parts, "/")] = text
+}
+
+// cluster returns the contents of the named file in the cluster info directory.
+func (a *Archive) cluster(name string) string {
+	return a.files["cluster/"+name]
+}
+
+// proxyConfigDumps returns the Envoy and ztunnel config dumps in the archive, keyed by pod.
+func (a *Archive) proxyConfigDumps() map[podKey]string {
+	out := make(map[podKey]string)
+	for name, text := range a.files {
+		ns, pod, file, ok := splitPodPath(name, "proxies")
+		if !ok || (file != configDumpFile && file != ztunnelDumpFile) {
+			continue
+		}
+		out[podKey{namespace: ns, name: pod}] = text
+	}
+	return out
+}
+
+// logs returns all proxy, istiod and operator logs in the archive.
+func (a *Archive) logs() []logFile {
+	var out []logFile
+	for _, name := range sortedKeys(a.files) {
+		for _, dir := range []string{"proxies", "istio", "operator"} {
+			ns, pod, file, ok := splitPodPath(name, dir)
+			if !ok || !strings.HasSuffix(file, ".log") {
+				continue
+			}
+			out = append(out, logFile{namespace: ns, pod: pod, container: strings.TrimSuffix(file, ".log"), text: a.files[name]})
+		}
+	}
+	return out
+}
+
+// splitPodPath splits a path of the form dir/namespace/pod/file.
+func splitPodPath(name, dir string) (namespace, pod, file string, ok bool) {
+	parts := strings.SplitN(name, "/", 4)
+	if len(parts) != 4 || parts[0] != dir {
+		return "", "", "", false
+	}
+	return parts[1], parts[2], parts[3], true
+}
@@ -0,0 +1,428 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares two bug-report archives captured from the same cluster at different points in time.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/tools/bug-report/pkg/config"
+	"istio.io/istio/tools/bug-report/pkg/processlog"
+)
+
+// Change describes how an item differs between the two archives.
+type Change string
+
+const (
+	Added    Change = "added"
+	Removed  Change = "removed"
+	Modified Change = "modified"
+)
+
+// ResourceChange is an Istio resource that differs between the two archives.
+type ResourceChange struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Change    Change `json:"change"`
+}
+
+// ProxyConfigChange is a proxy whose config dump differs between the two archives.
+type ProxyConfigChange struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Change    Change `json:"change"`
+	// Sections lists the config dump sections (e.g. ListenersConfigDump) which were modified.
+	Sections []string `json:"sections,omitempty"`
+}
+
+// LogSignature is an error log signature that only appears in the newer archive.
+type LogSignature struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Signature string `json:"signature"`
+	Count     int    `json:"count"`
+}
+
+// ContainerRestart is a container whose restart count increased between the two archives.
+type ContainerRestart struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Before    int    `json:"before"`
+	After     int    `json:"after"`
+}
+
+// Report is the result of comparing two archives.
+type Report struct {
+	Resources    []ResourceChange    `json:"resources"`
+	ProxyConfigs []ProxyConfigChange `json:"proxyConfigs"`
+	NewErrors    []LogSignature      `json:"newErrors"`
+	Restarts     []ContainerRestart  `json:"restarts"`
+}
+
+// Empty reports whether no differences were found.
+func (r *Report) Empty() bool {
+	return len(r.Resources) == 0 && len(r.ProxyConfigs) == 0 && len(r.NewErrors) == 0 && len(r.Restarts) == 0
+}
+
+// String returns a human-readable form of the report.
+func (r *Report) String() string {
+	var sb strings.Builder
+	if r.Empty() {
+		sb.WriteString("No differences found.\n")
+		return sb.String()
+	}
+	if len(r.Resources) > 0 {
+		sb.WriteString("Changed Istio resources:\n")
+		for _, c := range r.Resources {
+			fmt.Fprintf(&sb, "  %-8s %s %s\n", c.Change, c.Kind, namespacedName(c.Namespace, c.Name))
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.ProxyConfigs) > 0 {
+		sb.WriteString("Changed proxy configs:\n")
+		for _, c := range r.ProxyConfigs {
+			fmt.Fprintf(&sb, "  %-8s %s", c.Change, namespacedName(c.Namespace, c.Pod))
+			if len(c.Sections) > 0 {
+				fmt.Fprintf(&sb, " (%s)", strings.Join(c.Sections, ", "))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.NewErrors) > 0 {
+		sb.WriteString("New error log signatures:\n")
+		for _, s := range r.NewErrors {
+			fmt.Fprintf(&sb, "  %s/%s x%d: %s\n", namespacedName(s.Namespace, s.Pod), s.Container, s.Count,
+				strings.Replace(s.Signature, "\t", " ", 1))
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.Restarts) > 0 {
+		sb.WriteString("Restarted containers:\n")
+		for _, c := range r.Restarts {
+			fmt.Fprintf(&sb, "  %s/%s restarts %d -> %d\n", namespacedName(c.Namespace, c.Pod), c.Container, c.Before, c.After)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Compare returns the differences between the before and after archives. Error log signatures which match
+// cfg.IgnoredErrors are not reported.
+func Compare(before, after *Archive, cfg *config.BugReportConfig) (*Report, error) {
+	r := &Report{}
+	var err error
+	if r.Resources, err = compareResources(before, after); err != nil {
+		return nil, err
+	}
+	if r.ProxyConfigs, err = compareProxyConfigs(before, after); err != nil {
+		return nil, err
+	}
+	r.NewErrors = compareLogs(before, after, cfg)
+	if r.Restarts, err = compareRestarts(before, after); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ignoredMetadataFields are server populated fields which change without any user visible config change.
+var ignoredMetadataFields = []string{"resourceVersion", "generation", "managedFields", "uid", "creationTimestamp"}
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+type object = map[string]any
+
+func compareResources(before, after *Archive) ([]ResourceChange, error) {
+	b, err := istioResources(before)
+	if err != nil {
+		return nil, fmt.Errorf("before: %v", err)
+	}
+	a, err := istioResources(after)
+	if err != nil {
+		return nil, fmt.Errorf("after: %v", err)
+	}
+	var out []ResourceChange
+	for _, k := range unionKeys(b, a) {
+		bo, inBefore := b[k]
+		ao, inAfter := a[k]
+		c := ResourceChange{Kind: k.kind, Namespace: k.namespace, Name: k.name}
+		switch {
+		case !inBefore:
+			c.Change = Added
+		case !inAfter:
+			c.Change = Removed
+		case !reflect.DeepEqual(bo, ao):
+			c.Change = Modified
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+type resourceKey struct {
+	kind, namespace, name string
+}
+
+type podKey struct {
+	namespace, name string
+}
+
+type containerKey struct {
+	podKey
+	container string
+}
+
+// istioResources returns the normalized Istio custom resources in the archive, keyed by kind, namespace and name.
+func istioResources(a *Archive) (map[resourceKey]object, error) {
+	items, err := listItems(a.cluster(crsFile))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[resourceKey]object)
+	for _, item := range items {
+		apiVersion, _ := item["apiVersion"].(string)
+		group, _, _ := strings.Cut(apiVersion, "/")
+		if !strings.HasSuffix(group, "istio.io") {
+			continue
+		}
+		md, _ := item["metadata"].(object)
+		kind, _ := item["kind"].(string)
+		ns, _ := md["namespace"].(string)
+		name, _ := md["name"].(string)
+		for _, f := range ignoredMetadataFields {
+			delete(md, f)
+		}
+		if an, ok := md["annotations"].(object); ok {
+			delete(an, lastAppliedAnnotation)
+		}
+		delete(item, "status")
+		out[resourceKey{kind: kind, namespace: ns, name: name}] = item
+	}
+	return out, nil
+}
+
+func compareProxyConfigs(before, after *Archive) ([]ProxyConfigChange, error) {
+	b, a := before.proxyConfigDumps(), after.proxyConfigDumps()
+	var out []ProxyConfigChange
+	for _, pod := range unionKeys(b, a) {
+		bs, inBefore := b[pod]
+		as, inAfter := a[pod]
+		c := ProxyConfigChange{Namespace: pod.namespace, Pod: pod.name}
+		switch {
+		case !inBefore:
+			c.Change = Added
+		case !inAfter:
+			c.Change = Removed
+		default:
+			sections, err := configDumpDiff(bs, as)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", namespacedName(pod.namespace, pod.name), err)
+			}
+			if len(sections) == 0 {
+				continue
+			}
+			c.Change = Modified
+			c.Sections = sections
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// volatileConfigDumpFields change on every push even when the config itself does not.
+var volatileConfigDumpFields = sets.New("version_info", "last_updated", "dynamic_active_clusters_version",
+	"dynamic_warming_clusters_version")
+
+// configDumpDiff returns the names of the config dump sections which differ between before and after, ignoring
+// versions and update timestamps.
+func configDumpDiff(before, after string) ([]string, error) {
+	b, err := configDumpSections(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := configDumpSections(after)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, s := range unionKeys(b, a) {
+		if !reflect.DeepEqual(b[s], a[s]) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func configDumpSections(dump string) (map[string]any, error) {
+	var cd struct {
+		Configs []object `json:"configs"`
+	}
+	if err := json.Unmarshal([]byte(dump), &cd); err != nil {
+		return nil, err
+	}
+	out := make(map[string]any)
+	for _, c := range cd.Configs {
+		t, _ := c["@type"].(string)
+		if i := strings.LastIndex(t, "."); i >= 0 {
+			t = t[i+1:]
+		}
+		out[t] = stripVolatile(c)
+	}
+	return out, nil
+}
+
+func stripVolatile(v any) any {
+	switch t := v.(type) {
+	case object:
+		for k, vv := range t {
+			if volatileConfigDumpFields.Contains(k) {
+				delete(t, k)
+				continue
+			}
+			t[k] = stripVolatile(vv)
+		}
+	case []any:
+		for i := range t {
+			t[i] = stripVolatile(t[i])
+		}
+	}
+	return v
+}
+
+func compareLogs(before, after *Archive, cfg *config.BugReportConfig) []LogSignature {
+	// Pod names change across rollouts, so a signature is only new if no log in the earlier archive contained it.
+	seen := sets.New[string]()
+	for _, l := range before.logs() {
+		for s := range processlog.ErrorSignatures(cfg, l.text) {
+			seen.Insert(s)
+		}
+	}
+	var out []LogSignature
+	for _, l := range after.logs() {
+		sigs := processlog.ErrorSignatures(cfg, l.text)
+		for _, s := range sets.SortedList(sets.New(maps.Keys(sigs)...)) {
+			if seen.Contains(s) {
+				continue
+			}
+			out = append(out, LogSignature{Namespace: l.namespace, Pod: l.pod, Container: l.container, Signature: s, Count: sigs[s]})
+		}
+	}
+	return out
+}
+
+func compareRestarts(before, after *Archive) ([]ContainerRestart, error) {
+	b, err := restartCounts(before)
+	if err != nil {
+		return nil, fmt.Errorf("before: %v", err)
+	}
+	a, err := restartCounts(after)
+	if err != nil {
+		return nil, fmt.Errorf("after: %v", err)
+	}
+	var out []ContainerRestart
+	for _, k := range sortedKeys(a) {
+		// Containers of pods which did not exist before are new, not restarted.
+		bc, ok := b[k]
+		if !ok || a[k] <= bc {
+			continue
+		}
+		out = append(out, ContainerRestart{Namespace: k.namespace, Pod: k.name, Container: k.container, Before: bc, After: a[k]})
+	}
+	return out, nil
+}
+
+// restartCounts returns the container restart counts of all pods in the archive.
+func restartCounts(a *Archive) (map[containerKey]int, error) {
+	items, err := listItems(a.cluster(k8sResourcesFile))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[containerKey]int)
+	for _, item := range items {
+		if item["kind"] != "Pod" {
+			continue
+		}
+		md, _ := item["metadata"].(object)
+		status, _ := item["status"].(object)
+		ns, _ := md["namespace"].(string)
+		name, _ := md["name"].(string)
+		for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+			statuses, _ := status[field].([]any)
+			for _, s := range statuses {
+				cs, _ := s.(object)
+				container, _ := cs["name"].(string)
+				count, _ := cs["restartCount"].(float64)
+				out[containerKey{podKey: podKey{namespace: ns, name: name}, container: container}] = int(count)
+			}
+		}
+	}
+	return out, nil
+}
+
+// listItems parses the output of a kubectl get -o yaml command, which is either a List or a single object.
+func listItems(text string) ([]object, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	list := object{}
+	if err := yaml.Unmarshal([]byte(text), &list); err != nil {
+		return nil, err
+	}
+	items, ok := list["items"].([]any)
+	if !ok {
+		return []object{list}, nil
+	}
+	out := make([]object, 0, len(items))
+	for _, i := range items {
+		if o, ok := i.(object); ok {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func namespacedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// sortedKeys returns the keys of m in a stable order.
+func sortedKeys[K comparable, V any](m map[K]V) []K {
+	return slices.SortFunc(maps.Keys(m), func(a, b K) bool { return fmt.Sprint(a) < fmt.Sprint(b) })
+}
+
+// unionKeys returns the sorted union of the keys of a and b.
+func unionKeys[K comparable, V any](a, b map[K]V) []K {
+	u := maps.Clone(a)
+	for k, v := range b {
+		u[k] = v
+	}
+	return sortedKeys(u)
+}
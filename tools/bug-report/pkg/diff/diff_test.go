@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/bug-report/pkg/archive"
+	"istio.io/istio/tools/bug-report/pkg/config"
+)
+
+const crsBefore = `apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.istio.io/v1beta1
+  kind: VirtualService
+  metadata:
+    name: reviews
+    namespace: default
+    resourceVersion: "100"
+  spec:
+    hosts: [reviews]
+- apiVersion: networking.istio.io/v1beta1
+  kind: DestinationRule
+  metadata:
+    name: reviews
+    namespace: default
+    resourceVersion: "101"
+  spec:
+    host: reviews
+- apiVersion: security.istio.io/v1beta1
+  kind: PeerAuthentication
+  metadata:
+    name: default
+    namespace: istio-system
+  spec:
+    mtls:
+      mode: STRICT
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: w
+`
+
+const crsAfter = `apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.istio.io/v1beta1
+  kind: VirtualService
+  metadata:
+    name: reviews
+    namespace: default
+    resourceVersion: "200"
+  spec:
+    hosts: [reviews]
+    http:
+    - route:
+      - destination:
+          host: reviews
+          subset: v2
+- apiVersion: networking.istio.io/v1beta1
+  kind: DestinationRule
+  metadata:
+    name: reviews
+    namespace: default
+    resourceVersion: "201"
+    generation: 3
+  spec:
+    host: reviews
+- apiVersion: networking.istio.io/v1beta1
+  kind: Gateway
+  metadata:
+    name: ingress
+    namespace: istio-system
+  spec: {}
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: w
+  spec:
+    changed: true
+`
+
+const podsBefore = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: reviews-v1-abc
+    namespace: default
+  status:
+    containerStatuses:
+    - name: reviews
+      restartCount: 0
+    - name: istio-proxy
+      restartCount: 1
+`
+
+const podsAfter = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: reviews-v1-abc
+    namespace: default
+  status:
+    containerStatuses:
+    - name: reviews
+      restartCount: 0
+    - name: istio-proxy
+      restartCount: 3
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: reviews-v2-def
+    namespace: default
+  status:
+    containerStatuses:
+    - name: istio-proxy
+      restartCount: 2
+`
+
+const (
+	dumpBefore = `{"configs":[
+{"@type":"type.googleapis.com/envoy.admin.v3.ListenersConfigDump","version_info":"1","dynamic_listeners":[{"name":"a"}]},
+{"@type":"type.googleapis.com/envoy.admin.v3.ClustersConfigDump","version_info":"1","dynamic_active_clusters":[{"version_info":"1","cluster":{"name":"c"}}]}
+]}`
+	dumpAfter = `{"configs":[
+{"@type":"type.googleapis.com/envoy.admin.v3.ListenersConfigDump","version_info":"2","dynamic_listeners":[{"name":"a"},{"name":"b"}]},
+{"@type":"type.googleapis.com/envoy.admin.v3.ClustersConfigDump","version_info":"2","dynamic_active_clusters":[{"version_info":"2","cluster":{"name":"c"}}]}
+]}`
+	dumpUnchanged = `{"configs":[{"@type":"type.googleapis.com/envoy.admin.v3.ListenersConfigDump","version_info":"5"}]}`
+)
+
+const (
+	logBefore = "2023-05-10T17:43:55.356647Z\terror\tads\tpush failed for 10.0.0.1:15010\n"
+	logAfter  = "2023-05-10T18:43:55.356647Z\terror\tads\tpush failed for 10.0.0.2:15010\n" +
+		"2023-05-10T18:43:56.356647Z\terror\tcache\tsecret fetch timed out after 30s\n" +
+		"2023-05-10T18:43:57.356647Z\terror\tcache\tsecret fetch timed out after 45s\n"
+)
+
+func TestCompare(t *testing.T) {
+	before := NewArchive(map[string]string{
+		"bug-report/cluster/crs":                                            crsBefore,
+		"bug-report/cluster/k8s-resources":                                  podsBefore,
+		"bug-report/proxies/default/reviews-v1-abc/config_dump?include_eds": dumpBefore,
+		"bug-report/proxies/default/ratings-v1-abc/config_dump?include_eds": dumpUnchanged,
+		"bug-report/proxies/default/gone-abc/config_dump?include_eds":       dumpUnchanged,
+		"bug-report/istio/istio-system/istiod-abc/discovery.log":            logBefore,
+	})
+	after := NewArchive(map[string]string{
+		"bug-report/cluster/crs":                                            crsAfter,
+		"bug-report/cluster/k8s-resources":                                  podsAfter,
+		"bug-report/proxies/default/reviews-v1-abc/config_dump?include_eds": dumpAfter,
+		"bug-report/proxies/default/ratings-v1-abc/config_dump?include_eds": dumpUnchanged,
+		"bug-report/proxies/ztunnel/ztunnel-xyz/config_dump":                `{"configs":[]}`,
+		"bug-report/istio/istio-system/istiod-def/discovery.log":            logAfter,
+	})
+
+	got, err := Compare(before, after, &config.BugReportConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, got, &Report{
+		Resources: []ResourceChange{
+			{Kind: "Gateway", Namespace: "istio-system", Name: "ingress", Change: Added},
+			{Kind: "PeerAuthentication", Namespace: "istio-system", Name: "default", Change: Removed},
+			{Kind: "VirtualService", Namespace: "default", Name: "reviews", Change: Modified},
+		},
+		ProxyConfigs: []ProxyConfigChange{
+			{Namespace: "default", Pod: "gone-abc", Change: Removed},
+			{Namespace: "default", Pod: "reviews-v1-abc", Change: Modified, Sections: []string{"ListenersConfigDump"}},
+			{Namespace: "ztunnel", Pod: "ztunnel-xyz", Change: Added},
+		},
+		NewErrors: []LogSignature{
+			{
+				Namespace: "istio-system", Pod: "istiod-def", Container: "discovery",
+				Signature: "error\tcache secret fetch timed out after <num>", Count: 2,
+			},
+		},
+		Restarts: []ContainerRestart{
+			{Namespace: "default", Pod: "reviews-v1-abc", Container: "istio-proxy", Before: 1, After: 3},
+		},
+	})
+}
+
+func TestCompareIdentical(t *testing.T) {
+	files := map[string]string{
+		"cluster/crs":           crsBefore,
+		"cluster/k8s-resources": podsBefore,
+		"istio/istio-system/istiod-abc/discovery.log": logBefore,
+	}
+	got, err := Compare(NewArchive(files), NewArchive(files), &config.BugReportConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, got.Empty(), true)
+	assert.Equal(t, got.String(), "No differences found.\n")
+}
+
+func TestLoad(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "bug-report")
+	files := map[string]string{
+		"cluster/crs": crsBefore,
+		"proxies/default/reviews-v1-abc/config_dump?include_eds": dumpBefore,
+	}
+	for name, text := range files {
+		p := filepath.Join(src, "bug-report", name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(text), 0o644))
+	}
+	tarball := filepath.Join(t.TempDir(), "bug-report.tar.gz")
+	assert.NoError(t, archive.Create(src, tarball))
+
+	for _, path := range []string{src, tarball} {
+		a, err := Load(path)
+		assert.NoError(t, err)
+		assert.Equal(t, a.files, files)
+	}
+}
@@ -35,6 +35,18 @@ const (
 
 var ztunnelLogPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+Z)\s+(?:\w+\s+)?(\w+)\s+([\w\.:]+)(.*)`)
 
+// signatureReplacers strip the variable parts of a log message (addresses, identifiers, counters) so that
+// messages which differ only by those parts map to the same signature.
+var signatureReplacers = []struct {
+	pattern *regexp.Regexp
+	repl    string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{12,}\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ms|s|us|ns)?\b`), "<num>"},
+}
+
 // Stats represents log statistics.
 type Stats struct {
 	numFatals   int
@@ -108,6 +120,37 @@ func getStats(config *config.BugReportConfig, logStr string) *Stats {
 	return out
 }
 
+// ErrorSignatures returns the normalized signatures of the fatal and error entries in logStr, mapped to the number
+// of times each was seen. Entries matching config.IgnoredErrors are skipped.
+func ErrorSignatures(config *config.BugReportConfig, logStr string) map[string]int {
+	out := make(map[string]int)
+	for _, l := range strings.Split(logStr, "\n") {
+		_, level, text, valid := parseLog(l)
+		if !valid {
+			continue
+		}
+		switch strings.ToLower(level) {
+		case levelFatal, levelError:
+		default:
+			continue
+		}
+		// MatchesGlobs treats an empty pattern list as match-all, which is not what we want here.
+		if len(config.IgnoredErrors) > 0 && match.MatchesGlobs(text, config.IgnoredErrors) {
+			continue
+		}
+		out[level+"\t"+signature(text)]++
+	}
+	return out
+}
+
+// signature returns text with its variable parts replaced by placeholders.
+func signature(text string) string {
+	for _, r := range signatureReplacers {
+		text = r.pattern.ReplaceAllString(text, r.repl)
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
 func parseLog(line string) (timeStamp *time.Time, level string, text string, valid bool) {
 	if isJSONLog(line) {
 		return parseJSONLog(line)
@@ -80,3 +80,21 @@ func TestProcessLogsFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorSignatures(t *testing.T) {
+	logStr := `2023-05-10T17:43:55.356647Z	error	ads	ADS:CDS: ACK ERROR sidecar~10.244.0.7~foo-abc.default~default.svc.cluster.local-12 Internal:Proxy rejected
+2023-05-10T17:43:56.356647Z	error	ads	ADS:CDS: ACK ERROR sidecar~10.244.0.9~bar-def.default~default.svc.cluster.local-34 Internal:Proxy rejected
+2023-05-10T17:43:57.356647Z	info	ads	Push debounce stable 3 for config ServiceEntry
+2023-05-10T17:43:58.356647Z	error	cache	ignored failure
+{"time":"2023-05-10T17:43:59.356647Z","level":"fatal","msg":"failed to start after 30s"}`
+	c := &config.BugReportConfig{IgnoredErrors: []string{"*ignored*"}}
+	got := ErrorSignatures(c, logStr)
+	want := map[string]int{
+		"error\tads ADS:CDS: ACK ERROR sidecar~<ip>~foo-abc.default~default.svc.cluster.local-<num> Internal:Proxy rejected": 1,
+		"error\tads ADS:CDS: ACK ERROR sidecar~<ip>~bar-def.default~default.svc.cluster.local-<num> Internal:Proxy rejected": 1,
+		"fatal\tfailed to start after <num>": 1,
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("unexpected signatures (-got, +want):\n%s", diff)
+	}
+}
@@ -101,4 +101,14 @@ func (c *IptablesCleaner) Run() {
 	for _, cmd := range []string{constants.IPTABLES, constants.IP6TABLES} {
 		removeOldChains(c.cfg, c.ext, cmd)
 	}
+	removeNftables(c.ext)
+}
+
+// removeNftables deletes the tables created by the nftables backend, if any.
+func removeNftables(ext dep.Dependencies) {
+	for _, family := range []string{"ip", "ip6"} {
+		for _, table := range []string{constants.NAT, constants.MANGLE, constants.RAW, constants.FILTER} {
+			ext.RunQuietlyAndIgnore(constants.NFT, nil, "delete", "table", family, builder.NftablesTablePrefix+table)
+		}
+	}
 }
@@ -109,6 +109,21 @@ func TestIptables(t *testing.T) {
 	}
 }
 
+func TestRemoveNftables(t *testing.T) {
+	ext := &DependenciesStub{}
+	removeNftables(ext)
+	var want []string
+	for _, family := range []string{"ip", "ip6"} {
+		// Every table the nftables builder can create.
+		for _, table := range []string{"nat", "mangle", "raw", "filter"} {
+			want = append(want, "nft delete table "+family+" istio_"+table)
+		}
+	}
+	if diff := cmp.Diff(ext.ExecutedQuietly, want); diff != "" {
+		t.Fatalf("nft commands: got\n%v\nwant\n%v\ndiff %v", ext.ExecutedQuietly, want, diff)
+	}
+}
+
 func compareToGolden(t *testing.T, name string, actual []string) {
 	t.Helper()
 	gotBytes := []byte(strings.Join(actual, "\n"))
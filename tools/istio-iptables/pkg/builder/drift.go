@@ -0,0 +1,456 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"net/netip"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// RulesDiff is the difference between the rules added to the builder and the rules in place.
+type RulesDiff struct {
+	// Missing rules are expected but not in place.
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected rules are in a chain owned by Istio, but not expected.
+	Unexpected []string `json:"unexpected,omitempty"`
+	// Reordered chains hold the expected rules, in a different order.
+	Reordered []string `json:"reordered,omitempty"`
+	// Bypassing rules of other components are placed before the expected rules in a built-in chain, so the traffic
+	// they match skips the redirection.
+	Bypassing []string `json:"bypassing,omitempty"`
+}
+
+// Empty returns true if the rules in place are the expected ones.
+func (d RulesDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0 && len(d.Reordered) == 0 && len(d.Bypassing) == 0
+}
+
+// Merge returns the union of both differences.
+func (d RulesDiff) Merge(o RulesDiff) RulesDiff {
+	return RulesDiff{
+		Missing:    concat(d.Missing, o.Missing),
+		Unexpected: concat(d.Unexpected, o.Unexpected),
+		Reordered:  concat(d.Reordered, o.Reordered),
+		Bypassing:  concat(d.Bypassing, o.Bypassing),
+	}
+}
+
+func concat(a, b []string) []string {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+	return append(append(make([]string, 0, len(a)+len(b)), a...), b...)
+}
+
+func (d RulesDiff) String() string {
+	var parts []string
+	if len(d.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing", len(d.Missing)))
+	}
+	if len(d.Unexpected) > 0 {
+		parts = append(parts, fmt.Sprintf("%d unexpected", len(d.Unexpected)))
+	}
+	if len(d.Reordered) > 0 {
+		parts = append(parts, "reordered "+strings.Join(d.Reordered, ","))
+	}
+	if len(d.Bypassing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d bypassing", len(d.Bypassing)))
+	}
+	if len(parts) == 0 {
+		return "in sync"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DiffV4 compares the IPv4 rules added to the builder with the output of iptables-save.
+func (rb *IptablesBuilder) DiffV4(iptablesSave string) (RulesDiff, error) {
+	return diffIptables(rb.rules.rulesv4, iptablesSave)
+}
+
+// DiffV6 compares the IPv6 rules added to the builder with the output of ip6tables-save.
+func (rb *IptablesBuilder) DiffV6(ip6tablesSave string) (RulesDiff, error) {
+	return diffIptables(rb.rules.rulesv6, ip6tablesSave)
+}
+
+// diffIptables compares the chains of the expected rules. Chains created by Istio must hold exactly the expected
+// rules, in order. Other components may add rules to the built-in chains, so only the presence and relative order
+// of the expected rules are checked there. Rules are compared once normalized, as iptables-save prints them in its
+// own way: matches in a canonical order, numbers in hexadecimal, owners by ID, and so on.
+// The rules of other components placed before the expected ones in a built-in chain are reported as bypassing, unless
+// they cannot stop the traversal of the chain.
+func diffIptables(rules []*Rule, iptablesSave string) (RulesDiff, error) {
+	expected, err := layoutRules(rules)
+	if err != nil {
+		return RulesDiff{}, err
+	}
+	live := parseIptablesSave(iptablesSave)
+	var diff RulesDiff
+	for _, t := range expected {
+		for _, c := range t.chains {
+			want := make([]string, 0, len(c.rules))
+			for _, r := range c.rules {
+				want = append(want, normalizeIptablesRule(r))
+			}
+			got := live[t.name+"/"+c.name]
+			name := t.name + "/" + c.name
+			if _, builtin := constants.BuiltInChainsMap[c.name]; builtin {
+				// Only the rules of other components placed before the expected ones matter.
+				expectedSet := map[string]bool{}
+				for _, r := range want {
+					expectedSet[r] = true
+				}
+				filtered := make([]string, 0, len(got))
+				var bypassing []string
+				for _, r := range got {
+					switch {
+					case expectedSet[r]:
+						filtered = append(filtered, r)
+					case len(filtered) == 0 && !nonTerminating(r):
+						bypassing = append(bypassing, name+": "+r)
+					}
+				}
+				if len(filtered) > 0 {
+					// Otherwise none of the expected rules is in place, they are all reported missing.
+					diff.Bypassing = append(diff.Bypassing, bypassing...)
+				}
+				got = filtered
+			}
+			diff = diff.Merge(diffChain(name, want, got))
+		}
+	}
+	return diff, nil
+}
+
+// nonTerminatingTargets do not stop the traversal of a chain.
+var nonTerminatingTargets = map[string]bool{
+	"LOG":      true,
+	"NFLOG":    true,
+	"TRACE":    true,
+	"MARK":     true,
+	"CONNMARK": true,
+	"CT":       true,
+}
+
+// nonTerminating returns true if a normalized rule cannot stop the traversal of its chain.
+func nonTerminating(rule string) bool {
+	_, target, found := strings.Cut(rule, "-j ")
+	if !found {
+		// Rules without target only update counters.
+		return true
+	}
+	return nonTerminatingTargets[strings.Fields(target)[0]]
+}
+
+func diffChain(name string, want, got []string) RulesDiff {
+	var diff RulesDiff
+	remaining := map[string]int{}
+	for _, r := range got {
+		remaining[r]++
+	}
+	for _, r := range want {
+		if remaining[r] > 0 {
+			remaining[r]--
+			continue
+		}
+		diff.Missing = append(diff.Missing, name+": "+r)
+	}
+	for _, r := range got {
+		if remaining[r] > 0 {
+			remaining[r]--
+			diff.Unexpected = append(diff.Unexpected, name+": "+r)
+		}
+	}
+	if diff.Empty() && strings.Join(want, "\n") != strings.Join(got, "\n") {
+		diff.Reordered = append(diff.Reordered, name)
+	}
+	return diff
+}
+
+// parseIptablesSave returns the normalized rules of each table/chain.
+func parseIptablesSave(out string) map[string][]string {
+	res := map[string][]string{}
+	table := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, "-A "):
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			key := table + "/" + fields[1]
+			res[key] = append(res[key], normalizeIptablesRule(fields[2:]))
+		}
+	}
+	return res
+}
+
+// lookupOwnerID resolves a user or group name to its ID, as iptables-save only prints IDs.
+var lookupOwnerID = func(flag, name string) string {
+	if flag == "--uid-owner" {
+		if u, err := user.Lookup(name); err == nil {
+			return u.Uid
+		}
+	} else if g, err := user.LookupGroup(name); err == nil {
+		return g.Gid
+	}
+	return name
+}
+
+// normalizeIptablesRule returns a canonical form of the matches and target of a rule. Matches are sorted, as their
+// order does not change what the rule matches.
+func normalizeIptablesRule(params []string) string {
+	var (
+		matches []string
+		target  []string
+		module  string
+		negate  bool
+	)
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == "!":
+			negate = true
+			continue
+		case p == "-j" || p == "-g":
+			target = normalizeIptablesTarget(params[i+1:])
+			i = len(params)
+			continue
+		case i+1 >= len(params):
+			matches = append(matches, p)
+			continue
+		}
+		i++
+		v := strings.Trim(params[i], `"`)
+		switch p {
+		case "-m", "--match":
+			// Modules are implied by their options, except for marks.
+			module = v
+			continue
+		case "-s", "--source":
+			p, v = "-s", normalizePrefix(v)
+		case "-d", "--destination":
+			p, v = "-d", normalizePrefix(v)
+		case "--uid-owner", "--gid-owner":
+			if _, err := strconv.Atoi(v); err != nil {
+				v = lookupOwnerID(p, v)
+			}
+		case "--mark":
+			p = module + " " + p
+			v = normalizeNumber(v)
+		}
+		m := p + " " + v
+		if negate {
+			m = "! " + m
+		}
+		matches = append(matches, m)
+		negate = false
+	}
+	sort.Strings(matches)
+	return strings.Join(append(matches, target...), " ")
+}
+
+func normalizeIptablesTarget(params []string) []string {
+	if len(params) == 0 {
+		return nil
+	}
+	res := []string{"-j", params[0]}
+	var opts []string
+	for i := 1; i < len(params); i++ {
+		p := params[i]
+		v := ""
+		if i+1 < len(params) && !strings.HasPrefix(params[i+1], "--") {
+			v = strings.Trim(params[i+1], `"`)
+			i++
+		}
+		switch p {
+		case "--to-port":
+			p = "--to-ports"
+		case "--set-xmark":
+			p = "--set-mark"
+		case "--on-ip":
+			if v == "0.0.0.0" || v == "::" {
+				// Default value.
+				continue
+			}
+		}
+		switch p {
+		case "--set-mark", "--tproxy-mark", "--zone":
+			v = normalizeNumber(v)
+		case "--nfmask", "--ctmask":
+			if v == "0xffffffff" {
+				// Default value.
+				continue
+			}
+		}
+		opts = append(opts, strings.TrimSpace(p+" "+v))
+	}
+	sort.Strings(opts)
+	return append(res, opts...)
+}
+
+// normalizeNumber prints numbers in decimal, without a mask covering every bit.
+func normalizeNumber(v string) string {
+	num, mask, found := strings.Cut(v, "/")
+	if found && mask != "0xffffffff" {
+		return v
+	}
+	n, err := strconv.ParseUint(num, 0, 32)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// normalizePrefix prints addresses as prefixes.
+func normalizePrefix(v string) string {
+	if p, err := netip.ParsePrefix(v); err == nil {
+		return p.Masked().String()
+	}
+	if a, err := netip.ParseAddr(v); err == nil {
+		return netip.PrefixFrom(a, a.BitLen()).String()
+	}
+	return v
+}
+
+// DiffNftables compares the rules added to the builder with the output of `nft list ruleset`. nft prints rules in
+// a normalized form that differs from the generated one, so only the Istio tables and chains and the number of rules
+// in each chain are compared.
+func (rb *IptablesBuilder) DiffNftables(ruleset string) (RulesDiff, error) {
+	live := parseNftRuleset(ruleset)
+	var diff RulesDiff
+	for _, family := range []struct {
+		name  string
+		rules []*Rule
+	}{{"ip", rb.rules.rulesv4}, {"ip6", rb.rules.rulesv6}} {
+		expected, err := layoutRules(family.rules)
+		if err != nil {
+			return RulesDiff{}, err
+		}
+		for _, t := range expected {
+			for _, c := range t.chains {
+				name := fmt.Sprintf("%s %s%s/%s", family.name, NftablesTablePrefix, t.name, c.name)
+				got, ok := live[name]
+				switch {
+				case !ok:
+					diff.Missing = append(diff.Missing, name+": chain")
+				case got < len(c.rules):
+					diff.Missing = append(diff.Missing, fmt.Sprintf("%s: %d of %d rules", name, len(c.rules)-got, len(c.rules)))
+				case got > len(c.rules):
+					diff.Unexpected = append(diff.Unexpected, fmt.Sprintf("%s: %d rules", name, got-len(c.rules)))
+				}
+			}
+		}
+	}
+	return diff, nil
+}
+
+// parseNftRuleset returns the number of rules of the chains of the Istio tables, keyed by "family table/chain".
+func parseNftRuleset(out string) map[string]int {
+	res := map[string]int{}
+	table, chain := "", ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case len(fields) == 4 && fields[0] == "table" && fields[3] == "{":
+			table, chain = "", ""
+			if strings.HasPrefix(fields[2], NftablesTablePrefix) {
+				table = fields[1] + " " + fields[2]
+			}
+		case table == "":
+		case len(fields) == 3 && fields[0] == "chain" && fields[2] == "{":
+			chain = table + "/" + fields[1]
+			res[chain] = 0
+		case fields[0] == "}":
+			if chain == "" {
+				table = ""
+			}
+			chain = ""
+		case chain != "" && fields[0] != "type" && fields[0] != "policy":
+			res[chain]++
+		}
+	}
+	return res
+}
+
+// BuildV4Repair returns the commands restoring the IPv4 rules added to the builder, whatever rules are in place.
+// The delete commands remove the expected rules from the built-in chains, ignoring failures, before the
+// iptables-restore input recreates the Istio chains, flushing them if they exist, adds their rules and inserts the
+// rules of the built-in chains at their top.
+func (rb *IptablesBuilder) BuildV4Repair() ([][]string, string) {
+	return buildRepair(constants.IPTABLES, rb.rules.rulesv4)
+}
+
+// BuildV6Repair is the IPv6 equivalent of BuildV4Repair.
+func (rb *IptablesBuilder) BuildV6Repair() ([][]string, string) {
+	return buildRepair(constants.IP6TABLES, rb.rules.rulesv6)
+}
+
+func buildRepair(command string, rules []*Rule) ([][]string, string) {
+	deletes := make([][]string, 0)
+	tableRules := map[string][]string{}
+	var tables []string
+	declared := map[string]bool{}
+	for _, r := range rules {
+		if _, ok := tableRules[r.table]; !ok {
+			tables = append(tables, r.table)
+			tableRules[r.table] = nil
+		}
+		if _, builtin := constants.BuiltInChainsMap[r.chain]; builtin {
+			deletes = append(deletes, append([]string{command, "-t", r.table, "-D", r.chain}, ruleArgs(r)...))
+		} else if !declared[r.table+"/"+r.chain] {
+			// Unlike -N, declaring a chain flushes it if it exists.
+			tableRules[r.table] = append(tableRules[r.table], fmt.Sprintf(":%s - [0:0]", r.chain))
+			declared[r.table+"/"+r.chain] = true
+		}
+	}
+	// The rules of the built-in chains are inserted at their top, in order, so the rules of other components placed
+	// before them no longer bypass the redirection.
+	positions := map[string]int{}
+	for _, r := range rules {
+		params := r.params
+		if _, builtin := constants.BuiltInChainsMap[r.chain]; builtin {
+			positions[r.table+"/"+r.chain]++
+			params = append([]string{"-I", r.chain, strconv.Itoa(positions[r.table+"/"+r.chain])}, ruleArgs(r)...)
+		}
+		tableRules[r.table] = append(tableRules[r.table], strings.Join(params, " "))
+	}
+	var b strings.Builder
+	for _, table := range tables {
+		_, _ = fmt.Fprintln(&b, "*", table)
+		for _, r := range tableRules[table] {
+			_, _ = fmt.Fprintln(&b, r)
+		}
+		_, _ = fmt.Fprintln(&b, "COMMIT")
+	}
+	return deletes, b.String()
+}
+
+// ruleArgs returns the matches and target of a rule, without the command adding it to its chain.
+func ruleArgs(r *Rule) []string {
+	if r.params[0] == "-I" {
+		return r.params[3:]
+	}
+	return r.params[2:]
+}
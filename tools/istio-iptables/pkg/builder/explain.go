@@ -0,0 +1,384 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// Packet is a hypothetical packet evaluated against the rules by Explain. Only the properties matched by the rules
+// istio-iptables generates are modeled.
+type Packet struct {
+	// Inbound packets are received by the pod on Interface. Other packets are sent by a process of the pod through
+	// Interface.
+	Inbound  bool
+	Protocol string
+	Src      netip.AddrPort
+	Dst      netip.AddrPort
+	// Interface is the interface the packet is received on, or sent through.
+	Interface string
+	// UID and GID of the process sending an outbound packet.
+	UID string
+	GID string
+	// CtState is the conntrack state of the connection of the packet, NEW if empty.
+	CtState string
+	Mark    uint32
+}
+
+// Verdict is where a packet ends up once the rules are applied.
+type Verdict string
+
+const (
+	// VerdictRedirected packets are sent to a local port by a REDIRECT rule.
+	VerdictRedirected Verdict = "redirected"
+	// VerdictDiverted packets are delivered to a local port by a TPROXY rule, keeping their original destination.
+	VerdictDiverted Verdict = "diverted"
+	// VerdictPassthrough packets go to their original destination.
+	VerdictPassthrough Verdict = "passthrough"
+	// VerdictDropped packets are dropped or rejected.
+	VerdictDropped Verdict = "dropped"
+)
+
+// TraceStep is a rule matching the packet.
+type TraceStep struct {
+	Table string
+	Chain string
+	Rule  string
+}
+
+// Trace is the path of a packet through the rules.
+type Trace struct {
+	Steps   []TraceStep
+	Verdict Verdict
+	// Dst is the destination of the packet once the rules are applied.
+	Dst netip.AddrPort
+	// Mark is the mark of the packet once the rules are applied.
+	Mark uint32
+}
+
+// explainHooks lists the chains traversed by inbound and outbound packets, in order.
+var explainHooks = map[bool][][2]string{
+	true: {
+		{constants.RAW, constants.PREROUTING},
+		{constants.MANGLE, constants.PREROUTING},
+		{constants.NAT, constants.PREROUTING},
+		{constants.MANGLE, constants.INPUT},
+		{constants.NAT, constants.INPUT},
+		{constants.FILTER, constants.INPUT},
+	},
+	false: {
+		{constants.RAW, constants.OUTPUT},
+		{constants.MANGLE, constants.OUTPUT},
+		{constants.NAT, constants.OUTPUT},
+		{constants.FILTER, constants.OUTPUT},
+		{constants.MANGLE, constants.POSTROUTING},
+		{constants.NAT, constants.POSTROUTING},
+	},
+}
+
+// maxJumpDepth bounds the chains a packet can jump through, as the kernel does to reject loops.
+const maxJumpDepth = 32
+
+// Explain evaluates a packet against the rules added to the builder, and returns the rules matching it and where it
+// ends up. The IPv6 rules are used if the destination is an IPv6 address.
+func (rb *IptablesBuilder) Explain(pkt Packet) (*Trace, error) {
+	rules := rb.rules.rulesv4
+	if pkt.Dst.Addr().Is6() {
+		rules = rb.rules.rulesv6
+	}
+	tables, err := layoutRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if pkt.CtState == "" {
+		pkt.CtState = "NEW"
+	}
+	e := &explainer{tables: tables, pkt: pkt, trace: &Trace{Verdict: VerdictPassthrough}}
+	for _, hook := range explainHooks[pkt.Inbound] {
+		// The nat table only sees the first packet of each connection.
+		if hook[0] == constants.NAT && !strings.EqualFold(pkt.CtState, "NEW") {
+			continue
+		}
+		if _, err := e.traverse(hook[0], hook[1], 0); err != nil {
+			return nil, err
+		}
+		if e.trace.Verdict == VerdictDropped {
+			break
+		}
+	}
+	e.trace.Dst = e.pkt.Dst
+	e.trace.Mark = e.pkt.Mark
+	return e.trace, nil
+}
+
+type explainer struct {
+	tables []*ruleTable
+	pkt    Packet
+	ctMark uint32
+	trace  *Trace
+}
+
+func (e *explainer) rules(table, chain string) [][]string {
+	for _, t := range e.tables {
+		if t.name != table {
+			continue
+		}
+		for _, c := range t.chains {
+			if c.name == chain {
+				return c.rules
+			}
+		}
+	}
+	return nil
+}
+
+// traverse evaluates the rules of a chain, and returns true if the packet reached a terminating target.
+func (e *explainer) traverse(table, chain string, depth int) (bool, error) {
+	if depth > maxJumpDepth {
+		return false, fmt.Errorf("too many jumps from chain %s", chain)
+	}
+	for _, params := range e.rules(table, chain) {
+		match, target, err := e.matches(params)
+		if err != nil {
+			return false, fmt.Errorf("%v: %v", strings.Join(params, " "), err)
+		}
+		if !match {
+			continue
+		}
+		e.trace.Steps = append(e.trace.Steps, TraceStep{Table: table, Chain: chain, Rule: strings.Join(params, " ")})
+		terminal, jump, err := e.apply(target)
+		if err != nil {
+			return false, fmt.Errorf("%v: %v", strings.Join(params, " "), err)
+		}
+		if jump != "" {
+			terminal, err = e.traverse(table, jump, depth+1)
+			if err != nil {
+				return false, err
+			}
+		}
+		if terminal {
+			return true, nil
+		}
+		if target[0] == constants.RETURN {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// matches returns whether the packet matches a rule, and the target of the rule with its options.
+func (e *explainer) matches(params []string) (bool, []string, error) {
+	var (
+		module string
+		negate bool
+	)
+	result := true
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		if p == "-j" {
+			if i+1 >= len(params) {
+				return false, nil, fmt.Errorf("missing target")
+			}
+			return result, params[i+1:], nil
+		}
+		if i+1 >= len(params) {
+			return false, nil, fmt.Errorf("missing value for %q", p)
+		}
+		i++
+		v := params[i]
+		var m bool
+		switch p {
+		case "-m":
+			module = v
+			continue
+		case "-p":
+			m = v == "all" || strings.EqualFold(v, e.pkt.Protocol)
+		case "--dport", "--dports":
+			m = matchPorts(v, e.pkt.Dst.Port())
+		case "--sport", "--sports":
+			m = matchPorts(v, e.pkt.Src.Port())
+		case "-d":
+			m = matchPrefix(v, e.pkt.Dst.Addr())
+		case "-s":
+			m = matchPrefix(v, e.pkt.Src.Addr())
+		case "-i":
+			m = e.pkt.Inbound && matchInterface(v, e.pkt.Interface)
+		case "-o":
+			m = !e.pkt.Inbound && matchInterface(v, e.pkt.Interface)
+		case "--uid-owner", "--gid-owner":
+			id := e.pkt.UID
+			if p == "--gid-owner" {
+				id = e.pkt.GID
+			}
+			// Like the kernel, inbound packets have no owner, so only negated matches apply to them.
+			m = !e.pkt.Inbound && id == v
+		case "--ctstate", "--state":
+			m = false
+			for _, s := range strings.Split(v, ",") {
+				m = m || strings.EqualFold(s, e.pkt.CtState)
+			}
+		case "--mark":
+			value, mask, err := parseMark(v)
+			if err != nil {
+				return false, nil, err
+			}
+			mark := e.pkt.Mark
+			if module == "connmark" {
+				mark = e.ctMark
+			}
+			m = mark&mask == value&mask
+		default:
+			return false, nil, fmt.Errorf("unsupported match %q", p)
+		}
+		if negate {
+			m = !m
+		}
+		negate = false
+		result = result && m
+	}
+	return false, nil, fmt.Errorf("missing target")
+}
+
+// apply applies a target to the packet. It returns whether the target terminates the traversal of the table, or
+// the chain to jump to.
+func (e *explainer) apply(target []string) (bool, string, error) {
+	opts := map[string]string{}
+	for i := 1; i < len(target); i++ {
+		if i+1 < len(target) && !strings.HasPrefix(target[i+1], "--") {
+			opts[target[i]] = target[i+1]
+			i++
+		} else {
+			opts[target[i]] = ""
+		}
+	}
+	switch target[0] {
+	case constants.RETURN:
+		return false, "", nil
+	case constants.ACCEPT:
+		return true, "", nil
+	case constants.DROP, constants.REJECT:
+		e.trace.Verdict = VerdictDropped
+		return true, "", nil
+	case constants.REDIRECT:
+		port, ok := opts["--to-ports"]
+		if !ok {
+			port = opts["--to-port"]
+		}
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid port %q", port)
+		}
+		addr := e.pkt.Dst.Addr()
+		if !e.pkt.Inbound {
+			// Locally generated packets are redirected to the loopback address.
+			addr = netip.MustParseAddr("127.0.0.1")
+			if e.pkt.Dst.Addr().Is6() {
+				addr = netip.IPv6Loopback()
+			}
+		}
+		e.pkt.Dst = netip.AddrPortFrom(addr, uint16(p))
+		e.trace.Verdict = VerdictRedirected
+		return true, "", nil
+	case constants.TPROXY:
+		p, err := strconv.ParseUint(opts["--on-port"], 10, 16)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid port %q", opts["--on-port"])
+		}
+		if err := e.setMark(opts["--tproxy-mark"]); err != nil {
+			return false, "", err
+		}
+		e.pkt.Dst = netip.AddrPortFrom(e.pkt.Dst.Addr(), uint16(p))
+		e.trace.Verdict = VerdictDiverted
+		return true, "", nil
+	case constants.MARK:
+		return false, "", e.setMark(opts["--set-mark"])
+	case "CONNMARK":
+		if _, ok := opts["--save-mark"]; ok {
+			e.ctMark = e.pkt.Mark
+		} else if _, ok := opts["--restore-mark"]; ok {
+			e.pkt.Mark = e.ctMark
+		}
+		return false, "", nil
+	case constants.CT, "NFLOG", "LOG":
+		return false, "", nil
+	}
+	if strings.HasPrefix(target[0], "ISTIO_") {
+		return false, target[0], nil
+	}
+	return false, "", fmt.Errorf("unsupported target %q", target[0])
+}
+
+func (e *explainer) setMark(v string) error {
+	value, mask, err := parseMark(v)
+	if err != nil {
+		return err
+	}
+	e.pkt.Mark = e.pkt.Mark&^mask | value&mask
+	return nil
+}
+
+// parseMark parses a mark with an optional mask.
+func parseMark(v string) (uint32, uint32, error) {
+	value, mask, found := strings.Cut(v, "/")
+	n, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mark %q", v)
+	}
+	m := uint64(0xffffffff)
+	if found {
+		if m, err = strconv.ParseUint(mask, 0, 32); err != nil {
+			return 0, 0, fmt.Errorf("invalid mark %q", v)
+		}
+	}
+	return uint32(n), uint32(m), nil
+}
+
+// matchPorts matches a port against an iptables list (a,b) or range (a:b) of ports.
+func matchPorts(v string, port uint16) bool {
+	for _, r := range strings.Split(v, ",") {
+		lo, hi, found := strings.Cut(r, ":")
+		if !found {
+			hi = lo
+		}
+		l, err1 := strconv.ParseUint(lo, 10, 16)
+		h, err2 := strconv.ParseUint(hi, 10, 16)
+		if err1 == nil && err2 == nil && uint64(port) >= l && uint64(port) <= h {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPrefix(v string, addr netip.Addr) bool {
+	p, err := netip.ParsePrefix(normalizePrefix(v))
+	return err == nil && p.Contains(addr)
+}
+
+// matchInterface matches an interface name, a trailing + matching any suffix.
+func matchInterface(v, name string) bool {
+	if prefix, ok := strings.CutSuffix(v, "+"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return v == name
+}
@@ -16,6 +16,7 @@ package builder
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"istio.io/istio/tools/istio-iptables/pkg/config"
@@ -312,3 +313,125 @@ func TestBuildV4V6MultipleRulesWithNewChain(t *testing.T) {
 		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actualV6, expectedV6)
 	}
 }
+
+func TestBuildNftablesInsertAppend(t *testing.T) {
+	iptables := NewIptablesBuilder(&config.Config{EnableInboundIPv6: true})
+	iptables.AppendRule(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT, "-p", "tcp", "-j", constants.RETURN)
+	iptables.InsertRule(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT, 1,
+		"-o", "lo", "!", "-d", constants.IPVersionSpecific, "-m", "owner", "!", "--uid-owner", "1337", "-j", constants.ISTIOREDIRECT)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.NAT, "-p", "tcp", "-j", constants.ISTIOOUTPUT)
+	actual, err := iptables.BuildNftables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `add table ip istio_nat
+delete table ip istio_nat
+table ip istio_nat {
+	chain ISTIO_OUTPUT {
+		oifname "lo" ip daddr != PLACEHOLDER_IP_VERSION_SPECIFIC meta skuid != 1337 jump ISTIO_REDIRECT
+		meta l4proto tcp return
+	}
+	chain OUTPUT {
+		type nat hook output priority dstnat; policy accept;
+		meta l4proto tcp jump ISTIO_OUTPUT
+	}
+}
+add table ip6 istio_nat
+delete table ip6 istio_nat
+table ip6 istio_nat {
+	chain ISTIO_OUTPUT {
+		oifname "lo" ip6 daddr != PLACEHOLDER_IP_VERSION_SPECIFIC meta skuid != 1337 jump ISTIO_REDIRECT
+		meta l4proto tcp return
+	}
+}
+`
+	if actual != expected {
+		t.Errorf("Output didn't match: Got: %s, Expected: %s", actual, expected)
+	}
+}
+
+func TestBuildNftablesUnsupported(t *testing.T) {
+	cases := [][]string{
+		{"-m", "mark", "--mark", "0x1/0x1", "-j", constants.RETURN},
+		{"--dport", "53", "-j", constants.RETURN},
+		{"-p", "tcp", "-j", "LOG"},
+		{"-p", "tcp"},
+	}
+	for _, params := range cases {
+		iptables := NewIptablesBuilder(nil)
+		iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT, params...)
+		if _, err := iptables.BuildNftables(); err == nil {
+			t.Errorf("Expected an error for %v", params)
+		}
+	}
+}
+
+func TestNormalizeIptablesRule(t *testing.T) {
+	cases := []struct {
+		generated []string
+		saved     string
+	}{
+		{
+			[]string{"-p", "tcp", "-m", "mark", "--mark", "1337", "-j", "CONNMARK", "--save-mark"},
+			"-p tcp -m mark --mark 0x539 -j CONNMARK --save-mark --nfmask 0xffffffff --ctmask 0xffffffff",
+		},
+		{
+			[]string{"!", "-d", "127.0.0.1/32", "-p", "tcp", "-j", "TPROXY", "--tproxy-mark", "1337/0xffffffff", "--on-port", "15006"},
+			"! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15006 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff",
+		},
+		{
+			[]string{"!", "-d", "::1/128", "-p", "tcp", "-o", "lo", "-m", "owner", "--gid-owner", "1337", "-j", "MARK", "--set-mark", "1338"},
+			"! -d ::1/128 -o lo -p tcp -m owner --gid-owner 1337 -j MARK --set-xmark 0x53a/0xffffffff",
+		},
+		{
+			[]string{"-p", "tcp", "-m", "connmark", "--mark", "1337", "-j", "CONNMARK", "--restore-mark"},
+			"-p tcp -m connmark --mark 0x539 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff",
+		},
+		{
+			[]string{"-p", "udp", "--dport", "53", "-d", "127.0.0.53", "-j", constants.REDIRECT, "--to-port", "15053"},
+			"-d 127.0.0.53/32 -p udp -m udp --dport 53 -j REDIRECT --to-ports 15053",
+		},
+		{
+			[]string{"-p", "tcp", "-j", "NFLOG", "--nflog-prefix", `"InboundCapture"`, "--nflog-group", "1337", "--nflog-size", "20"},
+			"-p tcp -j NFLOG --nflog-prefix InboundCapture --nflog-size 20 --nflog-group 1337",
+		},
+	}
+	for _, tt := range cases {
+		want := normalizeIptablesRule(tt.generated)
+		got := normalizeIptablesRule(strings.Fields(tt.saved))
+		if want != got {
+			t.Errorf("Normalized rules mismatch for %v: %q, %q", tt.generated, want, got)
+		}
+	}
+	if normalizeIptablesRule([]string{"-m", "mark", "--mark", "1337", "-j", constants.RETURN}) ==
+		normalizeIptablesRule([]string{"-m", "connmark", "--mark", "1337", "-j", constants.RETURN}) {
+		t.Errorf("Expected packet and connection marks to differ")
+	}
+}
+
+func TestBuildV4Repair(t *testing.T) {
+	iptables := NewIptablesBuilder(nil)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.OUTPUT, constants.NAT, "-p", "tcp", "-j", constants.ISTIOOUTPUT)
+	iptables.AppendRuleV4(iptableslog.UndefinedCommand, constants.ISTIOOUTPUT, constants.NAT, "-j", constants.RETURN)
+	iptables.InsertRuleV4(iptableslog.UndefinedCommand, constants.PREROUTING, constants.MANGLE, 1, "-j", constants.ISTIOINBOUND)
+	deletes, restore := iptables.BuildV4Repair()
+	expectedDeletes := [][]string{
+		{"iptables", "-t", "nat", "-D", "OUTPUT", "-p", "tcp", "-j", "ISTIO_OUTPUT"},
+		{"iptables", "-t", "mangle", "-D", "PREROUTING", "-j", "ISTIO_INBOUND"},
+	}
+	if !reflect.DeepEqual(deletes, expectedDeletes) {
+		t.Errorf("Output didn't match: Got: %#v, Expected: %#v", deletes, expectedDeletes)
+	}
+	expectedRestore := `* nat
+:ISTIO_OUTPUT - [0:0]
+-I OUTPUT 1 -p tcp -j ISTIO_OUTPUT
+-A ISTIO_OUTPUT -j RETURN
+COMMIT
+* mangle
+-I PREROUTING 1 -j ISTIO_INBOUND
+COMMIT
+`
+	if restore != expectedRestore {
+		t.Errorf("Output didn't match: Got: %s, Expected: %s", restore, expectedRestore)
+	}
+}
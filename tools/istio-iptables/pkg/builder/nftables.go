@@ -0,0 +1,363 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// NftablesTablePrefix prefixes the name of the nftables tables owned by Istio. Each iptables table is mapped to
+// its own nftables table, so the chains keep the names they have with iptables.
+const NftablesTablePrefix = "istio_"
+
+// nftBaseChains maps the built-in chains of each iptables table to the nftables hook they are attached to.
+var nftBaseChains = map[string]map[string]string{
+	constants.NAT: {
+		constants.PREROUTING:  "type nat hook prerouting priority dstnat",
+		constants.INPUT:       "type nat hook input priority srcnat",
+		constants.OUTPUT:      "type nat hook output priority dstnat",
+		constants.POSTROUTING: "type nat hook postrouting priority srcnat",
+	},
+	constants.MANGLE: {
+		constants.PREROUTING: "type filter hook prerouting priority mangle",
+		constants.INPUT:      "type filter hook input priority mangle",
+		constants.FORWARD:    "type filter hook forward priority mangle",
+		// Like the iptables mangle table, packets are routed again if their mark changes.
+		constants.OUTPUT:      "type route hook output priority mangle",
+		constants.POSTROUTING: "type filter hook postrouting priority mangle",
+	},
+	constants.RAW: {
+		constants.PREROUTING: "type filter hook prerouting priority raw",
+		constants.OUTPUT:     "type filter hook output priority raw",
+	},
+	constants.FILTER: {
+		constants.INPUT:   "type filter hook input priority filter",
+		constants.FORWARD: "type filter hook forward priority filter",
+		constants.OUTPUT:  "type filter hook output priority filter",
+	},
+}
+
+// ruleChain holds the rules of a chain, in the order they end up in once every insertion is applied.
+type ruleChain struct {
+	name  string
+	rules [][]string
+}
+
+type ruleTable struct {
+	name   string
+	chains []*ruleChain
+}
+
+func (t *ruleTable) chain(name string) *ruleChain {
+	for _, c := range t.chains {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &ruleChain{name: name}
+	t.chains = append(t.chains, c)
+	return c
+}
+
+// layoutRules resolves the positions of the rules added to the builder. The tables and chains are listed in the
+// order they are first used, and the rules are returned without the operation, chain and position.
+func layoutRules(rules []*Rule) ([]*ruleTable, error) {
+	var tables []*ruleTable
+	for _, r := range rules {
+		var t *ruleTable
+		for _, existing := range tables {
+			if existing.name == r.table {
+				t = existing
+			}
+		}
+		if t == nil {
+			t = &ruleTable{name: r.table}
+			tables = append(tables, t)
+		}
+		c := t.chain(r.chain)
+		if len(r.params) < 2 {
+			return nil, fmt.Errorf("invalid rule %v", r.params)
+		}
+		switch r.params[0] {
+		case "-A":
+			c.rules = append(c.rules, r.params[2:])
+		case "-I":
+			if len(r.params) < 3 {
+				return nil, fmt.Errorf("invalid rule %v", r.params)
+			}
+			pos, err := strconv.Atoi(r.params[2])
+			if err != nil || pos < 1 {
+				return nil, fmt.Errorf("%v: invalid position %q", strings.Join(r.params, " "), r.params[2])
+			}
+			idx := pos - 1
+			if idx > len(c.rules) {
+				idx = len(c.rules)
+			}
+			c.rules = append(c.rules[:idx], append([][]string{r.params[3:]}, c.rules[idx:]...)...)
+		default:
+			return nil, fmt.Errorf("unsupported operation %q", r.params[0])
+		}
+	}
+	return tables, nil
+}
+
+// BuildNftables returns an nft script replacing the Istio tables with the rules added to the builder. The script
+// is meant to be applied with `nft -f`, which runs it as a single transaction: either every rule is applied, or
+// the previous rules are left untouched.
+func (rb *IptablesBuilder) BuildNftables() (string, error) {
+	var b strings.Builder
+	if err := buildNftables(&b, "ip", rb.rules.rulesv4); err != nil {
+		return "", err
+	}
+	if err := buildNftables(&b, "ip6", rb.rules.rulesv6); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func buildNftables(b *strings.Builder, family string, rules []*Rule) error {
+	tables, err := layoutRules(rules)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if _, ok := nftBaseChains[t.name]; !ok {
+			return fmt.Errorf("unsupported table %q", t.name)
+		}
+		name := NftablesTablePrefix + t.name
+		// Adding the table first makes the deletion succeed whether or not it exists.
+		_, _ = fmt.Fprintf(b, "add table %s %s\n", family, name)
+		_, _ = fmt.Fprintf(b, "delete table %s %s\n", family, name)
+		_, _ = fmt.Fprintf(b, "table %s %s {\n", family, name)
+		for _, c := range t.chains {
+			_, _ = fmt.Fprintf(b, "\tchain %s {\n", c.name)
+			if _, builtin := constants.BuiltInChainsMap[c.name]; builtin {
+				hook, ok := nftBaseChains[t.name][c.name]
+				if !ok {
+					return fmt.Errorf("unsupported chain %q in table %q", c.name, t.name)
+				}
+				_, _ = fmt.Fprintf(b, "\t\t%s; policy accept;\n", hook)
+			}
+			for _, r := range c.rules {
+				stmt, err := nftRule(family, r)
+				if err != nil {
+					return fmt.Errorf("%v: %v", strings.Join(r, " "), err)
+				}
+				_, _ = fmt.Fprintf(b, "\t\t%s\n", stmt)
+			}
+			_, _ = fmt.Fprintln(b, "\t}")
+		}
+		_, _ = fmt.Fprintln(b, "}")
+	}
+	return nil
+}
+
+// nftRule translates the matches and target of an iptables rule to an nftables rule.
+func nftRule(family string, params []string) (string, error) {
+	var (
+		exprs    []string
+		protoIdx = -1
+		proto    string
+		module   string
+		negate   bool
+	)
+	op := func() string {
+		if negate {
+			return "!= "
+		}
+		return ""
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		if p == "-j" {
+			if i+1 >= len(params) {
+				return "", fmt.Errorf("missing target")
+			}
+			target, err := nftTarget(params[i+1], params[i+2:])
+			if err != nil {
+				return "", err
+			}
+			if protoIdx >= 0 && exprs[protoIdx] == "" {
+				exprs[protoIdx] = fmt.Sprintf("meta l4proto %s", proto)
+			}
+			exprs = append(exprs, target)
+			return strings.Join(nonEmpty(exprs), " "), nil
+		}
+		if i+1 >= len(params) {
+			return "", fmt.Errorf("missing value for %q", p)
+		}
+		i++
+		v := params[i]
+		switch p {
+		case "-m":
+			module = v
+			continue
+		case "-p":
+			if negate {
+				exprs = append(exprs, fmt.Sprintf("meta l4proto != %s", v))
+			} else {
+				// Filled in with an explicit protocol match only if no port match implies it.
+				proto, protoIdx = v, len(exprs)
+				exprs = append(exprs, "")
+			}
+		case "--dport", "--dports", "--sport", "--sports":
+			if proto == "" {
+				return "", fmt.Errorf("%s requires a protocol", p)
+			}
+			dir := "dport"
+			if strings.HasPrefix(p, "--s") {
+				dir = "sport"
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s %s%s", proto, dir, op(), nftSet(v)))
+			if protoIdx >= 0 {
+				exprs[protoIdx] = ""
+				protoIdx = -1
+			}
+		case "-d", "-s":
+			dir := "daddr"
+			if p == "-s" {
+				dir = "saddr"
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s %s%s", family, dir, op(), v))
+		case "-i", "-o":
+			dir := "iifname"
+			if p == "-o" {
+				dir = "oifname"
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s%q", dir, op(), strings.Replace(v, "+", "*", 1)))
+		case "--uid-owner":
+			exprs = append(exprs, fmt.Sprintf("meta skuid %s%s", op(), v))
+		case "--gid-owner":
+			exprs = append(exprs, fmt.Sprintf("meta skgid %s%s", op(), v))
+		case "--ctstate", "--state":
+			exprs = append(exprs, fmt.Sprintf("ct state %s%s", op(), strings.ToLower(v)))
+		case "--mark":
+			key := "meta mark"
+			if module == "connmark" {
+				key = "ct mark"
+			}
+			mark, err := nftMark(v)
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s%s", key, op(), mark))
+		default:
+			return "", fmt.Errorf("unsupported match %q", p)
+		}
+		negate = false
+	}
+	return "", fmt.Errorf("missing target")
+}
+
+// nftTarget translates an iptables target with its options to nftables statements.
+func nftTarget(target string, params []string) (string, error) {
+	opts := map[string]string{}
+	for i := 0; i < len(params); i++ {
+		if !strings.HasPrefix(params[i], "--") {
+			return "", fmt.Errorf("unexpected argument %q", params[i])
+		}
+		if i+1 < len(params) && !strings.HasPrefix(params[i+1], "--") {
+			opts[params[i]] = params[i+1]
+			i++
+		} else {
+			opts[params[i]] = ""
+		}
+	}
+	switch target {
+	case constants.RETURN:
+		return "return", nil
+	case constants.ACCEPT:
+		return "accept", nil
+	case constants.DROP:
+		return "drop", nil
+	case constants.REJECT:
+		return "reject", nil
+	case constants.REDIRECT:
+		port, ok := opts["--to-ports"]
+		if !ok {
+			port = opts["--to-port"]
+		}
+		if port == "" {
+			return "", fmt.Errorf("REDIRECT requires a port")
+		}
+		return fmt.Sprintf("redirect to :%s", port), nil
+	case constants.TPROXY:
+		mark, err := nftMark(opts["--tproxy-mark"])
+		if err != nil {
+			return "", err
+		}
+		// Like the iptables target, the packet is accepted once it has been diverted to the proxy.
+		return fmt.Sprintf("tproxy to :%s meta mark set %s accept", opts["--on-port"], mark), nil
+	case constants.MARK:
+		mark, err := nftMark(opts["--set-mark"])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("meta mark set %s", mark), nil
+	case "CONNMARK":
+		if _, ok := opts["--save-mark"]; ok {
+			return "ct mark set meta mark", nil
+		}
+		if _, ok := opts["--restore-mark"]; ok {
+			return "meta mark set ct mark", nil
+		}
+		return "", fmt.Errorf("unsupported CONNMARK options %v", params)
+	case constants.CT:
+		return fmt.Sprintf("ct zone set %s", opts["--zone"]), nil
+	case "NFLOG":
+		// The prefix is already quoted.
+		return fmt.Sprintf("log prefix %s group %s snaplen %s", opts["--nflog-prefix"], opts["--nflog-group"], opts["--nflog-size"]), nil
+	}
+	if strings.HasPrefix(target, "ISTIO_") {
+		return fmt.Sprintf("jump %s", target), nil
+	}
+	return "", fmt.Errorf("unsupported target %q", target)
+}
+
+// nftMark translates a mark with an optional mask. Only marks covering the whole mask are supported.
+func nftMark(v string) (string, error) {
+	mark, mask, found := strings.Cut(v, "/")
+	if mark == "" || (found && mask != "0xffffffff") {
+		return "", fmt.Errorf("unsupported mark %q", v)
+	}
+	return mark, nil
+}
+
+// nftSet translates an iptables list (a,b) or range (a:b) of ports.
+func nftSet(v string) string {
+	v = strings.ReplaceAll(v, ":", "-")
+	if !strings.Contains(v, ",") {
+		return v
+	}
+	return "{ " + strings.Join(strings.Split(v, ","), ", ") + " }"
+}
+
+func nonEmpty(s []string) []string {
+	res := make([]string, 0, len(s))
+	for _, e := range s {
+		if e != "" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"os/exec"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// lookPath is overridden in tests.
+var lookPath = exec.LookPath
+
+// DetectBackend resolves the backend used to apply the rules. An explicit backend is returned as is. Otherwise,
+// iptables is preferred whenever its binaries are available, so existing nodes keep their behavior, and nftables is
+// only selected on hosts shipping nft alone. Dry runs always use iptables.
+func DetectBackend(backend string, dryRun bool) string {
+	if backend != "" && backend != constants.BackendAuto {
+		return backend
+	}
+	if dryRun {
+		return constants.BackendIptables
+	}
+	for _, cmd := range []string{constants.IPTABLESRESTORE, constants.IPTABLES} {
+		if _, err := lookPath(cmd); err == nil {
+			return constants.BackendIptables
+		}
+	}
+	if _, err := lookPath(constants.NFT); err == nil {
+		return constants.BackendNftables
+	}
+	return constants.BackendIptables
+}
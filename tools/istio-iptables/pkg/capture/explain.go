@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// Explain evaluates a packet against the rules Run would apply. It neither applies nor reads any rule, so it can
+// run outside of the network namespace of the pod.
+func (cfg *IptablesConfigurator) Explain(pkt builder.Packet) (*builder.Trace, error) {
+	cfg.appendRules()
+	return cfg.iptables.Explain(pkt)
+}
+
+// PrintTrace writes the rules matching a packet, and a summary of where it ends up.
+func (cfg *IptablesConfigurator) PrintTrace(w io.Writer, pkt builder.Packet, trace *builder.Trace) {
+	direction := "outbound"
+	if pkt.Inbound {
+		direction = "inbound"
+	}
+	_, _ = fmt.Fprintf(w, "Packet: %s %s %s -> %s", direction, pkt.Protocol, pkt.Src, pkt.Dst)
+	if pkt.Interface != "" {
+		_, _ = fmt.Fprintf(w, " on %s", pkt.Interface)
+	}
+	if !pkt.Inbound && (pkt.UID != "" || pkt.GID != "") {
+		_, _ = fmt.Fprintf(w, " from uid=%s gid=%s", pkt.UID, pkt.GID)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w)
+	if len(trace.Steps) == 0 {
+		_, _ = fmt.Fprintln(w, "No rule matches the packet.")
+	}
+	for _, s := range trace.Steps {
+		_, _ = fmt.Fprintf(w, "%-7s %-18s %s\n", s.Table, s.Chain, s.Rule)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Result: %s\n", cfg.describeVerdict(pkt, trace))
+}
+
+func (cfg *IptablesConfigurator) describeVerdict(pkt builder.Packet, trace *builder.Trace) string {
+	port := strconv.Itoa(int(trace.Dst.Port()))
+	listener := "port " + port
+	switch port {
+	case cfg.cfg.ProxyPort:
+		listener = "the outbound listener of the sidecar (" + port + ")"
+	case cfg.cfg.InboundCapturePort:
+		listener = "the inbound listener of the sidecar (" + port + ")"
+	case constants.IstioAgentDNSListenerPort:
+		listener = "the DNS proxy of istio-agent (" + port + ")"
+	}
+	switch trace.Verdict {
+	case builder.VerdictRedirected:
+		return fmt.Sprintf("redirected to %s at %s", listener, trace.Dst)
+	case builder.VerdictDiverted:
+		return fmt.Sprintf("diverted to %s with TPROXY, keeping its original destination %s", listener, pkt.Dst)
+	case builder.VerdictDropped:
+		return "dropped"
+	}
+	if pkt.Inbound {
+		return fmt.Sprintf("not captured, delivered to the application on port %d", pkt.Dst.Port())
+	}
+	return fmt.Sprintf("not captured, sent to %s directly", pkt.Dst)
+}
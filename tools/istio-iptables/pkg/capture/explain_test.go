@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+func TestExplain(t *testing.T) {
+	outbound := func(dst string, uid string) builder.Packet {
+		d := netip.MustParseAddrPort(dst)
+		return builder.Packet{
+			Protocol:  "tcp",
+			Src:       netip.AddrPortFrom(netip.IPv4Unspecified(), 0),
+			Dst:       d,
+			Interface: "eth0",
+			UID:       uid,
+			GID:       uid,
+		}
+	}
+	inbound := func(dst string) builder.Packet {
+		return builder.Packet{
+			Inbound:   true,
+			Protocol:  "tcp",
+			Src:       netip.MustParseAddrPort("10.0.0.9:40000"),
+			Dst:       netip.MustParseAddrPort(dst),
+			Interface: "eth0",
+		}
+	}
+	cases := []struct {
+		name    string
+		config  func(cfg *config.Config)
+		packet  builder.Packet
+		verdict builder.Verdict
+		dst     string
+		result  string
+	}{
+		{
+			name: "outbound captured",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+			},
+			packet:  outbound("10.0.0.5:3306", "1000"),
+			verdict: builder.VerdictRedirected,
+			dst:     "127.0.0.1:15001",
+			result:  "redirected to the outbound listener of the sidecar (15001) at 127.0.0.1:15001",
+		},
+		{
+			name: "outbound excluded range",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+				cfg.OutboundIPRangesExclude = "10.0.0.0/16"
+			},
+			packet:  outbound("10.0.0.5:3306", "1000"),
+			verdict: builder.VerdictPassthrough,
+			dst:     "10.0.0.5:3306",
+			result:  "not captured, sent to 10.0.0.5:3306 directly",
+		},
+		{
+			name: "outbound excluded port",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+				cfg.OutboundPortsExclude = "3306"
+			},
+			packet:  outbound("10.0.0.5:3306", "1000"),
+			verdict: builder.VerdictPassthrough,
+			dst:     "10.0.0.5:3306",
+		},
+		{
+			name: "outbound from the proxy",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+			},
+			packet:  outbound("10.0.0.5:3306", "1337"),
+			verdict: builder.VerdictPassthrough,
+			dst:     "10.0.0.5:3306",
+		},
+		{
+			name: "outbound on excluded interface",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+				cfg.ExcludeInterfaces = "eth1"
+			},
+			packet: func() builder.Packet {
+				p := outbound("10.0.0.5:3306", "1000")
+				p.Interface = "eth1"
+				return p
+			}(),
+			verdict: builder.VerdictPassthrough,
+			dst:     "10.0.0.5:3306",
+		},
+		{
+			name: "outbound ipv6",
+			config: func(cfg *config.Config) {
+				cfg.OutboundIPRangesInclude = "*"
+				cfg.EnableInboundIPv6 = true
+			},
+			packet: func() builder.Packet {
+				p := outbound("[2001:db8::5]:3306", "1000")
+				p.Src = netip.AddrPortFrom(netip.IPv6Unspecified(), 0)
+				return p
+			}(),
+			verdict: builder.VerdictRedirected,
+			dst:     "[::1]:15001",
+		},
+		{
+			name: "dns",
+			config: func(cfg *config.Config) {
+				cfg.RedirectDNS = true
+				cfg.CaptureAllDNS = true
+			},
+			packet: func() builder.Packet {
+				p := outbound("10.96.0.10:53", "1000")
+				p.Protocol = "udp"
+				return p
+			}(),
+			verdict: builder.VerdictRedirected,
+			dst:     "127.0.0.1:15053",
+			result:  "redirected to the DNS proxy of istio-agent (15053) at 127.0.0.1:15053",
+		},
+		{
+			name: "inbound captured",
+			config: func(cfg *config.Config) {
+				cfg.InboundPortsInclude = "*"
+				cfg.InboundPortsExclude = "15090,15021,15020"
+			},
+			packet:  inbound("10.0.0.7:8080"),
+			verdict: builder.VerdictRedirected,
+			dst:     "10.0.0.7:15006",
+			result:  "redirected to the inbound listener of the sidecar (15006) at 10.0.0.7:15006",
+		},
+		{
+			name: "inbound excluded port",
+			config: func(cfg *config.Config) {
+				cfg.InboundPortsInclude = "*"
+				cfg.InboundPortsExclude = "15090,15021,15020"
+			},
+			packet:  inbound("10.0.0.7:15020"),
+			verdict: builder.VerdictPassthrough,
+			dst:     "10.0.0.7:15020",
+			result:  "not captured, delivered to the application on port 15020",
+		},
+		{
+			name: "inbound tproxy",
+			config: func(cfg *config.Config) {
+				cfg.InboundPortsInclude = "*"
+				cfg.InboundInterceptionMode = "TPROXY"
+			},
+			packet:  inbound("10.0.0.7:8080"),
+			verdict: builder.VerdictDiverted,
+			dst:     "10.0.0.7:15006",
+			result:  "diverted to the inbound listener of the sidecar (15006) with TPROXY, keeping its original destination 10.0.0.7:8080",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			tt.config(cfg)
+			iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+			trace, err := iptConfigurator.Explain(tt.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			iptConfigurator.PrintTrace(&out, tt.packet, trace)
+			if trace.Verdict != tt.verdict || trace.Dst.String() != tt.dst {
+				t.Fatalf("got %s to %s, want %s to %s\n%s", trace.Verdict, trace.Dst, tt.verdict, tt.dst, out.String())
+			}
+			if tt.result != "" && !strings.Contains(out.String(), "Result: "+tt.result+"\n") {
+				t.Fatalf("unexpected result:\n%s", out.String())
+			}
+		})
+	}
+}
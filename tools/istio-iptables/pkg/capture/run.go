@@ -280,6 +280,10 @@ func configureIPv6Addresses(cfg *config.Config) error {
 
 func (cfg *IptablesConfigurator) Run() {
 	defer func() {
+		if cfg.cfg.Backend == constants.BackendNftables {
+			_ = cfg.ext.Run(constants.NFT, nil, "list", "ruleset")
+			return
+		}
 		// Best effort since we don't know if the commands exist
 		_ = cfg.ext.Run(constants.IPTABLESSAVE, nil)
 		if cfg.cfg.EnableInboundIPv6 {
@@ -287,6 +291,13 @@ func (cfg *IptablesConfigurator) Run() {
 		}
 	}()
 
+	cfg.logConfig()
+	cfg.appendRules()
+	cfg.executeCommands()
+}
+
+// appendRules adds every rule for the configuration to the builder.
+func (cfg *IptablesConfigurator) appendRules() {
 	// Since OUTBOUND_IP_RANGES_EXCLUDE could carry ipv4 and ipv6 ranges
 	// need to split them in different arrays one for ipv4 and one for ipv6
 	// in order to not to fail
@@ -305,7 +316,6 @@ func (cfg *IptablesConfigurator) Run() {
 	}
 
 	redirectDNS := cfg.cfg.RedirectDNS
-	cfg.logConfig()
 
 	cfg.shortCircuitExcludeInterfaces()
 
@@ -542,7 +552,6 @@ func (cfg *IptablesConfigurator) Run() {
 		cfg.iptables.InsertRule(iptableslog.UndefinedCommand, constants.ISTIOINBOUND, constants.MANGLE, 3,
 			"-p", constants.TCP, "-i", "lo", "-m", "mark", "!", "--mark", outboundMark, "-j", constants.RETURN)
 	}
-	cfg.executeCommands()
 }
 
 type UDPRuleApplier struct {
@@ -752,8 +761,20 @@ func (cfg *IptablesConfigurator) executeIptablesRestoreCommand(isIpv4 bool) {
 	cfg.ext.RunOrFail(cmd, strings.NewReader(data), "--noflush")
 }
 
+func (cfg *IptablesConfigurator) executeNftablesCommand() {
+	data, err := cfg.iptables.BuildNftables()
+	if err != nil {
+		panic(err)
+	}
+	log.Infof("Running %s with the following input:\n%v", constants.NFT, strings.TrimSpace(data))
+	// nft applies the whole file as a single transaction.
+	cfg.ext.RunOrFail(constants.NFT, strings.NewReader(data), "-f", "-")
+}
+
 func (cfg *IptablesConfigurator) executeCommands() {
-	if cfg.cfg.RestoreFormat {
+	if cfg.cfg.Backend == constants.BackendNftables {
+		cfg.executeNftablesCommand()
+	} else if cfg.cfg.RestoreFormat {
 		// Execute iptables-restore
 		cfg.executeIptablesRestoreCommand(true)
 		// Execute ip6tables-restore
@@ -16,6 +16,7 @@ package capture
 
 import (
 	"net/netip"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -41,11 +42,14 @@ func constructTestConfig() *config.Config {
 	}
 }
 
-func TestIptables(t *testing.T) {
-	cases := []struct {
-		name   string
-		config func(cfg *config.Config)
-	}{
+type captureTestCase struct {
+	name   string
+	config func(cfg *config.Config)
+}
+
+// getCommonTestCases returns the configurations shared by the iptables and nftables golden tests.
+func getCommonTestCases() []captureTestCase {
+	return []captureTestCase{
 		{
 			"ipv6-empty-inbound-ports",
 			func(cfg *config.Config) {
@@ -264,7 +268,10 @@ func TestIptables(t *testing.T) {
 			},
 		},
 	}
-	for _, tt := range cases {
+}
+
+func TestIptables(t *testing.T) {
+	for _, tt := range getCommonTestCases() {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := constructTestConfig()
 			tt.config(cfg)
@@ -279,6 +286,56 @@ func TestIptables(t *testing.T) {
 	}
 }
 
+func TestNftables(t *testing.T) {
+	for _, tt := range getCommonTestCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			tt.config(cfg)
+			cfg.Backend = constants.BackendNftables
+			iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+			iptConfigurator.Run()
+			actual, err := iptConfigurator.iptables.BuildNftables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.CompareContent(t, []byte(actual), filepath.Join("testdata", "nftables", tt.name+".golden"))
+		})
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	cases := []struct {
+		name      string
+		backend   string
+		dryRun    bool
+		available []string
+		want      string
+	}{
+		{"explicit", constants.BackendNftables, false, []string{constants.IPTABLES}, constants.BackendNftables},
+		{"dry run", constants.BackendAuto, true, []string{constants.NFT}, constants.BackendIptables},
+		{"iptables available", constants.BackendAuto, false, []string{constants.IPTABLES, constants.NFT}, constants.BackendIptables},
+		{"nft only", "", false, []string{constants.NFT}, constants.BackendNftables},
+		{"nothing available", constants.BackendAuto, false, nil, constants.BackendIptables},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := lookPath
+			t.Cleanup(func() { lookPath = orig })
+			lookPath = func(file string) (string, error) {
+				for _, a := range tt.available {
+					if a == file {
+						return "/usr/sbin/" + file, nil
+					}
+				}
+				return "", exec.ErrNotFound
+			}
+			if got := DetectBackend(tt.backend, tt.dryRun); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestSeparateV4V6(t *testing.T) {
 	mkIPList := func(ips ...string) []netip.Prefix {
 		ret := []netip.Prefix{}
@@ -0,0 +1,95 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// readRules returns the output of a command listing the rules in place. It runs in the current network namespace.
+var readRules = func(cmd string, args ...string) (string, error) {
+	out, err := exec.Command(cmd, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %v", cmd, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// Verify compares the rules in place in the current network namespace with the rules Run would apply.
+func (cfg *IptablesConfigurator) Verify() (builder.RulesDiff, error) {
+	cfg.appendRules()
+	if cfg.cfg.Backend == constants.BackendNftables {
+		out, err := readRules(constants.NFT, "list", "ruleset")
+		if err != nil {
+			return builder.RulesDiff{}, err
+		}
+		return cfg.iptables.DiffNftables(out)
+	}
+	out, err := readRules(constants.IPTABLESSAVE)
+	if err != nil {
+		return builder.RulesDiff{}, err
+	}
+	diff, err := cfg.iptables.DiffV4(out)
+	if err != nil || !cfg.cfg.EnableInboundIPv6 {
+		return diff, err
+	}
+	out, err = readRules(constants.IP6TABLESSAVE)
+	if err != nil {
+		return builder.RulesDiff{}, err
+	}
+	v6, err := cfg.iptables.DiffV6(out)
+	if err != nil {
+		return builder.RulesDiff{}, err
+	}
+	return diff.Merge(v6), nil
+}
+
+// Repair applies the rules again, replacing the Istio rules in place. Unlike Run, which is meant to run once in a
+// fresh network namespace, it can run over rules that drifted, and returns errors rather than exiting.
+func (cfg *IptablesConfigurator) Repair() error {
+	cfg.appendRules()
+	if cfg.cfg.Backend == constants.BackendNftables {
+		data, err := cfg.iptables.BuildNftables()
+		if err != nil {
+			return err
+		}
+		// The script replaces the Istio tables in a single transaction.
+		return cfg.ext.Run(constants.NFT, strings.NewReader(data), "-f", "-")
+	}
+	deletes, restore := cfg.iptables.BuildV4Repair()
+	if err := cfg.repairIptables(constants.IPTABLESRESTORE, deletes, restore); err != nil {
+		return err
+	}
+	if !cfg.cfg.EnableInboundIPv6 {
+		return nil
+	}
+	deletes, restore = cfg.iptables.BuildV6Repair()
+	return cfg.repairIptables(constants.IP6TABLESRESTORE, deletes, restore)
+}
+
+func (cfg *IptablesConfigurator) repairIptables(restoreCmd string, deletes [][]string, restore string) error {
+	for _, cmd := range deletes {
+		cfg.ext.RunQuietlyAndIgnore(cmd[0], nil, cmd[1:]...)
+	}
+	if restore == "" {
+		return nil
+	}
+	return cfg.ext.Run(restoreCmd, strings.NewReader(restore), "--noflush")
+}
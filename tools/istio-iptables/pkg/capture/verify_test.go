@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// iptablesSave is the output of iptables-save once the default configuration is applied, with rules of other
+// components in the OUTPUT chain.
+const iptablesSave = `# Generated by iptables-save v1.8.7 on Mon Oct 19 10:00:00 2026
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+:ISTIO_IN_REDIRECT - [0:0]
+:ISTIO_OUTPUT - [0:0]
+:ISTIO_REDIRECT - [0:0]
+-A OUTPUT -p tcp -j LOG --log-prefix "output: "
+-A OUTPUT -p tcp -j ISTIO_OUTPUT
+-A OUTPUT -d 169.254.169.254/32 -j ACCEPT
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15008 -j RETURN
+-A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-ports 15006
+-A ISTIO_OUTPUT -s 127.0.0.6/32 -o lo -j RETURN
+-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -p tcp -m tcp ! --dport 15008 -m owner --uid-owner 1337 -j ISTIO_IN_REDIRECT
+-A ISTIO_OUTPUT -o lo -m owner ! --uid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -p tcp -m tcp ! --dport 15008 -m owner --gid-owner 1337 -j ISTIO_IN_REDIRECT
+-A ISTIO_OUTPUT -o lo -m owner ! --gid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -m owner --gid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -d 127.0.0.1/32 -j RETURN
+-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001
+COMMIT
+# Completed on Mon Oct 19 10:00:00 2026
+`
+
+func TestVerify(t *testing.T) {
+	outboundRule := "-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN\n"
+	cases := []struct {
+		name    string
+		backend string
+		live    func(expected string) string
+		want    builder.RulesDiff
+	}{
+		{
+			name: "in sync",
+			live: func(string) string { return iptablesSave },
+		},
+		{
+			name: "flushed",
+			live: func(string) string {
+				var lines []string
+				for _, l := range strings.Split(iptablesSave, "\n") {
+					if !strings.HasPrefix(l, "-A ISTIO_OUTPUT") && !strings.HasPrefix(l, "-A OUTPUT") {
+						lines = append(lines, l)
+					}
+				}
+				return strings.Join(lines, "\n")
+			},
+			want: builder.RulesDiff{Missing: []string{
+				"nat/OUTPUT: -p tcp -j ISTIO_OUTPUT",
+				"nat/ISTIO_OUTPUT: -o lo -s 127.0.0.6/32 -j RETURN",
+				"nat/ISTIO_OUTPUT: ! --dport 15008 ! -d 127.0.0.1/32 --uid-owner 1337 -o lo -p tcp -j ISTIO_IN_REDIRECT",
+				"nat/ISTIO_OUTPUT: ! --uid-owner 1337 -o lo -j RETURN",
+				"nat/ISTIO_OUTPUT: --uid-owner 1337 -j RETURN",
+				"nat/ISTIO_OUTPUT: ! --dport 15008 ! -d 127.0.0.1/32 --gid-owner 1337 -o lo -p tcp -j ISTIO_IN_REDIRECT",
+				"nat/ISTIO_OUTPUT: ! --gid-owner 1337 -o lo -j RETURN",
+				"nat/ISTIO_OUTPUT: --gid-owner 1337 -j RETURN",
+				"nat/ISTIO_OUTPUT: -d 127.0.0.1/32 -j RETURN",
+			}},
+		},
+		{
+			name: "reordered",
+			live: func(string) string {
+				s := strings.Replace(iptablesSave, outboundRule, "", 1)
+				return strings.Replace(s, "-A ISTIO_OUTPUT -s 127.0.0.6/32", strings.TrimSuffix(outboundRule, "\n")+"\n-A ISTIO_OUTPUT -s 127.0.0.6/32", 1)
+			},
+			want: builder.RulesDiff{Reordered: []string{"nat/ISTIO_OUTPUT"}},
+		},
+		{
+			name: "bypassed",
+			live: func(string) string {
+				return strings.Replace(iptablesSave, "-A OUTPUT -p tcp -j ISTIO_OUTPUT", "-A OUTPUT -p tcp -j ACCEPT\n-A OUTPUT -p tcp -j ISTIO_OUTPUT", 1)
+			},
+			want: builder.RulesDiff{Bypassing: []string{"nat/OUTPUT: -p tcp -j ACCEPT"}},
+		},
+		{
+			name: "unexpected",
+			live: func(string) string {
+				return strings.Replace(iptablesSave, "-A ISTIO_REDIRECT", "-A ISTIO_REDIRECT -j RETURN\n-A ISTIO_REDIRECT", 1)
+			},
+			want: builder.RulesDiff{Unexpected: []string{"nat/ISTIO_REDIRECT: -j RETURN"}},
+		},
+		{
+			name:    "nftables in sync",
+			backend: constants.BackendNftables,
+			live:    func(expected string) string { return expected },
+		},
+		{
+			name:    "nftables missing rule",
+			backend: constants.BackendNftables,
+			live: func(expected string) string {
+				return strings.Replace(expected, "\t\tmeta l4proto tcp redirect to :15001\n", "", 1)
+			},
+			want: builder.RulesDiff{Missing: []string{"ip istio_nat/ISTIO_REDIRECT: 1 of 1 rules"}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			cfg.Backend = tt.backend
+			generated := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+			generated.appendRules()
+			expected, err := generated.iptables.BuildNftables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			orig := readRules
+			t.Cleanup(func() { readRules = orig })
+			readRules = func(cmd string, args ...string) (string, error) {
+				return tt.live(expected), nil
+			}
+			got, err := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{}).Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
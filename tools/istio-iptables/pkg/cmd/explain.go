@@ -0,0 +1,165 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+// PacketOptions describes the packet evaluated by the explain command.
+type PacketOptions struct {
+	Direction string
+	Protocol  string
+	Src       string
+	Dst       string
+	Interface string
+	UID       string
+	GID       string
+	CtState   string
+}
+
+// AddFlags adds the flags describing the packet.
+func (o *PacketOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Direction, "direction", "outbound",
+		"Direction of the packet: \"outbound\" for packets sent by the application, \"inbound\" for packets received by the pod")
+	fs.StringVar(&o.Protocol, "protocol", "tcp", "Protocol of the packet, \"tcp\" or \"udp\"")
+	fs.StringVar(&o.Src, "src", "", "Source address of the packet, as ip or ip:port")
+	fs.StringVar(&o.Dst, "dst", "", "Destination of the packet, as ip:port")
+	fs.StringVar(&o.Interface, "interface", "",
+		"Interface the packet is received on or sent through (defaults to \"lo\" for loopback destinations, \"eth0\" otherwise)")
+	fs.StringVar(&o.UID, "uid", "", "UID of the process sending an outbound packet (defaults to a user other than the proxy)")
+	fs.StringVar(&o.GID, "gid", "", "GID of the process sending an outbound packet (defaults to a group other than the proxy)")
+	fs.StringVar(&o.CtState, "ctstate", "NEW", "Conntrack state of the connection of the packet, e.g. NEW or ESTABLISHED")
+}
+
+// Packet returns the packet described by the options.
+func (o *PacketOptions) Packet() (builder.Packet, error) {
+	pkt := builder.Packet{
+		Protocol: strings.ToLower(o.Protocol),
+		UID:      o.UID,
+		GID:      o.GID,
+		CtState:  strings.ToUpper(o.CtState),
+	}
+	switch o.Direction {
+	case "inbound":
+		pkt.Inbound = true
+	case "outbound":
+	default:
+		return pkt, fmt.Errorf("invalid direction %q, must be inbound or outbound", o.Direction)
+	}
+	if pkt.Protocol != "tcp" && pkt.Protocol != "udp" {
+		return pkt, fmt.Errorf("invalid protocol %q, must be tcp or udp", o.Protocol)
+	}
+	if o.Dst == "" {
+		return pkt, fmt.Errorf("--dst is required")
+	}
+	dst, err := netip.ParseAddrPort(o.Dst)
+	if err != nil {
+		return pkt, fmt.Errorf("invalid destination %q, must be ip:port: %v", o.Dst, err)
+	}
+	pkt.Dst = netip.AddrPortFrom(dst.Addr().Unmap(), dst.Port())
+	switch {
+	case o.Src == "":
+		pkt.Src = netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+		if pkt.Dst.Addr().Is6() {
+			pkt.Src = netip.AddrPortFrom(netip.IPv6Unspecified(), 0)
+		}
+	case strings.Contains(o.Src, "]:") || strings.Count(o.Src, ":") == 1:
+		if pkt.Src, err = netip.ParseAddrPort(o.Src); err != nil {
+			return pkt, fmt.Errorf("invalid source %q: %v", o.Src, err)
+		}
+	default:
+		addr, err := netip.ParseAddr(o.Src)
+		if err != nil {
+			return pkt, fmt.Errorf("invalid source %q: %v", o.Src, err)
+		}
+		pkt.Src = netip.AddrPortFrom(addr, 0)
+	}
+	pkt.Src = netip.AddrPortFrom(pkt.Src.Addr().Unmap(), pkt.Src.Port())
+	if pkt.Src.Addr().Is6() != pkt.Dst.Addr().Is6() {
+		return pkt, fmt.Errorf("source %s and destination %s must be of the same IP family", pkt.Src.Addr(), pkt.Dst.Addr())
+	}
+	pkt.Interface = o.Interface
+	if pkt.Interface == "" {
+		pkt.Interface = "eth0"
+		if !pkt.Inbound && pkt.Dst.Addr().IsLoopback() {
+			pkt.Interface = "lo"
+		}
+	}
+	return pkt, nil
+}
+
+var packetOptions PacketOptions
+
+var explainCommand = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain how the traffic capture rules handle a packet",
+	Long: `Evaluates a hypothetical packet against the rules istio-iptables would apply with the same flags, and prints
+the rules matching it and where it ends up. No rule is applied nor read, so it can run anywhere.`,
+	Example: `  # Is a connection from the application to 10.0.0.5:3306 captured when 10.0.0.0/16 is excluded?
+  istio-iptables explain -i '*' -x 10.0.0.0/16 --dst 10.0.0.5:3306 --uid 1000
+
+  # Is a connection to port 15020 of the pod captured?
+  istio-iptables explain -b '*' -d 15090,15021,15020 --direction inbound --dst 10.0.0.7:15020`,
+	PreRun: bindFlags,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkt, err := packetOptions.Packet()
+		if err != nil {
+			handleError(err)
+		}
+		if err := explain(cmd.OutOrStdout(), pkt); err != nil {
+			handleError(err)
+		}
+	},
+}
+
+// ExplainArgs evaluates a packet against the rules the command applies when run with the given arguments, and
+// writes the rules matching it and where it ends up.
+func ExplainArgs(w io.Writer, args []string, pkt builder.Packet) error {
+	c := &cobra.Command{}
+	bindCmdlineFlags(c)
+	if err := c.ParseFlags(args); err != nil {
+		return err
+	}
+	bindFlags(c, nil)
+	return explain(w, pkt)
+}
+
+func explain(w io.Writer, pkt builder.Packet) (err error) {
+	cfg, err := safeConstructConfig()
+	if err != nil {
+		return err
+	}
+	// The IPv6 rules are only generated when enabled.
+	cfg.EnableInboundIPv6 = cfg.EnableInboundIPv6 || pkt.Dst.Addr().Is6()
+	defer recoverError(&err)
+	iptConfigurator := capture.NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	trace, err := iptConfigurator.Explain(pkt)
+	if err != nil {
+		return err
+	}
+	iptConfigurator.PrintTrace(w, pkt, trace)
+	return nil
+}
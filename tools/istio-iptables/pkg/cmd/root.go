@@ -63,16 +63,7 @@ var rootCmd = &cobra.Command{
 		if err := cfg.Validate(); err != nil {
 			handleErrorWithCode(err, 1)
 		}
-		var ext dep.Dependencies
-		if cfg.DryRun {
-			ext = &dep.StdoutStubDependencies{}
-		} else {
-			ext = &dep.RealDependencies{
-				CNIMode:          cfg.CNIMode,
-				HostNSEnterExec:  cfg.HostNSEnterExec,
-				NetworkNamespace: cfg.NetworkNamespace,
-			}
-		}
+		ext := newDependencies(cfg)
 
 		iptConfigurator := capture.NewIptablesConfigurator(cfg, ext)
 		if !cfg.SkipRuleApply {
@@ -121,6 +112,17 @@ var configureRoutesCommand = &cobra.Command{
 	},
 }
 
+func newDependencies(cfg *config.Config) dep.Dependencies {
+	if cfg.DryRun {
+		return &dep.StdoutStubDependencies{}
+	}
+	return &dep.RealDependencies{
+		CNIMode:          cfg.CNIMode,
+		HostNSEnterExec:  cfg.HostNSEnterExec,
+		NetworkNamespace: cfg.NetworkNamespace,
+	}
+}
+
 func constructConfig() *config.Config {
 	cfg := &config.Config{
 		DryRun:                  viper.GetBool(constants.DryRun),
@@ -155,6 +157,7 @@ func constructConfig() *config.Config {
 		CNIMode:                 viper.GetBool(constants.CNIMode),
 		HostNSEnterExec:         viper.GetBool(constants.HostNSEnterExec),
 	}
+	cfg.Backend = capture.DetectBackend(viper.GetString(constants.Backend), cfg.DryRun)
 
 	// TODO: Make this more configurable, maybe with an allowlist of users to be captured for output instead of a denylist.
 	if cfg.ProxyUID == "" {
@@ -408,6 +411,11 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.HostNSEnterExec, false)
+
+	if err := viper.BindPFlag(constants.Backend, cmd.Flags().Lookup(constants.Backend)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Backend, constants.BackendAuto)
 }
 
 // https://github.com/spf13/viper/issues/233.
@@ -416,6 +424,9 @@ func bindFlags(cmd *cobra.Command, args []string) {
 func init() {
 	bindCmdlineFlags(rootCmd)
 	bindCmdlineFlags(configureRoutesCommand)
+	bindCmdlineFlags(explainCommand)
+	packetOptions.AddFlags(explainCommand.Flags())
+	rootCmd.AddCommand(explainCommand)
 }
 
 func bindCmdlineFlags(rootCmd *cobra.Command) {
@@ -494,6 +505,10 @@ func bindCmdlineFlags(rootCmd *cobra.Command) {
 	rootCmd.Flags().Bool(constants.CNIMode, false, "Whether to run as CNI plugin.")
 
 	rootCmd.Flags().Bool(constants.HostNSEnterExec, false, "Instead of using the internal go netns, use the nsenter command for switching network namespaces.")
+
+	rootCmd.Flags().String(constants.Backend, constants.BackendAuto,
+		"The backend used to apply the rules, one of \"iptables\", \"nftables\" or \"auto\". With \"auto\", iptables is used "+
+			"if its binaries are available, nftables otherwise.")
 }
 
 func GetCommand() *cobra.Command {
@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+)
+
+// VerifyRules compares the rules in place with the ones the command would apply, with the configuration set in viper.
+// It must run in the network namespace holding the rules. Unlike the command, it is meant to run in long-lived
+// processes, so invalid configurations are returned as errors.
+func VerifyRules() (diff builder.RulesDiff, err error) {
+	cfg, err := safeConstructConfig()
+	if err != nil {
+		return builder.RulesDiff{}, err
+	}
+	defer recoverError(&err)
+	return capture.NewIptablesConfigurator(cfg, newDependencies(cfg)).Verify()
+}
+
+// RepairRules replaces the rules in place with the ones the command would apply, with the configuration set in viper.
+// Like VerifyRules, it must run in the network namespace holding the rules.
+func RepairRules() (err error) {
+	cfg, err := safeConstructConfig()
+	if err != nil {
+		return err
+	}
+	defer recoverError(&err)
+	return capture.NewIptablesConfigurator(cfg, newDependencies(cfg)).Repair()
+}
+
+func safeConstructConfig() (cfg *config.Config, err error) {
+	defer recoverError(&err)
+	cfg = constructConfig()
+	return cfg, cfg.Validate()
+}
+
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%v", r)
+	}
+}
+
+// ParseArgs parses the arguments the command is run with, e.g. the ones of an istio-init container, and returns the
+// resulting flags. Unlike ExplainArgs, it leaves the configuration set in viper untouched.
+func ParseArgs(args []string) (*pflag.FlagSet, error) {
+	c := &cobra.Command{}
+	bindCmdlineFlags(c)
+	if err := c.ParseFlags(args); err != nil {
+		return nil, err
+	}
+	return c.Flags(), nil
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
 )
 
 // Command line options
@@ -61,6 +62,7 @@ type Config struct {
 	CNIMode                 bool          `json:"CNI_MODE"`
 	HostNSEnterExec         bool          `json:"HOST_NSENTER_EXEC"`
 	TraceLogging            bool          `json:"IPTABLES_TRACE_LOGGING"`
+	Backend                 string        `json:"BACKEND"`
 }
 
 func (c *Config) String() string {
@@ -99,9 +101,15 @@ func (c *Config) Print() {
 	b.WriteString(fmt.Sprintf("CNI_MODE=%s\n", strconv.FormatBool(c.CNIMode)))
 	b.WriteString(fmt.Sprintf("HOST_NSENTER_EXEC=%s\n", strconv.FormatBool(c.HostNSEnterExec)))
 	b.WriteString(fmt.Sprintf("EXCLUDE_INTERFACES=%s\n", c.ExcludeInterfaces))
+	b.WriteString(fmt.Sprintf("BACKEND=%s\n", c.Backend))
 	log.Infof("Istio iptables variables:\n%s", b.String())
 }
 
 func (c *Config) Validate() error {
+	switch c.Backend {
+	case "", constants.BackendAuto, constants.BackendIptables, constants.BackendNftables:
+	default:
+		return fmt.Errorf("invalid backend %q", c.Backend)
+	}
 	return ValidateOwnerGroups(c.OwnerGroupsInclude, c.OwnerGroupsExclude)
 }
@@ -108,6 +108,7 @@ const (
 	NetworkNamespace          = "network-namespace"
 	CNIMode                   = "cni-mode"
 	HostNSEnterExec           = "host-nsenter-exec"
+	Backend                   = "backend"
 )
 
 // Environment variables that deliberately have no equivalent command-line flags.
@@ -152,6 +153,15 @@ const (
 	IP6TABLESRESTORE = "ip6tables-restore"
 	IP6TABLESSAVE    = "ip6tables-save"
 	NSENTER          = "nsenter"
+	NFT              = "nft"
+)
+
+// Backends used to apply the rules
+const (
+	// BackendAuto selects iptables if its binaries are available, nftables otherwise.
+	BackendAuto     = "auto"
+	BackendIptables = "iptables"
+	BackendNftables = "nftables"
 )
 
 // Constants for syscall